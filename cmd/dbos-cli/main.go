@@ -1,15 +1,19 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/filesystem"
 	"github.com/brainwavecollective/stone-os/pkg/schema"
+	"github.com/brainwavecollective/stone-os/pkg/server"
 	"github.com/brainwavecollective/stone-os/pkg/shell"
 	"github.com/brainwavecollective/stone-os/internal/util"
 )
@@ -17,8 +21,11 @@ import (
 var (
 	// Command line flags
 	dbType      = flag.String("db", "sqlite", "Database type (sqlite, postgres, inmemory)")
-	dbPath      = flag.String("path", "", "Database path or connection string")
+	dbPath      = flag.String("path", "", "Database path, or connection string for postgres")
 	interactive = flag.Bool("i", true, "Run in interactive mode")
+	idScheme    = flag.String("ids", "time", "Resource ID scheme (time, sequence)")
+	store       = flag.String("store", "table", "File content store (table, inline, dir:<path>)")
+	serveAddr   = flag.String("serve", "", "Serve the filesystem over gRPC on this loopback address instead of running the shell")
 	version     = flag.Bool("version", false, "Show version information")
 )
 
@@ -34,8 +41,9 @@ func main() {
 		return
 	}
 
-	// Set default database path if not specified
-	if *dbPath == "" {
+	// Set default database path if not specified. A postgres connection
+	// string has no default and names no local directory.
+	if *dbPath == "" && *dbType != "postgres" {
 		homeDir, err := util.GetHomeDirectory()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error getting home directory: %v\n", err)
@@ -45,15 +53,25 @@ func main() {
 	}
 
 	// Ensure data directory exists
-	dataDir := filepath.Dir(*dbPath)
-	if err := util.CreateDirectory(dataDir); err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating data directory: %v\n", err)
-		os.Exit(1)
+	if *dbType != "postgres" {
+		dataDir := filepath.Dir(*dbPath)
+		if err := util.CreateDirectory(dataDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating data directory: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	// Initialize database connection
 	fmt.Println("Connecting to database...")
-	db, err := database.Connect(*dbType, *dbPath)
+	config := database.DefaultConfig()
+	scheme, err := database.ParseIDScheme(*idScheme)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	config.IDScheme = scheme
+
+	db, err := database.ConnectWithConfig(*dbType, *dbPath, config)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error connecting to database: %v\n", err)
 		fmt.Fprintf(os.Stderr, "Starting with in-memory database for demo purposes...\n")
@@ -72,22 +90,65 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Setup signal handling for graceful shutdown
+	// Warn about missing recommended indexes
+	missing, err := schema.AnalyzeIndexes(db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not analyze indexes: %v\n", err)
+	}
+	for _, idx := range missing {
+		fmt.Fprintf(os.Stderr, "Warning: missing recommended index %s on %s(%s) for %s\n",
+			idx.Name, idx.Table, strings.Join(idx.Columns, ", "), idx.Reason)
+	}
+
+	if *serveAddr != "" {
+		serve(db, *serveAddr)
+		return
+	}
+
+	var sh *shell.Shell
+	if *interactive {
+		sh = shell.NewShell(db)
+		err := sh.LoadSettings()
+		if err == nil {
+			err = sh.SetContentStore(*store)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Setup signal handling for graceful shutdown. Closing the connection
+	// rolls back open transactions, so they are reported rather than lost
+	// silently. The interactive shell handles SIGINT itself, interrupting
+	// the running command, and is sent SIGTERM so that its command loop
+	// stops and the transaction is rolled back below, after Run returns,
+	// rather than while a command is using it.
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-sigChan
-		fmt.Println("\nShutting down DBOS...")
-		db.Close()
-		os.Exit(0)
-	}()
+	if sh != nil {
+		signal.Notify(sigChan, syscall.SIGTERM)
+		sh.SetTerminate(sigChan)
+	} else {
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigChan
+			fmt.Println("\nShutting down DBOS...")
+			if n := db.GetActiveTransactionCount(); n > 0 {
+				fmt.Fprintf(os.Stderr, "Warning: rolling back %d active transaction(s)\n", n)
+			}
+			db.Close()
+			os.Exit(0)
+		}()
+	}
 
 	// Execute commands from arguments if not in interactive mode
 	if !*interactive && len(flag.Args()) > 0 {
-		cmd := flag.Args()[0]
-		args := flag.Args()[1:]
-		if err := executeCommand(db, cmd, args); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if err := executeCommand(db, flag.Args()); err != nil {
+			// A false test only sets the exit status, as in sh
+			if !errors.Is(err, shell.ErrTestFalse) {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			db.Close()
 			os.Exit(1)
 		}
 		return
@@ -97,12 +158,76 @@ func main() {
 	if *interactive {
 		fmt.Printf("DBOS CLI v%s - Database Operating System\n", AppVersion)
 		fmt.Println("Type 'help' for available commands")
-		shell := shell.NewShell(db)
-		shell.Run()
+		sh.Run()
+		if err := sh.Shutdown(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		// Commands piped in as a script exit with the status of the last
+		if status := sh.ExitStatus(); status != 0 {
+			db.Close()
+			os.Exit(status)
+		}
+	}
+}
+
+// serve runs the gRPC server until it fails or the process is stopped,
+// rolling back the transactions clients left open on the way out
+func serve(db *database.Connection, addr string) {
+	fm := filesystem.NewFileManager(db)
+	err := fm.LoadCompression()
+	var name, dir string
+	if err == nil {
+		name, dir, err = filesystem.ParseContentStore(*store)
+	}
+	if err == nil {
+		err = fm.SetContentStore(name, dir)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	user := os.Getenv("USER")
+	if user == "" {
+		user = "system"
+	}
+	srv := server.New(db, fm, user)
+	l, err := server.Listen(addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		fmt.Println("\nShutting down DBOS...")
+		if n := srv.RollbackAll(); n > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: rolled back %d open transaction(s)\n", n)
+		}
+		db.Close()
+		os.Exit(0)
+	}()
+
+	fmt.Printf("Serving gRPC on %s\n", l.Addr())
+	if err := srv.Serve(l); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 }
 
-func executeCommand(db *database.Connection, cmd string, args []string) error {
-	// TODO: Implement command execution logic
-	return fmt.Errorf("Command execution not implemented yet")
+// executeCommand runs one shell command given as words on the command line,
+// without prompting for confirmation
+func executeCommand(db *database.Connection, words []string) error {
+	sh := shell.NewShell(db)
+	sh.SetInteractive(false)
+	if err := sh.LoadSettings(); err != nil {
+		return err
+	}
+	if err := sh.SetContentStore(*store); err != nil {
+		return err
+	}
+	defer sh.Shutdown()
+	return sh.Execute(words)
 }
\ No newline at end of file
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiffEqual(t *testing.T) {
+	diff, err := UnifiedDiff("a", "b", []byte("same\n"), []byte("same\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != "" {
+		t.Errorf("diff of equal texts = %q", diff)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\n"
+	b := "one\ntwo\nthree\nfour\nFIVE\nsix\nseven\neight\nnine\n"
+
+	diff, err := UnifiedDiff("main:/f", "dev:/f", []byte(a), []byte(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `--- main:/f
++++ dev:/f
+@@ -2,7 +2,8 @@
+ two
+ three
+ four
+-five
++FIVE
+ six
+ seven
+ eight
++nine
+`
+	if diff != want {
+		t.Errorf("diff =\n%s\nwant\n%s", diff, want)
+	}
+}
+
+func TestUnifiedDiffSeparateHunks(t *testing.T) {
+	var a, b []string
+	for i := 1; i <= 20; i++ {
+		a = append(a, fmt.Sprint(i))
+		b = append(b, fmt.Sprint(i))
+	}
+	b[1] = "two"
+	b[17] = "eighteen"
+
+	diff, err := UnifiedDiff("a", "b", []byte(strings.Join(a, "\n")+"\n"), []byte(strings.Join(b, "\n")+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(diff, "@@ -"); got != 2 {
+		t.Errorf("diff has %d hunks, want 2:\n%s", got, diff)
+	}
+	if !strings.Contains(diff, "@@ -1,5 +1,5 @@") || !strings.Contains(diff, "@@ -15,6 +15,6 @@") {
+		t.Errorf("unexpected hunk headers:\n%s", diff)
+	}
+}
+
+func TestUnifiedDiffFromEmpty(t *testing.T) {
+	diff, err := UnifiedDiff("a", "b", nil, []byte("new\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "--- a\n+++ b\n@@ -0,0 +1 @@\n+new\n"; diff != want {
+		t.Errorf("diff = %q, want %q", diff, want)
+	}
+}
+
+func TestUnifiedDiffTooLarge(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&a, "a%d\n", i)
+		fmt.Fprintf(&b, "b%d\n", i)
+	}
+	if _, err := UnifiedDiff("a", "b", []byte(a.String()), []byte(b.String())); err == nil {
+		t.Error("diffing texts past MaxDiffCells succeeded")
+	}
+}
@@ -0,0 +1,137 @@
+package util
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Conflict markers written around the sides of a three-way merge conflict
+const (
+	ConflictStart = "<<<<<<<"
+	ConflictBase  = "|||||||"
+	ConflictSep   = "======="
+	ConflictEnd   = ">>>>>>>"
+)
+
+// Merge3 merges the changes made to base in ours and in theirs, line by
+// line. Lines changed on only one side take that side's version. Where both
+// sides changed the same lines differently, the result holds all three
+// versions between diff3-style markers labelled with the given names. It
+// returns the merged text and the number of conflicts.
+func Merge3(base, ours, theirs []byte, baseName, oursName, theirsName string) ([]byte, int, error) {
+	baseLines := splitLines(string(base))
+	ourLines := splitLines(string(ours))
+	theirLines := splitLines(string(theirs))
+
+	toOurs, err := matchLines(baseLines, ourLines)
+	if err != nil {
+		return nil, 0, err
+	}
+	toTheirs, err := matchLines(baseLines, theirLines)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var out strings.Builder
+	writeLines := func(lines []string) {
+		for _, line := range lines {
+			out.WriteString(line)
+			out.WriteByte('\n')
+		}
+	}
+
+	conflicts := 0
+	o, a, b := 0, 0, 0
+	for o < len(baseLines) || a < len(ourLines) || b < len(theirLines) {
+		// A stable run is base lines kept in place on both sides
+		run := 0
+		for o+run < len(baseLines) && toOurs[o+run] == a+run && toTheirs[o+run] == b+run {
+			run++
+		}
+		if run > 0 {
+			writeLines(baseLines[o : o+run])
+			o, a, b = o+run, a+run, b+run
+			continue
+		}
+
+		// Otherwise the changes run up to the next base line both sides kept
+		next := o
+		for next < len(baseLines) && (toOurs[next] < 0 || toTheirs[next] < 0) {
+			next++
+		}
+		endA, endB := len(ourLines), len(theirLines)
+		if next < len(baseLines) {
+			endA, endB = toOurs[next], toTheirs[next]
+		}
+
+		baseChunk, ourChunk, theirChunk := baseLines[o:next], ourLines[a:endA], theirLines[b:endB]
+		switch {
+		case equalLines(ourChunk, baseChunk):
+			writeLines(theirChunk)
+		case equalLines(theirChunk, baseChunk), equalLines(ourChunk, theirChunk):
+			writeLines(ourChunk)
+		default:
+			conflicts++
+			out.WriteString(ConflictStart + " " + oursName + "\n")
+			writeLines(ourChunk)
+			out.WriteString(ConflictBase + " " + baseName + "\n")
+			writeLines(baseChunk)
+			out.WriteString(ConflictSep + "\n")
+			writeLines(theirChunk)
+			out.WriteString(ConflictEnd + " " + theirsName + "\n")
+		}
+		o, a, b = next, endA, endB
+	}
+
+	return []byte(out.String()), conflicts, nil
+}
+
+// HasConflictMarkers reports whether content still holds a line starting a
+// merge conflict
+func HasConflictMarkers(content []byte) bool {
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte(ConflictStart+" ")) || string(line) == ConflictStart {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLines maps each line of a to the index of the line of b it is kept
+// as, or -1 when it is removed
+func matchLines(a, b []string) ([]int, error) {
+	ops, err := diffLines(a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]int, len(a))
+	i, j := 0, 0
+	for _, op := range ops {
+		switch op.kind {
+		case ' ':
+			matches[i] = j
+			i++
+			j++
+		case '-':
+			matches[i] = -1
+			i++
+		default:
+			j++
+		}
+	}
+	return matches, nil
+}
+
+// equalLines reports whether two runs of lines are the same
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package util
+
+import "testing"
+
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\n"
+	tests := []struct {
+		name         string
+		ours, theirs string
+		want         string
+		conflicts    int
+	}{
+		{"unchanged", base, base, base, 0},
+		{"ours only", "a\nB\nc\nd\n", base, "a\nB\nc\nd\n", 0},
+		{"theirs only", base, "a\nb\nc\nD\n", "a\nb\nc\nD\n", 0},
+		{"both apart", "A\nb\nc\nd\n", "a\nb\nc\nD\n", "A\nb\nc\nD\n", 0},
+		{"both the same", "a\nX\nc\nd\n", "a\nX\nc\nd\n", "a\nX\nc\nd\n", 0},
+		{"insert and delete", "a\nb\nnew\nc\nd\n", "a\nb\nc\n", "a\nb\nnew\nc\n", 0},
+		{
+			"conflict", "a\nours\nc\nd\n", "a\ntheirs\nc\nd\n",
+			"a\n<<<<<<< main\nours\n||||||| base\nb\n=======\ntheirs\n>>>>>>> dev\nc\nd\n", 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts, err := Merge3([]byte(base), []byte(tt.ours), []byte(tt.theirs), "base", "main", "dev")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want || conflicts != tt.conflicts {
+				t.Errorf("merge = %q with %d conflicts, want %q with %d", got, conflicts, tt.want, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestHasConflictMarkers(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"plain\ntext\n", false},
+		{"a\n<<<<<<< main\nx\n=======\ny\n>>>>>>> dev\n", true},
+		{"<<<<<<<\n", true},
+		{"<<<<<<<<< not a marker\n", false},
+		{"quoted <<<<<<< main\n", false},
+	}
+	for _, tt := range tests {
+		if got := HasConflictMarkers([]byte(tt.content)); got != tt.want {
+			t.Errorf("HasConflictMarkers(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
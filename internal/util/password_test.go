@@ -0,0 +1,66 @@
+package util
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// TestPBKDF2Vectors checks the key derivation against the PBKDF2-HMAC-SHA256
+// test vectors of RFC 7914, section 11
+func TestPBKDF2Vectors(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		want           string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2SHA256([]byte(tt.password), []byte(tt.salt), tt.iterations, 64))
+		if got != tt.want {
+			t.Errorf("pbkdf2(%q, %q, %d) = %s, want %s", tt.password, tt.salt, tt.iterations, got, tt.want)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$600000$") {
+		t.Errorf("hash = %q", hash)
+	}
+	if !VerifyPassword(hash, "secret") {
+		t.Error("the password does not match its own hash")
+	}
+	if VerifyPassword(hash, "Secret") {
+		t.Error("a different password matches")
+	}
+
+	again, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == hash {
+		t.Error("two hashes of the same password share a salt")
+	}
+}
+
+func TestVerifyPasswordRejectsMalformedHashes(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"secret",
+		"md5$1$c2FsdA$a2V5",
+		"pbkdf2-sha256$0$c2FsdA$a2V5",
+		"pbkdf2-sha256$x$c2FsdA$a2V5",
+		"pbkdf2-sha256$1$!!$a2V5",
+		"pbkdf2-sha256$1$c2FsdA$",
+	} {
+		if VerifyPassword(hash, "secret") {
+			t.Errorf("VerifyPassword(%q) = true", hash)
+		}
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	pathpkg "path"
 	"path/filepath"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // CreateDirectory creates a directory if it doesn't exist
@@ -32,6 +34,37 @@ func FormatByteSize(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
+// binarySampleSize is how much of the content IsBinary inspects
+const binarySampleSize = 8000
+
+// IsBinary reports whether content looks like binary data rather than text:
+// it contains a NUL byte, or more than a tenth of it is not valid UTF-8.
+// Only the first 8000 bytes are inspected.
+func IsBinary(content []byte) bool {
+	sample := content
+	if len(sample) > binarySampleSize {
+		sample = sample[:binarySampleSize]
+	}
+
+	invalid := 0
+	for i := 0; i < len(sample); {
+		if sample[i] == 0 {
+			return true
+		}
+		r, size := utf8.DecodeRune(sample[i:])
+		if r == utf8.RuneError && size == 1 {
+			// A multi-byte character cut off by the sample is not an error
+			if len(sample) < len(content) && !utf8.FullRune(sample[i:]) {
+				break
+			}
+			invalid++
+		}
+		i += size
+	}
+
+	return invalid*10 > len(sample)
+}
+
 // FormatTimestamp formats a timestamp
 func FormatTimestamp(t time.Time) string {
 	return t.Format("2006-01-02 15:04:05")
@@ -43,13 +76,14 @@ func CalculateChecksum(data []byte) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// SplitPath splits a path into its components
-func SplitPath(path string) []string {
+// SplitPath splits a DBOS path into its components. DBOS paths are always
+// separated by "/", whatever the host OS.
+func SplitPath(p string) []string {
 	// Normalize path
-	path = filepath.Clean(path)
+	p = pathpkg.Clean(p)
 	
 	// Split path
-	components := strings.Split(path, string(os.PathSeparator))
+	components := strings.Split(p, "/")
 	
 	// Filter out empty components
 	var result []string
@@ -62,19 +96,20 @@ func SplitPath(path string) []string {
 	return result
 }
 
-// JoinPath joins path components
+// JoinPath joins DBOS path components
 func JoinPath(components ...string) string {
-	return filepath.Join(components...)
+	return pathpkg.Join(components...)
 }
 
-// IsAbsolutePath checks if a path is absolute
-func IsAbsolutePath(path string) bool {
-	return filepath.IsAbs(path)
+// IsAbsolutePath checks if a DBOS path is absolute
+func IsAbsolutePath(p string) bool {
+	return pathpkg.IsAbs(p)
 }
 
-// GetRelativePath gets a path relative to a base path
-func GetRelativePath(basePath, path string) (string, error) {
-	return filepath.Rel(basePath, path)
+// GetRelativePath gets a DBOS path relative to a base path
+func GetRelativePath(basePath, p string) (string, error) {
+	rel, err := filepath.Rel(filepath.FromSlash(basePath), filepath.FromSlash(p))
+	return filepath.ToSlash(rel), err
 }
 
 // ParseTimeSpec parses a time specification string
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    bool
+	}{
+		{"empty", nil, false},
+		{"text", []byte("hello\nworld\n"), false},
+		{"utf-8", []byte("héllo wörld ✓\n"), false},
+		{"nul", []byte("abc\x00def"), true},
+		{"invalid utf-8", bytes.Repeat([]byte{0xff, 'a', 'b', 'c'}, 10), true},
+		{"some invalid utf-8", append(bytes.Repeat([]byte("a"), 100), 0xff), false},
+		{"nul past the sample", append(bytes.Repeat([]byte("a"), binarySampleSize), 0), false},
+		{
+			"character cut by the sample",
+			append(bytes.Repeat([]byte("a"), binarySampleSize-1), []byte("é")...),
+			false,
+		},
+	}
+	for _, tt := range tests {
+		if got := IsBinary(tt.content); got != tt.want {
+			t.Errorf("%s: IsBinary = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/", nil},
+		{"/a/b", []string{"a", "b"}},
+		{"/a//b/", []string{"a", "b"}},
+		{"/a/./b", []string{"a", "b"}},
+		{"/a/x/../b", []string{"a", "b"}},
+		{"/../a", []string{"a"}},
+	}
+	for _, tt := range tests {
+		if got := SplitPath(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{5 << 20, "5.0 MB"},
+		{3 << 30, "3.0 GB"},
+	}
+	for _, tt := range tests {
+		if got := FormatByteSize(tt.bytes); got != tt.want {
+			t.Errorf("FormatByteSize(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
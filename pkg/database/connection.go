@@ -1,9 +1,14 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/lib/pq"           // PostgreSQL driver
@@ -13,10 +18,23 @@ import (
 // Connection represents a database connection
 type Connection struct {
 	db           *sql.DB
+	// writeDB is where writing transactions begin. For a SQLite file it
+	// is a second pool whose transactions take the write lock when they
+	// begin; otherwise it is db.
+	writeDB      *sql.DB
 	dbType       string
+	path         string
 	connectionID string
-	mu           sync.Mutex
-	txs          map[string]*Transaction
+	idScheme     IDScheme
+	dialect      Dialect
+	// schemaVersion is the schema version the program expects, set by
+	// schema.Initialize; 0 until then
+	schemaVersion int
+	// logTransactions is set once the transactions table can record
+	// ended transactions
+	logTransactions atomic.Bool
+	mu              sync.Mutex
+	txs             map[string]*Transaction
 }
 
 // ConnectionConfig holds database connection configuration
@@ -24,6 +42,7 @@ type ConnectionConfig struct {
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
+	IDScheme        IDScheme // How Transaction.NextID generates IDs
 }
 
 // DefaultConfig returns a default connection configuration
@@ -32,10 +51,14 @@ func DefaultConfig() ConnectionConfig {
 		MaxOpenConns:    10,
 		MaxIdleConns:    5,
 		ConnMaxLifetime: time.Hour,
+		IDScheme:        IDSchemeTime,
 	}
 }
 
-// Connect establishes a connection to the specified database
+// Connect establishes a connection to the specified database. An
+// "inmemory" database is served by a single connection, so while one of its
+// transactions is open, statements run on the Connection itself wait for it
+// to end; run them in the transaction instead.
 func Connect(dbType, connString string) (*Connection, error) {
 	return ConnectWithConfig(dbType, connString, DefaultConfig())
 }
@@ -43,77 +66,251 @@ func Connect(dbType, connString string) (*Connection, error) {
 // ConnectWithConfig establishes a connection with custom configuration
 func ConnectWithConfig(dbType, connString string, config ConnectionConfig) (*Connection, error) {
 	var driverName string
+	var path string
+	var writeConnString string
+	
+	dialect, err := DialectFor(dbType)
+	if err != nil {
+		return nil, err
+	}
 	
 	switch dbType {
 	case "sqlite":
 		driverName = "sqlite3"
+		path = connString
+		if writeConnString, err = sqliteDSN(connString, true); err != nil {
+			return nil, err
+		}
+		if connString, err = sqliteDSN(connString, false); err != nil {
+			return nil, err
+		}
 	case "postgres":
+		// Check the connection string here, since the driver only reports
+		// a malformed one as a failure to ping
+		if _, err := ParsePostgresDSN(connString); err != nil {
+			return nil, err
+		}
 		driverName = "postgres"
 	case "inmemory":
 		driverName = "sqlite3"
-		connString = ":memory:"
-	default:
-		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+		connString = inMemoryDSN(connString)
 	}
 	
-	db, err := sql.Open(driverName, connString)
+	db, err := openPool(driverName, connString, config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
+	}
+	writeDB := db
+	if writeConnString != "" {
+		if writeDB, err = openPool(driverName, writeConnString, config); err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
 	
-	// Configure connection pool
-	db.SetMaxOpenConns(config.MaxOpenConns)
-	db.SetMaxIdleConns(config.MaxIdleConns)
-	db.SetConnMaxLifetime(config.ConnMaxLifetime)
-	
-	// Test connection
-	if err := db.Ping(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	if dbType == "inmemory" {
+		// Connections to a shared-cache database lock each other out table
+		// by table with SQLITE_LOCKED, which does not wait on the busy
+		// timeout, so the pool keeps to one connection. Statements outside
+		// a transaction wait for it to end, as Connect documents. More
+		// connections would need sqlite3_unlock_notify, which the driver
+		// only builds with the sqlite_unlock_notify tag.
+		db.SetMaxOpenConns(1)
+		// The database only lives while a connection to it is open, so the
+		// pool must never retire its last one
+		db.SetConnMaxLifetime(0)
+		db.SetConnMaxIdleTime(0)
+		if config.MaxIdleConns < 1 {
+			db.SetMaxIdleConns(1)
+		}
 	}
 	
 	conn := &Connection{
 		db:           db,
+		writeDB:      writeDB,
 		dbType:       dbType,
+		path:         path,
 		connectionID: GenerateUUID(),
+		idScheme:     config.IDScheme,
+		dialect:      dialect,
 		txs:          make(map[string]*Transaction),
 	}
 	
+	// Test connection
+	if err := ping(db); err != nil {
+		conn.closePools()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	
 	return conn, nil
 }
 
+// Querier runs queries on a Connection or inside a Transaction
+type Querier interface {
+	ExecuteQuery(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// openPool opens a connection pool configured by config
+func openPool(driverName, connString string, config ConnectionConfig) (*sql.DB, error) {
+	db, err := sql.Open(driverName, connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	return db, nil
+}
+
+// SQLiteBusyTimeout is how long a SQLite connection waits on another
+// session's lock before failing with "database is locked"
+const SQLiteBusyTimeout = 5 * time.Second
+
+// sqliteDSN adds the settings that let several sessions share one SQLite
+// file: a busy timeout and write-ahead logging, so readers do not block the
+// writer. With immediate set, transactions take the write lock when they
+// begin instead of failing when they first write; only writing transactions
+// use such a DSN, so that a reader never holds the write lock. Settings
+// already present in the connection string are kept.
+func sqliteDSN(connString string, immediate bool) (string, error) {
+	params := []string{
+		"_busy_timeout", strconv.FormatInt(SQLiteBusyTimeout.Milliseconds(), 10),
+		"_journal_mode", "WAL",
+	}
+	if immediate {
+		params = append(params, "_txlock", "immediate")
+	}
+
+	sep := "?"
+	var query url.Values
+	if i := strings.Index(connString, "?"); i >= 0 {
+		var err error
+		if query, err = url.ParseQuery(connString[i+1:]); err != nil {
+			return "", fmt.Errorf("invalid sqlite connection string: %w", err)
+		}
+		sep = "&"
+	}
+	for i := 0; i < len(params); i += 2 {
+		if query.Has(params[i]) {
+			continue
+		}
+		connString += sep + params[i] + "=" + params[i+1]
+		sep = "&"
+	}
+	return connString, nil
+}
+
+// inMemoryDSN returns the DSN of an in-memory SQLite database shared through
+// SQLite's shared cache by every connection in the process that uses the same
+// name. An empty name or ":memory:" selects the process's default database.
+func inMemoryDSN(name string) string {
+	if name == "" || name == ":memory:" {
+		return "file::memory:?cache=shared"
+	}
+	return "file:" + url.PathEscape(name) + "?mode=memory&cache=shared"
+}
+
 // Close closes the database connection
 func (c *Connection) Close() error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	txs := make([]*Transaction, 0, len(c.txs))
+	for _, tx := range c.txs {
+		txs = append(txs, tx)
+	}
+	c.mu.Unlock()
 	
-	// Roll back any active transactions
-	for id, tx := range c.txs {
+	// Roll back any active transactions; each one forgets itself
+	for _, tx := range txs {
 		if tx.IsActive() {
 			tx.Rollback()
 		}
-		delete(c.txs, id)
 	}
 	
-	return c.db.Close()
+	return c.closePools()
 }
 
-// Begin starts a new transaction
+// forget removes an ended transaction from the connection's active ones
+func (c *Connection) forget(t *Transaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.txs, t.id)
+}
+
+// closePools closes the connection's pools
+func (c *Connection) closePools() error {
+	err := c.db.Close()
+	if c.writeDB != c.db {
+		if werr := c.writeDB.Close(); err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// Begin starts a new transaction with the driver's default isolation
 func (c *Connection) Begin() (*Transaction, error) {
+	return c.BeginTx(context.Background(), nil)
+}
+
+// BeginTx starts a new transaction with the given isolation level and
+// read-only mode. SQLite transactions are always serializable, so every
+// isolation level maps to that, and read-only transactions are enforced with
+// the query_only pragma for their duration. Only transactions that may write
+// take the SQLite write lock when they begin.
+func (c *Connection) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Transaction, error) {
+	pool := c.writeDB
+	if opts != nil && opts.ReadOnly {
+		pool = c.db
+	}
+	return c.beginTx(ctx, opts, pool)
+}
+
+// BeginDeferred starts a transaction like BeginTx, except that on SQLite it
+// takes the write lock at its first write rather than when it begins. It is
+// meant for transactions held open while a user types, which would otherwise
+// keep every other session from writing until they end.
+func (c *Connection) BeginDeferred(ctx context.Context, opts *sql.TxOptions) (*Transaction, error) {
+	return c.beginTx(ctx, opts, c.db)
+}
+
+// beginTx starts a transaction on pool
+func (c *Connection) beginTx(ctx context.Context, opts *sql.TxOptions, pool *sql.DB) (*Transaction, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
-	tx, err := c.db.Begin()
+	if opts == nil {
+		opts = &sql.TxOptions{}
+	}
+	switch opts.Isolation {
+	case sql.LevelDefault, sql.LevelReadCommitted, sql.LevelRepeatableRead, sql.LevelSerializable:
+	default:
+		return nil, fmt.Errorf("unsupported isolation level: %s", opts.Isolation)
+	}
+	
+	driverOpts := opts
+	if c.IsSQLite() {
+		driverOpts = nil
+	}
+	tx, err := pool.BeginTx(ctx, driverOpts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	
+	if opts.ReadOnly && c.IsSQLite() {
+		if _, err := tx.Exec("PRAGMA query_only = ON"); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to make transaction read-only: %w", err)
+		}
+	}
+	
 	transaction := &Transaction{
 		tx:         tx,
 		id:         GenerateUUID(),
 		startTime:  time.Now(),
 		status:     TransactionStatusActive,
 		connection: c,
+		isolation:  opts.Isolation,
+		readOnly:   opts.ReadOnly,
 	}
 	
 	c.txs[transaction.id] = transaction
@@ -123,12 +320,12 @@ func (c *Connection) Begin() (*Transaction, error) {
 
 // ExecuteQuery executes a SQL query without a transaction
 func (c *Connection) ExecuteQuery(query string, args ...interface{}) (*sql.Rows, error) {
-	return c.db.Query(query, args...)
+	return c.db.Query(c.dialect.Rebind(query), args...)
 }
 
 // ExecuteStatement executes a SQL statement without a transaction
 func (c *Connection) ExecuteStatement(statement string, args ...interface{}) (sql.Result, error) {
-	return c.db.Exec(statement, args...)
+	return c.db.Exec(c.dialect.Rebind(statement), args...)
 }
 
 // GetDatabaseType returns the type of database being used
@@ -136,6 +333,33 @@ func (c *Connection) GetDatabaseType() string {
 	return c.dbType
 }
 
+// GetPath returns the database file path, or "" when the database is not a
+// local file
+func (c *Connection) GetPath() string {
+	return c.path
+}
+
+// Dialect returns the SQL dialect of the connection
+func (c *Connection) Dialect() Dialect {
+	return c.dialect
+}
+
+// IsSQLite reports whether the connection uses the SQLite driver
+func (c *Connection) IsSQLite() bool {
+	return c.dbType == "sqlite" || c.dbType == "inmemory"
+}
+
+// ExplainStatement wraps a query in the dialect's query plan statement
+func (c *Connection) ExplainStatement(query string) string {
+	return c.dialect.Explain(query)
+}
+
+// JSONExtract returns an expression extracting the dotted field path from a
+// JSON text column. The path must already be validated by the caller.
+func (c *Connection) JSONExtract(column, path string) string {
+	return c.dialect.JSONExtract(column, path)
+}
+
 // GetConnectionID returns the unique ID for this connection
 func (c *Connection) GetConnectionID() string {
 	return c.connectionID
@@ -160,5 +384,10 @@ func (c *Connection) GetActiveTransactionCount() int {
 func GenerateUUID() string {
 	// Simple UUID generation for now
 	// In a real implementation, use a proper UUID library
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+	return fmt.Sprintf("%d", nextTimeID())
+}
+
+// GetIDScheme returns how the connection's transactions generate IDs
+func (c *Connection) GetIDScheme() IDScheme {
+	return c.idScheme
 }
\ No newline at end of file
@@ -0,0 +1,253 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLiteDSN(t *testing.T) {
+	tests := []struct {
+		connString string
+		immediate  bool
+		want       string
+	}{
+		{"dbos.db", false, "dbos.db?_busy_timeout=5000&_journal_mode=WAL"},
+		{"dbos.db", true, "dbos.db?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"},
+		{"dbos.db?_busy_timeout=100", false, "dbos.db?_busy_timeout=100&_journal_mode=WAL"},
+		{"dbos.db?_txlock=deferred", true, "dbos.db?_txlock=deferred&_busy_timeout=5000&_journal_mode=WAL"},
+		// Setting names in the file name do not count as settings
+		{"_busy_timeout=1.db", false, "_busy_timeout=1.db?_busy_timeout=5000&_journal_mode=WAL"},
+	}
+	for _, tt := range tests {
+		got, err := sqliteDSN(tt.connString, tt.immediate)
+		if err != nil {
+			t.Errorf("sqliteDSN(%q): %v", tt.connString, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("sqliteDSN(%q, %v) = %q, want %q", tt.connString, tt.immediate, got, tt.want)
+		}
+	}
+
+	if _, err := sqliteDSN("dbos.db?a=%zz", false); err == nil {
+		t.Error("sqliteDSN accepted a malformed query string")
+	}
+}
+
+// connectFile connects to a SQLite file that waits only briefly on locks,
+// so that a test sees a held lock as an error rather than a delay
+func connectFile(t *testing.T, path string) *Connection {
+	t.Helper()
+	db, err := Connect("sqlite", path+"?_busy_timeout=50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestTransactionWriteLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dbos.db")
+	db := connectFile(t, path)
+	if _, err := db.ExecuteStatement(`CREATE TABLE t (n INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	other := connectFile(t, path)
+
+	begin := map[string]func() (*Transaction, error){
+		"read-only": func() (*Transaction, error) {
+			return db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+		},
+		"deferred": func() (*Transaction, error) {
+			return db.BeginDeferred(context.Background(), nil)
+		},
+	}
+	for name, fn := range begin {
+		t.Run(name, func(t *testing.T) {
+			tx, err := fn()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			rows, err := tx.ExecuteQuery(`SELECT COUNT(*) FROM t`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows.Close()
+
+			if _, err := other.ExecuteStatement(`INSERT INTO t VALUES (1)`); err != nil {
+				t.Errorf("write beside a %s transaction: %v", name, err)
+			}
+		})
+	}
+
+	t.Run("write", func(t *testing.T) {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+
+		_, err = other.ExecuteStatement(`INSERT INTO t VALUES (1)`)
+		if err == nil || !strings.Contains(err.Error(), "locked") {
+			t.Errorf("write beside a write transaction: got %v, want database is locked", err)
+		}
+	})
+}
+
+// TestInMemoryConcurrentTransactions runs transactions from several
+// goroutines at once. Connections sharing a cache would refuse each other's
+// writes with SQLITE_LOCKED instead of waiting.
+func TestInMemoryConcurrentTransactions(t *testing.T) {
+	db, err := Connect("inmemory", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecuteStatement(`CREATE TABLE t (n INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, writes = 8, 20
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		go func() {
+			for j := 0; j < writes; j++ {
+				if err := countAndInsert(db); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}()
+	}
+	for i := 0; i < writers; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// countAndInsert reads table t and then writes it in one transaction
+func countAndInsert(db *Connection) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.ExecuteQuery(`SELECT COUNT(*) FROM t`)
+	if err != nil {
+		return err
+	}
+	var n int
+	for rows.Next() {
+		if err := rows.Scan(&n); err != nil {
+			rows.Close()
+			return err
+		}
+	}
+	rows.Close()
+	// Give the other goroutines time to read before this one writes
+	time.Sleep(time.Millisecond)
+
+	if _, err := tx.Execute(`INSERT INTO t VALUES (?)`, n); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// connectMemory connects to an in-memory database named after the test and
+// runs the given statements on it
+func connectMemory(t *testing.T, statements ...string) *Connection {
+	t.Helper()
+	db, err := Connect("inmemory", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range statements {
+		if _, err := db.ExecuteStatement(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return db
+}
+
+// TestInMemorySharedAcrossConnect checks that connections to the same
+// in-memory name see one database, and other names do not
+func TestInMemorySharedAcrossConnect(t *testing.T) {
+	first := connectMemory(t, `CREATE TABLE t (n INTEGER)`, `INSERT INTO t VALUES (1)`)
+
+	second, err := Connect("inmemory", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	var n int
+	if err := second.db.QueryRow(`SELECT n FROM t`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("second connection read %d, %v; want 1", n, err)
+	}
+
+	other, err := Connect("inmemory", t.Name()+"-other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := other.ExecuteQuery(`SELECT n FROM t`); err == nil {
+		t.Error("a connection with another name sees the table")
+	}
+
+	// The database outlives the first connection while another is open
+	first.Close()
+	if err := second.db.QueryRow(`SELECT n FROM t`).Scan(&n); err != nil {
+		t.Errorf("read after the first connection closed: %v", err)
+	}
+}
+
+func TestBeginTxIsolation(t *testing.T) {
+	db := connectMemory(t)
+
+	for _, level := range []sql.IsolationLevel{sql.LevelDefault, sql.LevelReadCommitted, sql.LevelRepeatableRead, sql.LevelSerializable} {
+		tx, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: level})
+		if err != nil {
+			t.Errorf("begin %s: %v", level, err)
+			continue
+		}
+		if tx.GetIsolation() != level {
+			t.Errorf("GetIsolation = %s, want %s", tx.GetIsolation(), level)
+		}
+		tx.Rollback()
+	}
+
+	if _, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSnapshot}); err == nil {
+		t.Error("begin with snapshot isolation succeeded")
+	}
+}
+
+func TestReadOnlyTransaction(t *testing.T) {
+	db := connectMemory(t, `CREATE TABLE t (n INTEGER)`)
+
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tx.IsReadOnly() {
+		t.Error("IsReadOnly = false")
+	}
+	if _, err := tx.Execute(`INSERT INTO t VALUES (1)`); err == nil {
+		t.Error("write in a read-only transaction succeeded")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pragma must not outlive the transaction on its pooled connection
+	if _, err := db.ExecuteStatement(`INSERT INTO t VALUES (1)`); err != nil {
+		t.Errorf("write after a read-only transaction: %v", err)
+	}
+}
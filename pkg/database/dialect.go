@@ -0,0 +1,183 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect generates the SQL that differs between database backends
+type Dialect interface {
+	// Name returns the dialect name, "sqlite" or "postgres"
+	Name() string
+
+	// Placeholder returns the placeholder for the nth (1-based) bound argument
+	Placeholder(n int) string
+
+	// Rebind rewrites the ? placeholders of a query into the dialect's own,
+	// leaving quoted text and comments alone
+	Rebind(query string) string
+
+	// TransactionLock returns a statement that takes the lock identified by
+	// its one integer argument until the end of the transaction, or "" when
+	// the database's write lock already serializes writing transactions
+	TransactionLock() string
+
+	// ByteOrder returns expr collated so that it compares and sorts byte by
+	// byte, as the path ranges that match a subtree require
+	ByteOrder(expr string) string
+
+	// IndexExistsQuery returns a query that yields a row when the named
+	// index exists
+	IndexExistsQuery(name string) string
+
+	// ColumnExistsQuery returns a query that yields a row when the named
+	// table has the named column
+	ColumnExistsQuery(table, column string) string
+
+	// JSONExtract returns an expression extracting the dotted field path
+	// from a JSON text column. The path must already be validated.
+	JSONExtract(column, path string) string
+
+	// Explain wraps a query in the statement that shows its query plan
+	Explain(query string) string
+}
+
+// DialectFor returns the dialect for a database type
+func DialectFor(dbType string) (Dialect, error) {
+	switch dbType {
+	case "sqlite", "inmemory":
+		return SQLiteDialect{}, nil
+	case "postgres":
+		return PostgresDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
+}
+
+// quoteLiteral quotes s as a SQL string literal
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// rebind replaces each ? of a query outside string literals, quoted
+// identifiers and comments with placeholder(n), numbering them from 1
+func rebind(query string, placeholder func(n int) string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			// A doubled quote inside the text ends it and starts it again
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+1])
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 3
+		case c == '?':
+			n++
+			b.WriteString(placeholder(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// SQLiteDialect is the dialect of SQLite databases
+type SQLiteDialect struct{}
+
+// Name returns "sqlite"
+func (SQLiteDialect) Name() string { return "sqlite" }
+
+// Placeholder returns ?n
+func (SQLiteDialect) Placeholder(n int) string { return fmt.Sprintf("?%d", n) }
+
+// Rebind returns the query unchanged: SQLite understands ?
+func (SQLiteDialect) Rebind(query string) string { return query }
+
+// TransactionLock returns "": the first write of a transaction takes
+// SQLite's database-wide write lock
+func (SQLiteDialect) TransactionLock() string { return "" }
+
+// ByteOrder returns expr unchanged: SQLite compares text with the BINARY
+// collation unless told otherwise
+func (SQLiteDialect) ByteOrder(expr string) string { return expr }
+
+// IndexExistsQuery looks the index up in sqlite_master
+func (SQLiteDialect) IndexExistsQuery(name string) string {
+	return "SELECT name FROM sqlite_master WHERE type = 'index' AND name = " + quoteLiteral(name)
+}
+
+// ColumnExistsQuery looks the column up in the table's pragma_table_info
+func (SQLiteDialect) ColumnExistsQuery(table, column string) string {
+	return fmt.Sprintf("SELECT name FROM pragma_table_info(%s) WHERE name = %s", quoteLiteral(table), quoteLiteral(column))
+}
+
+// JSONExtract uses json_extract, which returns native values: booleans as
+// 0/1 and numbers as numbers
+func (SQLiteDialect) JSONExtract(column, path string) string {
+	return fmt.Sprintf("json_extract(%s, '$.%s')", column, path)
+}
+
+// Explain uses EXPLAIN QUERY PLAN
+func (SQLiteDialect) Explain(query string) string { return "EXPLAIN QUERY PLAN " + query }
+
+// PostgresDialect is the dialect of PostgreSQL databases
+type PostgresDialect struct{}
+
+// Name returns "postgres"
+func (PostgresDialect) Name() string { return "postgres" }
+
+// Placeholder returns $n
+func (PostgresDialect) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+// Rebind numbers the ? placeholders as $1, $2, ...
+func (d PostgresDialect) Rebind(query string) string { return rebind(query, d.Placeholder) }
+
+// TransactionLock takes a transaction-scoped advisory lock
+func (PostgresDialect) TransactionLock() string { return "SELECT pg_advisory_xact_lock(?)" }
+
+// ByteOrder collates expr as "C": the database's default collation may
+// order text by locale, which puts /a-b between /a/ and /a0
+func (PostgresDialect) ByteOrder(expr string) string { return expr + ` COLLATE "C"` }
+
+// IndexExistsQuery looks the index up in pg_indexes
+func (PostgresDialect) IndexExistsQuery(name string) string {
+	return "SELECT indexname FROM pg_indexes WHERE indexname = " + quoteLiteral(name)
+}
+
+// ColumnExistsQuery looks the column up in information_schema
+func (PostgresDialect) ColumnExistsQuery(table, column string) string {
+	return fmt.Sprintf("SELECT column_name FROM information_schema.columns WHERE table_name = %s AND column_name = %s", quoteLiteral(table), quoteLiteral(column))
+}
+
+// JSONExtract casts the column to jsonb and extracts the path as text
+func (PostgresDialect) JSONExtract(column, path string) string {
+	return fmt.Sprintf("(%s::jsonb #>> '{%s}')", column, strings.ReplaceAll(path, ".", ","))
+}
+
+// Explain uses EXPLAIN
+func (PostgresDialect) Explain(query string) string { return "EXPLAIN " + query }
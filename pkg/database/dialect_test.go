@@ -0,0 +1,141 @@
+package database
+
+import "testing"
+
+func TestPostgresRebind(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`SELECT 1`, `SELECT 1`},
+		{`SELECT * FROM t WHERE a = ? AND b = ?`, `SELECT * FROM t WHERE a = $1 AND b = $2`},
+		{`SELECT * FROM t WHERE a = $1`, `SELECT * FROM t WHERE a = $1`},
+		{`SELECT '?', ? FROM t`, `SELECT '?', $1 FROM t`},
+		{`SELECT 'it''s ?', ?`, `SELECT 'it''s ?', $1`},
+		{`SELECT "odd?column" FROM t WHERE a = ?`, `SELECT "odd?column" FROM t WHERE a = $1`},
+		{"SELECT ? -- why?\nFROM t WHERE a = ?", "SELECT $1 -- why?\nFROM t WHERE a = $2"},
+		{`SELECT /* ? */ ?`, `SELECT /* ? */ $1`},
+		{`SELECT 'unterminated ?`, `SELECT 'unterminated ?`},
+	}
+	for _, tt := range tests {
+		if got := (PostgresDialect{}).Rebind(tt.query); got != tt.want {
+			t.Errorf("Rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSQLiteRebind(t *testing.T) {
+	query := `SELECT * FROM t WHERE a = ? AND b = '?'`
+	if got := (SQLiteDialect{}).Rebind(query); got != query {
+		t.Errorf("Rebind(%q) = %q, want it unchanged", query, got)
+	}
+}
+
+// TestRebindOnConnection runs a ? query through a connection, which rebinds
+// it for its dialect
+func TestRebindOnConnection(t *testing.T) {
+	db, err := Connect("inmemory", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.ExecuteQuery(`SELECT ? || '?'`, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	for rows.Next() {
+		if err := rows.Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows.Close()
+	if got != "a?" {
+		t.Errorf("got %q, want %q", got, "a?")
+	}
+}
+
+func TestByteOrder(t *testing.T) {
+	if got := (SQLiteDialect{}).ByteOrder("path"); got != "path" {
+		t.Errorf("SQLite ByteOrder = %q, want %q", got, "path")
+	}
+	if got, want := (PostgresDialect{}).ByteOrder("path"), `path COLLATE "C"`; got != want {
+		t.Errorf("Postgres ByteOrder = %q, want %q", got, want)
+	}
+}
+
+func TestDialectFor(t *testing.T) {
+	for dbType, want := range map[string]string{"sqlite": "sqlite", "inmemory": "sqlite", "postgres": "postgres"} {
+		d, err := DialectFor(dbType)
+		if err != nil || d.Name() != want {
+			t.Errorf("DialectFor(%q) = %v, %v; want %s", dbType, d, err, want)
+		}
+	}
+	if _, err := DialectFor("mysql"); err == nil {
+		t.Error("DialectFor(mysql) succeeded")
+	}
+}
+
+func TestPostgresStatements(t *testing.T) {
+	d := PostgresDialect{}
+	tests := []struct{ got, want string }{
+		{d.JSONExtract("metadata", "owner"), `(metadata::jsonb #>> '{owner}')`},
+		{d.JSONExtract("metadata", "a.b"), `(metadata::jsonb #>> '{a,b}')`},
+		{d.Explain("SELECT 1"), "EXPLAIN SELECT 1"},
+		{d.IndexExistsQuery("it's"), `SELECT indexname FROM pg_indexes WHERE indexname = 'it''s'`},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+// TestSQLiteStatements runs the SQLite dialect's generated statements
+func TestSQLiteStatements(t *testing.T) {
+	db := connectMemory(t,
+		`CREATE TABLE r (metadata TEXT)`,
+		`CREATE INDEX idx_r ON r (metadata)`,
+		`INSERT INTO r VALUES ('{"owner":"alice","a":{"b":2}}')`,
+	)
+	d := db.Dialect()
+
+	queryOne := func(query string) (string, bool) {
+		t.Helper()
+		rows, err := db.ExecuteQuery(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		defer rows.Close()
+		if !rows.Next() {
+			return "", false
+		}
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		return v, true
+	}
+
+	if v, _ := queryOne(`SELECT ` + db.JSONExtract("metadata", "owner") + ` FROM r`); v != "alice" {
+		t.Errorf("owner = %q", v)
+	}
+	if v, _ := queryOne(`SELECT ` + db.JSONExtract("metadata", "a.b") + ` FROM r`); v != "2" {
+		t.Errorf("a.b = %q", v)
+	}
+	if _, ok := queryOne(d.IndexExistsQuery("idx_r")); !ok {
+		t.Error("IndexExistsQuery did not find idx_r")
+	}
+	if _, ok := queryOne(d.IndexExistsQuery("idx_missing")); ok {
+		t.Error("IndexExistsQuery found a missing index")
+	}
+	if _, ok := queryOne(d.ColumnExistsQuery("r", "metadata")); !ok {
+		t.Error("ColumnExistsQuery did not find r.metadata")
+	}
+
+	plan, err := db.Query(db.ExplainStatement(`SELECT * FROM r`), QueryOptions{})
+	if err != nil || plan.Count == 0 {
+		t.Errorf("explain returned %v, %v", plan, err)
+	}
+}
@@ -0,0 +1,30 @@
+package database
+
+import "errors"
+
+// Errors returned, wrapped with context, by the database and filesystem
+// packages and the shell, so that callers can test for them with errors.Is
+// instead of matching messages. Their text reads as part of the wrapping
+// message, as in "file not found: /a".
+var (
+	// ErrNotFound means the resource, directory or other object does not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrAlreadyExists means the path or object to be created is taken
+	ErrAlreadyExists = errors.New("already exists")
+
+	// ErrNoTransaction means a write was attempted without a transaction
+	ErrNoTransaction = errors.New("transaction required")
+
+	// ErrTxNotActive means the transaction has already been committed or
+	// rolled back
+	ErrTxNotActive = errors.New("transaction is not active")
+
+	// ErrWriteConflict means a transaction kept conflicting with concurrent
+	// writers and was given up
+	ErrWriteConflict = errors.New("write conflict")
+
+	// ErrPermissionDenied means the user may not change the resource, for
+	// example because another user holds its lock
+	ErrPermissionDenied = errors.New("permission denied")
+)
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SetSchemaVersion records the schema version the program expects, which
+// HealthCheck compares with the database's
+func (c *Connection) SetSchemaVersion(version int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schemaVersion = version
+}
+
+// HealthCheck reports whether the database is ready to serve: it can be
+// reached, its schema is at the version the program expects, and the root
+// directory exists. It is meant for readiness and liveness probes.
+func (c *Connection) HealthCheck(ctx context.Context) error {
+	if err := c.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database unreachable: %w", err)
+	}
+
+	c.mu.Lock()
+	expected := c.schemaVersion
+	c.mu.Unlock()
+	if expected == 0 {
+		return fmt.Errorf("schema not initialized on this connection")
+	}
+
+	var version sql.NullInt64
+	if err := c.db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_version`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if !version.Valid {
+		return fmt.Errorf("schema version not recorded")
+	}
+	if int(version.Int64) != expected {
+		return fmt.Errorf("schema version is %d, expected %d", version.Int64, expected)
+	}
+
+	var count int
+	err := c.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM resources
+		WHERE path = '/' AND type = 'directory' AND valid_to IS NULL
+	`).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to look up root directory: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("root directory not found")
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHealthCheck(t *testing.T) {
+	db := connectMemory(t,
+		`CREATE TABLE schema_version (version INTEGER PRIMARY KEY)`,
+		`CREATE TABLE resources (path TEXT, type TEXT, valid_to TIMESTAMP)`,
+	)
+	ctx := context.Background()
+
+	check := func(want string) {
+		t.Helper()
+		err := db.HealthCheck(ctx)
+		switch {
+		case want == "" && err != nil:
+			t.Errorf("HealthCheck: %v", err)
+		case want != "" && (err == nil || !strings.Contains(err.Error(), want)):
+			t.Errorf("HealthCheck = %v, want %q", err, want)
+		}
+	}
+
+	check("schema not initialized")
+	db.SetSchemaVersion(2)
+	check("schema version not recorded")
+	db.ExecuteStatement(`INSERT INTO schema_version VALUES (1)`)
+	check("schema version is 1, expected 2")
+	db.ExecuteStatement(`INSERT INTO schema_version VALUES (2)`)
+	check("root directory not found")
+	db.ExecuteStatement(`INSERT INTO resources VALUES ('/', 'directory', NULL)`)
+	check("")
+
+	db.Close()
+	check("database unreachable")
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// IDScheme selects how resource IDs are generated
+type IDScheme string
+
+const (
+	// IDSchemeTime derives IDs from the clock. It needs no database access
+	// but IDs are only ordered within one process.
+	IDSchemeTime IDScheme = "time"
+
+	// IDSchemeSequence draws IDs from counters in the sequences table, giving
+	// IDs that sort in creation order across all sessions
+	IDSchemeSequence IDScheme = "sequence"
+)
+
+// ParseIDScheme converts a scheme name to an IDScheme
+func ParseIDScheme(name string) (IDScheme, error) {
+	switch IDScheme(name) {
+	case IDSchemeTime, IDSchemeSequence:
+		return IDScheme(name), nil
+	default:
+		return "", fmt.Errorf("unknown id scheme: %s", name)
+	}
+}
+
+// lastTimeID holds the value of the most recent time-based ID
+var lastTimeID int64
+
+// nextTimeID returns the current time in nanoseconds, bumped when needed so
+// that every call in the process returns a larger value than the last
+func nextTimeID() int64 {
+	for {
+		last := atomic.LoadInt64(&lastTimeID)
+		next := time.Now().UnixNano()
+		if next <= last {
+			next = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&lastTimeID, last, next) {
+			return next
+		}
+	}
+}
+
+// idSequence is the counter in the sequences table that IDs are drawn from
+const idSequence = "ids"
+
+// NextID returns a new ID of the form <prefix>-<n> under the connection's
+// ID scheme. With IDSchemeSequence, n is the next value of a counter shared
+// by all prefixes, zero-padded so that IDs sort as strings; the counter is
+// advanced in this transaction.
+func (t *Transaction) NextID(prefix string) (string, error) {
+	if t.connection == nil || t.connection.idScheme != IDSchemeSequence {
+		return fmt.Sprintf("%s-%d", prefix, nextTimeID()), nil
+	}
+
+	result, err := t.Execute(`UPDATE sequences SET value = value + 1 WHERE name = $1`, idSequence)
+	if err != nil {
+		return "", fmt.Errorf("failed to advance id sequence: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		if _, err := t.Execute(`INSERT INTO sequences (name, value) VALUES ($1, 1)`, idSequence); err != nil {
+			return "", fmt.Errorf("failed to create id sequence: %w", err)
+		}
+	}
+
+	rows, err := t.ExecuteQuery(`SELECT value FROM sequences WHERE name = $1`, idSequence)
+	if err != nil {
+		return "", fmt.Errorf("failed to read id sequence: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", fmt.Errorf("id sequence not found")
+	}
+	var value int64
+	if err := rows.Scan(&value); err != nil {
+		return "", fmt.Errorf("failed to scan id sequence: %w", err)
+	}
+	return fmt.Sprintf("%s-%012d", prefix, value), nil
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIDScheme(t *testing.T) {
+	for _, name := range []string{"time", "sequence"} {
+		if scheme, err := ParseIDScheme(name); err != nil || string(scheme) != name {
+			t.Errorf("ParseIDScheme(%q) = %q, %v", name, scheme, err)
+		}
+	}
+	if _, err := ParseIDScheme("uuid"); err == nil {
+		t.Error("ParseIDScheme(uuid) succeeded")
+	}
+}
+
+func TestTimeIDsIncrease(t *testing.T) {
+	last := nextTimeID()
+	for i := 0; i < 1000; i++ {
+		next := nextTimeID()
+		if next <= last {
+			t.Fatalf("nextTimeID returned %d after %d", next, last)
+		}
+		last = next
+	}
+}
+
+func TestSequenceIDs(t *testing.T) {
+	config := DefaultConfig()
+	config.IDScheme = IDSchemeSequence
+	db, err := ConnectWithConfig("inmemory", t.Name(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecuteStatement(`CREATE TABLE sequences (name TEXT PRIMARY KEY, value INTEGER NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+
+	nextID := func(prefix string, commit bool) string {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := tx.NextID(prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	if id := nextID("file", true); id != "file-000000000001" {
+		t.Errorf("first ID = %q", id)
+	}
+	if id := nextID("dir", true); id != "dir-000000000002" {
+		t.Errorf("second ID = %q, want the counter shared across prefixes", id)
+	}
+	nextID("file", false)
+	if id := nextID("file", true); id != "file-000000000003" {
+		t.Errorf("ID after a rollback = %q, want the rolled back value reused", id)
+	}
+}
+
+func TestTimeIDsWithoutConnection(t *testing.T) {
+	id, err := (&Transaction{}).NextID("file")
+	if err != nil || !strings.HasPrefix(id, "file-") {
+		t.Errorf("NextID = %q, %v", id, err)
+	}
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// RowIterator streams query results row by row instead of buffering them
+type RowIterator struct {
+	rows    *sql.Rows
+	columns []string
+	count   int
+}
+
+// QueryStream executes a query with the given options and returns an iterator
+// over its rows. The caller must Close the iterator.
+func (c *Connection) QueryStream(query string, options QueryOptions, args ...interface{}) (*RowIterator, error) {
+	query = applyQueryOptions(query, options)
+
+	rows, err := c.ExecuteQuery(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query execution failed: %w", err)
+	}
+
+	return newRowIterator(rows)
+}
+
+// QueryStream executes a query within the transaction and returns an iterator
+// over its rows. The caller must Close the iterator.
+func (tx *Transaction) QueryStream(query string, options QueryOptions, args ...interface{}) (*RowIterator, error) {
+	query = applyQueryOptions(query, options)
+
+	rows, err := tx.ExecuteQuery(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query execution failed within transaction: %w", err)
+	}
+
+	return newRowIterator(rows)
+}
+
+// newRowIterator wraps sql.Rows in a RowIterator
+func newRowIterator(rows *sql.Rows) (*RowIterator, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+
+	return &RowIterator{rows: rows, columns: columns}, nil
+}
+
+// Columns returns the column names of the result
+func (it *RowIterator) Columns() []string {
+	return it.columns
+}
+
+// Next advances to the next row, returning false when there are no more rows
+// or an error occurred (check Err)
+func (it *RowIterator) Next() bool {
+	if !it.rows.Next() {
+		return false
+	}
+	it.count++
+	return true
+}
+
+// Scan copies the columns of the current row into dest
+func (it *RowIterator) Scan(dest ...interface{}) error {
+	return it.rows.Scan(dest...)
+}
+
+// Values returns the raw values of the current row
+func (it *RowIterator) Values() ([]interface{}, error) {
+	values := make([]interface{}, len(it.columns))
+	valuePointers := make([]interface{}, len(it.columns))
+	for i := range values {
+		valuePointers[i] = &values[i]
+	}
+
+	if err := it.rows.Scan(valuePointers...); err != nil {
+		return nil, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	return values, nil
+}
+
+// Count returns the number of rows read so far
+func (it *RowIterator) Count() int {
+	return it.count
+}
+
+// Err returns the error, if any, encountered during iteration
+func (it *RowIterator) Err() error {
+	if err := it.rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+	return nil
+}
+
+// Close releases the underlying rows
+func (it *RowIterator) Close() error {
+	return it.rows.Close()
+}
@@ -0,0 +1,74 @@
+package database
+
+import "testing"
+
+func TestQueryStream(t *testing.T) {
+	db := connectMemory(t,
+		`CREATE TABLE t (n INTEGER, name TEXT)`,
+		`INSERT INTO t VALUES (1, 'one'), (2, 'two'), (3, NULL)`,
+	)
+
+	it, err := db.QueryStream(`SELECT n, name FROM t WHERE 1 = 1`, QueryOptions{OrderBy: "n", OrderDirection: "DESC", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	if cols := it.Columns(); len(cols) != 2 || cols[0] != "n" || cols[1] != "name" {
+		t.Errorf("Columns = %v", cols)
+	}
+	var rows [][]interface{}
+	for it.Next() {
+		values, err := it.Values()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, values)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if it.Count() != 2 || len(rows) != 2 {
+		t.Fatalf("read %d rows, Count = %d; want 2", len(rows), it.Count())
+	}
+	if rows[0][0] != int64(3) || rows[0][1] != nil || rows[1][0] != int64(2) {
+		t.Errorf("rows = %v", rows)
+	}
+}
+
+func TestQueryStreamInTransaction(t *testing.T) {
+	db := connectMemory(t, `CREATE TABLE t (n INTEGER)`)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Execute(`INSERT INTO t VALUES (5)`); err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := tx.QueryStream(`SELECT n FROM t`, QueryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	var n int
+	if !it.Next() {
+		t.Fatalf("no rows: %v", it.Err())
+	}
+	if err := it.Scan(&n); err != nil || n != 5 {
+		t.Errorf("Scan = %d, %v; want the uncommitted row", n, err)
+	}
+	if it.Next() {
+		t.Error("more than one row")
+	}
+}
+
+func TestQueryStreamError(t *testing.T) {
+	db := connectMemory(t)
+	if _, err := db.QueryStream(`SELECT * FROM missing`, QueryOptions{}); err == nil {
+		t.Error("query of a missing table succeeded")
+	}
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// orderColumnPattern matches the column names accepted for ordering, which
+// are written into the query text
+var orderColumnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// temporalConditions lists the accepted TemporalCondition values
+var temporalConditions = map[string]bool{"": true, "AS OF": true, "FROM": true, "BETWEEN": true}
+
+// Validate reports the first invalid field or combination of fields
+func (o QueryOptions) Validate() error {
+	if o.Limit < 0 {
+		return fmt.Errorf("invalid limit: %d", o.Limit)
+	}
+	if o.Offset < 0 {
+		return fmt.Errorf("invalid offset: %d", o.Offset)
+	}
+	if o.Offset > 0 && o.Limit == 0 {
+		return fmt.Errorf("offset requires a limit")
+	}
+	if o.OrderBy != "" && !orderColumnPattern.MatchString(o.OrderBy) {
+		return fmt.Errorf("invalid order column: %s", o.OrderBy)
+	}
+	switch o.OrderDirection {
+	case "", "ASC", "DESC":
+	default:
+		return fmt.Errorf("invalid order direction: %s", o.OrderDirection)
+	}
+	if o.OrderDirection != "" && o.OrderBy == "" {
+		return fmt.Errorf("order direction requires an order column")
+	}
+	if !temporalConditions[o.TemporalCondition] {
+		return fmt.Errorf("invalid temporal condition: %s", o.TemporalCondition)
+	}
+	if o.IncludeDeleted && o.PointInTime != nil {
+		return fmt.Errorf("include deleted cannot be combined with a point in time")
+	}
+	return nil
+}
+
+// QueryOptionsBuilder builds QueryOptions step by step and validates them.
+// The first invalid input is kept and returned by Build.
+type QueryOptionsBuilder struct {
+	options QueryOptions
+	err     error
+}
+
+// NewQueryOptions starts building options that match current resources on
+// every branch, unordered and unpaged
+func NewQueryOptions() *QueryOptionsBuilder {
+	return &QueryOptionsBuilder{}
+}
+
+// WithBranch restricts the query to a branch
+func (b *QueryOptionsBuilder) WithBranch(branchID string) *QueryOptionsBuilder {
+	if branchID == "" && b.err == nil {
+		b.err = fmt.Errorf("branch required")
+	}
+	b.options.BranchID = branchID
+	return b
+}
+
+// AsOf reads the state as of a point in time
+func (b *QueryOptionsBuilder) AsOf(t time.Time) *QueryOptionsBuilder {
+	if t.IsZero() && b.err == nil {
+		b.err = fmt.Errorf("point in time required")
+	}
+	b.options.PointInTime = &t
+	b.options.TemporalCondition = "AS OF"
+	return b
+}
+
+// IncludeDeleted includes versions that are no longer current
+func (b *QueryOptionsBuilder) IncludeDeleted() *QueryOptionsBuilder {
+	b.options.IncludeDeleted = true
+	return b
+}
+
+// OrderBy orders the results by a column in the direction "ASC" or "DESC"
+// (case-insensitive)
+func (b *QueryOptionsBuilder) OrderBy(column, direction string) *QueryOptionsBuilder {
+	b.options.OrderBy = column
+	b.options.OrderDirection = strings.ToUpper(direction)
+	return b
+}
+
+// Page limits the results to limit rows starting at offset
+func (b *QueryOptionsBuilder) Page(limit, offset int) *QueryOptionsBuilder {
+	if limit <= 0 && b.err == nil {
+		b.err = fmt.Errorf("invalid limit: %d", limit)
+	}
+	b.options.Limit = limit
+	b.options.Offset = offset
+	return b
+}
+
+// Build returns the options, or the first error found while building or
+// validating them
+func (b *QueryOptionsBuilder) Build() (QueryOptions, error) {
+	if b.err != nil {
+		return QueryOptions{}, b.err
+	}
+	if err := b.options.Validate(); err != nil {
+		return QueryOptions{}, err
+	}
+	return b.options, nil
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryOptionsValidate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		options QueryOptions
+		valid   bool
+	}{
+		{"zero", QueryOptions{}, true},
+		{"default", DefaultQueryOptions(), true},
+		{"paged", QueryOptions{Limit: 10, Offset: 20}, true},
+		{"ordered", QueryOptions{OrderBy: "r.path", OrderDirection: "DESC"}, true},
+		{"as of", QueryOptions{PointInTime: &now, TemporalCondition: "AS OF"}, true},
+		{"negative limit", QueryOptions{Limit: -1}, false},
+		{"negative offset", QueryOptions{Limit: 1, Offset: -1}, false},
+		{"offset without limit", QueryOptions{Offset: 5}, false},
+		{"injected order", QueryOptions{OrderBy: "path; DROP TABLE resources"}, false},
+		{"bad direction", QueryOptions{OrderBy: "path", OrderDirection: "SIDEWAYS"}, false},
+		{"direction without column", QueryOptions{OrderDirection: "ASC"}, false},
+		{"bad temporal condition", QueryOptions{TemporalCondition: "SINCE"}, false},
+		{"deleted at a point in time", QueryOptions{IncludeDeleted: true, PointInTime: &now}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.options.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestQueryOptionsBuilder(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	options, err := NewQueryOptions().WithBranch("dev").AsOf(at).OrderBy("name", "desc").Page(10, 5).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.BranchID != "dev" || !options.PointInTime.Equal(at) || options.TemporalCondition != "AS OF" ||
+		options.OrderBy != "name" || options.OrderDirection != "DESC" || options.Limit != 10 || options.Offset != 5 {
+		t.Errorf("options = %+v", options)
+	}
+
+	failures := map[string]*QueryOptionsBuilder{
+		"empty branch":       NewQueryOptions().WithBranch(""),
+		"zero time":          NewQueryOptions().AsOf(time.Time{}),
+		"zero limit":         NewQueryOptions().Page(0, 0),
+		"bad direction":      NewQueryOptions().OrderBy("name", "up"),
+		"deleted as of time": NewQueryOptions().IncludeDeleted().AsOf(at),
+	}
+	for name, b := range failures {
+		if _, err := b.Build(); err == nil {
+			t.Errorf("%s: Build succeeded", name)
+		}
+	}
+}
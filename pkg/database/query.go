@@ -58,6 +58,7 @@ func (tx *Transaction) Query(query string, options QueryOptions, args ...interfa
 	// Apply options to query
 	query = applyQueryOptions(query, options)
 	
+	tx.statements++
 	rows, err := tx.tx.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query execution failed within transaction: %w", err)
@@ -167,38 +168,29 @@ func applyQueryOptions(query string, options QueryOptions) string {
 
 // processQueryRows processes SQL rows into a QueryResult
 func processQueryRows(rows *sql.Rows) (*QueryResult, error) {
-	columns, err := rows.Columns()
+	it, err := newRowIterator(rows)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get columns: %w", err)
+		return nil, err
 	}
 	
 	result := &QueryResult{
-		Columns: columns,
+		Columns: it.Columns(),
 		Rows:    [][]interface{}{},
 		Count:   0,
 	}
 	
-	for rows.Next() {
-		// Create a slice of interface{} to hold the row values
-		values := make([]interface{}, len(columns))
-		valuePointers := make([]interface{}, len(columns))
-		
-		// Create pointers to each element in the values slice
-		for i := range values {
-			valuePointers[i] = &values[i]
-		}
-		
-		// Scan the row into the valuePointers
-		if err := rows.Scan(valuePointers...); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+	for it.Next() {
+		values, err := it.Values()
+		if err != nil {
+			return nil, err
 		}
 		
 		result.Rows = append(result.Rows, values)
 		result.Count++
 	}
 	
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+	if err := it.Err(); err != nil {
+		return nil, err
 	}
 	
 	return result, nil
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ColumnIndex returns the index of the named column
+func (r *QueryResult) ColumnIndex(name string) (int, error) {
+	for i, col := range r.Columns {
+		if col == name {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("column not found: %s", name)
+}
+
+// Value returns the raw value at the given row and column
+func (r *QueryResult) Value(row, col int) (interface{}, error) {
+	if row < 0 || row >= len(r.Rows) {
+		return nil, fmt.Errorf("row index out of range: %d", row)
+	}
+	if col < 0 || col >= len(r.Rows[row]) {
+		return nil, fmt.Errorf("column index out of range: %d", col)
+	}
+	return r.Rows[row][col], nil
+}
+
+// IsNull reports whether the value at the given row and column is NULL
+func (r *QueryResult) IsNull(row, col int) (bool, error) {
+	val, err := r.Value(row, col)
+	if err != nil {
+		return false, err
+	}
+	return val == nil, nil
+}
+
+// GetString returns the value at the given row and column as a string
+func (r *QueryResult) GetString(row, col int) (string, error) {
+	val, err := r.nonNullValue(row, col)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := val.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	default:
+		return "", r.typeMismatch(row, col, "string", val)
+	}
+}
+
+// GetInt64 returns the value at the given row and column as an int64
+func (r *QueryResult) GetInt64(row, col int) (int64, error) {
+	val, err := r.nonNullValue(row, col)
+	if err != nil {
+		return 0, err
+	}
+
+	switch v := val.(type) {
+	case int64:
+		return v, nil
+	case int32:
+		return int64(v), nil
+	case int:
+		return int64(v), nil
+	case []byte:
+		// Some drivers return numeric columns as text
+		n, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return 0, r.typeMismatch(row, col, "int64", val)
+		}
+		return n, nil
+	default:
+		return 0, r.typeMismatch(row, col, "int64", val)
+	}
+}
+
+// GetTime returns the value at the given row and column as a time.Time
+func (r *QueryResult) GetTime(row, col int) (time.Time, error) {
+	val, err := r.nonNullValue(row, col)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	t, ok := val.(time.Time)
+	if !ok {
+		return time.Time{}, r.typeMismatch(row, col, "time", val)
+	}
+	return t, nil
+}
+
+// GetBytes returns the value at the given row and column as a byte slice
+func (r *QueryResult) GetBytes(row, col int) ([]byte, error) {
+	val, err := r.nonNullValue(row, col)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := val.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, r.typeMismatch(row, col, "bytes", val)
+	}
+}
+
+// IsNullByName reports whether the value of the named column is NULL
+func (r *QueryResult) IsNullByName(row int, col string) (bool, error) {
+	idx, err := r.ColumnIndex(col)
+	if err != nil {
+		return false, err
+	}
+	return r.IsNull(row, idx)
+}
+
+// GetStringByName returns the value of the named column as a string
+func (r *QueryResult) GetStringByName(row int, col string) (string, error) {
+	idx, err := r.ColumnIndex(col)
+	if err != nil {
+		return "", err
+	}
+	return r.GetString(row, idx)
+}
+
+// GetInt64ByName returns the value of the named column as an int64
+func (r *QueryResult) GetInt64ByName(row int, col string) (int64, error) {
+	idx, err := r.ColumnIndex(col)
+	if err != nil {
+		return 0, err
+	}
+	return r.GetInt64(row, idx)
+}
+
+// GetTimeByName returns the value of the named column as a time.Time
+func (r *QueryResult) GetTimeByName(row int, col string) (time.Time, error) {
+	idx, err := r.ColumnIndex(col)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return r.GetTime(row, idx)
+}
+
+// GetBytesByName returns the value of the named column as a byte slice
+func (r *QueryResult) GetBytesByName(row int, col string) ([]byte, error) {
+	idx, err := r.ColumnIndex(col)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetBytes(row, idx)
+}
+
+// nonNullValue returns the value at the given position, failing on NULL
+func (r *QueryResult) nonNullValue(row, col int) (interface{}, error) {
+	val, err := r.Value(row, col)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, fmt.Errorf("value at row %d, column %s is NULL", row, r.columnName(col))
+	}
+	return val, nil
+}
+
+// typeMismatch builds the error returned when a value has an unexpected type
+func (r *QueryResult) typeMismatch(row, col int, want string, val interface{}) error {
+	return fmt.Errorf("value at row %d, column %s is %T, not %s", row, r.columnName(col), val, want)
+}
+
+// columnName returns the name of a column for error messages
+func (r *QueryResult) columnName(col int) string {
+	if col >= 0 && col < len(r.Columns) {
+		return r.Columns[col]
+	}
+	return strconv.Itoa(col)
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryResultByName(t *testing.T) {
+	result := &QueryResult{
+		Columns: []string{"id", "content", "size"},
+		Rows:    [][]interface{}{{"a", nil, int64(3)}},
+	}
+
+	if isNull, err := result.IsNullByName(0, "content"); err != nil || !isNull {
+		t.Errorf("IsNullByName(content) = %v, %v; want true", isNull, err)
+	}
+	if isNull, err := result.IsNullByName(0, "id"); err != nil || isNull {
+		t.Errorf("IsNullByName(id) = %v, %v; want false", isNull, err)
+	}
+	if _, err := result.IsNullByName(0, "missing"); err == nil {
+		t.Error("IsNullByName(missing) succeeded")
+	}
+	if size, err := result.GetInt64ByName(0, "size"); err != nil || size != 3 {
+		t.Errorf("GetInt64ByName(size) = %d, %v; want 3", size, err)
+	}
+	if _, err := result.GetBytesByName(0, "content"); err == nil {
+		t.Error("GetBytesByName on NULL succeeded")
+	}
+}
+
+func TestQueryResultTypedAccessors(t *testing.T) {
+	now := time.Now()
+	result := &QueryResult{
+		Columns: []string{"name", "raw", "n", "text_n", "at"},
+		Rows:    [][]interface{}{{"a", []byte("b"), int64(7), []byte("42"), now}},
+	}
+
+	if s, err := result.GetString(0, 0); err != nil || s != "a" {
+		t.Errorf("GetString(name) = %q, %v", s, err)
+	}
+	if s, err := result.GetString(0, 1); err != nil || s != "b" {
+		t.Errorf("GetString(raw) = %q, %v", s, err)
+	}
+	if n, err := result.GetInt64(0, 3); err != nil || n != 42 {
+		t.Errorf("GetInt64(text_n) = %d, %v", n, err)
+	}
+	if at, err := result.GetTimeByName(0, "at"); err != nil || !at.Equal(now) {
+		t.Errorf("GetTimeByName(at) = %v, %v", at, err)
+	}
+	if b, err := result.GetBytes(0, 0); err != nil || string(b) != "a" {
+		t.Errorf("GetBytes(name) = %q, %v", b, err)
+	}
+
+	if _, err := result.GetInt64(0, 0); err == nil {
+		t.Error("GetInt64 of a string succeeded")
+	}
+	if _, err := result.GetTime(0, 2); err == nil {
+		t.Error("GetTime of an integer succeeded")
+	}
+	if _, err := result.Value(1, 0); err == nil {
+		t.Error("Value past the last row succeeded")
+	}
+	if _, err := result.Value(0, 5); err == nil {
+		t.Error("Value past the last column succeeded")
+	}
+}
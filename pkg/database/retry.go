@@ -0,0 +1,90 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Retry settings for WithTransaction
+const (
+	DefaultTransactionAttempts = 5
+	transactionRetryBackoff    = 10 * time.Millisecond
+)
+
+// WithTransaction runs fn in a new transaction, committing when fn returns
+// nil and rolling back otherwise. If the transaction fails with a transient
+// busy or serialization error it is retried from the start, up to
+// DefaultTransactionAttempts times in all, so fn must be safe to run again.
+func (c *Connection) WithTransaction(fn func(tx *Transaction) error) error {
+	var err error
+	for attempt := 0; attempt < DefaultTransactionAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(transactionRetryBackoff << (attempt - 1))
+		}
+
+		err = c.runTransaction(fn)
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("transaction failed after %d attempts: %w: %w", DefaultTransactionAttempts, ErrWriteConflict, err)
+}
+
+// runTransaction makes a single attempt at running fn in a transaction
+func (c *Connection) runTransaction(fn func(tx *Transaction) error) error {
+	tx, err := c.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if tx.IsActive() {
+			tx.Rollback()
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ping checks that the database can be reached. The first connection to a
+// SQLite file switches it to write-ahead logging, and connections making
+// the switch at once can fail as busy without waiting on the busy timeout,
+// so a transient failure is retried as WithTransaction would retry it.
+func ping(db *sql.DB) error {
+	var err error
+	for attempt := 0; attempt < DefaultTransactionAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(transactionRetryBackoff << (attempt - 1))
+		}
+
+		err = db.Ping()
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// IsRetryable reports whether err is a transient failure that may succeed
+// if the transaction is run again: SQLite busy or locked errors, and
+// PostgreSQL serialization failures and deadlocks
+func IsRetryable(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+
+	return false
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("boom"), false},
+		{sqlite3.Error{Code: sqlite3.ErrBusy}, true},
+		{fmt.Errorf("insert: %w", sqlite3.Error{Code: sqlite3.ErrLocked}), true},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint}, false},
+		{&pq.Error{Code: "40001"}, true},
+		{&pq.Error{Code: "40P01"}, true},
+		{&pq.Error{Code: "23505"}, false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWithTransactionRetries(t *testing.T) {
+	db := connectMemory(t, `CREATE TABLE t (n INTEGER)`)
+
+	attempts := 0
+	err := db.WithTransaction(func(tx *Transaction) error {
+		attempts++
+		if _, err := tx.Execute(`INSERT INTO t VALUES (?)`, attempts); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return sqlite3.Error{Code: sqlite3.ErrBusy}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Errorf("ran %d times, want 3", attempts)
+	}
+	// Only the attempt that succeeded is committed
+	if n := countRows(t, db); n != 1 {
+		t.Errorf("%d rows committed, want 1", n)
+	}
+}
+
+func TestWithTransactionGivesUp(t *testing.T) {
+	db := connectMemory(t)
+
+	attempts := 0
+	err := db.WithTransaction(func(tx *Transaction) error {
+		attempts++
+		return sqlite3.Error{Code: sqlite3.ErrBusy}
+	})
+	if !errors.Is(err, ErrWriteConflict) {
+		t.Errorf("error = %v, want ErrWriteConflict", err)
+	}
+	if attempts != DefaultTransactionAttempts {
+		t.Errorf("ran %d times, want %d", attempts, DefaultTransactionAttempts)
+	}
+}
+
+func TestWithTransactionStopsOnOtherErrors(t *testing.T) {
+	db := connectMemory(t, `CREATE TABLE t (n INTEGER)`)
+
+	boom := errors.New("boom")
+	attempts := 0
+	err := db.WithTransaction(func(tx *Transaction) error {
+		attempts++
+		tx.Execute(`INSERT INTO t VALUES (1)`)
+		return boom
+	})
+	if !errors.Is(err, boom) || attempts != 1 {
+		t.Errorf("error = %v after %d attempts, want boom after 1", err, attempts)
+	}
+	if n := countRows(t, db); n != 0 {
+		t.Errorf("%d rows committed after an error, want 0", n)
+	}
+}
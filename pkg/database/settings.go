@@ -0,0 +1,40 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// Setting returns the value saved for a database-wide setting, and whether
+// one is saved
+func (c *Connection) Setting(name string) (string, bool, error) {
+	rows, err := c.ExecuteQuery(`SELECT value FROM settings WHERE name = $1`, name)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read setting %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", false, rows.Err()
+	}
+	var value string
+	if err := rows.Scan(&value); err != nil {
+		return "", false, fmt.Errorf("failed to scan setting %s: %w", name, err)
+	}
+	return value, true, nil
+}
+
+// SaveSetting saves the value of a database-wide setting in the transaction
+func (t *Transaction) SaveSetting(name, value string) error {
+	now := time.Now()
+	result, err := t.Execute(`UPDATE settings SET value = $1, updated_at = $2 WHERE name = $3`, value, now, name)
+	if err != nil {
+		return fmt.Errorf("failed to save setting %s: %w", name, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		if _, err := t.Execute(`INSERT INTO settings (name, value, updated_at) VALUES ($1, $2, $3)`, name, value, now); err != nil {
+			return fmt.Errorf("failed to save setting %s: %w", name, err)
+		}
+	}
+	return nil
+}
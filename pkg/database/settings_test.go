@@ -0,0 +1,24 @@
+package database
+
+import "testing"
+
+func TestSettings(t *testing.T) {
+	db := connectMemory(t, `CREATE TABLE settings (name TEXT PRIMARY KEY, value TEXT NOT NULL, updated_at TIMESTAMP NOT NULL)`)
+
+	if _, ok, err := db.Setting("compression"); err != nil || ok {
+		t.Errorf("unsaved setting: ok %v, %v", ok, err)
+	}
+
+	for _, value := range []string{"gzip", "none"} {
+		err := db.WithTransaction(func(tx *Transaction) error {
+			return tx.SaveSetting("compression", value)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok, err := db.Setting("compression")
+		if err != nil || !ok || got != value {
+			t.Errorf("Setting = %q, %v, %v; want %q", got, ok, err, value)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FormatValue returns a value as shown in a table: NULL for nil, byte
+// slices as text, and times in RFC 3339 with fractional seconds
+func FormatValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ColumnWidths returns the width of each column of the result when
+// rendered as a table: the widest of its name and its formatted values, in
+// characters
+func (r *QueryResult) ColumnWidths() []int {
+	widths := make([]int, len(r.Columns))
+	for i, col := range r.Columns {
+		widths[i] = utf8.RuneCountInString(col)
+	}
+	for _, row := range r.Rows {
+		for i, val := range row {
+			if i >= len(widths) {
+				break
+			}
+			if w := utf8.RuneCountInString(FormatValue(val)); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	return widths
+}
+
+// String renders the result as an aligned table, like psql: a header row,
+// a separator, then one line per row, with columns separated by " | ".
+// Columns holding only numbers and NULLs are right-aligned; the rest are
+// left-aligned. The row count is not included.
+func (r *QueryResult) String() string {
+	var b strings.Builder
+	table := NewTableWriter(&b, r.Columns, len(r.Rows))
+	for _, row := range r.Rows {
+		table.WriteRow(row)
+	}
+	table.Flush()
+	return b.String()
+}
+
+// TableWriter prints rows as an aligned table, like QueryResult.String,
+// while they are read. It holds back the first rows, up to its sample size,
+// and sizes and aligns the columns to fit them; once it has the sample, it
+// prints the table so far and every later row as it is written. A later
+// value wider than its column overflows it, since the lines before it are
+// already printed.
+type TableWriter struct {
+	w       io.Writer
+	columns []string
+	sample  int
+	pending [][]interface{}
+	widths  []int
+	numeric []bool
+	started bool
+}
+
+// NewTableWriter returns a TableWriter printing to w that sizes its columns
+// from the first sample rows
+func NewTableWriter(w io.Writer, columns []string, sample int) *TableWriter {
+	return &TableWriter{w: w, columns: columns, sample: sample}
+}
+
+// WriteRow adds a row to the table, printing it unless the sample is still
+// being collected
+func (t *TableWriter) WriteRow(row []interface{}) error {
+	if !t.started {
+		t.pending = append(t.pending, row)
+		if len(t.pending) < t.sample {
+			return nil
+		}
+		return t.start()
+	}
+	return t.writeLine(row)
+}
+
+// Flush prints the header and any rows held back for the sample. A table
+// without rows prints only its header.
+func (t *TableWriter) Flush() error {
+	if t.started {
+		return nil
+	}
+	return t.start()
+}
+
+// start sizes the columns from the rows held back, then prints the header
+// and those rows
+func (t *TableWriter) start() error {
+	sample := &QueryResult{Columns: t.columns, Rows: t.pending}
+	t.widths = sample.ColumnWidths()
+	t.numeric = make([]bool, len(t.columns))
+	for i := range t.columns {
+		t.numeric[i] = sample.isNumericColumn(i)
+	}
+	t.started = true
+
+	var b strings.Builder
+	for i, col := range t.columns {
+		writeCell(&b, i, col, t.widths[i], false)
+	}
+	b.WriteString("\n")
+	for i, width := range t.widths {
+		if i > 0 {
+			b.WriteString("-+-")
+		}
+		b.WriteString(strings.Repeat("-", width))
+	}
+	b.WriteString("\n")
+	if _, err := io.WriteString(t.w, b.String()); err != nil {
+		return err
+	}
+
+	for _, row := range t.pending {
+		if err := t.writeLine(row); err != nil {
+			return err
+		}
+	}
+	t.pending = nil
+	return nil
+}
+
+// writeLine prints one row
+func (t *TableWriter) writeLine(row []interface{}) error {
+	var b strings.Builder
+	for i := range t.columns {
+		var val interface{}
+		if i < len(row) {
+			val = row[i]
+		}
+		writeCell(&b, i, FormatValue(val), t.widths[i], t.numeric[i])
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(t.w, b.String())
+	return err
+}
+
+// writeCell writes one cell of a table line, padded to width
+func writeCell(b *strings.Builder, col int, text string, width int, alignRight bool) {
+	if col > 0 {
+		b.WriteString(" | ")
+	}
+	padding := ""
+	if n := width - utf8.RuneCountInString(text); n > 0 {
+		padding = strings.Repeat(" ", n)
+	}
+	if alignRight {
+		b.WriteString(padding)
+		b.WriteString(text)
+		return
+	}
+	b.WriteString(text)
+	b.WriteString(padding)
+}
+
+// isNumericColumn reports whether every non-NULL value in a column is a
+// number, and there is at least one
+func (r *QueryResult) isNumericColumn(col int) bool {
+	found := false
+	for _, row := range r.Rows {
+		if col >= len(row) {
+			continue
+		}
+		switch row[col].(type) {
+		case nil:
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			found = true
+		default:
+			return false
+		}
+	}
+	return found
+}
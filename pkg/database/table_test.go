@@ -0,0 +1,57 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQueryResultString(t *testing.T) {
+	result := &QueryResult{
+		Columns: []string{"name", "size"},
+		Rows: [][]interface{}{
+			{"a", int64(1)},
+			{"résumé.txt", int64(12345)},
+			{[]byte("notes"), nil},
+		},
+	}
+
+	want := "" +
+		"name       | size \n" +
+		"-----------+------\n" +
+		"a          |     1\n" +
+		"résumé.txt | 12345\n" +
+		"notes      |  NULL\n"
+	if got := result.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestQueryResultStringEmpty(t *testing.T) {
+	result := &QueryResult{Columns: []string{"path"}}
+	if got, want := result.String(), "path\n----\n"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+// TestTableWriterStreams checks that rows after the sample are printed as
+// they are written, in columns sized from the sample
+func TestTableWriterStreams(t *testing.T) {
+	var b strings.Builder
+	table := NewTableWriter(&b, []string{"path"}, 2)
+
+	table.WriteRow([]interface{}{"/a"})
+	if b.Len() != 0 {
+		t.Fatalf("printed before the sample was complete:\n%s", b.String())
+	}
+	table.WriteRow([]interface{}{"/abc"})
+	if got, want := b.String(), "path\n----\n/a  \n/abc\n"; got != want {
+		t.Fatalf("after the sample got %q, want %q", got, want)
+	}
+
+	table.WriteRow([]interface{}{"/b"})
+	table.WriteRow([]interface{}{"/longer"})
+	table.Flush()
+	if got, want := b.String(), "path\n----\n/a  \n/abc\n/b  \n/longer\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -26,30 +26,46 @@ type Transaction struct {
 	connection *Connection
 	branchID   string
 	userID     string
+	statements int
+	isolation  sql.IsolationLevel
+	readOnly   bool
 }
 
 // Execute executes a SQL statement within the transaction
 func (t *Transaction) Execute(statement string, args ...interface{}) (sql.Result, error) {
 	if t.status != TransactionStatusActive {
-		return nil, fmt.Errorf("transaction is not active (status: %s)", t.status)
+		return nil, fmt.Errorf("%w (status: %s)", ErrTxNotActive, t.status)
 	}
 	
-	return t.tx.Exec(statement, args...)
+	t.statements++
+	return t.tx.Exec(t.connection.dialect.Rebind(statement), args...)
 }
 
 // ExecuteQuery executes a SQL query within the transaction
 func (t *Transaction) ExecuteQuery(query string, args ...interface{}) (*sql.Rows, error) {
 	if t.status != TransactionStatusActive {
-		return nil, fmt.Errorf("transaction is not active (status: %s)", t.status)
+		return nil, fmt.Errorf("%w (status: %s)", ErrTxNotActive, t.status)
 	}
 	
-	return t.tx.Query(query, args...)
+	t.statements++
+	return t.tx.Query(t.connection.dialect.Rebind(query), args...)
 }
 
 // Commit commits the transaction
 func (t *Transaction) Commit() error {
 	if t.status != TransactionStatusActive {
-		return fmt.Errorf("transaction is not active (status: %s)", t.status)
+		return fmt.Errorf("%w (status: %s)", ErrTxNotActive, t.status)
+	}
+	
+	if err := t.endReadOnly(); err != nil {
+		return err
+	}
+	
+	// Record the committed transaction in the transactions table as part
+	// of the transaction itself
+	end := time.Now()
+	if err := t.logCommit(end); err != nil {
+		return err
 	}
 	
 	err := t.tx.Commit()
@@ -58,11 +74,8 @@ func (t *Transaction) Commit() error {
 	}
 	
 	t.status = TransactionStatusCommitted
-	t.endTime = time.Now()
-	
-	// Record the committed transaction in the database
-	// This would normally involve a separate connection to the database
-	// for recording metadata about the transaction
+	t.endTime = end
+	t.connection.forget(t)
 	
 	return nil
 }
@@ -70,7 +83,15 @@ func (t *Transaction) Commit() error {
 // Rollback rolls back the transaction
 func (t *Transaction) Rollback() error {
 	if t.status != TransactionStatusActive {
-		return fmt.Errorf("transaction is not active (status: %s)", t.status)
+		return fmt.Errorf("%w (status: %s)", ErrTxNotActive, t.status)
+	}
+	
+	if err := t.endReadOnly(); err != nil {
+		t.tx.Rollback()
+		t.status = TransactionStatusRolledBack
+		t.endTime = time.Now()
+		t.connection.forget(t)
+		return err
 	}
 	
 	err := t.tx.Rollback()
@@ -80,14 +101,15 @@ func (t *Transaction) Rollback() error {
 	
 	t.status = TransactionStatusRolledBack
 	t.endTime = time.Now()
+	t.connection.forget(t)
 	
-	return nil
+	return t.logRollback()
 }
 
 // Savepoint creates a savepoint within the transaction
 func (t *Transaction) Savepoint(name string) error {
 	if t.status != TransactionStatusActive {
-		return fmt.Errorf("transaction is not active (status: %s)", t.status)
+		return fmt.Errorf("%w (status: %s)", ErrTxNotActive, t.status)
 	}
 	
 	if t.savepoints == nil {
@@ -107,11 +129,11 @@ func (t *Transaction) Savepoint(name string) error {
 // RollbackToSavepoint rolls back to a savepoint within the transaction
 func (t *Transaction) RollbackToSavepoint(name string) error {
 	if t.status != TransactionStatusActive {
-		return fmt.Errorf("transaction is not active (status: %s)", t.status)
+		return fmt.Errorf("%w (status: %s)", ErrTxNotActive, t.status)
 	}
 	
 	if t.savepoints == nil || t.savepoints[name].IsZero() {
-		return fmt.Errorf("savepoint '%s' does not exist", name)
+		return fmt.Errorf("savepoint '%s' %w", name, ErrNotFound)
 	}
 	
 	// Roll back to savepoint in the database
@@ -120,17 +142,19 @@ func (t *Transaction) RollbackToSavepoint(name string) error {
 		return fmt.Errorf("failed to roll back to savepoint: %w", err)
 	}
 	
+	// Savepoints created after this one are gone
+	t.forgetSavepointsAfter(t.savepoints[name])
 	return nil
 }
 
 // ReleaseSavepoint releases a savepoint within the transaction
 func (t *Transaction) ReleaseSavepoint(name string) error {
 	if t.status != TransactionStatusActive {
-		return fmt.Errorf("transaction is not active (status: %s)", t.status)
+		return fmt.Errorf("%w (status: %s)", ErrTxNotActive, t.status)
 	}
 	
 	if t.savepoints == nil || t.savepoints[name].IsZero() {
-		return fmt.Errorf("savepoint '%s' does not exist", name)
+		return fmt.Errorf("savepoint '%s' %w", name, ErrNotFound)
 	}
 	
 	// Release savepoint in the database
@@ -139,10 +163,21 @@ func (t *Transaction) ReleaseSavepoint(name string) error {
 		return fmt.Errorf("failed to release savepoint: %w", err)
 	}
 	
+	// Releasing a savepoint also releases the ones created after it
+	t.forgetSavepointsAfter(t.savepoints[name])
 	delete(t.savepoints, name)
 	return nil
 }
 
+// forgetSavepointsAfter drops the savepoints created after the given time
+func (t *Transaction) forgetSavepointsAfter(created time.Time) {
+	for name, at := range t.savepoints {
+		if at.After(created) {
+			delete(t.savepoints, name)
+		}
+	}
+}
+
 // IsActive returns whether the transaction is active
 func (t *Transaction) IsActive() bool {
 	return t.status == TransactionStatusActive
@@ -168,6 +203,40 @@ func (t *Transaction) GetStatus() TransactionStatus {
 	return t.status
 }
 
+// GetIsolation returns the isolation level the transaction was started with
+func (t *Transaction) GetIsolation() sql.IsolationLevel {
+	return t.isolation
+}
+
+// IsReadOnly reports whether the transaction rejects writes
+func (t *Transaction) IsReadOnly() bool {
+	return t.readOnly
+}
+
+// Dialect returns the dialect of the transaction's connection
+func (t *Transaction) Dialect() Dialect {
+	return t.connection.Dialect()
+}
+
+// endReadOnly lifts the SQLite query_only pragma of a read-only transaction
+// before it ends, since the pragma outlives the transaction on its pooled
+// connection
+func (t *Transaction) endReadOnly() error {
+	if !t.readOnly || !t.connection.IsSQLite() {
+		return nil
+	}
+	if _, err := t.tx.Exec("PRAGMA query_only = OFF"); err != nil {
+		return fmt.Errorf("failed to end read-only transaction: %w", err)
+	}
+	return nil
+}
+
+// GetStatementCount returns the number of statements and queries executed
+// in the transaction so far
+func (t *Transaction) GetStatementCount() int {
+	return t.statements
+}
+
 // SetBranchID sets the branch ID for the transaction
 func (t *Transaction) SetBranchID(branchID string) {
 	t.branchID = branchID
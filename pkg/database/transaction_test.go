@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// countRows returns the number of rows in table t
+func countRows(t *testing.T, q Querier) int {
+	t.Helper()
+	rows, err := q.ExecuteQuery(`SELECT COUNT(*) FROM t`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var n int
+	for rows.Next() {
+		if err := rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return n
+}
+
+func TestTransactionStatus(t *testing.T) {
+	db := connectMemory(t, `CREATE TABLE t (n INTEGER)`)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.GetStatus() != TransactionStatusActive || db.GetActiveTransactionCount() != 1 {
+		t.Errorf("status = %s with %d active", tx.GetStatus(), db.GetActiveTransactionCount())
+	}
+	tx.Execute(`INSERT INTO t VALUES (1)`)
+	countRows(t, tx)
+	if n := tx.GetStatementCount(); n != 2 {
+		t.Errorf("GetStatementCount = %d, want 2", n)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if tx.GetStatus() != TransactionStatusCommitted || tx.GetEndTime().IsZero() {
+		t.Errorf("after commit: status %s, end %v", tx.GetStatus(), tx.GetEndTime())
+	}
+	if db.GetActiveTransactionCount() != 0 {
+		t.Errorf("%d transactions active after commit", db.GetActiveTransactionCount())
+	}
+
+	if err := tx.Commit(); !errors.Is(err, ErrTxNotActive) {
+		t.Errorf("second commit: %v, want ErrTxNotActive", err)
+	}
+	if err := tx.Rollback(); !errors.Is(err, ErrTxNotActive) {
+		t.Errorf("rollback after commit: %v, want ErrTxNotActive", err)
+	}
+	if _, err := tx.Execute(`INSERT INTO t VALUES (2)`); !errors.Is(err, ErrTxNotActive) {
+		t.Errorf("execute after commit: %v, want ErrTxNotActive", err)
+	}
+}
+
+func TestEndedTransactionsForgotten(t *testing.T) {
+	db := connectMemory(t, `CREATE TABLE t (n INTEGER)`)
+
+	for _, commit := range []bool{true, false} {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.mu.Lock()
+	n := len(db.txs)
+	db.mu.Unlock()
+	if n != 0 {
+		t.Errorf("connection still holds %d ended transactions", n)
+	}
+
+	// Close rolls back what is still open without deadlocking on forget
+	if _, err := db.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSavepoints(t *testing.T) {
+	db := connectMemory(t, `CREATE TABLE t (n INTEGER)`)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	tx.Execute(`INSERT INTO t VALUES (1)`)
+	if err := tx.Savepoint("a"); err != nil {
+		t.Fatal(err)
+	}
+	tx.Execute(`INSERT INTO t VALUES (2)`)
+	if err := tx.Savepoint("b"); err != nil {
+		t.Fatal(err)
+	}
+	tx.Execute(`INSERT INTO t VALUES (3)`)
+
+	if err := tx.RollbackToSavepoint("a"); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, tx); n != 1 {
+		t.Errorf("%d rows after rolling back to a, want 1", n)
+	}
+	// Rolling back to a dropped b, but keeps a itself
+	if err := tx.RollbackToSavepoint("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rollback to b: %v, want ErrNotFound", err)
+	}
+	if err := tx.RollbackToSavepoint("a"); err != nil {
+		t.Errorf("second rollback to a: %v", err)
+	}
+
+	if err := tx.ReleaseSavepoint("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.ReleaseSavepoint("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second release of a: %v, want ErrNotFound", err)
+	}
+}
+
+func TestTransactionLog(t *testing.T) {
+	db := connectMemory(t, `CREATE TABLE t (n INTEGER)`, `
+		CREATE TABLE transactions (
+			id TEXT PRIMARY KEY,
+			start_time TIMESTAMP NOT NULL,
+			end_time TIMESTAMP,
+			status TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			branch_id TEXT NOT NULL
+		)
+	`)
+
+	run := func(commit bool) string {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx.SetUserID("alice")
+		tx.SetBranchID("main")
+		tx.Execute(`INSERT INTO t VALUES (1)`)
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx.GetID()
+	}
+
+	unlogged := run(true)
+	db.SetTransactionLog(true)
+	committed := run(true)
+	aborted := run(false)
+
+	readOnly, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readOnly.SetUserID("alice")
+	readOnly.SetBranchID("main")
+	if err := readOnly.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	status := func(id string) string {
+		rows, err := db.ExecuteQuery(`SELECT status, user_id, branch_id FROM transactions WHERE id = ?`, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		if !rows.Next() {
+			return ""
+		}
+		var s, user, branch string
+		if err := rows.Scan(&s, &user, &branch); err != nil {
+			t.Fatal(err)
+		}
+		if user != "alice" || branch != "main" {
+			t.Errorf("transaction %s logged for %s on %s", id, user, branch)
+		}
+		return s
+	}
+	if s := status(unlogged); s != "" {
+		t.Errorf("transaction before SetTransactionLog logged as %s", s)
+	}
+	if s := status(committed); s != loggedStatusCommitted {
+		t.Errorf("committed transaction logged as %q", s)
+	}
+	if s := status(aborted); s != loggedStatusAborted {
+		t.Errorf("rolled back transaction logged as %q", s)
+	}
+	if s := status(readOnly.GetID()); s != "" {
+		t.Errorf("read-only transaction logged as %s", s)
+	}
+}
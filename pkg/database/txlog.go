@@ -0,0 +1,65 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// Statuses of ended transactions in the transactions table, matching
+// schema.TransactionStatusCommitted and schema.TransactionStatusAborted
+const (
+	loggedStatusCommitted = "committed"
+	loggedStatusAborted   = "aborted"
+)
+
+// SetTransactionLog turns recording of ended transactions in the
+// transactions table on or off. schema.Initialize turns it on once the table
+// exists. Read-only transactions are never recorded.
+func (c *Connection) SetTransactionLog(enabled bool) {
+	c.logTransactions.Store(enabled)
+}
+
+// logCommit records the transaction as committed from within itself, so the
+// record commits with it. A row already written for the transaction, such
+// as a branch point, is kept.
+func (t *Transaction) logCommit(end time.Time) error {
+	if !t.connection.logTransactions.Load() || t.readOnly {
+		return nil
+	}
+
+	rows, err := t.tx.Query(t.connection.dialect.Rebind(`SELECT 1 FROM transactions WHERE id = ?`), t.id)
+	if err != nil {
+		return fmt.Errorf("failed to record transaction: %w", err)
+	}
+	recorded := rows.Next()
+	rows.Close()
+	if recorded {
+		return nil
+	}
+
+	_, err = t.tx.Exec(t.connection.dialect.Rebind(`
+		INSERT INTO transactions (id, start_time, end_time, status, user_id, branch_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`), t.id, t.startTime, end, loggedStatusCommitted, t.userID, t.branchID)
+	if err != nil {
+		return fmt.Errorf("failed to record transaction: %w", err)
+	}
+	return nil
+}
+
+// logRollback records the transaction as aborted once it has been rolled
+// back, outside of it
+func (t *Transaction) logRollback() error {
+	if !t.connection.logTransactions.Load() || t.readOnly {
+		return nil
+	}
+
+	_, err := t.connection.db.Exec(t.connection.dialect.Rebind(`
+		INSERT INTO transactions (id, start_time, end_time, status, user_id, branch_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`), t.id, t.startTime, t.endTime, loggedStatusAborted, t.userID, t.branchID)
+	if err != nil {
+		return fmt.Errorf("failed to record rolled back transaction: %w", err)
+	}
+	return nil
+}
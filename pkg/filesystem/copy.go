@@ -0,0 +1,113 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+	pathpkg "path"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// CopyFile creates a file at dest with the content of the file at source on
+// the branch of tx and the given metadata. The copy refers to the source's
+// content by checksum instead of storing it again: content already in a
+// content store is shared as is, and content kept in the source's row is
+// shared through the inline store (see ShareContent). Size, checksum,
+// encoding and content store are taken from the source. The returned File
+// has no content loaded.
+func (fm *FileManager) CopyFile(source, dest string, metadata schema.ResourceMetadata, tx *database.Transaction) (*File, error) {
+	if tx == nil {
+		return nil, fmt.Errorf("%w for file copy", database.ErrNoTransaction)
+	}
+
+	source = pathpkg.Clean(source)
+	dest = pathpkg.Clean(dest)
+	options := branchQueryOptions(tx)
+
+	condition, args := resourceCondition(options, 2)
+	rows, err := tx.ExecuteQuery(`
+		SELECT content, metadata FROM resources
+		WHERE type = 'file' AND path = $1`+condition, append([]interface{}{source}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query for file: %w", err)
+	}
+	var sourceMetadata string
+	var content []byte
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&content, &sourceMetadata)
+	}
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan file: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("file %w: %s", database.ErrNotFound, source)
+	}
+
+	stored, err := schema.NormalizeMetadata(json.RawMessage(sourceMetadata))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+
+	dir, name := pathpkg.Split(dest)
+	parentID, err := fm.getDirectoryID(pathpkg.Clean(dir), tx, options)
+	if err != nil {
+		return nil, fmt.Errorf("parent directory not found: %w", err)
+	}
+	exists, err := fm.resourceExists(dest, tx, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if file exists: %w", err)
+	}
+	if exists {
+		return nil, fmt.Errorf("file %w: %s", database.ErrAlreadyExists, dest)
+	}
+
+	// Content without a checksum to share it by is copied as stored
+	shared, err := fm.ShareContent(content, stored, tx)
+	if err != nil {
+		return nil, err
+	}
+	if shared.Store != "" {
+		content = nil
+	}
+
+	metadata.Size = stored.Size
+	metadata.Checksum = stored.Checksum
+	metadata.Encoding = shared.Encoding
+	metadata.Store = shared.Store
+	metadata.Chunks = stored.Chunks
+	if metadata.MimeType == "" {
+		metadata.MimeType = detectMimeType(name)
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	id, err := tx.NextID("file")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	_, err = tx.Execute(`
+		INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id, branch_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, id, schema.ResourceTypeFile, name, parentID, dest, content, string(metadataJSON), now, tx.GetID(), transactionBranch(tx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert file: %w", err)
+	}
+
+	return &File{
+		ID:            id,
+		Name:          name,
+		ParentID:      parentID,
+		Path:          dest,
+		Metadata:      metadata,
+		CreatedAt:     metadata.CreatedAt,
+		ModifiedAt:    metadata.ModifiedAt,
+		TransactionID: tx.GetID(),
+	}, nil
+}
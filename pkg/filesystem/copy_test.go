@@ -0,0 +1,77 @@
+package filesystem
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+func TestCopyFile(t *testing.T) {
+	fm := newTestManager(t)
+	fm.SetCompression(CompressionConfig{Enabled: true, Threshold: 1})
+	content := bytes.Repeat([]byte("compressible "), 100)
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		_, err := fm.CreateFile("/tmp/src.txt", content, tx, "system")
+		return err
+	})
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		metadata := schema.NewResourceMetadata("alice", 0600)
+		copied, err := fm.CopyFile("/tmp/src.txt", "/tmp/dst.txt", metadata, tx)
+		if err != nil {
+			return err
+		}
+		if copied.Metadata.Owner != "alice" || copied.Metadata.Permissions != 0600 {
+			t.Errorf("copy metadata = %+v, want the given owner and permissions", copied.Metadata)
+		}
+		return nil
+	})
+
+	src, err := fm.GetFile("/tmp/src.txt", nil, mainOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := fm.GetFile("/tmp/dst.txt", nil, mainOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dst.Content, content) {
+		t.Errorf("copied content differs: %d bytes", len(dst.Content))
+	}
+	if dst.Metadata.Size != src.Metadata.Size || dst.Metadata.Checksum != src.Metadata.Checksum {
+		t.Errorf("copy metadata %+v does not match source %+v", dst.Metadata, src.Metadata)
+	}
+	// The copy refers to the content by checksum instead of repeating it
+	if dst.Metadata.Store != StoreInline || dst.Metadata.Encoding != "" {
+		t.Errorf("copy store %q encoding %q, want the inline store", dst.Metadata.Store, dst.Metadata.Encoding)
+	}
+}
+
+func TestCopyFileErrors(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, []string{"/tmp/dir"}, []string{"/tmp/a", "/tmp/b"})
+
+	tests := []struct {
+		name     string
+		src, dst string
+		want     error
+	}{
+		{"missing source", "/tmp/missing", "/tmp/c", database.ErrNotFound},
+		{"directory source", "/tmp/dir", "/tmp/c", database.ErrNotFound},
+		{"existing destination", "/tmp/a", "/tmp/b", database.ErrAlreadyExists},
+		{"missing parent", "/tmp/a", "/nowhere/a", database.ErrNotFound},
+	}
+	for _, tt := range tests {
+		err := fm.db.WithTransaction(func(tx *database.Transaction) error {
+			tx.SetBranchID("main")
+			_, err := fm.CopyFile(tt.src, tt.dst, schema.NewResourceMetadata("system", schema.DefaultFilePermissions), tx)
+			return err
+		})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: CopyFile = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package filesystem
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// DefaultCompressionThreshold is the smallest content size that is
+// compressed when compression is enabled
+const DefaultCompressionThreshold = 64 << 10
+
+// CompressionConfig controls gzip encoding of stored file content
+type CompressionConfig struct {
+	Enabled   bool
+	Threshold int // Content smaller than this is stored as is
+}
+
+// DefaultCompressionConfig returns the default configuration, which leaves
+// content uncompressed
+func DefaultCompressionConfig() CompressionConfig {
+	return CompressionConfig{
+		Enabled:   false,
+		Threshold: DefaultCompressionThreshold,
+	}
+}
+
+// Names of the database settings the compression configuration is saved
+// under
+const (
+	compressSetting    = "compress"
+	compressMinSetting = "compressmin"
+)
+
+// SetCompression changes how content written by CreateFile and UpdateFile is
+// encoded. Content already stored keeps its encoding.
+func (fm *FileManager) SetCompression(config CompressionConfig) {
+	fm.compression = config
+}
+
+// SaveCompression changes how content is encoded, as SetCompression does,
+// and saves the configuration in the database for LoadCompression
+func (fm *FileManager) SaveCompression(config CompressionConfig) error {
+	err := fm.db.WithTransaction(func(tx *database.Transaction) error {
+		if err := tx.SaveSetting(compressSetting, strconv.FormatBool(config.Enabled)); err != nil {
+			return err
+		}
+		return tx.SaveSetting(compressMinSetting, strconv.Itoa(config.Threshold))
+	})
+	if err != nil {
+		return err
+	}
+	fm.compression = config
+	return nil
+}
+
+// LoadCompression sets the compression configuration saved in the database
+// by SaveCompression. Settings that were never saved keep their current
+// values.
+func (fm *FileManager) LoadCompression() error {
+	config := fm.compression
+
+	value, ok, err := fm.db.Setting(compressSetting)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if config.Enabled, err = strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid saved %s setting %q", compressSetting, value)
+		}
+	}
+
+	value, ok, err = fm.db.Setting(compressMinSetting)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if config.Threshold, err = strconv.Atoi(value); err != nil || config.Threshold < 0 {
+			return fmt.Errorf("invalid saved %s setting %q", compressMinSetting, value)
+		}
+	}
+
+	fm.compression = config
+	return nil
+}
+
+// Compression returns the current compression configuration
+func (fm *FileManager) Compression() CompressionConfig {
+	return fm.compression
+}
+
+// encodeContent returns the bytes to store for content and the encoding to
+// record in its metadata. Content is only stored compressed when that makes
+// it smaller.
+func (fm *FileManager) encodeContent(content []byte) ([]byte, string, error) {
+	if !fm.compression.Enabled || len(content) < fm.compression.Threshold {
+		return content, "", nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		return nil, "", fmt.Errorf("failed to compress content: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to compress content: %w", err)
+	}
+
+	if buf.Len() >= len(content) {
+		return content, "", nil
+	}
+	return buf.Bytes(), schema.ContentEncodingGzip, nil
+}
+
+// DecodeContent returns the original content of stored bytes
+func DecodeContent(stored []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return stored, nil
+	case schema.ContentEncodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(stored))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress content: %w", err)
+		}
+		defer zr.Close()
+
+		content, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress content: %w", err)
+		}
+		return content, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
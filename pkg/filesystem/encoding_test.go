@@ -0,0 +1,75 @@
+package filesystem
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+func TestCompressedRoundTrip(t *testing.T) {
+	fm := newTestManager(t)
+	fm.SetCompression(CompressionConfig{Enabled: true, Threshold: 1024})
+	content := bytes.Repeat([]byte("all work and no play\n"), 10000)
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		_, err := fm.CreateFile("/big.txt", content, tx, "system")
+		return err
+	})
+
+	file, err := fm.GetFile("/big.txt", nil, database.QueryOptions{BranchID: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(file.Content, content) {
+		t.Error("content changed in the round trip")
+	}
+	if file.Metadata.Encoding != schema.ContentEncodingGzip {
+		t.Errorf("encoding = %q, want %q", file.Metadata.Encoding, schema.ContentEncodingGzip)
+	}
+
+	rows, err := fm.db.ExecuteQuery(`SELECT LENGTH(content) FROM resources WHERE path = '/big.txt'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var stored int
+	for rows.Next() {
+		if err := rows.Scan(&stored); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stored >= len(content) {
+		t.Errorf("stored %d bytes for %d bytes of content", stored, len(content))
+	}
+}
+
+func TestCompressionIsSaved(t *testing.T) {
+	fm := newTestManager(t)
+	config := CompressionConfig{Enabled: true, Threshold: 4096}
+	if err := fm.SaveCompression(config); err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewFileManager(fm.db)
+	if got := other.Compression(); got != DefaultCompressionConfig() {
+		t.Fatalf("new manager starts with %+v, want the defaults", got)
+	}
+	if err := other.LoadCompression(); err != nil {
+		t.Fatal(err)
+	}
+	if got := other.Compression(); got != config {
+		t.Errorf("loaded %+v, want %+v", got, config)
+	}
+}
+
+func TestLoadCompressionWithoutSaved(t *testing.T) {
+	fm := newTestManager(t)
+	if err := fm.LoadCompression(); err != nil {
+		t.Fatal(err)
+	}
+	if got := fm.Compression(); got != DefaultCompressionConfig() {
+		t.Errorf("loaded %+v, want the defaults", got)
+	}
+}
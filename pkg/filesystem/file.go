@@ -3,10 +3,11 @@ package filesystem
 import (
 	"encoding/json"
 	"fmt"
-	"path/filepath"
+	pathpkg "path"
 	"strings"
 	"time"
 
+	"github.com/brainwavecollective/stone-os/internal/util"
 	"github.com/brainwavecollective/stone-os/pkg/database"
 	"github.com/brainwavecollective/stone-os/pkg/schema"
 )
@@ -26,38 +27,44 @@ type File struct {
 
 // FileManager handles file operations
 type FileManager struct {
-	db *database.Connection
+	db          *database.Connection
+	compression CompressionConfig
+	storeName   string    // Store new content is written to; empty for the resources table
+	dirStore    *DirStore // Configured directory store, if any
 }
 
 // NewFileManager creates a new FileManager
 func NewFileManager(db *database.Connection) *FileManager {
-	return &FileManager{db: db}
+	return &FileManager{db: db, compression: DefaultCompressionConfig()}
 }
 
 // GetFile retrieves a file by path
 func (fm *FileManager) GetFile(path string, tx *database.Transaction, options database.QueryOptions) (*File, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Normalize path
-	path = filepath.Clean(path)
+	path = pathpkg.Clean(path)
 
 	// Query for the file
 	var query string
 	var result *database.QueryResult
 	var err error
 
+	// The branch and point in time are applied as bound conditions rather
+	// than through the generic query options
+	condition, args := resourceCondition(options, 2)
 	query = `
 		SELECT r.id, r.name, r.parent_id, r.content, r.metadata, r.valid_from, r.transaction_id
 		FROM resources r
-		WHERE r.type = 'file' AND r.path = $1
-	`
-
-	if !options.IncludeDeleted {
-		query += " AND r.valid_to IS NULL"
-	}
+		WHERE r.type = 'file' AND r.path = $1` + condition
+	args = append([]interface{}{path}, args...)
 
 	if tx != nil {
-		result, err = tx.Query(query, options, path)
+		result, err = tx.Query(query, database.QueryOptions{}, args...)
 	} else {
-		result, err = fm.db.Query(query, options, path)
+		result, err = fm.db.Query(query, database.QueryOptions{}, args...)
 	}
 
 	if err != nil {
@@ -65,25 +72,50 @@ func (fm *FileManager) GetFile(path string, tx *database.Transaction, options da
 	}
 
 	if result.Count == 0 {
-		return nil, fmt.Errorf("file not found: %s", path)
+		return nil, fmt.Errorf("file %w: %s", database.ErrNotFound, path)
 	}
 
 	// Parse the result
-	row := result.Rows[0]
-	
-	id := row[0].(string)
-	name := row[1].(string)
-	parentID := row[2].(string)
-	content := row[3].([]byte)
-	metadataJSON := row[4].([]byte)
-	validFrom := row[5].(time.Time)
-	transactionID := row[6].(string)
-
-	var metadata schema.ResourceMetadata
-	if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+	id, err := result.GetStringByName(0, "id")
+	if err != nil {
+		return nil, err
+	}
+	name, err := result.GetStringByName(0, "name")
+	if err != nil {
+		return nil, err
+	}
+	parentID, err := result.GetStringByName(0, "parent_id")
+	if err != nil {
+		return nil, err
+	}
+	var content []byte
+	contentNull, err := result.IsNullByName(0, "content")
+	if err != nil {
+		return nil, err
+	}
+	if !contentNull {
+		if content, err = result.GetBytesByName(0, "content"); err != nil {
+			return nil, err
+		}
+	}
+	metadataJSON, err := result.GetBytesByName(0, "metadata")
+	if err != nil {
+		return nil, err
+	}
+	transactionID, err := result.GetStringByName(0, "transaction_id")
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := schema.NormalizeMetadata(metadataJSON)
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 	}
 
+	if content, err = fm.LoadContent(content, metadata, tx); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
 	file := &File{
 		ID:           id,
 		Name:         name,
@@ -101,46 +133,74 @@ func (fm *FileManager) GetFile(path string, tx *database.Transaction, options da
 
 // CreateFile creates a new file
 func (fm *FileManager) CreateFile(path string, content []byte, tx *database.Transaction, owner string) (*File, error) {
+	metadata := schema.NewResourceMetadata(owner, schema.DefaultFilePermissions)
+	metadata.Checksum = util.CalculateChecksum(content)
+	return fm.createFile(path, content, metadata, tx)
+}
+
+// CreateFileWithMetadata creates a new file with the given metadata, such as
+// permissions and timestamps carried over from another filesystem. Size,
+// checksum and encoding are always derived from the content.
+func (fm *FileManager) CreateFileWithMetadata(path string, content []byte, metadata schema.ResourceMetadata, tx *database.Transaction) (*File, error) {
+	metadata.Checksum = util.CalculateChecksum(content)
+	return fm.createFile(path, content, metadata, tx)
+}
+
+// createFile creates a new file with the given metadata. Size and encoding
+// are set from the content, and the MIME type when the metadata has none.
+func (fm *FileManager) createFile(path string, content []byte, metadata schema.ResourceMetadata, tx *database.Transaction) (*File, error) {
+	file, err := fm.insertFile(path, metadata, tx, func(metadata *schema.ResourceMetadata) ([]byte, error) {
+		metadata.Size = int64(len(content))
+		return fm.storeContent(content, metadata, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	file.Content = content
+	return file, nil
+}
+
+// insertFile creates a new file with the given metadata once its parent
+// directory is found and the path is free. store stores the content,
+// completing the metadata, and returns the bytes for the content column.
+// The MIME type is set when the metadata has none. The returned File has
+// no content loaded.
+func (fm *FileManager) insertFile(path string, metadata schema.ResourceMetadata, tx *database.Transaction, store func(metadata *schema.ResourceMetadata) ([]byte, error)) (*File, error) {
 	if tx == nil {
-		return nil, fmt.Errorf("transaction required for file creation")
+		return nil, fmt.Errorf("%w for file creation", database.ErrNoTransaction)
 	}
 
 	// Normalize path
-	path = filepath.Clean(path)
+	path = pathpkg.Clean(path)
 
 	// Parse the path
-	dir, name := filepath.Split(path)
-	dir = filepath.Clean(dir)
+	dir, name := pathpkg.Split(path)
+	dir = pathpkg.Clean(dir)
 
 	// Get parent directory ID
-	parentID, err := fm.getDirectoryID(dir, tx, database.DefaultQueryOptions())
+	options := branchQueryOptions(tx)
+	parentID, err := fm.getDirectoryID(dir, tx, options)
 	if err != nil {
 		return nil, fmt.Errorf("parent directory not found: %w", err)
 	}
 
 	// Check if file already exists
-	exists, err := fm.resourceExists(name, parentID, tx, database.DefaultQueryOptions())
+	exists, err := fm.resourceExists(path, tx, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check if file exists: %w", err)
 	}
 
 	if exists {
-		return nil, fmt.Errorf("file already exists: %s", path)
+		return nil, fmt.Errorf("file %w: %s", database.ErrAlreadyExists, path)
 	}
 
-	// Create metadata
-	metadata := schema.NewResourceMetadata(owner)
-	metadata.Size = int64(len(content))
+	stored, err := store(&metadata)
+	if err != nil {
+		return nil, err
+	}
 	
-	// Detect MIME type (simplified)
-	if strings.HasSuffix(name, ".txt") {
-		metadata.MimeType = "text/plain"
-	} else if strings.HasSuffix(name, ".json") {
-		metadata.MimeType = "application/json"
-	} else if strings.HasSuffix(name, ".html") {
-		metadata.MimeType = "text/html"
-	} else {
-		metadata.MimeType = "application/octet-stream"
+	if metadata.MimeType == "" {
+		metadata.MimeType = detectMimeType(name)
 	}
 
 	metadataJSON, err := json.Marshal(metadata)
@@ -149,14 +209,17 @@ func (fm *FileManager) CreateFile(path string, content []byte, tx *database.Tran
 	}
 
 	// Generate ID
-	id := generateResourceID()
+	id, err := tx.NextID("file")
+	if err != nil {
+		return nil, err
+	}
 
 	// Insert the file
 	now := time.Now()
 	_, err = tx.Execute(`
-		INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	`, id, schema.ResourceTypeFile, name, parentID, path, content, metadataJSON, now, tx.GetID())
+		INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id, branch_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, id, schema.ResourceTypeFile, name, parentID, path, stored, string(metadataJSON), now, tx.GetID(), transactionBranch(tx))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert file: %w", err)
@@ -167,10 +230,9 @@ func (fm *FileManager) CreateFile(path string, content []byte, tx *database.Tran
 		Name:         name,
 		ParentID:     parentID,
 		Path:         path,
-		Content:      content,
 		Metadata:     metadata,
-		CreatedAt:    now,
-		ModifiedAt:   now,
+		CreatedAt:    metadata.CreatedAt,
+		ModifiedAt:   metadata.ModifiedAt,
 		TransactionID: tx.GetID(),
 	}
 
@@ -179,16 +241,25 @@ func (fm *FileManager) CreateFile(path string, content []byte, tx *database.Tran
 
 // UpdateFile updates an existing file
 func (fm *FileManager) UpdateFile(path string, content []byte, tx *database.Transaction) (*File, error) {
+	return fm.UpdateFileWithType(path, content, "", tx)
+}
+
+// UpdateFileWithType updates an existing file and sets its MIME type. An
+// empty type keeps the file's current one.
+func (fm *FileManager) UpdateFileWithType(path string, content []byte, mimeType string, tx *database.Transaction) (*File, error) {
 	if tx == nil {
-		return nil, fmt.Errorf("transaction required for file update")
+		return nil, fmt.Errorf("%w for file update", database.ErrNoTransaction)
 	}
 
 	// Normalize path
-	path = filepath.Clean(path)
+	path = pathpkg.Clean(path)
+
+	if err := fm.CheckLock(path, tx); err != nil {
+		return nil, err
+	}
 
 	// Get the current file
-	options := database.DefaultQueryOptions()
-	file, err := fm.GetFile(path, tx, options)
+	file, err := fm.GetFile(path, tx, branchQueryOptions(tx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file: %w", err)
 	}
@@ -208,6 +279,15 @@ func (fm *FileManager) UpdateFile(path string, content []byte, tx *database.Tran
 	// Update metadata
 	file.Metadata.ModifiedAt = now
 	file.Metadata.Size = int64(len(content))
+	file.Metadata.Checksum = util.CalculateChecksum(content)
+
+	stored, err := fm.storeContent(content, &file.Metadata, tx)
+	if err != nil {
+		return nil, err
+	}
+	if mimeType != "" {
+		file.Metadata.MimeType = mimeType
+	}
 	
 	metadataJSON, err := json.Marshal(file.Metadata)
 	if err != nil {
@@ -215,11 +295,14 @@ func (fm *FileManager) UpdateFile(path string, content []byte, tx *database.Tran
 	}
 
 	// Insert the new version
-	newID := generateResourceID()
+	newID, err := tx.NextID("file")
+	if err != nil {
+		return nil, err
+	}
 	_, err = tx.Execute(`
-		INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	`, newID, schema.ResourceTypeFile, file.Name, file.ParentID, path, content, metadataJSON, now, tx.GetID())
+		INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id, branch_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, newID, schema.ResourceTypeFile, file.Name, file.ParentID, path, stored, string(metadataJSON), now, tx.GetID(), transactionBranch(tx))
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert new file version: %w", err)
@@ -243,15 +326,18 @@ func (fm *FileManager) UpdateFile(path string, content []byte, tx *database.Tran
 // DeleteFile marks a file as deleted
 func (fm *FileManager) DeleteFile(path string, tx *database.Transaction) error {
 	if tx == nil {
-		return fmt.Errorf("transaction required for file deletion")
+		return fmt.Errorf("%w for file deletion", database.ErrNoTransaction)
 	}
 
 	// Normalize path
-	path = filepath.Clean(path)
+	path = pathpkg.Clean(path)
+
+	if err := fm.CheckLock(path, tx); err != nil {
+		return err
+	}
 
 	// Get the current file
-	options := database.DefaultQueryOptions()
-	file, err := fm.GetFile(path, tx, options)
+	file, err := fm.GetFile(path, tx, branchQueryOptions(tx))
 	if err != nil {
 		return fmt.Errorf("failed to get file: %w", err)
 	}
@@ -273,13 +359,8 @@ func (fm *FileManager) DeleteFile(path string, tx *database.Transaction) error {
 
 // getDirectoryID gets the ID of a directory by path
 func (fm *FileManager) getDirectoryID(path string, tx *database.Transaction, options database.QueryOptions) (string, error) {
-	// Special case for root directory
-	if path == "/" {
-		return "root", nil
-	}
-
 	// Normalize path
-	path = filepath.Clean(path)
+	path = pathpkg.Clean(path)
 
 	// Query for the directory
 	var query string
@@ -307,14 +388,16 @@ func (fm *FileManager) getDirectoryID(path string, tx *database.Transaction, opt
 	}
 
 	if result.Count == 0 {
-		return "", fmt.Errorf("directory not found: %s", path)
+		return "", fmt.Errorf("directory %w: %s", database.ErrNotFound, path)
 	}
 
 	return result.Rows[0][0].(string), nil
 }
 
-// resourceExists checks if a resource with the given name exists in the given parent directory
-func (fm *FileManager) resourceExists(name string, parentID string, tx *database.Transaction, options database.QueryOptions) (bool, error) {
+// resourceExists checks if a resource exists at the given path. It matches
+// by path rather than parent ID because every metadata change to a
+// directory gives it a new version ID, which its children do not follow.
+func (fm *FileManager) resourceExists(path string, tx *database.Transaction, options database.QueryOptions) (bool, error) {
 	var query string
 	var result *database.QueryResult
 	var err error
@@ -322,7 +405,7 @@ func (fm *FileManager) resourceExists(name string, parentID string, tx *database
 	query = `
 		SELECT 1
 		FROM resources
-		WHERE name = $1 AND parent_id = $2
+		WHERE path = $1
 	`
 
 	if !options.IncludeDeleted {
@@ -330,9 +413,9 @@ func (fm *FileManager) resourceExists(name string, parentID string, tx *database
 	}
 
 	if tx != nil {
-		result, err = tx.Query(query, options, name, parentID)
+		result, err = tx.Query(query, options, path)
 	} else {
-		result, err = fm.db.Query(query, options, name, parentID)
+		result, err = fm.db.Query(query, options, path)
 	}
 
 	if err != nil {
@@ -342,7 +425,29 @@ func (fm *FileManager) resourceExists(name string, parentID string, tx *database
 	return result.Count > 0, nil
 }
 
-// generateResourceID generates a unique resource ID
-func generateResourceID() string {
-	return fmt.Sprintf("r-%d", time.Now().UnixNano())
-}
\ No newline at end of file
+// detectMimeType guesses a file's MIME type from its name (simplified)
+func detectMimeType(name string) string {
+	if strings.HasSuffix(name, ".txt") {
+		return "text/plain"
+	} else if strings.HasSuffix(name, ".json") {
+		return "application/json"
+	} else if strings.HasSuffix(name, ".html") {
+		return "text/html"
+	}
+	return "application/octet-stream"
+}
+
+// transactionBranch returns the branch a transaction writes to
+func transactionBranch(tx *database.Transaction) string {
+	if branchID := tx.GetBranchID(); branchID != "" {
+		return branchID
+	}
+	return "main"
+}
+
+// branchQueryOptions returns default query options scoped to the branch of tx
+func branchQueryOptions(tx *database.Transaction) database.QueryOptions {
+	options := database.DefaultQueryOptions()
+	options.BranchID = transactionBranch(tx)
+	return options
+}
@@ -0,0 +1,187 @@
+package filesystem
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+func TestGetFile(t *testing.T) {
+	fm := newTestManager(t)
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		if _, err := fm.CreateFile("/tmp/notes", []byte("hello"), tx, "system"); err != nil {
+			return err
+		}
+		_, err := fm.CreateFile("/tmp/empty", nil, tx, "system")
+		return err
+	})
+	if _, err := fm.db.ExecuteStatement(
+		`UPDATE resources SET content = NULL WHERE path = $1`, "/tmp/empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	options := database.QueryOptions{BranchID: "main"}
+	file, err := fm.GetFile("/tmp/notes", nil, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(file.Content) != "hello" || file.Path != "/tmp/notes" || file.Metadata.Size != 5 {
+		t.Errorf("GetFile(/tmp/notes) = %q at %s, size %d", file.Content, file.Path, file.Metadata.Size)
+	}
+
+	file, err = fm.GetFile("/tmp/empty", nil, options)
+	if err != nil {
+		t.Fatalf("file with NULL content: %v", err)
+	}
+	if len(file.Content) != 0 {
+		t.Errorf("NULL content read as %q", file.Content)
+	}
+
+	if _, err := fm.GetFile("/tmp", nil, options); err == nil {
+		t.Error("GetFile on a directory succeeded")
+	}
+}
+
+func TestCreateFileErrors(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, nil, []string{"/tmp/a"})
+
+	err := fm.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID("main")
+		_, err := fm.CreateFile("/tmp/a", nil, tx, "system")
+		return err
+	})
+	if !errors.Is(err, database.ErrAlreadyExists) {
+		t.Errorf("create over a file: %v, want ErrAlreadyExists", err)
+	}
+
+	err = fm.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID("main")
+		_, err := fm.CreateFile("/missing/a", nil, tx, "system")
+		return err
+	})
+	if !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("create in a missing directory: %v, want ErrNotFound", err)
+	}
+
+	if _, err := fm.CreateFile("/tmp/b", nil, nil, "system"); !errors.Is(err, database.ErrNoTransaction) {
+		t.Errorf("create without a transaction: %v, want ErrNoTransaction", err)
+	}
+}
+
+func TestCreateFileAfterDirectoryChange(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, []string{"/docs"}, []string{"/docs/a", "/tmp/b"})
+
+	// A metadata change gives the directory a new version ID that /docs/a
+	// does not point at
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		if _, err := tx.Execute(`UPDATE resources SET valid_to = $1 WHERE path = '/docs' AND valid_to IS NULL`, time.Now()); err != nil {
+			return err
+		}
+		metadata := schema.NewResourceMetadata("system", 0700)
+		return insertResource(fm, tx, schema.ResourceTypeDirectory, "/docs", metadata)
+	})
+
+	err := fm.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID("main")
+		_, err := fm.CreateFile("/docs/a", nil, tx, "system")
+		return err
+	})
+	if !errors.Is(err, database.ErrAlreadyExists) {
+		t.Errorf("create over a file in a changed directory: %v, want ErrAlreadyExists", err)
+	}
+
+	err = fm.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID("main")
+		_, err := fm.CopyFile("/tmp/b", "/docs/a", schema.NewResourceMetadata("system", schema.DefaultFilePermissions), tx)
+		return err
+	})
+	if !errors.Is(err, database.ErrAlreadyExists) {
+		t.Errorf("copy over a file in a changed directory: %v, want ErrAlreadyExists", err)
+	}
+}
+
+func TestCreateFileMimeType(t *testing.T) {
+	fm := newTestManager(t)
+	var detected, given *File
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		var err error
+		if detected, err = fm.CreateFile("/tmp/page.html", []byte("<p>"), tx, "system"); err != nil {
+			return err
+		}
+		metadata := schema.NewResourceMetadata("system", schema.DefaultFilePermissions)
+		metadata.MimeType = "application/x-custom"
+		given, err = fm.CreateFileWithMetadata("/tmp/page.txt", []byte("x"), metadata, tx)
+		return err
+	})
+
+	if detected.Metadata.MimeType != "text/html" {
+		t.Errorf("detected MIME type = %q, want text/html", detected.Metadata.MimeType)
+	}
+	if given.Metadata.MimeType != "application/x-custom" {
+		t.Errorf("given MIME type = %q, want it kept", given.Metadata.MimeType)
+	}
+	if given.Metadata.Checksum != util.CalculateChecksum([]byte("x")) {
+		t.Errorf("checksum = %q, want it derived from the content", given.Metadata.Checksum)
+	}
+}
+
+func TestUpdateFileKeepsHistory(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, nil, []string{"/tmp/notes"})
+	before := time.Now()
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		file, err := fm.UpdateFileWithType("/tmp/notes", []byte("second"), "text/markdown", tx)
+		if err != nil {
+			return err
+		}
+		if file.Metadata.Size != 6 || file.Metadata.MimeType != "text/markdown" {
+			t.Errorf("updated metadata = %+v", file.Metadata)
+		}
+		return nil
+	})
+
+	if got := readContent(t, fm, "/tmp/notes"); got != "second" {
+		t.Errorf("content = %q, want second", got)
+	}
+	old, err := fm.GetFile("/tmp/notes", nil, database.QueryOptions{BranchID: "main", PointInTime: &before})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(old.Content) != "/tmp/notes" {
+		t.Errorf("content before the update = %q", old.Content)
+	}
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		_, err := fm.UpdateFile("/tmp/notes", []byte("third"), tx)
+		return err
+	})
+	file, err := fm.GetFile("/tmp/notes", nil, mainOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Metadata.MimeType != "text/markdown" {
+		t.Errorf("MIME type after an update without one = %q, want it kept", file.Metadata.MimeType)
+	}
+}
+
+func TestDeleteFile(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, nil, []string{"/tmp/gone"})
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		return fm.DeleteFile("/tmp/gone", tx)
+	})
+	if _, err := fm.GetFile("/tmp/gone", nil, mainOptions); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("read after delete: %v, want ErrNotFound", err)
+	}
+
+	// The path is free for a new file
+	makeTree(t, fm, nil, []string{"/tmp/gone"})
+}
@@ -0,0 +1,105 @@
+package filesystem
+
+import (
+	"encoding/json"
+	pathpkg "path"
+	"testing"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// newTestManager returns a FileManager on a fresh in-memory database named
+// after the test
+func newTestManager(t *testing.T) *FileManager {
+	t.Helper()
+	db, err := database.Connect("inmemory", t.Name())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := schema.Initialize(db); err != nil {
+		t.Fatalf("initialize schema: %v", err)
+	}
+	return NewFileManager(db)
+}
+
+// inTransaction runs fn in a transaction on the main branch, failing the
+// test if it returns an error
+func inTransaction(t *testing.T, fm *FileManager, fn func(tx *database.Transaction) error) {
+	t.Helper()
+	err := fm.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID("main")
+		tx.SetUserID("system")
+		return fn(tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// insertResource adds a resource of the given type at path on the branch of
+// tx, under the directory that holds path. It is how tests make directories
+// and symlinks, which the FileManager does not create itself.
+func insertResource(fm *FileManager, tx *database.Transaction, resourceType, path string, metadata schema.ResourceMetadata) error {
+	parentID, err := fm.getDirectoryID(pathpkg.Dir(path), tx, branchQueryOptions(tx))
+	if err != nil {
+		return err
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	id, err := tx.NextID(resourceType)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Execute(`
+		INSERT INTO resources (id, type, name, parent_id, path, metadata, valid_from, transaction_id, branch_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, id, resourceType, pathpkg.Base(path), parentID, path, string(metadataJSON), time.Now(), tx.GetID(), transactionBranch(tx))
+	return err
+}
+
+// makeTree creates the given directories and then files, each file holding
+// its own path, in one transaction
+func makeTree(t *testing.T, fm *FileManager, dirs, files []string) {
+	t.Helper()
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		for _, dir := range dirs {
+			metadata := schema.NewResourceMetadata("system", schema.DefaultDirectoryPermissions)
+			if err := insertResource(fm, tx, schema.ResourceTypeDirectory, dir, metadata); err != nil {
+				return err
+			}
+		}
+		for _, file := range files {
+			if _, err := fm.CreateFile(file, []byte(file), tx, "system"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// mainOptions reads the current state of the main branch
+var mainOptions = database.QueryOptions{BranchID: "main"}
+
+// readContent returns the current content of the file at path on main
+func readContent(t *testing.T, fm *FileManager, path string) string {
+	t.Helper()
+	file, err := fm.GetFile(path, nil, mainOptions)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(file.Content)
+}
+
+// resourcePaths returns the paths of resources
+func resourcePaths(resources []*schema.Resource) []string {
+	var paths []string
+	for _, r := range resources {
+		paths = append(paths, r.Path)
+	}
+	return paths
+}
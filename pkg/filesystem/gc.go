@@ -0,0 +1,90 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// UnusedContent returns the checksums of the content in the inline store
+// that no version of any resource, on any branch, uses. With the table store
+// active, content kept in the rows of versions goes with them when their
+// history is deleted, so this is the content shared through the inline
+// store. Content in a directory store is not tracked, so while it is the
+// active store this returns an error rather than report nothing to collect.
+func (fm *FileManager) UnusedContent(tx *database.Transaction) ([]string, error) {
+	if fm.storeName == StoreDir {
+		return nil, fmt.Errorf("garbage collection does not support the %s content store", fm.ContentStoreSetting())
+	}
+
+	used := make(map[string]bool)
+	rows, err := fm.executeQuery(tx, `SELECT metadata FROM resources WHERE type = $1`, schema.ResourceTypeFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file metadata: %w", err)
+	}
+	for rows.Next() {
+		var metadataJSON string
+		if err := rows.Scan(&metadataJSON); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan metadata: %w", err)
+		}
+		metadata, err := schema.NormalizeMetadata(json.RawMessage(metadataJSON))
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to parse metadata: %w", err)
+		}
+		if metadata.Store != StoreInline {
+			continue
+		}
+		used[metadata.Checksum] = true
+		for _, checksum := range metadata.Chunks {
+			used[checksum] = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating metadata: %w", err)
+	}
+
+	rows, err = fm.executeQuery(tx, `SELECT checksum FROM blobs ORDER BY checksum`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stored content: %w", err)
+	}
+	defer rows.Close()
+	var unused []string
+	for rows.Next() {
+		var checksum string
+		if err := rows.Scan(&checksum); err != nil {
+			return nil, fmt.Errorf("failed to scan checksum: %w", err)
+		}
+		if !used[checksum] {
+			unused = append(unused, checksum)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stored content: %w", err)
+	}
+	return unused, nil
+}
+
+// CollectGarbage deletes the content in the inline store that no version of
+// any resource uses and returns how many entries it deleted. Content a
+// version needs is never deleted, since the check and the deletion happen in
+// tx.
+func (fm *FileManager) CollectGarbage(tx *database.Transaction) (int, error) {
+	if tx == nil {
+		return 0, fmt.Errorf("%w for garbage collection", database.ErrNoTransaction)
+	}
+	unused, err := fm.UnusedContent(tx)
+	if err != nil {
+		return 0, err
+	}
+	for _, checksum := range unused {
+		if _, err := tx.Execute(`DELETE FROM blobs WHERE checksum = $1`, checksum); err != nil {
+			return 0, fmt.Errorf("failed to delete content %s: %w", checksum, err)
+		}
+	}
+	return len(unused), nil
+}
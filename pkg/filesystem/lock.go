@@ -0,0 +1,142 @@
+package filesystem
+
+import (
+	"fmt"
+	pathpkg "path"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// Lock is an advisory lock held on a path within a branch
+type Lock struct {
+	BranchID string
+	Path     string
+	LockedBy string
+	LockedAt time.Time
+}
+
+// GetLock returns the lock on path in the branch of tx, or nil if unlocked
+func (fm *FileManager) GetLock(path string, tx *database.Transaction) (*Lock, error) {
+	if tx == nil {
+		return nil, fmt.Errorf("%w for reading locks", database.ErrNoTransaction)
+	}
+	path = pathpkg.Clean(path)
+	branchID := transactionBranch(tx)
+
+	rows, err := tx.ExecuteQuery(`
+		SELECT locked_by, locked_at FROM locks WHERE branch_id = $1 AND path = $2
+	`, branchID, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lock: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	lock := &Lock{BranchID: branchID, Path: path}
+	if err := rows.Scan(&lock.LockedBy, &lock.LockedAt); err != nil {
+		return nil, fmt.Errorf("failed to scan lock: %w", err)
+	}
+	return lock, nil
+}
+
+// CheckLock returns an error if path is locked by a user other than the
+// user of tx
+func (fm *FileManager) CheckLock(path string, tx *database.Transaction) error {
+	if tx == nil {
+		return fmt.Errorf("%w for checking locks", database.ErrNoTransaction)
+	}
+	lock, err := fm.GetLock(path, tx)
+	if err != nil {
+		return err
+	}
+	if lock != nil && lock.LockedBy != tx.GetUserID() {
+		return fmt.Errorf("%w: resource locked by %s: %s", database.ErrPermissionDenied, lock.LockedBy, lock.Path)
+	}
+	return nil
+}
+
+// LockResource places an advisory lock on an existing resource for the user
+// of tx. Locking a path the user already holds is a no-op.
+func (fm *FileManager) LockResource(path string, tx *database.Transaction) (*Lock, error) {
+	if tx == nil {
+		return nil, fmt.Errorf("%w for locking", database.ErrNoTransaction)
+	}
+	path = pathpkg.Clean(path)
+
+	exists, err := fm.pathExists(path, tx)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("resource %w: %s", database.ErrNotFound, path)
+	}
+
+	lock, err := fm.GetLock(path, tx)
+	if err != nil {
+		return nil, err
+	}
+	if lock != nil {
+		if lock.LockedBy != tx.GetUserID() {
+			return nil, fmt.Errorf("%w: resource locked by %s: %s", database.ErrPermissionDenied, lock.LockedBy, path)
+		}
+		return lock, nil
+	}
+
+	lock = &Lock{
+		BranchID: transactionBranch(tx),
+		Path:     path,
+		LockedBy: tx.GetUserID(),
+		LockedAt: time.Now(),
+	}
+	_, err = tx.Execute(`
+		INSERT INTO locks (branch_id, path, locked_by, locked_at) VALUES ($1, $2, $3, $4)
+	`, lock.BranchID, lock.Path, lock.LockedBy, lock.LockedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	return lock, nil
+}
+
+// UnlockResource releases the lock on path. Only the holder may unlock unless
+// force is set.
+func (fm *FileManager) UnlockResource(path string, tx *database.Transaction, force bool) error {
+	if tx == nil {
+		return fmt.Errorf("%w for unlocking", database.ErrNoTransaction)
+	}
+	path = pathpkg.Clean(path)
+
+	lock, err := fm.GetLock(path, tx)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		return fmt.Errorf("resource is not locked: %s", path)
+	}
+	if lock.LockedBy != tx.GetUserID() && !force {
+		return fmt.Errorf("%w: resource locked by %s: %s", database.ErrPermissionDenied, lock.LockedBy, path)
+	}
+
+	_, err = tx.Execute(`DELETE FROM locks WHERE branch_id = $1 AND path = $2`, lock.BranchID, path)
+	if err != nil {
+		return fmt.Errorf("failed to unlock %s: %w", path, err)
+	}
+	return nil
+}
+
+// pathExists reports whether a current resource exists at path
+func (fm *FileManager) pathExists(path string, tx *database.Transaction) (bool, error) {
+	rows, err := tx.ExecuteQuery(`
+		SELECT 1 FROM resources WHERE path = $1 AND branch_id = $2 AND valid_to IS NULL
+	`, path, transactionBranch(tx))
+	if err != nil {
+		return false, fmt.Errorf("failed to check if resource exists: %w", err)
+	}
+	defer rows.Close()
+
+	return rows.Next(), nil
+}
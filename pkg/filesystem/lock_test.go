@@ -0,0 +1,94 @@
+package filesystem
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+func TestLockNilTransaction(t *testing.T) {
+	fm := newTestManager(t)
+	if _, err := fm.GetLock("/tmp", nil); !errors.Is(err, database.ErrNoTransaction) {
+		t.Errorf("GetLock with nil tx: got %v, want ErrNoTransaction", err)
+	}
+	if err := fm.CheckLock("/tmp", nil); !errors.Is(err, database.ErrNoTransaction) {
+		t.Errorf("CheckLock with nil tx: got %v, want ErrNoTransaction", err)
+	}
+}
+
+func TestCheckLockOtherUser(t *testing.T) {
+	fm := newTestManager(t)
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		if _, err := fm.CreateFile("/tmp/notes", []byte("x"), tx, "system"); err != nil {
+			return err
+		}
+		_, err := fm.LockResource("/tmp/notes", tx)
+		return err
+	})
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		if err := fm.CheckLock("/tmp/notes", tx); err != nil {
+			t.Errorf("holder: %v", err)
+		}
+		tx.SetUserID("alice")
+		if err := fm.CheckLock("/tmp/notes", tx); !errors.Is(err, database.ErrPermissionDenied) {
+			t.Errorf("other user: got %v, want ErrPermissionDenied", err)
+		}
+		if err := fm.CheckLock("/tmp", tx); err != nil {
+			t.Errorf("unlocked path: %v", err)
+		}
+		return nil
+	})
+}
+
+func TestLockAndUnlock(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, nil, []string{"/tmp/notes"})
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		first, err := fm.LockResource("/tmp/notes", tx)
+		if err != nil {
+			return err
+		}
+		again, err := fm.LockResource("/tmp/./notes", tx)
+		if err != nil {
+			return err
+		}
+		if first.LockedBy != "system" || !again.LockedAt.Equal(first.LockedAt) {
+			t.Errorf("relocking = %+v, want the first lock %+v", again, first)
+		}
+		if _, err := fm.LockResource("/tmp/missing", tx); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("locking a missing path: %v, want ErrNotFound", err)
+		}
+		return nil
+	})
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		tx.SetUserID("alice")
+		if _, err := fm.LockResource("/tmp/notes", tx); !errors.Is(err, database.ErrPermissionDenied) {
+			t.Errorf("locking another user's lock: %v, want ErrPermissionDenied", err)
+		}
+		if err := fm.UnlockResource("/tmp/notes", tx, false); !errors.Is(err, database.ErrPermissionDenied) {
+			t.Errorf("unlocking another user's lock: %v, want ErrPermissionDenied", err)
+		}
+		if err := fm.UnlockResource("/tmp/notes", tx, true); err != nil {
+			return err
+		}
+		lock, err := fm.GetLock("/tmp/notes", tx)
+		if err != nil || lock != nil {
+			t.Errorf("lock after a forced unlock = %+v, %v", lock, err)
+		}
+		if err := fm.UnlockResource("/tmp/notes", tx, false); err == nil {
+			t.Error("unlocking an unlocked path succeeded")
+		}
+		return nil
+	})
+
+	if _, err := fm.LockResource("/tmp/notes", nil); !errors.Is(err, database.ErrNoTransaction) {
+		t.Errorf("LockResource with nil tx: got %v, want ErrNoTransaction", err)
+	}
+	if err := fm.UnlockResource("/tmp/notes", nil, true); !errors.Is(err, database.ErrNoTransaction) {
+		t.Errorf("UnlockResource with nil tx: got %v, want ErrNoTransaction", err)
+	}
+}
@@ -0,0 +1,148 @@
+package filesystem
+
+import (
+	"fmt"
+	pathpkg "path"
+	"strings"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// Move renames the resource at srcPath on the branch of tx to dstPath,
+// which may be in another directory. A directory is moved with everything
+// below it. Each moved resource gets a new version at its new path, so its
+// history stays at the old path, and its tags and locks follow it. dstPath
+// must not exist and its parent must be a directory. Move returns the
+// resource's new version.
+func (fm *FileManager) Move(srcPath, dstPath string, tx *database.Transaction) (*schema.Resource, error) {
+	if tx == nil {
+		return nil, fmt.Errorf("%w for move", database.ErrNoTransaction)
+	}
+	srcPath = pathpkg.Clean(srcPath)
+	dstPath = pathpkg.Clean(dstPath)
+	if srcPath == "/" {
+		return nil, fmt.Errorf("cannot move the root directory")
+	}
+	if dstPath == srcPath || strings.HasPrefix(dstPath, srcPath+"/") {
+		return nil, fmt.Errorf("cannot move %s into itself", srcPath)
+	}
+
+	options := branchQueryOptions(tx)
+	condition, args := resourceCondition(options, 2)
+	rows, err := tx.ExecuteQuery(`
+		SELECT `+resourceColumns+`
+		FROM resources
+		WHERE path = $1`+condition, append([]interface{}{srcPath}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query for resource: %w", err)
+	}
+	found, err := scanResources(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("resource %w: %s", database.ErrNotFound, srcPath)
+	}
+	root := found[0]
+
+	exists, err := fm.pathExists(dstPath, tx)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("resource %w: %s", database.ErrAlreadyExists, dstPath)
+	}
+	parentID, err := fm.getDirectoryID(pathpkg.Dir(dstPath), tx, options)
+	if err != nil {
+		return nil, fmt.Errorf("parent directory not found: %w", err)
+	}
+
+	// Collect the subtree before writing so the listing does not include the
+	// moved versions. Parents sort before their children.
+	entries := []*schema.Resource{root}
+	if root.Type == schema.ResourceTypeDirectory {
+		descendants, err := fm.ListSubtree(srcPath, tx, options)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, descendants...)
+	}
+	for _, entry := range entries {
+		if err := fm.CheckLock(entry.Path, tx); err != nil {
+			return nil, err
+		}
+	}
+	if err := fm.CheckLock(dstPath, tx); err != nil {
+		return nil, err
+	}
+
+	branchID := transactionBranch(tx)
+	dirIDs := map[string]string{pathpkg.Dir(dstPath): parentID}
+	now := time.Now()
+	var moved *schema.Resource
+	for i, entry := range entries {
+		newPath := dstPath + strings.TrimPrefix(entry.Path, srcPath)
+		newName := entry.Name
+		if i == 0 {
+			newName = pathpkg.Base(dstPath)
+		}
+
+		prefix := "file"
+		if entry.Type == schema.ResourceTypeDirectory {
+			prefix = "dir"
+		}
+		newID, err := tx.NextID(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Type == schema.ResourceTypeDirectory {
+			dirIDs[newPath] = newID
+		}
+
+		_, err = tx.Execute(`UPDATE resources SET valid_to = $1 WHERE id = $2 AND valid_to IS NULL`, now, entry.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to close old version of %s: %w", entry.Path, err)
+		}
+		_, err = tx.Execute(`
+			INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id, branch_id)
+			SELECT $1, type, $2, $3, $4, content, metadata, $5, $6, branch_id
+			FROM resources WHERE id = $7
+		`, newID, newName, dirIDs[pathpkg.Dir(newPath)], newPath, now, tx.GetID(), entry.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move %s: %w", entry.Path, err)
+		}
+
+		// Tags and locks are keyed by path. Any left at the new path by a
+		// resource that no longer exists there are dropped.
+		oldLineage, newLineage := ResourceLineage(branchID, entry.Path), ResourceLineage(branchID, newPath)
+		if _, err := tx.Execute(`DELETE FROM tags WHERE resource_lineage = $1`, newLineage); err != nil {
+			return nil, fmt.Errorf("failed to move tags of %s: %w", entry.Path, err)
+		}
+		if _, err := tx.Execute(`UPDATE tags SET resource_lineage = $1 WHERE resource_lineage = $2`, newLineage, oldLineage); err != nil {
+			return nil, fmt.Errorf("failed to move tags of %s: %w", entry.Path, err)
+		}
+		if _, err := tx.Execute(`DELETE FROM locks WHERE branch_id = $1 AND path = $2`, branchID, newPath); err != nil {
+			return nil, fmt.Errorf("failed to move lock of %s: %w", entry.Path, err)
+		}
+		if _, err := tx.Execute(`UPDATE locks SET path = $1 WHERE branch_id = $2 AND path = $3`, newPath, branchID, entry.Path); err != nil {
+			return nil, fmt.Errorf("failed to move lock of %s: %w", entry.Path, err)
+		}
+
+		if i == 0 {
+			moved = &schema.Resource{
+				ID:            newID,
+				Type:          entry.Type,
+				Name:          newName,
+				ParentID:      parentID,
+				Path:          newPath,
+				Metadata:      entry.Metadata,
+				ValidFrom:     now,
+				TransactionID: tx.GetID(),
+			}
+		}
+	}
+
+	return moved, nil
+}
@@ -0,0 +1,115 @@
+package filesystem
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+func TestMoveDirectory(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, []string{"/tmp/src", "/tmp/src/sub", "/tmp/dst"}, []string{"/tmp/src/a", "/tmp/src/sub/b"})
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		if err := fm.TagResource("/tmp/src/a", "keep", tx); err != nil {
+			return err
+		}
+		_, err := fm.LockResource("/tmp/src/sub/b", tx)
+		return err
+	})
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		moved, err := fm.Move("/tmp/src", "/tmp/dst/renamed", tx)
+		if err != nil {
+			return err
+		}
+		if moved.Path != "/tmp/dst/renamed" || moved.Name != "renamed" {
+			t.Errorf("moved = %s named %s", moved.Path, moved.Name)
+		}
+		return nil
+	})
+
+	subtree, err := fm.ListSubtree("/tmp/dst", nil, mainOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/tmp/dst/renamed", "/tmp/dst/renamed/a", "/tmp/dst/renamed/sub", "/tmp/dst/renamed/sub/b"}
+	if got := resourcePaths(subtree); !reflect.DeepEqual(got, want) {
+		t.Errorf("subtree after move = %v, want %v", got, want)
+	}
+	if got := readContent(t, fm, "/tmp/dst/renamed/sub/b"); got != "/tmp/src/sub/b" {
+		t.Errorf("moved content = %q", got)
+	}
+	if _, err := fm.Stat("/tmp/src", nil, mainOptions); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("old path after move: %v, want ErrNotFound", err)
+	}
+
+	// Children are found through the moved directory, not the old one
+	entries, err := fm.ListDirectory("/tmp/dst/renamed/sub", nil, mainOptions)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("ListDirectory of the moved subdirectory = %v, %v", resourcePaths(entries), err)
+	}
+
+	tags, err := fm.GetTags("/tmp/dst/renamed/a", "main", nil)
+	if err != nil || !reflect.DeepEqual(tags, []string{"keep"}) {
+		t.Errorf("tags after move = %v, %v; want [keep]", tags, err)
+	}
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		lock, err := fm.GetLock("/tmp/dst/renamed/sub/b", tx)
+		if err != nil || lock == nil {
+			t.Errorf("lock after move = %v, %v", lock, err)
+		}
+		return nil
+	})
+}
+
+func TestMoveErrors(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, []string{"/tmp/dir"}, []string{"/tmp/a", "/tmp/b"})
+
+	tests := []struct {
+		name     string
+		src, dst string
+		want     error
+	}{
+		{"root", "/", "/tmp/root", nil},
+		{"into itself", "/tmp/dir", "/tmp/dir/inner", nil},
+		{"missing source", "/tmp/missing", "/tmp/c", database.ErrNotFound},
+		{"existing destination", "/tmp/a", "/tmp/b", database.ErrAlreadyExists},
+		{"missing parent", "/tmp/a", "/nowhere/a", database.ErrNotFound},
+	}
+	for _, tt := range tests {
+		err := fm.db.WithTransaction(func(tx *database.Transaction) error {
+			tx.SetBranchID("main")
+			_, err := fm.Move(tt.src, tt.dst, tx)
+			return err
+		})
+		if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Errorf("%s: Move = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := fm.Move("/tmp/a", "/tmp/c", nil); !errors.Is(err, database.ErrNoTransaction) {
+		t.Errorf("move without a transaction: %v, want ErrNoTransaction", err)
+	}
+}
+
+func TestMoveLockedByOtherUser(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, []string{"/tmp/dir"}, []string{"/tmp/dir/locked"})
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		_, err := fm.LockResource("/tmp/dir/locked", tx)
+		return err
+	})
+
+	err := fm.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID("main")
+		tx.SetUserID("alice")
+		_, err := fm.Move("/tmp/dir", "/tmp/other", tx)
+		return err
+	})
+	if !errors.Is(err, database.ErrPermissionDenied) {
+		t.Errorf("moving a directory holding another user's lock: %v, want ErrPermissionDenied", err)
+	}
+}
@@ -0,0 +1,76 @@
+package filesystem
+
+import (
+	"fmt"
+	pathpkg "path"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// Remove deletes the resource at path from the branch of tx by closing its
+// current version. A directory with anything below it is only removed when
+// recursive is set, and then everything below it goes too. The closed
+// versions stay in the history of their paths, while tags and locks at the
+// removed paths are dropped. Remove returns the removed versions, parents
+// before their children.
+func (fm *FileManager) Remove(path string, recursive bool, tx *database.Transaction) ([]*schema.Resource, error) {
+	if tx == nil {
+		return nil, fmt.Errorf("%w for remove", database.ErrNoTransaction)
+	}
+	path = pathpkg.Clean(path)
+	if path == "/" {
+		return nil, fmt.Errorf("cannot remove the root directory")
+	}
+
+	options := branchQueryOptions(tx)
+	condition, args := resourceCondition(options, 2)
+	rows, err := tx.ExecuteQuery(`
+		SELECT `+resourceColumns+`
+		FROM resources
+		WHERE path = $1`+condition, append([]interface{}{path}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query for resource: %w", err)
+	}
+	found, err := scanResources(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("resource %w: %s", database.ErrNotFound, path)
+	}
+
+	entries := found[:1]
+	if found[0].Type == schema.ResourceTypeDirectory {
+		descendants, err := fm.ListSubtree(path, tx, options)
+		if err != nil {
+			return nil, err
+		}
+		if len(descendants) > 0 && !recursive {
+			return nil, fmt.Errorf("directory not empty: %s", path)
+		}
+		entries = append(entries, descendants...)
+	}
+	for _, entry := range entries {
+		if err := fm.CheckLock(entry.Path, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	branchID := transactionBranch(tx)
+	now := time.Now()
+	for _, entry := range entries {
+		_, err := tx.Execute(`UPDATE resources SET valid_to = $1 WHERE id = $2 AND valid_to IS NULL`, now, entry.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", entry.Path, err)
+		}
+		if _, err := tx.Execute(`DELETE FROM tags WHERE resource_lineage = $1`, ResourceLineage(branchID, entry.Path)); err != nil {
+			return nil, fmt.Errorf("failed to remove tags of %s: %w", entry.Path, err)
+		}
+		if _, err := tx.Execute(`DELETE FROM locks WHERE branch_id = $1 AND path = $2`, branchID, entry.Path); err != nil {
+			return nil, fmt.Errorf("failed to remove lock of %s: %w", entry.Path, err)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,71 @@
+package filesystem
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+func TestRemove(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, []string{"/tmp/dir", "/tmp/empty"}, []string{"/tmp/dir/a", "/tmp/file"})
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		return fm.TagResource("/tmp/dir/a", "old", tx)
+	})
+
+	err := fm.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID("main")
+		_, err := fm.Remove("/tmp/dir", false, tx)
+		return err
+	})
+	if err == nil {
+		t.Error("removing a non-empty directory without recursive succeeded")
+	}
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		if _, err := fm.Remove("/tmp/empty", false, tx); err != nil {
+			return err
+		}
+		if _, err := fm.Remove("/tmp/file", false, tx); err != nil {
+			return err
+		}
+		removed, err := fm.Remove("/tmp/dir", true, tx)
+		if err != nil {
+			return err
+		}
+		if got, want := resourcePaths(removed), []string{"/tmp/dir", "/tmp/dir/a"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("removed %v, want %v", got, want)
+		}
+		return nil
+	})
+
+	entries, err := fm.ListDirectory("/tmp", nil, mainOptions)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("/tmp after remove = %v, %v", resourcePaths(entries), err)
+	}
+
+	// A new resource at a removed path does not inherit its tags
+	makeTree(t, fm, []string{"/tmp/dir"}, []string{"/tmp/dir/a"})
+	if tags, err := fm.GetTags("/tmp/dir/a", "main", nil); err != nil || len(tags) != 0 {
+		t.Errorf("tags of a recreated path = %v, %v", tags, err)
+	}
+}
+
+func TestRemoveErrors(t *testing.T) {
+	fm := newTestManager(t)
+	for _, path := range []string{"/", "/tmp/missing"} {
+		err := fm.db.WithTransaction(func(tx *database.Transaction) error {
+			tx.SetBranchID("main")
+			_, err := fm.Remove(path, true, tx)
+			return err
+		})
+		if err == nil {
+			t.Errorf("Remove(%s) succeeded", path)
+		}
+	}
+	if _, err := fm.Remove("/tmp", true, nil); !errors.Is(err, database.ErrNoTransaction) {
+		t.Errorf("remove without a transaction: %v, want ErrNoTransaction", err)
+	}
+}
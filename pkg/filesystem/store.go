@@ -0,0 +1,305 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// Content store names, as given to SetContentStore and recorded in the
+// metadata of file versions whose content is kept in a store
+const (
+	StoreInline = "inline"
+	StoreDir    = "dir"
+)
+
+// ContentStore keeps file content apart from the resources table, addressed
+// by the checksum of the content. Putting content that is already stored is
+// not an error.
+type ContentStore interface {
+	Put(checksum string, data []byte) error
+	Get(checksum string) ([]byte, error)
+}
+
+// checksumPattern is the form of the checksums content is stored under
+var checksumPattern = regexp.MustCompile(`^[0-9a-f]{16,}$`)
+
+// InlineStore keeps content inline in the database, in the blobs table, so
+// that each distinct content is stored once however many versions use it.
+// Content put through a transaction commits or rolls back with it.
+type InlineStore struct {
+	db *database.Connection
+	tx *database.Transaction
+}
+
+// NewInlineStore creates an InlineStore that works within tx, or directly on
+// db when tx is nil
+func NewInlineStore(db *database.Connection, tx *database.Transaction) *InlineStore {
+	return &InlineStore{db: db, tx: tx}
+}
+
+// Put stores data under checksum unless the blobs table already has it
+func (s *InlineStore) Put(checksum string, data []byte) error {
+	query := `
+		INSERT INTO blobs (checksum, data, created_at)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (SELECT 1 FROM blobs WHERE checksum = $4)
+	`
+	args := []interface{}{checksum, data, time.Now(), checksum}
+
+	var err error
+	if s.tx != nil {
+		_, err = s.tx.Execute(query, args...)
+	} else {
+		_, err = s.db.ExecuteStatement(query, args...)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store content %s: %w", checksum, err)
+	}
+	return nil
+}
+
+// Get returns the content stored under checksum
+func (s *InlineStore) Get(checksum string) ([]byte, error) {
+	query := `SELECT data FROM blobs WHERE checksum = $1`
+
+	var result *database.QueryResult
+	var err error
+	if s.tx != nil {
+		result, err = s.tx.Query(query, database.QueryOptions{}, checksum)
+	} else {
+		result, err = s.db.Query(query, database.QueryOptions{}, checksum)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content %s: %w", checksum, err)
+	}
+	if result.Count == 0 {
+		return nil, fmt.Errorf("content %w: %s", database.ErrNotFound, checksum)
+	}
+	return result.GetBytesByName(0, "data")
+}
+
+// DirStore keeps content as files in a directory on the host, named by
+// checksum and spread over subdirectories named by its first two characters
+type DirStore struct {
+	root string
+}
+
+// NewDirStore creates a DirStore rooted at dir, creating dir if needed
+func NewDirStore(dir string) (*DirStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("content store directory must not be empty")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create content store directory: %w", err)
+	}
+	return &DirStore{root: dir}, nil
+}
+
+// Root returns the directory the store keeps its files in
+func (s *DirStore) Root() string {
+	return s.root
+}
+
+// Put writes data to the file for checksum unless it already exists. The
+// data is written to a temporary file first and renamed into place, so a
+// reader never sees a partial file.
+func (s *DirStore) Put(checksum string, data []byte) error {
+	path, err := s.path(checksum)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to store content %s: %w", checksum, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+checksum+".*")
+	if err != nil {
+		return fmt.Errorf("failed to store content %s: %w", checksum, err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to store content %s: %w", checksum, err)
+	}
+	return nil
+}
+
+// Get reads the file for checksum
+func (s *DirStore) Get(checksum string) ([]byte, error) {
+	path, err := s.path(checksum)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("content %w: %s", database.ErrNotFound, checksum)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content %s: %w", checksum, err)
+	}
+	return data, nil
+}
+
+// path returns the file content with the given checksum is kept in
+func (s *DirStore) path(checksum string) (string, error) {
+	if !checksumPattern.MatchString(checksum) {
+		return "", fmt.Errorf("invalid content checksum: %q", checksum)
+	}
+	return filepath.Join(s.root, checksum[:2], checksum), nil
+}
+
+// ParseContentStore parses a content store setting: "table" (or empty) to
+// keep content in the resources table, "inline", or "dir:<path>". It returns
+// the store name and directory to pass to SetContentStore.
+func ParseContentStore(setting string) (string, string, error) {
+	switch {
+	case setting == "" || setting == "table":
+		return "", "", nil
+	case setting == StoreInline:
+		return StoreInline, "", nil
+	case strings.HasPrefix(setting, StoreDir+":"):
+		return StoreDir, strings.TrimPrefix(setting, StoreDir+":"), nil
+	default:
+		return "", "", fmt.Errorf("invalid content store %q (use table, inline or dir:<path>)", setting)
+	}
+}
+
+// SetContentStore selects where content written from now on is kept: in the
+// resources table when name is empty, in the blobs table for StoreInline, or
+// under dir for StoreDir. Content already written stays where it is and is
+// still read from there, so a directory store stays configured when another
+// store is selected.
+func (fm *FileManager) SetContentStore(name, dir string) error {
+	switch name {
+	case "", StoreInline:
+		fm.storeName = name
+	case StoreDir:
+		store, err := NewDirStore(dir)
+		if err != nil {
+			return err
+		}
+		fm.storeName, fm.dirStore = name, store
+	default:
+		return fmt.Errorf("unknown content store: %s", name)
+	}
+	return nil
+}
+
+// ContentStoreSetting returns the current content store in the form
+// ParseContentStore accepts
+func (fm *FileManager) ContentStoreSetting() string {
+	switch fm.storeName {
+	case "":
+		return "table"
+	case StoreDir:
+		return StoreDir + ":" + fm.dirStore.Root()
+	default:
+		return fm.storeName
+	}
+}
+
+// contentStore returns the store with the given name, working within tx for
+// the inline store
+func (fm *FileManager) contentStore(name string, tx *database.Transaction) (ContentStore, error) {
+	switch name {
+	case StoreInline:
+		return NewInlineStore(fm.db, tx), nil
+	case StoreDir:
+		if fm.dirStore == nil {
+			return nil, fmt.Errorf("content is kept in a directory store, but none is configured")
+		}
+		return fm.dirStore, nil
+	default:
+		return nil, fmt.Errorf("unknown content store: %s", name)
+	}
+}
+
+// storeContent returns the bytes to write to the content column for a new
+// version and sets the encoding and store recorded in its metadata. Content
+// bound for a content store is put there as is and the column is left
+// empty; otherwise it is encoded for the column.
+func (fm *FileManager) storeContent(content []byte, metadata *schema.ResourceMetadata, tx *database.Transaction) ([]byte, error) {
+	if fm.storeName == "" {
+		stored, encoding, err := fm.encodeContent(content)
+		if err != nil {
+			return nil, err
+		}
+		metadata.Encoding, metadata.Store, metadata.Chunks = encoding, "", nil
+		return stored, nil
+	}
+
+	store, err := fm.contentStore(fm.storeName, tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Put(metadata.Checksum, content); err != nil {
+		return nil, err
+	}
+	metadata.Encoding, metadata.Store, metadata.Chunks = "", fm.storeName, nil
+	return nil, nil
+}
+
+// ShareContent returns the metadata for a copy of a file version that
+// shares its content instead of repeating it. Content kept in the content
+// column of the version's row is decoded and put in the inline store, where
+// every copy finds it by checksum, and the metadata names that store; the
+// copy's content column is then left empty. Content already in a store, or
+// without a checksum to find it by, is returned as is.
+func (fm *FileManager) ShareContent(stored []byte, metadata schema.ResourceMetadata, tx *database.Transaction) (schema.ResourceMetadata, error) {
+	if metadata.Store != "" || metadata.Checksum == "" {
+		return metadata, nil
+	}
+	content, err := DecodeContent(stored, metadata.Encoding)
+	if err != nil {
+		return metadata, err
+	}
+	if err := NewInlineStore(fm.db, tx).Put(metadata.Checksum, content); err != nil {
+		return metadata, err
+	}
+	metadata.Encoding, metadata.Store = "", StoreInline
+	return metadata, nil
+}
+
+// LoadContent returns the content of a file version from the content column
+// of its row and its metadata, reading it from the content store the
+// metadata names if there is one, piece by piece when it was stored in
+// chunks
+func (fm *FileManager) LoadContent(stored []byte, metadata schema.ResourceMetadata, tx *database.Transaction) ([]byte, error) {
+	if metadata.Store == "" {
+		return DecodeContent(stored, metadata.Encoding)
+	}
+	store, err := fm.contentStore(metadata.Store, tx)
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata.Chunks) == 0 {
+		return store.Get(metadata.Checksum)
+	}
+
+	content := make([]byte, 0, metadata.Size)
+	for _, checksum := range metadata.Chunks {
+		chunk, err := store.Get(checksum)
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, chunk...)
+	}
+	return content, nil
+}
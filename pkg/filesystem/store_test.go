@@ -0,0 +1,290 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+func TestParseContentStore(t *testing.T) {
+	tests := []struct {
+		setting   string
+		name, dir string
+		ok        bool
+	}{
+		{"", "", "", true},
+		{"table", "", "", true},
+		{"inline", StoreInline, "", true},
+		{"dir:/var/blobs", StoreDir, "/var/blobs", true},
+		{"s3:bucket", "", "", false},
+	}
+	for _, tt := range tests {
+		name, dir, err := ParseContentStore(tt.setting)
+		if (err == nil) != tt.ok || name != tt.name || dir != tt.dir {
+			t.Errorf("ParseContentStore(%q) = %q, %q, %v", tt.setting, name, dir, err)
+		}
+	}
+}
+
+func TestContentStoreSetting(t *testing.T) {
+	fm := newTestManager(t)
+	dir := t.TempDir()
+	for _, setting := range []string{"table", "inline", "dir:" + dir, "table"} {
+		name, storeDir, err := ParseContentStore(setting)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fm.SetContentStore(name, storeDir); err != nil {
+			t.Fatal(err)
+		}
+		if got := fm.ContentStoreSetting(); got != setting {
+			t.Errorf("ContentStoreSetting = %q, want %q", got, setting)
+		}
+	}
+	if err := fm.SetContentStore("s3", ""); err == nil {
+		t.Error("unknown content store accepted")
+	}
+	if err := fm.SetContentStore(StoreDir, ""); err == nil {
+		t.Error("directory store without a directory accepted")
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	store, err := NewDirStore(filepath.Join(t.TempDir(), "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := util.CalculateChecksum([]byte("hello"))
+
+	if _, err := store.Get(checksum); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Get before Put: %v, want ErrNotFound", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.Put(checksum, []byte("hello")); err != nil {
+			t.Fatalf("Put #%d: %v", i+1, err)
+		}
+	}
+	data, err := store.Get(checksum)
+	if err != nil || string(data) != "hello" {
+		t.Errorf("Get = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(store.Root(), checksum[:2], checksum)); err != nil {
+		t.Errorf("content is not under its checksum prefix: %v", err)
+	}
+
+	for _, bad := range []string{"", "../../etc/passwd", "ABCDEF0123456789", "0123"} {
+		if err := store.Put(bad, []byte("x")); err == nil {
+			t.Errorf("Put accepted checksum %q", bad)
+		}
+	}
+}
+
+func TestInlineStore(t *testing.T) {
+	fm := newTestManager(t)
+	store := NewInlineStore(fm.db, nil)
+	checksum := util.CalculateChecksum([]byte("hello"))
+
+	if _, err := store.Get(checksum); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Get before Put: %v, want ErrNotFound", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.Put(checksum, []byte("hello")); err != nil {
+			t.Fatalf("Put #%d: %v", i+1, err)
+		}
+	}
+	data, err := store.Get(checksum)
+	if err != nil || string(data) != "hello" {
+		t.Errorf("Get = %q, %v", data, err)
+	}
+
+	// Content put in a transaction that rolls back is not kept
+	other := util.CalculateChecksum([]byte("gone"))
+	fm.db.WithTransaction(func(tx *database.Transaction) error {
+		if err := NewInlineStore(fm.db, tx).Put(other, []byte("gone")); err != nil {
+			t.Fatal(err)
+		}
+		return errors.New("roll back")
+	})
+	if _, err := store.Get(other); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("content from a rolled back transaction: %v, want ErrNotFound", err)
+	}
+}
+
+func TestFilesInContentStores(t *testing.T) {
+	for _, setting := range []string{"inline", "dir:" + t.TempDir()} {
+		t.Run(setting, func(t *testing.T) {
+			fm := newTestManager(t)
+			name, dir, _ := ParseContentStore(setting)
+			if err := fm.SetContentStore(name, dir); err != nil {
+				t.Fatal(err)
+			}
+			makeTree(t, fm, nil, []string{"/tmp/a"})
+
+			file, err := fm.GetFile("/tmp/a", nil, mainOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(file.Content) != "/tmp/a" || file.Metadata.Store != name {
+				t.Errorf("file = %q in store %q, want /tmp/a in %q", file.Content, file.Metadata.Store, name)
+			}
+
+			// Content written before switching back is still read from the store
+			if err := fm.SetContentStore("", ""); err != nil {
+				t.Fatal(err)
+			}
+			if got := readContent(t, fm, "/tmp/a"); got != "/tmp/a" {
+				t.Errorf("content after switching stores = %q", got)
+			}
+		})
+	}
+}
+
+func TestShareContent(t *testing.T) {
+	fm := newTestManager(t)
+	fm.SetCompression(CompressionConfig{Enabled: true, Threshold: 1})
+	content := []byte("shared content shared content shared content")
+	stored, encoding, err := fm.encodeContent(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := schema.NewResourceMetadata("system", schema.DefaultFilePermissions)
+	metadata.Checksum, metadata.Encoding = util.CalculateChecksum(content), encoding
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		shared, err := fm.ShareContent(stored, metadata, tx)
+		if err != nil {
+			return err
+		}
+		if shared.Store != StoreInline || shared.Encoding != "" {
+			t.Errorf("shared metadata store %q encoding %q", shared.Store, shared.Encoding)
+		}
+		got, err := fm.LoadContent(nil, shared, tx)
+		if err != nil || string(got) != string(content) {
+			t.Errorf("LoadContent of shared content = %q, %v", got, err)
+		}
+
+		// Sharing content already in a store changes nothing
+		again, err := fm.ShareContent(nil, shared, tx)
+		if err != nil || !reflect.DeepEqual(again, shared) {
+			t.Errorf("sharing again = %+v, %v", again, err)
+		}
+		return nil
+	})
+}
+
+func TestCollectGarbage(t *testing.T) {
+	fm := newTestManager(t)
+	if err := fm.SetContentStore(StoreInline, ""); err != nil {
+		t.Fatal(err)
+	}
+	makeTree(t, fm, nil, []string{"/tmp/a"})
+	before := time.Now()
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		_, err := fm.UpdateFile("/tmp/a", []byte("second version"), tx)
+		return err
+	})
+	orphan := util.CalculateChecksum([]byte("orphan"))
+	if err := NewInlineStore(fm.db, nil).Put(orphan, []byte("orphan")); err != nil {
+		t.Fatal(err)
+	}
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		unused, err := fm.UnusedContent(tx)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(unused, []string{orphan}) {
+			t.Errorf("UnusedContent = %v, want only the orphan %s", unused, orphan)
+		}
+		n, err := fm.CollectGarbage(tx)
+		if err != nil || n != 1 {
+			t.Errorf("CollectGarbage = %d, %v; want 1", n, err)
+		}
+		return nil
+	})
+
+	// Both versions of the file are still readable
+	if got := readContent(t, fm, "/tmp/a"); got != "second version" {
+		t.Errorf("content after collection = %q", got)
+	}
+	old, err := fm.GetFile("/tmp/a", nil, database.QueryOptions{BranchID: "main", PointInTime: &before})
+	if err != nil || string(old.Content) != "/tmp/a" {
+		t.Errorf("first version after collection = %v, %v", old, err)
+	}
+	if _, err := fm.CollectGarbage(nil); !errors.Is(err, database.ErrNoTransaction) {
+		t.Errorf("CollectGarbage with nil tx: got %v, want ErrNoTransaction", err)
+	}
+}
+
+// TestCollectGarbageTableStore collects with the table store active, where
+// copies share content through the inline store
+func TestCollectGarbageTableStore(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, nil, []string{"/tmp/a"})
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		_, err := fm.CopyFile("/tmp/a", "/tmp/b", schema.NewResourceMetadata("system", schema.DefaultFilePermissions), tx)
+		return err
+	})
+	orphan := util.CalculateChecksum([]byte("orphan"))
+	if err := NewInlineStore(fm.db, nil).Put(orphan, []byte("orphan")); err != nil {
+		t.Fatal(err)
+	}
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		n, err := fm.CollectGarbage(tx)
+		if err != nil || n != 1 {
+			t.Errorf("CollectGarbage = %d, %v; want 1", n, err)
+		}
+		return nil
+	})
+	if got := readContent(t, fm, "/tmp/b"); got != "/tmp/a" {
+		t.Errorf("copy after collection = %q", got)
+	}
+}
+
+func TestCollectGarbageDirStore(t *testing.T) {
+	fm := newTestManager(t)
+	if err := fm.SetContentStore(StoreDir, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	orphan := util.CalculateChecksum([]byte("orphan"))
+	if err := NewInlineStore(fm.db, nil).Put(orphan, []byte("orphan")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := fm.db.WithTransaction(func(tx *database.Transaction) error {
+		_, err := fm.CollectGarbage(tx)
+		return err
+	})
+	if err == nil {
+		t.Fatal("CollectGarbage with a directory store succeeded")
+	}
+	if _, err := NewInlineStore(fm.db, nil).Get(orphan); err != nil {
+		t.Errorf("content deleted despite the error: %v", err)
+	}
+}
+
+// TestUnusedContentMetadataVersion reads version metadata it cannot
+// understand, which must fail rather than leave its content unused
+func TestUnusedContentMetadataVersion(t *testing.T) {
+	fm := newTestManager(t)
+	if err := fm.SetContentStore(StoreInline, ""); err != nil {
+		t.Fatal(err)
+	}
+	makeTree(t, fm, nil, []string{"/tmp/a"})
+	_, err := fm.db.ExecuteStatement(`UPDATE resources SET metadata = '{"version": 99, "store": "inline"}' WHERE path = '/tmp/a'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if unused, err := fm.UnusedContent(nil); err == nil {
+		t.Errorf("UnusedContent = %v, want an error for metadata version 99", unused)
+	}
+}
@@ -0,0 +1,79 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// StreamChunkSize is the size of the pieces CreateFileReader reads content
+// in. Content that fits in one piece is stored like content given whole;
+// larger content is kept in a content store piece by piece.
+const StreamChunkSize = 1 << 20
+
+// CreateFileReader creates a new file with content read from r, computing
+// its size and checksum while reading. Content larger than StreamChunkSize
+// is never held in memory whole: it is put in the content store one chunk at
+// a time, in the inline store when content is otherwise kept in the
+// resources table, and its metadata lists the chunks.
+func (fm *FileManager) CreateFileReader(path string, r io.Reader, tx *database.Transaction, owner string) (*File, error) {
+	metadata := schema.NewResourceMetadata(owner, schema.DefaultFilePermissions)
+	return fm.insertFile(path, metadata, tx, func(metadata *schema.ResourceMetadata) ([]byte, error) {
+		return fm.streamContent(path, r, metadata, tx)
+	})
+}
+
+// streamContent stores the content read from r and completes its metadata,
+// returning the bytes for the content column
+func (fm *FileManager) streamContent(path string, r io.Reader, metadata *schema.ResourceMetadata, tx *database.Transaction) ([]byte, error) {
+	buf := make([]byte, StreamChunkSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		content := buf[:n]
+		metadata.Size = int64(n)
+		metadata.Checksum = util.CalculateChecksum(content)
+		return fm.storeContent(content, metadata, tx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content for %s: %w", path, err)
+	}
+
+	storeName := fm.storeName
+	if storeName == "" {
+		storeName = StoreInline
+	}
+	store, err := fm.contentStore(storeName, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	var size int64
+	var chunks []string
+	for n > 0 {
+		chunk := buf[:n]
+		hash.Write(chunk)
+		size += int64(n)
+
+		checksum := util.CalculateChecksum(chunk)
+		if err := store.Put(checksum, chunk); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, checksum)
+
+		n, err = io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("failed to read content for %s: %w", path, err)
+		}
+	}
+
+	metadata.Size = size
+	metadata.Checksum = hex.EncodeToString(hash.Sum(nil))
+	metadata.Encoding, metadata.Store, metadata.Chunks = "", storeName, chunks
+	return nil, nil
+}
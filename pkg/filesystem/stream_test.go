@@ -0,0 +1,115 @@
+package filesystem
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// streamFile creates path from a reader of content and returns the file as
+// read back
+func streamFile(t *testing.T, fm *FileManager, path string, content []byte) *File {
+	t.Helper()
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		_, err := fm.CreateFileReader(path, bytes.NewReader(content), tx, "system")
+		return err
+	})
+	file, err := fm.GetFile(path, nil, database.QueryOptions{BranchID: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// checkStreamed checks a file's content, size and checksum
+func checkStreamed(t *testing.T, file *File, content []byte) {
+	t.Helper()
+	if !bytes.Equal(file.Content, content) {
+		t.Errorf("%s: content changed (%d bytes read back, %d written)", file.Path, len(file.Content), len(content))
+	}
+	if file.Metadata.Size != int64(len(content)) {
+		t.Errorf("%s: size = %d, want %d", file.Path, file.Metadata.Size, len(content))
+	}
+	if want := util.CalculateChecksum(content); file.Metadata.Checksum != want {
+		t.Errorf("%s: checksum = %s, want %s", file.Path, file.Metadata.Checksum, want)
+	}
+}
+
+// largeContent returns content of n bytes that does not repeat chunk to
+// chunk
+func largeContent(n int) []byte {
+	content := make([]byte, n)
+	for i := range content {
+		content[i] = byte(i * 7 / 13)
+	}
+	return content
+}
+
+func TestCreateFileReaderSmall(t *testing.T) {
+	fm := newTestManager(t)
+	content := []byte("hello, world\n")
+
+	file := streamFile(t, fm, "/small.txt", content)
+	checkStreamed(t, file, content)
+	if file.Metadata.Store != "" || len(file.Metadata.Chunks) != 0 {
+		t.Errorf("small content kept in store %q in %d chunk(s), want the resources table", file.Metadata.Store, len(file.Metadata.Chunks))
+	}
+}
+
+func TestCreateFileReaderChunked(t *testing.T) {
+	fm := newTestManager(t)
+	content := largeContent(2*StreamChunkSize + 12345)
+
+	file := streamFile(t, fm, "/large.bin", content)
+	checkStreamed(t, file, content)
+	if file.Metadata.Store != StoreInline {
+		t.Errorf("store = %q, want %q", file.Metadata.Store, StoreInline)
+	}
+	if len(file.Metadata.Chunks) != 3 {
+		t.Errorf("stored in %d chunk(s), want 3", len(file.Metadata.Chunks))
+	}
+}
+
+func TestCreateFileReaderDirStore(t *testing.T) {
+	fm := newTestManager(t)
+	if err := fm.SetContentStore(StoreDir, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	content := largeContent(StreamChunkSize + 1)
+
+	file := streamFile(t, fm, "/large.bin", content)
+	checkStreamed(t, file, content)
+	if file.Metadata.Store != StoreDir || len(file.Metadata.Chunks) != 2 {
+		t.Errorf("stored in %q in %d chunk(s), want %q in 2", file.Metadata.Store, len(file.Metadata.Chunks), StoreDir)
+	}
+}
+
+// failingReader returns n bytes and then an error
+type failingReader struct{ n int }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, errors.New("disk on fire")
+	}
+	n := min(len(p), r.n)
+	r.n -= n
+	return n, nil
+}
+
+func TestCreateFileReaderReadError(t *testing.T) {
+	fm := newTestManager(t)
+	for _, n := range []int{10, StreamChunkSize + 10} {
+		err := fm.db.WithTransaction(func(tx *database.Transaction) error {
+			tx.SetBranchID("main")
+			_, err := fm.CreateFileReader("/broken", &failingReader{n: n}, tx, "system")
+			return err
+		})
+		if err == nil || errors.Is(err, io.EOF) {
+			t.Errorf("after %d bytes: err = %v, want the read error", n, err)
+		}
+	}
+}
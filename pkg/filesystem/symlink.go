@@ -0,0 +1,49 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	pathpkg "path"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// MaxSymlinkHops is how many symlinks ResolveSymlink follows in a chain
+// before giving up
+const MaxSymlinkHops = 40
+
+// ErrTooManyLinks is returned when resolving a symlink takes more than
+// MaxSymlinkHops links, which usually means the links form a loop
+var ErrTooManyLinks = errors.New("too many levels of symbolic links")
+
+// SymlinkTargetPath returns the path a symlink points to. A relative target
+// is taken relative to the directory holding the link.
+func SymlinkTargetPath(linkPath, target string) string {
+	if !pathpkg.IsAbs(target) {
+		target = pathpkg.Join(pathpkg.Dir(linkPath), target)
+	}
+	return pathpkg.Clean(target)
+}
+
+// ResolveSymlink returns the resource a symlink points to, following
+// symlinks that point to other symlinks, read as of options.PointInTime when
+// set, on options.BranchID when set. A link whose target does not exist
+// gives an error wrapping database.ErrNotFound; a chain longer than
+// MaxSymlinkHops gives ErrTooManyLinks.
+func (fm *FileManager) ResolveSymlink(link *schema.Resource, tx *database.Transaction, options database.QueryOptions) (*schema.Resource, error) {
+	current := link
+	for hops := 0; current.Type == schema.ResourceTypeSymlink; hops++ {
+		if hops == MaxSymlinkHops {
+			return nil, fmt.Errorf("%w: %s", ErrTooManyLinks, link.Path)
+		}
+		metadata, err := schema.NormalizeMetadata(current.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata for %s: %w", current.Path, err)
+		}
+		if current, err = fm.Stat(SymlinkTargetPath(current.Path, metadata.SymlinkTarget), tx, options); err != nil {
+			return nil, err
+		}
+	}
+	return current, nil
+}
@@ -0,0 +1,97 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+func TestSymlinkTargetPath(t *testing.T) {
+	tests := []struct {
+		link, target, want string
+	}{
+		{"/tmp/link", "/usr/file", "/usr/file"},
+		{"/tmp/link", "file", "/tmp/file"},
+		{"/tmp/dir/link", "../file", "/tmp/file"},
+		{"/link", "./a/../b", "/b"},
+	}
+	for _, tt := range tests {
+		if got := SymlinkTargetPath(tt.link, tt.target); got != tt.want {
+			t.Errorf("SymlinkTargetPath(%q, %q) = %q, want %q", tt.link, tt.target, got, tt.want)
+		}
+	}
+}
+
+// makeSymlinks creates a symlink at each key of links pointing to its value
+func makeSymlinks(t *testing.T, fm *FileManager, links map[string]string) {
+	t.Helper()
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		for path, target := range links {
+			metadata := schema.NewResourceMetadata("system", schema.DefaultFilePermissions)
+			metadata.SymlinkTarget = target
+			if err := insertResource(fm, tx, schema.ResourceTypeSymlink, path, metadata); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func TestResolveSymlink(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, nil, []string{"/usr/file"})
+	makeSymlinks(t, fm, map[string]string{
+		"/tmp/direct":   "/usr/file",
+		"/tmp/relative": "../usr/file",
+		"/tmp/chain":    "direct",
+		"/tmp/dangling": "/usr/missing",
+		"/tmp/loop1":    "loop2",
+		"/tmp/loop2":    "loop1",
+	})
+
+	resolve := func(path string) (*schema.Resource, error) {
+		link, err := fm.Stat(path, nil, mainOptions)
+		if err != nil {
+			t.Fatalf("stat %s: %v", path, err)
+		}
+		return fm.ResolveSymlink(link, nil, mainOptions)
+	}
+	for _, path := range []string{"/tmp/direct", "/tmp/relative", "/tmp/chain", "/usr/file"} {
+		target, err := resolve(path)
+		if err != nil || target.Path != "/usr/file" {
+			t.Errorf("ResolveSymlink(%s) = %v, %v; want /usr/file", path, target, err)
+		}
+	}
+	if _, err := resolve("/tmp/dangling"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("dangling link: %v, want ErrNotFound", err)
+	}
+	if _, err := resolve("/tmp/loop1"); !errors.Is(err, ErrTooManyLinks) {
+		t.Errorf("loop: %v, want ErrTooManyLinks", err)
+	}
+}
+
+func TestResolveSymlinkHopLimit(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, nil, []string{"/usr/file"})
+
+	// A chain of exactly MaxSymlinkHops links resolves; one more does not
+	links := map[string]string{fmt.Sprintf("/tmp/l%d", MaxSymlinkHops+1): "/usr/file"}
+	for i := 1; i <= MaxSymlinkHops; i++ {
+		links[fmt.Sprintf("/tmp/l%d", i)] = fmt.Sprintf("l%d", i+1)
+	}
+	makeSymlinks(t, fm, links)
+
+	for start, want := range map[int]error{1: ErrTooManyLinks, 2: nil} {
+		link, err := fm.Stat(fmt.Sprintf("/tmp/l%d", start), nil, mainOptions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = fm.ResolveSymlink(link, nil, mainOptions)
+		if !errors.Is(err, want) {
+			t.Errorf("chain from l%d: %v, want %v", start, err, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package filesystem
+
+import (
+	"fmt"
+	pathpkg "path"
+	"regexp"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// tagPattern matches valid tag labels
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ResourceLineage identifies a resource across all of its versions: every
+// version of the resource at path on a branch shares the same lineage
+func ResourceLineage(branchID, path string) string {
+	return branchID + ":" + pathpkg.Clean(path)
+}
+
+// lineageExpr is the SQL expression for the lineage of a resources row
+const lineageExpr = "r.branch_id || ':' || r.path"
+
+// TagResource attaches a label to an existing resource in the branch of tx.
+// Tagging a resource with a label it already has is a no-op.
+func (fm *FileManager) TagResource(path, tag string, tx *database.Transaction) error {
+	if tx == nil {
+		return fmt.Errorf("%w for tagging", database.ErrNoTransaction)
+	}
+	if !tagPattern.MatchString(tag) {
+		return fmt.Errorf("invalid tag: %s", tag)
+	}
+	path = pathpkg.Clean(path)
+
+	exists, err := fm.pathExists(path, tx)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("resource %w: %s", database.ErrNotFound, path)
+	}
+
+	lineage := ResourceLineage(transactionBranch(tx), path)
+	rows, err := tx.ExecuteQuery(`
+		SELECT 1 FROM tags WHERE resource_lineage = $1 AND tag = $2
+	`, lineage, tag)
+	if err != nil {
+		return fmt.Errorf("failed to query tags: %w", err)
+	}
+	tagged := rows.Next()
+	rows.Close()
+	if tagged {
+		return nil
+	}
+
+	_, err = tx.Execute(`
+		INSERT INTO tags (resource_lineage, tag, tagged_by, tagged_at) VALUES ($1, $2, $3, $4)
+	`, lineage, tag, tx.GetUserID(), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to tag %s: %w", path, err)
+	}
+	return nil
+}
+
+// UntagResource removes a label from a resource in the branch of tx
+func (fm *FileManager) UntagResource(path, tag string, tx *database.Transaction) error {
+	if tx == nil {
+		return fmt.Errorf("%w for untagging", database.ErrNoTransaction)
+	}
+	path = pathpkg.Clean(path)
+
+	result, err := tx.Execute(`
+		DELETE FROM tags WHERE resource_lineage = $1 AND tag = $2
+	`, ResourceLineage(transactionBranch(tx), path), tag)
+	if err != nil {
+		return fmt.Errorf("failed to untag %s: %w", path, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s is not tagged %s", path, tag)
+	}
+	return nil
+}
+
+// GetTags returns the labels attached to the resource at path on a branch,
+// in alphabetical order
+func (fm *FileManager) GetTags(path, branchID string, tx *database.Transaction) ([]string, error) {
+	query := `SELECT tag FROM tags WHERE resource_lineage = $1 ORDER BY tag ASC`
+	lineage := ResourceLineage(branchID, path)
+
+	var result *database.QueryResult
+	var err error
+	if tx != nil {
+		result, err = tx.Query(query, database.QueryOptions{}, lineage)
+	} else {
+		result, err = fm.db.Query(query, database.QueryOptions{}, lineage)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+
+	tags := make([]string, 0, result.Count)
+	for i := 0; i < result.Count; i++ {
+		tag, err := result.GetStringByName(i, "tag")
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// FindTagged returns the paths of the current resources on a branch that
+// carry a label, limited to the subtree under root, in path order
+func (fm *FileManager) FindTagged(tag, root, branchID string, tx *database.Transaction) ([]string, error) {
+	root = pathpkg.Clean(root)
+	prefix := root + "/"
+	if root == "/" {
+		prefix = "/"
+	}
+
+	query := `
+		SELECT r.path FROM resources r
+		JOIN tags t ON t.resource_lineage = ` + lineageExpr + `
+		WHERE t.tag = $1 AND r.branch_id = $2 AND r.valid_to IS NULL
+		  AND (r.path = $3 OR substr(r.path, 1, $4) = $5)
+		ORDER BY r.path ASC`
+	args := []interface{}{tag, branchID, root, len(prefix), prefix}
+
+	var result *database.QueryResult
+	var err error
+	if tx != nil {
+		result, err = tx.Query(query, database.QueryOptions{}, args...)
+	} else {
+		result, err = fm.db.Query(query, database.QueryOptions{}, args...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tagged resources: %w", err)
+	}
+
+	paths := make([]string, 0, result.Count)
+	for i := 0; i < result.Count; i++ {
+		path, err := result.GetStringByName(i, "path")
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// CopyTags gives the resources of a new branch the tags of the branch it
+// was copied from
+func (fm *FileManager) CopyTags(fromBranch, toBranch string, tx *database.Transaction) error {
+	prefix := fromBranch + ":"
+	_, err := tx.Execute(`
+		INSERT INTO tags (resource_lineage, tag, tagged_by, tagged_at)
+		SELECT $1 || substr(resource_lineage, $2), tag, tagged_by, tagged_at
+		FROM tags WHERE substr(resource_lineage, 1, $3) = $4
+	`, toBranch+":", len(prefix)+1, len(prefix), prefix)
+	if err != nil {
+		return fmt.Errorf("failed to copy tags to branch %s: %w", toBranch, err)
+	}
+	return nil
+}
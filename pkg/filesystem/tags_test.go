@@ -0,0 +1,112 @@
+package filesystem
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+func TestResourceLineage(t *testing.T) {
+	if got := ResourceLineage("main", "/tmp//a/"); got != "main:/tmp/a" {
+		t.Errorf("ResourceLineage = %q, want main:/tmp/a", got)
+	}
+}
+
+func TestTagResource(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, []string{"/tmp/dir"}, []string{"/tmp/dir/a", "/tmp/b", "/usr/c"})
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		for _, path := range []string{"/tmp/dir/a", "/tmp/b", "/usr/c"} {
+			if err := fm.TagResource(path, "draft", tx); err != nil {
+				return err
+			}
+		}
+		if err := fm.TagResource("/tmp/b", "v1.0_final-2", tx); err != nil {
+			return err
+		}
+		// Tagging twice is a no-op
+		return fm.TagResource("/tmp/b", "draft", tx)
+	})
+
+	tags, err := fm.GetTags("/tmp/b", "main", nil)
+	if err != nil || !reflect.DeepEqual(tags, []string{"draft", "v1.0_final-2"}) {
+		t.Errorf("GetTags = %v, %v", tags, err)
+	}
+	paths, err := fm.FindTagged("draft", "/tmp", "main", nil)
+	if err != nil || !reflect.DeepEqual(paths, []string{"/tmp/b", "/tmp/dir/a"}) {
+		t.Errorf("FindTagged under /tmp = %v, %v", paths, err)
+	}
+	paths, err = fm.FindTagged("draft", "/", "main", nil)
+	if err != nil || len(paths) != 3 {
+		t.Errorf("FindTagged under / = %v, %v", paths, err)
+	}
+
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		if err := fm.UntagResource("/tmp/b", "draft", tx); err != nil {
+			return err
+		}
+		if err := fm.UntagResource("/tmp/b", "draft", tx); err == nil {
+			t.Error("removing a tag that is not set succeeded")
+		}
+		return nil
+	})
+	tags, err = fm.GetTags("/tmp/b", "main", nil)
+	if err != nil || !reflect.DeepEqual(tags, []string{"v1.0_final-2"}) {
+		t.Errorf("GetTags after untag = %v, %v", tags, err)
+	}
+}
+
+func TestTagResourceErrors(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, nil, []string{"/tmp/a"})
+
+	for _, tag := range []string{"", "-lead", "has space", "semi;colon"} {
+		err := fm.db.WithTransaction(func(tx *database.Transaction) error {
+			tx.SetBranchID("main")
+			return fm.TagResource("/tmp/a", tag, tx)
+		})
+		if err == nil {
+			t.Errorf("tag %q was accepted", tag)
+		}
+	}
+
+	err := fm.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID("main")
+		return fm.TagResource("/tmp/missing", "draft", tx)
+	})
+	if !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("tagging a missing path: %v, want ErrNotFound", err)
+	}
+	if err := fm.TagResource("/tmp/a", "draft", nil); !errors.Is(err, database.ErrNoTransaction) {
+		t.Errorf("TagResource with nil tx: got %v, want ErrNoTransaction", err)
+	}
+}
+
+func TestCopyTags(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, nil, []string{"/tmp/a"})
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		if err := fm.TagResource("/tmp/a", "draft", tx); err != nil {
+			return err
+		}
+		return fm.CopyTags("main", "feature", tx)
+	})
+
+	for _, branch := range []string{"main", "feature"} {
+		tags, err := fm.GetTags("/tmp/a", branch, nil)
+		if err != nil || !reflect.DeepEqual(tags, []string{"draft"}) {
+			t.Errorf("tags on %s = %v, %v", branch, tags, err)
+		}
+	}
+
+	// Tags on the copy are independent of the original
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		return fm.UntagResource("/tmp/a", "draft", tx)
+	})
+	if tags, _ := fm.GetTags("/tmp/a", "feature", nil); len(tags) != 1 {
+		t.Errorf("untagging on main changed feature: %v", tags)
+	}
+}
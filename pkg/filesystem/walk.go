@@ -0,0 +1,304 @@
+package filesystem
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	pathpkg "path"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// SkipDir can be returned by a WalkFunc to skip the contents of a directory
+var SkipDir = errors.New("skip this directory")
+
+// WalkFunc is called for each resource visited by Walk. Content is not loaded.
+type WalkFunc func(resource *schema.Resource) error
+
+// resourceColumns are the columns selected for walked resources
+const resourceColumns = "id, type, name, parent_id, path, metadata, valid_from, valid_to, transaction_id"
+
+// Walk visits the resource at rootPath and its descendants depth-first,
+// parents before children and siblings in name order. The tree is read as of
+// options.PointInTime when set, on options.BranchID when set. Walking stops at the first error returned by fn.
+func (fm *FileManager) Walk(rootPath string, tx *database.Transaction, options database.QueryOptions, fn WalkFunc) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	rootPath = pathpkg.Clean(rootPath)
+
+	condition, args := resourceCondition(options, 2)
+	rows, err := fm.executeQuery(tx, `
+		SELECT `+resourceColumns+`
+		FROM resources
+		WHERE path = $1`+condition, append([]interface{}{rootPath}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to query for %s: %w", rootPath, err)
+	}
+	resources, err := scanResources(rows)
+	if err != nil {
+		return err
+	}
+	if len(resources) == 0 {
+		return fmt.Errorf("resource %w: %s", database.ErrNotFound, rootPath)
+	}
+
+	return fm.walk(resources[0], tx, options, fn)
+}
+
+// ListSubtree returns every descendant of the directory at rootPath in a
+// single query, ordered by path, instead of walking the tree level by level.
+// Descendants are matched on the stored path as a range, compared in byte
+// order, so on SQLite the lookup uses the path index. The root itself is not
+// included.
+func (fm *FileManager) ListSubtree(rootPath string, tx *database.Transaction, options database.QueryOptions) ([]*schema.Resource, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	rootPath = pathpkg.Clean(rootPath)
+
+	// Paths under prefix sort between prefix and prefix with its trailing
+	// slash replaced by the next byte, '0'
+	prefix := rootPath + "/"
+	if rootPath == "/" {
+		prefix = "/"
+	}
+	upper := prefix[:len(prefix)-1] + "0"
+
+	path := fm.db.Dialect().ByteOrder("path")
+	condition, args := resourceCondition(options, 3)
+	rows, err := fm.executeQuery(tx, `
+		SELECT `+resourceColumns+`
+		FROM resources
+		WHERE `+path+` >= $1 AND `+path+` < $2 AND path <> '/'`+condition+`
+		ORDER BY `+path+` ASC`, append([]interface{}{prefix, upper}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subtree %s: %w", rootPath, err)
+	}
+
+	return scanResources(rows)
+}
+
+// PathFunc is called for each path listed by AllPaths
+type PathFunc func(path string) error
+
+// AllPaths calls fn with the path of every resource whose path starts with
+// prefix, or of every resource when prefix is empty, in path order. The tree
+// is read as of options.PointInTime when set, on options.BranchID when set.
+// Paths are passed to fn as they are read rather than collected first, so fn
+// must not query the database itself. Listing stops at the first error
+// returned by fn.
+func (fm *FileManager) AllPaths(prefix string, tx *database.Transaction, options database.QueryOptions, fn PathFunc) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	path := fm.db.Dialect().ByteOrder("path")
+	var bounds string
+	var args []interface{}
+	if prefix != "" {
+		// Paths starting with prefix sort from prefix up to, but not
+		// including, prefix with its last byte incremented
+		bounds = " AND " + path + " >= $1"
+		args = append(args, prefix)
+		if upper, ok := prefixUpperBound(prefix); ok {
+			bounds += " AND " + path + " < $2"
+			args = append(args, upper)
+		}
+	}
+
+	condition, conditionArgs := resourceCondition(options, len(args)+1)
+	rows, err := fm.executeQuery(tx, `
+		SELECT path
+		FROM resources
+		WHERE 1=1`+bounds+condition+`
+		ORDER BY `+path+` ASC`, append(args, conditionArgs...)...)
+	if err != nil {
+		return fmt.Errorf("failed to list paths: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return fmt.Errorf("failed to scan path: %w", err)
+		}
+		if err := fn(path); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating paths: %w", err)
+	}
+	return nil
+}
+
+// prefixUpperBound returns the smallest string greater than every string
+// starting with prefix, or false when there is none
+func prefixUpperBound(prefix string) (string, bool) {
+	upper := []byte(prefix)
+	for i := len(upper) - 1; i >= 0; i-- {
+		if upper[i] < 0xff {
+			upper[i]++
+			return string(upper[:i+1]), true
+		}
+	}
+	return "", false
+}
+
+// ListDirectory returns the children of the directory at path in name
+// order, read as of options.PointInTime when set, on options.BranchID when
+// set. Content is not loaded.
+func (fm *FileManager) ListDirectory(path string, tx *database.Transaction, options database.QueryOptions) ([]*schema.Resource, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	path = pathpkg.Clean(path)
+
+	dir, err := fm.Stat(path, tx, options)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return nil, err
+	}
+	if dir == nil || dir.Type != schema.ResourceTypeDirectory {
+		return nil, fmt.Errorf("directory %w: %s", database.ErrNotFound, path)
+	}
+
+	return fm.listChildren(path, tx, options)
+}
+
+// Stat returns the resource at path, of any type, read as of
+// options.PointInTime when set, on options.BranchID when set. Content is not
+// loaded. If there is no resource at path the error wraps
+// database.ErrNotFound.
+func (fm *FileManager) Stat(path string, tx *database.Transaction, options database.QueryOptions) (*schema.Resource, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	path = pathpkg.Clean(path)
+
+	condition, args := resourceCondition(options, 2)
+	rows, err := fm.executeQuery(tx, `
+		SELECT `+resourceColumns+`
+		FROM resources
+		WHERE path = $1`+condition, append([]interface{}{path}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query for %s: %w", path, err)
+	}
+	resources, err := scanResources(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("resource %w: %s", database.ErrNotFound, path)
+	}
+	return resources[0], nil
+}
+
+// walk visits a resource and recurses into directories
+func (fm *FileManager) walk(resource *schema.Resource, tx *database.Transaction, options database.QueryOptions, fn WalkFunc) error {
+	if err := fn(resource); err != nil {
+		if err == SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	if resource.Type != schema.ResourceTypeDirectory {
+		return nil
+	}
+
+	children, err := fm.listChildren(resource.Path, tx, options)
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		if err := fm.walk(child, tx, options, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listChildren returns the children of the directory at path. Children are
+// matched against every version of the directory so that re-versioning the
+// directory does not detach them.
+func (fm *FileManager) listChildren(path string, tx *database.Transaction, options database.QueryOptions) ([]*schema.Resource, error) {
+	condition, args := resourceCondition(options, 2)
+	rows, err := fm.executeQuery(tx, `
+		SELECT `+resourceColumns+`
+		FROM resources
+		WHERE parent_id IN (SELECT id FROM resources WHERE type = 'directory' AND path = $1)`+condition+`
+		ORDER BY name ASC`, append([]interface{}{path}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", path, err)
+	}
+
+	return scanResources(rows)
+}
+
+// executeQuery runs a query inside tx when given, otherwise on the connection
+func (fm *FileManager) executeQuery(tx *database.Transaction, query string, args ...interface{}) (*sql.Rows, error) {
+	if tx != nil {
+		return tx.ExecuteQuery(query, args...)
+	}
+	return fm.db.ExecuteQuery(query, args...)
+}
+
+// resourceCondition returns the branch and validity predicate for options,
+// numbering its placeholders from argIndex
+func resourceCondition(options database.QueryOptions, argIndex int) (string, []interface{}) {
+	var condition string
+	var args []interface{}
+
+	if options.BranchID != "" {
+		condition += fmt.Sprintf(" AND branch_id = $%d", argIndex)
+		args = append(args, options.BranchID)
+		argIndex++
+	}
+
+	if options.PointInTime != nil {
+		condition += fmt.Sprintf(" AND valid_from <= $%d AND (valid_to IS NULL OR valid_to > $%d)", argIndex, argIndex+1)
+		args = append(args, *options.PointInTime, *options.PointInTime)
+	} else if !options.IncludeDeleted {
+		condition += " AND valid_to IS NULL"
+	}
+
+	return condition, args
+}
+
+// scanResources reads rows selected with resourceColumns and closes them
+func scanResources(rows *sql.Rows) ([]*schema.Resource, error) {
+	defer rows.Close()
+
+	var resources []*schema.Resource
+	for rows.Next() {
+		var resource schema.Resource
+		var parentID sql.NullString
+		var metadata string
+		var validTo sql.NullTime
+
+		err := rows.Scan(&resource.ID, &resource.Type, &resource.Name, &parentID, &resource.Path,
+			&metadata, &resource.ValidFrom, &validTo, &resource.TransactionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan resource: %w", err)
+		}
+
+		resource.ParentID = parentID.String
+		resource.Metadata = []byte(metadata)
+		if validTo.Valid {
+			t := validTo.Time
+			resource.ValidTo = &t
+		}
+
+		resources = append(resources, &resource)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating resources: %w", err)
+	}
+
+	return resources, nil
+}
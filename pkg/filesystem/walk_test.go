@@ -0,0 +1,196 @@
+package filesystem
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+func TestListSubtreeMatchesPathBytes(t *testing.T) {
+	fm := newTestManager(t)
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		for _, path := range []string{"/home/notes", "/home-old", "/Home.txt", "/home0"} {
+			if _, err := fm.CreateFile(path, []byte(path), tx, "system"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	resources, err := fm.ListSubtree("/home", nil, database.QueryOptions{BranchID: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, r := range resources {
+		paths = append(paths, r.Path)
+	}
+	if want := []string{"/home/notes"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("ListSubtree(/home) = %v, want %v", paths, want)
+	}
+}
+
+// TestListSubtreeMatchesWalk lists a subtree in one query and by walking it,
+// which must find the same resources
+func TestListSubtreeMatchesWalk(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, []string{"/d", "/d/a", "/d/b", "/d/b/c", "/d0"},
+		[]string{"/d/a/x", "/d/a-b", "/d/b/c/y", "/d/gone", "/d0/z"})
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		return fm.DeleteFile("/d/gone", tx)
+	})
+
+	var walked []*schema.Resource
+	err := fm.Walk("/d", nil, mainOptions, func(resource *schema.Resource) error {
+		if resource.Path != "/d" {
+			walked = append(walked, resource)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed, err := fm.ListSubtree("/d", nil, mainOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := resourcePaths(walked)
+	sort.Strings(want)
+	if got := resourcePaths(listed); !reflect.DeepEqual(got, want) {
+		t.Errorf("ListSubtree(/d) = %v, Walk found %v", got, want)
+	}
+	if len(want) != 6 {
+		t.Errorf("Walk(/d) found %v, want 6 resources", want)
+	}
+}
+
+func TestAllPathsPrefix(t *testing.T) {
+	fm := newTestManager(t)
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		for _, path := range []string{"/tmp/a", "/tmp/b", "/TMP.txt"} {
+			if _, err := fm.CreateFile(path, []byte(path), tx, "system"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	var paths []string
+	err := fm.AllPaths("/tmp/", nil, database.QueryOptions{BranchID: "main"}, func(path string) error {
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/tmp/a", "/tmp/b"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("AllPaths(/tmp/) = %v, want %v", paths, want)
+	}
+}
+
+func TestWalkOrder(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, []string{"/tmp/b", "/tmp/a", "/tmp/a/skip"}, []string{"/tmp/a/x", "/tmp/b/y", "/tmp/a/skip/z", "/tmp/c"})
+
+	var visited []string
+	err := fm.Walk("/tmp", nil, mainOptions, func(r *schema.Resource) error {
+		visited = append(visited, r.Path)
+		if r.Name == "skip" {
+			return SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/tmp", "/tmp/a", "/tmp/a/skip", "/tmp/a/x", "/tmp/b", "/tmp/b/y", "/tmp/c"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited %v, want %v", visited, want)
+	}
+
+	stop := errors.New("stop")
+	visited = nil
+	err = fm.Walk("/tmp", nil, mainOptions, func(r *schema.Resource) error {
+		visited = append(visited, r.Path)
+		if r.Path == "/tmp/a/x" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || visited[len(visited)-1] != "/tmp/a/x" {
+		t.Errorf("walk returned %v after %v, want it to stop at /tmp/a/x", err, visited)
+	}
+
+	if err := fm.Walk("/missing", nil, mainOptions, func(*schema.Resource) error { return nil }); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("walk of a missing root: %v, want ErrNotFound", err)
+	}
+}
+
+func TestListDirectory(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, []string{"/tmp/dir"}, []string{"/tmp/b", "/tmp/a", "/tmp/dir/nested"})
+
+	entries, err := fm.ListDirectory("/tmp", nil, mainOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resourcePaths(entries), []string{"/tmp/a", "/tmp/b", "/tmp/dir"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListDirectory(/tmp) = %v, want %v", got, want)
+	}
+
+	if _, err := fm.ListDirectory("/tmp/a", nil, mainOptions); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("ListDirectory of a file: %v, want ErrNotFound", err)
+	}
+	if _, err := fm.ListDirectory("/tmp", nil, database.QueryOptions{Limit: -1}); err == nil {
+		t.Error("ListDirectory with invalid options succeeded")
+	}
+}
+
+func TestStat(t *testing.T) {
+	fm := newTestManager(t)
+	makeTree(t, fm, []string{"/tmp/dir"}, []string{"/tmp/file"})
+	before := time.Now()
+	inTransaction(t, fm, func(tx *database.Transaction) error {
+		_, err := fm.CreateFile("/tmp/later", nil, tx, "system")
+		return err
+	})
+
+	for path, want := range map[string]string{"/tmp/dir": schema.ResourceTypeDirectory, "/tmp/file/": schema.ResourceTypeFile} {
+		r, err := fm.Stat(path, nil, mainOptions)
+		if err != nil || r.Type != want {
+			t.Errorf("Stat(%s) = %v, %v; want a %s", path, r, err, want)
+		}
+	}
+	if _, err := fm.Stat("/tmp/missing", nil, mainOptions); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Stat of a missing path: %v, want ErrNotFound", err)
+	}
+	if _, err := fm.Stat("/tmp/later", nil, database.QueryOptions{BranchID: "main", PointInTime: &before}); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Stat before the file existed: %v, want ErrNotFound", err)
+	}
+	if _, err := fm.Stat("/tmp/file", nil, database.QueryOptions{BranchID: "dev"}); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Stat on another branch: %v, want ErrNotFound", err)
+	}
+}
+
+func TestPrefixUpperBound(t *testing.T) {
+	tests := []struct {
+		prefix, want string
+		ok           bool
+	}{
+		{"/tmp/", "/tmp0", true},
+		{"a\xff", "b", true},
+		{"\xff\xff", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := prefixUpperBound(tt.prefix)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("prefixUpperBound(%q) = %q, %v; want %q, %v", tt.prefix, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// JSONSchema is the subset of JSON Schema used to describe DBOS data
+type JSONSchema struct {
+	Schema     string                 `json:"$schema,omitempty"`
+	Title      string                 `json:"title,omitempty"`
+	Type       string                 `json:"type"`
+	Format     string                 `json:"format,omitempty"`
+	Enum       []string               `json:"enum,omitempty"`
+	Items      *JSONSchema            `json:"items,omitempty"`
+	Properties map[string]*JSONSchema `json:"properties,omitempty"`
+	Required   []string               `json:"required,omitempty"`
+}
+
+// ResourceDescription describes the shape of resources for clients
+type ResourceDescription struct {
+	Types    []string    `json:"types"`
+	Metadata *JSONSchema `json:"metadata"`
+}
+
+// ResourceTypes lists the resource types
+var ResourceTypes = []string{ResourceTypeFile, ResourceTypeDirectory, ResourceTypeSymlink}
+
+// DescribeResources returns the resource types and the JSON schema of
+// ResourceMetadata, derived from the struct's JSON tags
+func DescribeResources() ResourceDescription {
+	metadata := describeStruct(reflect.TypeOf(ResourceMetadata{}))
+	metadata.Schema = "https://json-schema.org/draft/2020-12/schema"
+	metadata.Title = "ResourceMetadata"
+
+	return ResourceDescription{
+		Types:    append([]string(nil), ResourceTypes...),
+		Metadata: metadata,
+	}
+}
+
+// DescribeResourcesJSON returns DescribeResources as indented JSON
+func DescribeResourcesJSON() ([]byte, error) {
+	return json.MarshalIndent(DescribeResources(), "", "  ")
+}
+
+// timeType is the reflected type of time.Time
+var timeType = reflect.TypeOf(time.Time{})
+
+// describeStruct builds the schema of a struct type from its JSON tags.
+// Fields without omitempty are required.
+func describeStruct(t reflect.Type) *JSONSchema {
+	s := &JSONSchema{
+		Type:       "object",
+		Properties: make(map[string]*JSONSchema),
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		s.Properties[name] = describeType(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// describeType returns the schema of a field type
+func describeType(t reflect.Type) *JSONSchema {
+	if t == timeType {
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Struct:
+		return describeStruct(t)
+	case reflect.Slice:
+		return &JSONSchema{Type: "array", Items: describeType(t.Elem())}
+	default:
+		return &JSONSchema{Type: "string"}
+	}
+}
@@ -0,0 +1,62 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDescribeResources(t *testing.T) {
+	description := DescribeResources()
+	if !reflect.DeepEqual(description.Types, []string{"file", "directory", "symlink"}) {
+		t.Errorf("types = %v", description.Types)
+	}
+
+	metadata := description.Metadata
+	if metadata.Type != "object" || metadata.Title != "ResourceMetadata" {
+		t.Errorf("metadata schema is %s titled %q", metadata.Type, metadata.Title)
+	}
+	tests := []struct {
+		property, typ, format string
+	}{
+		{"permissions", "integer", ""},
+		{"owner", "string", ""},
+		{"created_at", "string", "date-time"},
+		{"is_executable", "boolean", ""},
+		{"chunks", "array", ""},
+	}
+	for _, tt := range tests {
+		p := metadata.Properties[tt.property]
+		if p == nil || p.Type != tt.typ || p.Format != tt.format {
+			t.Errorf("property %s = %+v, want type %s format %q", tt.property, p, tt.typ, tt.format)
+		}
+	}
+	if items := metadata.Properties["chunks"].Items; items == nil || items.Type != "string" {
+		t.Errorf("chunks items = %+v, want strings", items)
+	}
+
+	required := make(map[string]bool)
+	for _, name := range metadata.Required {
+		required[name] = true
+	}
+	if !required["owner"] || required["mime_type"] || required["version"] {
+		t.Errorf("required = %v; want owner but not omitempty fields", metadata.Required)
+	}
+	if len(metadata.Properties) != reflect.TypeOf(ResourceMetadata{}).NumField() {
+		t.Errorf("%d properties for %d fields", len(metadata.Properties), reflect.TypeOf(ResourceMetadata{}).NumField())
+	}
+}
+
+func TestDescribeResourcesJSON(t *testing.T) {
+	data, err := DescribeResourcesJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ResourceDescription
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, DescribeResources()) {
+		t.Error("JSON description does not round trip")
+	}
+}
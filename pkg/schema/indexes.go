@@ -0,0 +1,84 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// IndexRecommendation describes an index the shell's query patterns rely on
+type IndexRecommendation struct {
+	Name    string
+	Table   string
+	Columns []string
+	Reason  string
+}
+
+// RecommendedIndexes lists composite indexes covering the most common lookups
+var RecommendedIndexes = []IndexRecommendation{
+	{
+		Name:    "idx_resources_parent_name_validto",
+		Table:   "resources",
+		Columns: []string{"parent_id", "name", "valid_to"},
+		Reason:  "existence checks by name within a directory",
+	},
+	{
+		Name:    "idx_resources_path_validto",
+		Table:   "resources",
+		Columns: []string{"path", "valid_to"},
+		Reason:  "current-version lookups by path",
+	},
+	{
+		Name:    "idx_resources_branch_path_validto",
+		Table:   "resources",
+		Columns: []string{"branch_id", "path", "valid_to"},
+		Reason:  "current-version lookups by path within a branch",
+	},
+}
+
+// CreateStatement returns the DDL that creates the index unless it exists
+func (r IndexRecommendation) CreateStatement() string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s(%s)", r.Name, r.Table, strings.Join(r.Columns, ", "))
+}
+
+// AnalyzeIndexes returns the recommended indexes that are missing from the database
+func AnalyzeIndexes(db *database.Connection) ([]IndexRecommendation, error) {
+	var missing []IndexRecommendation
+
+	for _, rec := range RecommendedIndexes {
+		exists, err := indexExists(db, rec.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, rec)
+		}
+	}
+
+	return missing, nil
+}
+
+// indexExists checks whether an index with the given name exists
+func indexExists(db *database.Connection, name string) (bool, error) {
+	rows, err := db.ExecuteQuery(db.Dialect().IndexExistsQuery(name))
+	if err != nil {
+		return false, fmt.Errorf("failed to check for index %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	return rows.Next(), nil
+}
+
+// createRecommendedIndex creates the recommended index with the given name
+func createRecommendedIndex(tx *database.Transaction, name string) error {
+	for _, rec := range RecommendedIndexes {
+		if rec.Name == name {
+			if _, err := tx.Execute(rec.CreateStatement()); err != nil {
+				return fmt.Errorf("failed to create index %s: %w", name, err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown index: %s", name)
+}
@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// pathLookup is the current-version lookup by path that most shell
+// commands make
+const pathLookup = `SELECT id FROM resources WHERE path = ? AND valid_to IS NULL`
+
+// newIndexTestDB returns an initialized in-memory database holding paths
+// files with versions versions each, all but the last closed
+func newIndexTestDB(tb testing.TB, paths, versions int) *database.Connection {
+	tb.Helper()
+	db, err := database.Connect("inmemory", tb.Name())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	if err := Initialize(db); err != nil {
+		tb.Fatal(err)
+	}
+
+	err = db.WithTransaction(func(tx *database.Transaction) error {
+		start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		for p := 0; p < paths; p++ {
+			path := fmt.Sprintf("/tmp/f%d", p)
+			for v := 0; v < versions; v++ {
+				validFrom := start.Add(time.Duration(v) * time.Minute)
+				var validTo interface{}
+				if v < versions-1 {
+					validTo = validFrom.Add(time.Minute)
+				}
+				_, err := tx.Execute(`
+					INSERT INTO resources (id, type, name, parent_id, path, metadata, valid_from, valid_to, transaction_id, branch_id)
+					VALUES (?, 'file', ?, NULL, ?, '{}', ?, ?, 'seed', 'main')
+				`, fmt.Sprintf("f%d-v%d", p, v), path[len("/tmp/"):], path, validFrom, validTo)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		_, err := tx.Execute(`ANALYZE`)
+		return err
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return db
+}
+
+// queryPlan returns the EXPLAIN QUERY PLAN output for query
+func queryPlan(t *testing.T, db *database.Connection, query string, args ...interface{}) string {
+	t.Helper()
+	rows, err := db.ExecuteQuery(db.ExplainStatement(query), args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			t.Fatal(err)
+		}
+		plan = append(plan, detail)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(plan, "\n")
+}
+
+func TestPathLookupUsesCompositeIndex(t *testing.T) {
+	db := newIndexTestDB(t, 20, 50)
+
+	if plan := queryPlan(t, db, pathLookup, "/tmp/f3"); !strings.Contains(plan, "idx_resources_path_validto") {
+		t.Errorf("path lookup does not use idx_resources_path_validto:\n%s", plan)
+	}
+
+	branchLookup := pathLookup + ` AND branch_id = ?`
+	if plan := queryPlan(t, db, branchLookup, "/tmp/f3", "main"); !strings.Contains(plan, "idx_resources_branch_path_validto") {
+		t.Errorf("branch path lookup does not use idx_resources_branch_path_validto:\n%s", plan)
+	}
+}
+
+func TestAnalyzeIndexes(t *testing.T) {
+	db := newIndexTestDB(t, 1, 1)
+	missing, err := AnalyzeIndexes(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 0 {
+		t.Errorf("initialized database is missing %v", missing)
+	}
+
+	if _, err := db.ExecuteStatement(`DROP INDEX idx_resources_path_validto`); err != nil {
+		t.Fatal(err)
+	}
+	missing, err = AnalyzeIndexes(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 1 || missing[0].Name != "idx_resources_path_validto" {
+		t.Errorf("AnalyzeIndexes after dropping the index = %v", missing)
+	}
+}
+
+// BenchmarkPathLookup looks up the current version of a path among many
+// closed versions, with the composite index and then with only the index
+// on path
+func BenchmarkPathLookup(b *testing.B) {
+	db := newIndexTestDB(b, 20, 2000)
+	lookup := func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rows, err := db.ExecuteQuery(pathLookup, fmt.Sprintf("/tmp/f%d", i%20))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if !rows.Next() {
+				b.Fatal("no current version")
+			}
+			rows.Close()
+		}
+	}
+
+	b.Run("path_validto", lookup)
+	for _, index := range []string{"idx_resources_path_validto", "idx_resources_branch_path_validto"} {
+		if _, err := db.ExecuteStatement(`DROP INDEX ` + index); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.Run("path_only", lookup)
+}
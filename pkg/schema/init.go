@@ -16,73 +16,71 @@ type SchemaVersion struct {
 }
 
 // CurrentSchemaVersion is the current version of the schema
-const CurrentSchemaVersion = 1
+const CurrentSchemaVersion = 13
 
-// Initialize initializes the database schema
+// schemaInitLockKey identifies the PostgreSQL advisory lock held while the
+// schema is initialized
+const schemaInitLockKey = 0x73746f6e65
+
+// Initialize initializes the database schema. It is safe to run against an
+// already initialized database, and from several processes at once: the
+// first to take the lock creates or upgrades the schema and the others find
+// it current and do nothing.
 func Initialize(db *database.Connection) error {
-	// Start a transaction for schema initialization
-	tx, err := db.Begin()
+	err := db.WithTransaction(func(tx *database.Transaction) error {
+		return initializeSchema(db, tx)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction for schema initialization: %w", err)
+		return err
 	}
-	defer func() {
-		if tx.IsActive() {
-			tx.Rollback()
-		}
-	}()
+	db.SetSchemaVersion(CurrentSchemaVersion)
+	db.SetTransactionLog(true)
+	
+	fmt.Println("Database schema initialized successfully.")
+	return nil
+}
 
-	// For SQLite, enable foreign keys
-	if db.GetDatabaseType() == "sqlite" {
-		_, err := tx.Execute("PRAGMA foreign_keys = ON")
-		if err != nil {
-			return fmt.Errorf("failed to enable foreign keys: %w", err)
+// initializeSchema creates the schema if the database is empty and applies
+// any missing migrations otherwise. A conflicting initialization in another
+// process surfaces as a retryable error, after which the schema is checked
+// again.
+func initializeSchema(db *database.Connection, tx *database.Transaction) error {
+	// Serialize initialization. Where the database's write lock does not
+	// already do so, the dialect takes an explicit lock.
+	if lock := tx.Dialect().TransactionLock(); lock != "" {
+		if _, err := tx.Execute(lock, schemaInitLockKey); err != nil {
+			return fmt.Errorf("failed to lock schema for initialization: %w", err)
 		}
 	}
 
-	// Check if schema_version table exists
-	var schemaVersionExists bool
-	
-	if db.GetDatabaseType() == "sqlite" {
-		rows, err := tx.ExecuteQuery(`SELECT name FROM sqlite_master WHERE type='table' AND name='schema_version'`)
-		if err != nil {
-			return fmt.Errorf("failed to check for schema_version table: %w", err)
-		}
-		defer rows.Close()
-		
-		schemaVersionExists = rows.Next()
-	} else {
-		rows, err := tx.ExecuteQuery(`
-			SELECT EXISTS (
-				SELECT 1 FROM information_schema.tables 
-				WHERE table_name = 'schema_version'
-			)
-		`)
-		if err != nil {
-			return fmt.Errorf("failed to check for schema_version table: %w", err)
-		}
-		defer rows.Close()
-		
-		if rows.Next() {
-			rows.Scan(&schemaVersionExists)
-		}
+	_, err := tx.Execute(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL,
+			description TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	// Check current schema version; an empty table means a fresh database
+	rows, err := tx.ExecuteQuery(`SELECT COALESCE(MAX(version), 0) FROM schema_version`)
+	if err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+	var currentVersion int
+	if rows.Next() {
+		err = rows.Scan(&currentVersion)
+	}
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("failed to scan schema version: %w", err)
 	}
 
-	// If schema_version doesn't exist, create it and initialize the database
-	if !schemaVersionExists {
+	if currentVersion == 0 {
 		fmt.Println("Initializing database schema...")
 		
-		// Create schema_version table
-		_, err := tx.Execute(`
-			CREATE TABLE schema_version (
-				version INTEGER PRIMARY KEY,
-				applied_at TIMESTAMP NOT NULL,
-				description TEXT NOT NULL
-			)
-		`)
-		if err != nil {
-			return fmt.Errorf("failed to create schema_version table: %w", err)
-		}
-		
 		// Apply initial schema
 		if err := applyInitialSchema(tx); err != nil {
 			return fmt.Errorf("failed to apply initial schema: %w", err)
@@ -96,53 +94,41 @@ func Initialize(db *database.Connection) error {
 		if err != nil {
 			return fmt.Errorf("failed to record schema version: %w", err)
 		}
-	} else {
-		// Check current schema version
-		rows, err := tx.ExecuteQuery(`SELECT MAX(version) FROM schema_version`)
-		if err != nil {
-			return fmt.Errorf("failed to get schema version: %w", err)
-		}
-		defer rows.Close()
-		
-		var currentVersion int
-		if rows.Next() {
-			if err := rows.Scan(&currentVersion); err != nil {
-				return fmt.Errorf("failed to scan schema version: %w", err)
-			}
-		}
 		
-		// Apply any missing migrations
-		if currentVersion < CurrentSchemaVersion {
-			fmt.Printf("Upgrading schema from version %d to %d...\n", currentVersion, CurrentSchemaVersion)
-			
-			for version := currentVersion + 1; version <= CurrentSchemaVersion; version++ {
-				fmt.Printf("Applying migration to version %d...\n", version)
-				
-				if err := applyMigration(tx, version); err != nil {
-					return fmt.Errorf("failed to apply migration to version %d: %w", version, err)
-				}
-				
-				// Record migration
-				_, err = tx.Execute(`
-					INSERT INTO schema_version (version, applied_at, description)
-					VALUES (?, ?, ?)
-				`, version, time.Now(), getMigrationDescription(version))
-				if err != nil {
-					return fmt.Errorf("failed to record migration to version %d: %w", version, err)
-				}
-			}
-		}
+		// Bring the fresh schema up to the current version
+		return applyMigrations(tx, 1, CurrentSchemaVersion)
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit schema initialization: %w", err)
+	// Apply any missing migrations
+	if currentVersion < CurrentSchemaVersion {
+		fmt.Printf("Upgrading schema from version %d to %d...\n", currentVersion, CurrentSchemaVersion)
+		return applyMigrations(tx, currentVersion, CurrentSchemaVersion)
 	}
-	
-	fmt.Println("Database schema initialized successfully.")
 	return nil
 }
 
+// GetVersion returns the most recently applied schema version
+func GetVersion(db database.Querier) (*SchemaVersion, error) {
+	rows, err := db.ExecuteQuery(`
+		SELECT version, applied_at, description FROM schema_version
+		ORDER BY version DESC LIMIT 1
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, fmt.Errorf("schema is not initialized")
+	}
+
+	var version SchemaVersion
+	if err := rows.Scan(&version.Version, &version.AppliedAt, &version.Description); err != nil {
+		return nil, fmt.Errorf("failed to scan schema version: %w", err)
+	}
+	return &version, nil
+}
+
 // applyMigrations applies database migrations from start version to end version
 func applyMigrations(tx *database.Transaction, startVersion, endVersion int) error {
 	for version := startVersion + 1; version <= endVersion; version++ {
@@ -173,6 +159,30 @@ func applyMigration(tx *database.Transaction, version int) error {
 	switch version {
 	case 1:
 		return applyInitialSchema(tx)
+	case 2:
+		return createRecommendedIndex(tx, "idx_resources_parent_name_validto")
+	case 3:
+		return createRecommendedIndex(tx, "idx_resources_path_validto")
+	case 4:
+		return applyBranchIsolation(tx)
+	case 5:
+		return applyLocks(tx)
+	case 6:
+		return applySessions(tx)
+	case 7:
+		return applyOperationKinds(tx)
+	case 8:
+		return applyTags(tx)
+	case 9:
+		return applySequences(tx)
+	case 10:
+		return applyUserHomes(tx)
+	case 11:
+		return applyBlobs(tx)
+	case 12:
+		return applySettings(tx)
+	case 13:
+		return applyAliases(tx)
 	default:
 		return fmt.Errorf("unknown schema version: %d", version)
 	}
@@ -183,6 +193,30 @@ func getMigrationDescription(version int) string {
 	switch version {
 	case 1:
 		return "Initial schema"
+	case 2:
+		return "Add resources(parent_id, name, valid_to) index"
+	case 3:
+		return "Add resources(path, valid_to) index"
+	case 4:
+		return "Scope resources to branches"
+	case 5:
+		return "Add advisory locks"
+	case 6:
+		return "Add sessions"
+	case 7:
+		return "Classify operations by kind"
+	case 8:
+		return "Add resource tags"
+	case 9:
+		return "Add ID sequences"
+	case 10:
+		return "Add user home directories and default branches"
+	case 11:
+		return "Add inline content store"
+	case 12:
+		return "Add database settings"
+	case 13:
+		return "Add user aliases"
 	default:
 		return fmt.Sprintf("Migration to version %d", version)
 	}
@@ -192,7 +226,7 @@ func getMigrationDescription(version int) string {
 func applyInitialSchema(tx *database.Transaction) error {
 	// Create resources table
 	_, err := tx.Execute(`
-		CREATE TABLE resources (
+		CREATE TABLE IF NOT EXISTS resources (
 			id TEXT PRIMARY KEY,
 			type TEXT NOT NULL,
 			name TEXT NOT NULL,
@@ -211,7 +245,7 @@ func applyInitialSchema(tx *database.Transaction) error {
 
 	// Create operations table
 	_, err = tx.Execute(`
-		CREATE TABLE operations (
+		CREATE TABLE IF NOT EXISTS operations (
 			id TEXT PRIMARY KEY,
 			user_id TEXT NOT NULL,
 			command_text TEXT NOT NULL,
@@ -226,7 +260,7 @@ func applyInitialSchema(tx *database.Transaction) error {
 
 	// Create transactions table
 	_, err = tx.Execute(`
-		CREATE TABLE transactions (
+		CREATE TABLE IF NOT EXISTS transactions (
 			id TEXT PRIMARY KEY,
 			start_time TIMESTAMP NOT NULL,
 			end_time TIMESTAMP,
@@ -241,7 +275,7 @@ func applyInitialSchema(tx *database.Transaction) error {
 
 	// Create branches table
 	_, err = tx.Execute(`
-		CREATE TABLE branches (
+		CREATE TABLE IF NOT EXISTS branches (
 			id TEXT PRIMARY KEY,
 			name TEXT NOT NULL UNIQUE,
 			base_state_id TEXT,
@@ -256,7 +290,7 @@ func applyInitialSchema(tx *database.Transaction) error {
 
 	// Create users table
 	_, err = tx.Execute(`
-		CREATE TABLE users (
+		CREATE TABLE IF NOT EXISTS users (
 			id TEXT PRIMARY KEY,
 			username TEXT NOT NULL UNIQUE,
 			password TEXT NOT NULL,
@@ -275,11 +309,11 @@ func applyInitialSchema(tx *database.Transaction) error {
 
 	// Create indexes
 	indexStmts := []string{
-		"CREATE INDEX idx_resources_parent_id ON resources(parent_id)",
-		"CREATE INDEX idx_resources_path ON resources(path)",
-		"CREATE INDEX idx_resources_valid_time ON resources(valid_from, valid_to)",
-		"CREATE INDEX idx_operations_transaction_id ON operations(transaction_id)",
-		"CREATE INDEX idx_transactions_branch_id ON transactions(branch_id)",
+		"CREATE INDEX IF NOT EXISTS idx_resources_parent_id ON resources(parent_id)",
+		"CREATE INDEX IF NOT EXISTS idx_resources_path ON resources(path)",
+		"CREATE INDEX IF NOT EXISTS idx_resources_valid_time ON resources(valid_from, valid_to)",
+		"CREATE INDEX IF NOT EXISTS idx_operations_transaction_id ON operations(transaction_id)",
+		"CREATE INDEX IF NOT EXISTS idx_transactions_branch_id ON transactions(branch_id)",
 	}
 
 	for _, stmt := range indexStmts {
@@ -311,7 +345,7 @@ func applyInitialSchema(tx *database.Transaction) error {
 
 	// Create root directory
 	rootID := "root"
-	rootMetadata, err := json.Marshal(NewDirectoryMetadata("system"))
+	rootMetadata, err := json.Marshal(NewDirectoryMetadata("system", DefaultDirectoryPermissions))
 	if err != nil {
 		return fmt.Errorf("failed to marshal root directory metadata: %w", err)
 	}
@@ -329,7 +363,7 @@ func applyInitialSchema(tx *database.Transaction) error {
 	for _, dir := range standardDirs {
 		dirID := fmt.Sprintf("dir-%s", dir)
 		dirPath := fmt.Sprintf("/%s", dir)
-		dirMetadata, err := json.Marshal(NewDirectoryMetadata("system"))
+		dirMetadata, err := json.Marshal(NewDirectoryMetadata("system", DefaultDirectoryPermissions))
 		if err != nil {
 			return fmt.Errorf("failed to marshal directory metadata: %w", err)
 		}
@@ -353,4 +387,182 @@ func applyInitialSchema(tx *database.Transaction) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// applyBranchIsolation adds the branch_id column to resources. Existing
+// resources belong to the main branch.
+func applyBranchIsolation(tx *database.Transaction) error {
+	if err := addColumn(tx, "resources", "branch_id", "TEXT NOT NULL DEFAULT 'main'"); err != nil {
+		return err
+	}
+
+	return createRecommendedIndex(tx, "idx_resources_branch_path_validto")
+}
+
+// applyLocks creates the table of advisory resource locks. Locks are keyed by
+// path because every version of a resource has its own ID.
+func applyLocks(tx *database.Transaction) error {
+	_, err := tx.Execute(`
+		CREATE TABLE IF NOT EXISTS locks (
+			branch_id TEXT NOT NULL,
+			path TEXT NOT NULL,
+			locked_by TEXT NOT NULL,
+			locked_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (branch_id, path)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create locks table: %w", err)
+	}
+	return nil
+}
+
+// applySessions creates the table of connected shell sessions
+func applySessions(tx *database.Transaction) error {
+	_, err := tx.Execute(`
+		CREATE TABLE IF NOT EXISTS sessions (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			branch_id TEXT NOT NULL,
+			hostname TEXT NOT NULL,
+			pid INTEGER NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			last_heartbeat TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create sessions table: %w", err)
+	}
+	return nil
+}
+
+// applyOperationKinds adds the kind of change to each audit log entry so the
+// log can be filtered by operation type
+func applyOperationKinds(tx *database.Transaction) error {
+	if err := addColumn(tx, "operations", "kind", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	_, err := tx.Execute(`CREATE INDEX IF NOT EXISTS idx_operations_kind ON operations(kind)`)
+	if err != nil {
+		return fmt.Errorf("failed to create index on operations(kind): %w", err)
+	}
+	return nil
+}
+
+// applyTags creates the table of labels attached to resources. Tags are
+// keyed by resource lineage rather than version ID so that they follow a
+// resource through its versions.
+func applyTags(tx *database.Transaction) error {
+	_, err := tx.Execute(`
+		CREATE TABLE IF NOT EXISTS tags (
+			resource_lineage TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			tagged_by TEXT NOT NULL,
+			tagged_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (resource_lineage, tag)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tags table: %w", err)
+	}
+
+	_, err = tx.Execute(`CREATE INDEX IF NOT EXISTS idx_tags_tag ON tags(tag)`)
+	if err != nil {
+		return fmt.Errorf("failed to create index on tags(tag): %w", err)
+	}
+	return nil
+}
+
+// applySequences creates the counters used by the sequence ID scheme
+func applySequences(tx *database.Transaction) error {
+	_, err := tx.Execute(`
+		CREATE TABLE IF NOT EXISTS sequences (
+			name TEXT PRIMARY KEY,
+			value INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create sequences table: %w", err)
+	}
+	return nil
+}
+
+// applyUserHomes adds each user's home directory and default branch. An empty
+// home directory means /home/<username>.
+func applyUserHomes(tx *database.Transaction) error {
+	if err := addColumn(tx, "users", "home_dir", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	return addColumn(tx, "users", "default_branch", "TEXT NOT NULL DEFAULT 'main'")
+}
+
+// applyBlobs creates the table of the inline content store, which holds
+// file content by checksum
+func applyBlobs(tx *database.Transaction) error {
+	_, err := tx.Execute(`
+		CREATE TABLE IF NOT EXISTS blobs (
+			checksum TEXT PRIMARY KEY,
+			data BLOB NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create blobs table: %w", err)
+	}
+	return nil
+}
+
+// applySettings creates the table of database-wide settings, such as how
+// file content is compressed, which apply to every session
+func applySettings(tx *database.Transaction) error {
+	_, err := tx.Execute(`
+		CREATE TABLE IF NOT EXISTS settings (
+			name TEXT PRIMARY KEY,
+			value TEXT NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create settings table: %w", err)
+	}
+	return nil
+}
+
+// applyAliases creates the table of shell aliases, which each user defines
+// for their own sessions
+func applyAliases(tx *database.Transaction) error {
+	_, err := tx.Execute(`
+		CREATE TABLE IF NOT EXISTS aliases (
+			username TEXT NOT NULL,
+			name TEXT NOT NULL,
+			expansion TEXT NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (username, name)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create aliases table: %w", err)
+	}
+	return nil
+}
+
+// addColumn adds a column to a table unless it already has one by that
+// name. SQLite has no ADD COLUMN IF NOT EXISTS.
+func addColumn(tx *database.Transaction, table, column, definition string) error {
+	rows, err := tx.ExecuteQuery(tx.Dialect().ColumnExistsQuery(table, column))
+	if err != nil {
+		return fmt.Errorf("failed to check for column %s in %s: %w", column, table, err)
+	}
+	exists := rows.Next()
+	rows.Close()
+	if exists {
+		return nil
+	}
+
+	_, err = tx.Execute(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add %s to %s: %w", column, table, err)
+	}
+	return nil
+}
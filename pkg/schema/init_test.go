@@ -0,0 +1,183 @@
+package schema
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+func TestInitializeTwice(t *testing.T) {
+	db, err := database.Connect("inmemory", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := Initialize(db); err != nil {
+			t.Fatalf("initialize %d: %v", i+1, err)
+		}
+	}
+	version, err := GetVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Version != CurrentSchemaVersion {
+		t.Errorf("version = %d, want %d", version.Version, CurrentSchemaVersion)
+	}
+	if err := db.HealthCheck(context.Background()); err != nil {
+		t.Errorf("health check: %v", err)
+	}
+}
+
+func TestInitializeConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dbos.db")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			db, err := database.Connect("sqlite", path)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer db.Close()
+			errs[i] = Initialize(db)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("initialize %d: %v", i, err)
+		}
+	}
+
+	db, err := database.Connect("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if roots := count(t, db, `SELECT COUNT(*) FROM resources WHERE path = '/'`); roots != 1 {
+		t.Errorf("%d root directories, want 1", roots)
+	}
+	if versions := count(t, db, `SELECT COUNT(*) FROM schema_version`); versions != CurrentSchemaVersion {
+		t.Errorf("%d schema versions recorded, want %d", versions, CurrentSchemaVersion)
+	}
+}
+
+func TestInitializeCreatesBaseDirectories(t *testing.T) {
+	db := newTestDB(t)
+	if err := Initialize(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/", "/home", "/tmp", "/usr"} {
+		n := count(t, db, `SELECT COUNT(*) FROM resources WHERE type = 'directory' AND valid_to IS NULL AND path = '`+path+`'`)
+		if n != 1 {
+			t.Errorf("%d current directories at %s, want 1", n, path)
+		}
+	}
+}
+
+func TestInitializeUpgradesOldSchema(t *testing.T) {
+	db := newTestDB(t)
+
+	// Build the schema as it was at version 4
+	err := db.WithTransaction(func(tx *database.Transaction) error {
+		_, err := tx.Execute(`CREATE TABLE schema_version (version INTEGER PRIMARY KEY, applied_at TIMESTAMP NOT NULL, description TEXT NOT NULL)`)
+		if err != nil {
+			return err
+		}
+		if err := applyInitialSchema(tx); err != nil {
+			return err
+		}
+		if _, err := tx.Execute(`INSERT INTO schema_version VALUES (1, ?, 'Initial schema')`, time.Now()); err != nil {
+			return err
+		}
+		return applyMigrations(tx, 1, 4)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Initialize(db); err != nil {
+		t.Fatal(err)
+	}
+	version, err := GetVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Version != CurrentSchemaVersion || version.Description != getMigrationDescription(CurrentSchemaVersion) {
+		t.Errorf("version = %+v, want %d", version, CurrentSchemaVersion)
+	}
+	if n := count(t, db, `SELECT COUNT(*) FROM schema_version`); n != CurrentSchemaVersion {
+		t.Errorf("%d schema versions recorded, want %d", n, CurrentSchemaVersion)
+	}
+}
+
+func TestMigrationsTolerateExistingObjects(t *testing.T) {
+	db := newTestDB(t)
+	if err := Initialize(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Running every migration again over the complete schema, as happens
+	// when the version records are lost, must not fail on tables, indexes
+	// or columns that already exist
+	err := db.WithTransaction(func(tx *database.Transaction) error {
+		if _, err := tx.Execute(`DELETE FROM schema_version WHERE version > 1`); err != nil {
+			return err
+		}
+		return applyMigrations(tx, 1, CurrentSchemaVersion)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Initialize(db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetVersionUninitialized(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.ExecuteStatement(`CREATE TABLE schema_version (version INTEGER PRIMARY KEY, applied_at TIMESTAMP NOT NULL, description TEXT NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetVersion(db); err == nil {
+		t.Error("GetVersion of an empty schema_version table succeeded")
+	}
+}
+
+// newTestDB returns an empty in-memory database named after the test
+func newTestDB(t *testing.T) *database.Connection {
+	t.Helper()
+	db, err := database.Connect("inmemory", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// count runs a query returning a single number
+func count(t *testing.T, db *database.Connection, query string) int {
+	t.Helper()
+	rows, err := db.ExecuteQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var n int
+	if rows.Next() {
+		if err := rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return n
+}
@@ -0,0 +1,35 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// Tables lists the tables managed by the schema
+var Tables = []string{"resources", "operations", "transactions", "branches", "users", "locks", "sessions", "tags", "sequences", "blobs", "settings", "aliases", "schema_version"}
+
+// MaintenanceStatements returns the statements that rebuild indexes and
+// refresh planner statistics for the connection's dialect
+func MaintenanceStatements(db *database.Connection) []string {
+	if db.IsSQLite() {
+		return []string{"REINDEX", "ANALYZE"}
+	}
+
+	statements := make([]string, 0, len(Tables)+1)
+	for _, table := range Tables {
+		statements = append(statements, fmt.Sprintf("REINDEX TABLE %s", table))
+	}
+	return append(statements, "ANALYZE")
+}
+
+// Reindex rebuilds all indexes and refreshes planner statistics. It runs
+// outside of any transaction.
+func Reindex(db *database.Connection) error {
+	for _, stmt := range MaintenanceStatements(db) {
+		if _, err := db.ExecuteStatement(stmt); err != nil {
+			return fmt.Errorf("failed to run %s: %w", stmt, err)
+		}
+	}
+	return nil
+}
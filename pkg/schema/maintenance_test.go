@@ -0,0 +1,37 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReindex(t *testing.T) {
+	db := newTestDB(t)
+	if err := Initialize(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := MaintenanceStatements(db); !reflect.DeepEqual(got, []string{"REINDEX", "ANALYZE"}) {
+		t.Errorf("SQLite maintenance statements = %v", got)
+	}
+	if err := Reindex(db); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, db, `SELECT COUNT(*) FROM resources`); n == 0 {
+		t.Error("no resources after reindexing")
+	}
+}
+
+func TestTablesExist(t *testing.T) {
+	db := newTestDB(t)
+	if err := Initialize(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every table maintenance rebuilds is created by the schema
+	for _, table := range Tables {
+		if n := count(t, db, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = '`+table+`'`); n != 1 {
+			t.Errorf("table %s does not exist", table)
+		}
+	}
+}
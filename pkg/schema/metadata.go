@@ -0,0 +1,84 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CurrentMetadataVersion is the format of ResourceMetadata written by this
+// version of the code. Bump it, and add a step to metadataMigrations, when a
+// field is renamed or changes meaning; added fields only need a default in
+// NormalizeMetadata.
+const CurrentMetadataVersion = 1
+
+// metadataMigrations upgrade stored metadata, as decoded JSON fields, from
+// the version they are keyed by to the next one
+var metadataMigrations = map[int]func(fields map[string]json.RawMessage) error{
+	0: migrateMetadataV0,
+}
+
+// NormalizeMetadata decodes stored resource metadata of any version into
+// the current ResourceMetadata. Older formats are migrated step by step, and
+// fields missing from the stored JSON get the defaults a new resource would
+// have rather than their zero values. Errors are returned without context,
+// for the caller to wrap like a json.Unmarshal error.
+func NormalizeMetadata(raw json.RawMessage) (ResourceMetadata, error) {
+	var metadata ResourceMetadata
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return metadata, err
+	}
+	if fields == nil {
+		return metadata, fmt.Errorf("metadata is not a JSON object")
+	}
+
+	version := 0
+	if v, ok := fields["version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return metadata, fmt.Errorf("invalid metadata version: %s", v)
+		}
+	}
+	if version > CurrentMetadataVersion {
+		return metadata, fmt.Errorf("metadata version %d is newer than supported version %d", version, CurrentMetadataVersion)
+	}
+	for ; version < CurrentMetadataVersion; version++ {
+		migrate, ok := metadataMigrations[version]
+		if !ok {
+			return metadata, fmt.Errorf("no migration from metadata version %d", version)
+		}
+		if err := migrate(fields); err != nil {
+			return metadata, fmt.Errorf("failed to migrate metadata from version %d: %w", version, err)
+		}
+	}
+
+	migrated, err := json.Marshal(fields)
+	if err != nil {
+		return metadata, err
+	}
+	if err := json.Unmarshal(migrated, &metadata); err != nil {
+		return metadata, err
+	}
+
+	if _, ok := fields["group"]; !ok {
+		metadata.Group = "users"
+	}
+	if _, ok := fields["modified_at"]; !ok {
+		metadata.ModifiedAt = metadata.CreatedAt
+	}
+	if _, ok := fields["accessed_at"]; !ok {
+		metadata.AccessedAt = metadata.ModifiedAt
+	}
+	if _, ok := fields["is_executable"]; !ok {
+		metadata.IsExecutable = metadata.Permissions&0111 != 0
+	}
+	metadata.Version = CurrentMetadataVersion
+	return metadata, nil
+}
+
+// migrateMetadataV0 upgrades metadata written before versioning. Its fields
+// have the current names, so only the version is set.
+func migrateMetadataV0(fields map[string]json.RawMessage) error {
+	fields["version"] = json.RawMessage("1")
+	return nil
+}
@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNormalizeMetadataV0(t *testing.T) {
+	created := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	raw := `{"permissions": 493, "owner": "alice", "created_at": "2021-06-01T12:00:00Z", "size": 3}`
+
+	metadata, err := NormalizeMetadata(json.RawMessage(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Version != CurrentMetadataVersion {
+		t.Errorf("version = %d, want %d", metadata.Version, CurrentMetadataVersion)
+	}
+	if metadata.Owner != "alice" || metadata.Permissions != 0755 || metadata.Size != 3 {
+		t.Errorf("stored fields changed: %+v", metadata)
+	}
+	// Missing fields get the defaults a new resource would have
+	if metadata.Group != "users" {
+		t.Errorf("group = %q, want users", metadata.Group)
+	}
+	if !metadata.ModifiedAt.Equal(created) || !metadata.AccessedAt.Equal(created) {
+		t.Errorf("modified %v accessed %v, want both %v", metadata.ModifiedAt, metadata.AccessedAt, created)
+	}
+	if !metadata.IsExecutable {
+		t.Error("0755 metadata without is_executable is not executable")
+	}
+}
+
+func TestNormalizeMetadataKeepsStoredValues(t *testing.T) {
+	want := NewResourceMetadata("bob", 0755)
+	want.Group = "staff"
+	want.IsExecutable = false
+	raw, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NormalizeMetadata(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Group != "staff" || got.IsExecutable || !got.ModifiedAt.Equal(want.ModifiedAt) {
+		t.Errorf("NormalizeMetadata replaced stored values: %+v", got)
+	}
+}
+
+func TestNormalizeMetadataErrors(t *testing.T) {
+	for _, raw := range []string{
+		`not json`,
+		`null`,
+		`[1, 2]`,
+		`{"version": "one"}`,
+		`{"version": 99}`,
+	} {
+		if _, err := NormalizeMetadata(json.RawMessage(raw)); err == nil {
+			t.Errorf("NormalizeMetadata(%s) succeeded", raw)
+		}
+	}
+}
+
+func TestNewDirectoryMetadata(t *testing.T) {
+	if m := NewResourceMetadata("alice", 0755); !m.IsExecutable {
+		t.Error("0755 file metadata is not executable")
+	}
+	if m := NewResourceMetadata("alice", 0644); m.IsExecutable {
+		t.Error("0644 file metadata is executable")
+	}
+	if m := NewDirectoryMetadata("alice", 0755); m.IsExecutable || m.Permissions != 0755 {
+		t.Errorf("directory metadata = %+v", m)
+	}
+}
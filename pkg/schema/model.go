@@ -11,6 +11,7 @@ type Resource struct {
 	Type          string          `json:"type"`          // "file", "directory", "symlink", etc.
 	Name          string          `json:"name"`
 	ParentID      string          `json:"parent_id"`
+	Path          string          `json:"path"`
 	Content       []byte          `json:"content,omitempty"`
 	Metadata      json.RawMessage `json:"metadata"`
 	ValidFrom     time.Time       `json:"valid_from"`
@@ -33,6 +34,10 @@ type ResourceMetadata struct {
 	IsSystem     bool      `json:"is_system"`
 	Checksum     string    `json:"checksum,omitempty"`
 	SymlinkTarget string    `json:"symlink_target,omitempty"`
+	Encoding     string    `json:"encoding,omitempty"` // How content is stored, e.g. "gzip"; empty for raw
+	Store        string    `json:"store,omitempty"`    // Content store holding the content; empty when it is in the resources table
+	Chunks       []string  `json:"chunks,omitempty"`   // Checksums of the pieces the content is kept in the store as, in order; empty when it is kept whole
+	Version      int       `json:"version,omitempty"`  // Format of the stored JSON; 0 for metadata written before versioning
 }
 
 // Operation represents a command executed in the system
@@ -43,6 +48,7 @@ type Operation struct {
 	Timestamp         time.Time       `json:"timestamp"`
 	TransactionID     string          `json:"transaction_id"`
 	AffectedResources json.RawMessage `json:"affected_resources"` // IDs of modified resources
+	Kind              string          `json:"kind"`               // One of the OperationKind constants
 }
 
 // Transaction represents a database transaction
@@ -77,6 +83,19 @@ type User struct {
 	LastLogin *time.Time `json:"last_login"`
 	IsActive  bool      `json:"is_active"`
 	IsAdmin   bool      `json:"is_admin"`
+	HomeDir       string `json:"home_dir"`       // Empty means /home/<username>
+	DefaultBranch string `json:"default_branch"` // Branch sessions start on
+}
+
+// Session represents a shell session connected to the database
+type Session struct {
+	ID            string    `json:"id"`
+	UserID        string    `json:"user_id"`
+	BranchID      string    `json:"branch_id"`
+	Hostname      string    `json:"hostname"`
+	PID           int       `json:"pid"`
+	StartedAt     time.Time `json:"started_at"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
 }
 
 // ResourceType constants
@@ -100,26 +119,49 @@ const (
 	BranchStatusAbandoned = "abandoned"
 )
 
-// NewResourceMetadata creates a new ResourceMetadata with default values
-func NewResourceMetadata(owner string) ResourceMetadata {
+// ContentEncoding constants
+const (
+	ContentEncodingGzip = "gzip"
+)
+
+// OperationKind constants classify the changes recorded in the audit log
+const (
+	OperationKindCreate = "create"
+	OperationKindUpdate = "update"
+	OperationKindDelete = "delete"
+	OperationKindMove   = "move"
+	OperationKindChmod  = "chmod"
+	OperationKindChown  = "chown"
+)
+
+// Default permissions before the creator's umask is applied
+const (
+	DefaultFilePermissions      uint32 = 0644 // rw-r--r--
+	DefaultDirectoryPermissions uint32 = 0755 // rwxr-xr-x
+	DefaultUmask                uint32 = 0022
+)
+
+// NewResourceMetadata creates a new ResourceMetadata with the given permissions
+func NewResourceMetadata(owner string, permissions uint32) ResourceMetadata {
 	now := time.Now()
 	
 	return ResourceMetadata{
-		Permissions: 0644, // Default file permissions (rw-r--r--)
+		Version:     CurrentMetadataVersion,
+		Permissions: permissions,
 		Owner:       owner,
 		Group:       "users",
 		CreatedAt:   now,
 		ModifiedAt:  now,
 		AccessedAt:  now,
-		IsExecutable: false,
+		IsExecutable: permissions&0111 != 0,
 		IsHidden:    false,
 		IsSystem:    false,
 	}
 }
 
-// NewDirectoryMetadata creates metadata for a new directory
-func NewDirectoryMetadata(owner string) ResourceMetadata {
-	metadata := NewResourceMetadata(owner)
-	metadata.Permissions = 0755 // Default directory permissions (rwxr-xr-x)
+// NewDirectoryMetadata creates metadata for a new directory with the given permissions
+func NewDirectoryMetadata(owner string, permissions uint32) ResourceMetadata {
+	metadata := NewResourceMetadata(owner, permissions)
+	metadata.IsExecutable = false // Search permission, not an executable file
 	return metadata
-}
\ No newline at end of file
+}
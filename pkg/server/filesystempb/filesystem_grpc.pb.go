@@ -0,0 +1,378 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: filesystem.proto
+
+// The filesystem service of a dbos database. Reads name a branch and an
+// optional point in time, or an open transaction to read in; writes run in
+// an open transaction or in one of their own on a branch.
+
+package filesystempb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Filesystem_GetFile_FullMethodName    = "/dbos.filesystem.v1.Filesystem/GetFile"
+	Filesystem_CreateFile_FullMethodName = "/dbos.filesystem.v1.Filesystem/CreateFile"
+	Filesystem_List_FullMethodName       = "/dbos.filesystem.v1.Filesystem/List"
+	Filesystem_Query_FullMethodName      = "/dbos.filesystem.v1.Filesystem/Query"
+	Filesystem_Begin_FullMethodName      = "/dbos.filesystem.v1.Filesystem/Begin"
+	Filesystem_Commit_FullMethodName     = "/dbos.filesystem.v1.Filesystem/Commit"
+	Filesystem_Rollback_FullMethodName   = "/dbos.filesystem.v1.Filesystem/Rollback"
+)
+
+// FilesystemClient is the client API for Filesystem service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FilesystemClient interface {
+	// GetFile returns a file with its content.
+	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (*File, error)
+	// CreateFile creates a file, failing if one exists at the path.
+	CreateFile(ctx context.Context, in *CreateFileRequest, opts ...grpc.CallOption) (*File, error)
+	// List streams the entries of a directory in name order.
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Entry], error)
+	// Query runs a SQL statement and streams its rows. The first message
+	// carries the column names and each later one a row.
+	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[QueryResponse], error)
+	// Begin opens a transaction that later calls name by its ID. It stays
+	// open until committed or rolled back, or until the server stops.
+	Begin(ctx context.Context, in *BeginRequest, opts ...grpc.CallOption) (*BeginResponse, error)
+	// Commit commits an open transaction.
+	Commit(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*Empty, error)
+	// Rollback rolls back an open transaction.
+	Rollback(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type filesystemClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFilesystemClient(cc grpc.ClientConnInterface) FilesystemClient {
+	return &filesystemClient{cc}
+}
+
+func (c *filesystemClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (*File, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(File)
+	err := c.cc.Invoke(ctx, Filesystem_GetFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filesystemClient) CreateFile(ctx context.Context, in *CreateFileRequest, opts ...grpc.CallOption) (*File, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(File)
+	err := c.cc.Invoke(ctx, Filesystem_CreateFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filesystemClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Entry], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Filesystem_ServiceDesc.Streams[0], Filesystem_List_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListRequest, Entry]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Filesystem_ListClient = grpc.ServerStreamingClient[Entry]
+
+func (c *filesystemClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[QueryResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Filesystem_ServiceDesc.Streams[1], Filesystem_Query_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[QueryRequest, QueryResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Filesystem_QueryClient = grpc.ServerStreamingClient[QueryResponse]
+
+func (c *filesystemClient) Begin(ctx context.Context, in *BeginRequest, opts ...grpc.CallOption) (*BeginResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BeginResponse)
+	err := c.cc.Invoke(ctx, Filesystem_Begin_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filesystemClient) Commit(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Filesystem_Commit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *filesystemClient) Rollback(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Filesystem_Rollback_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FilesystemServer is the server API for Filesystem service.
+// All implementations must embed UnimplementedFilesystemServer
+// for forward compatibility.
+type FilesystemServer interface {
+	// GetFile returns a file with its content.
+	GetFile(context.Context, *GetFileRequest) (*File, error)
+	// CreateFile creates a file, failing if one exists at the path.
+	CreateFile(context.Context, *CreateFileRequest) (*File, error)
+	// List streams the entries of a directory in name order.
+	List(*ListRequest, grpc.ServerStreamingServer[Entry]) error
+	// Query runs a SQL statement and streams its rows. The first message
+	// carries the column names and each later one a row.
+	Query(*QueryRequest, grpc.ServerStreamingServer[QueryResponse]) error
+	// Begin opens a transaction that later calls name by its ID. It stays
+	// open until committed or rolled back, or until the server stops.
+	Begin(context.Context, *BeginRequest) (*BeginResponse, error)
+	// Commit commits an open transaction.
+	Commit(context.Context, *TransactionRequest) (*Empty, error)
+	// Rollback rolls back an open transaction.
+	Rollback(context.Context, *TransactionRequest) (*Empty, error)
+	mustEmbedUnimplementedFilesystemServer()
+}
+
+// UnimplementedFilesystemServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFilesystemServer struct{}
+
+func (UnimplementedFilesystemServer) GetFile(context.Context, *GetFileRequest) (*File, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFile not implemented")
+}
+func (UnimplementedFilesystemServer) CreateFile(context.Context, *CreateFileRequest) (*File, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateFile not implemented")
+}
+func (UnimplementedFilesystemServer) List(*ListRequest, grpc.ServerStreamingServer[Entry]) error {
+	return status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedFilesystemServer) Query(*QueryRequest, grpc.ServerStreamingServer[QueryResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedFilesystemServer) Begin(context.Context, *BeginRequest) (*BeginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Begin not implemented")
+}
+func (UnimplementedFilesystemServer) Commit(context.Context, *TransactionRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Commit not implemented")
+}
+func (UnimplementedFilesystemServer) Rollback(context.Context, *TransactionRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rollback not implemented")
+}
+func (UnimplementedFilesystemServer) mustEmbedUnimplementedFilesystemServer() {}
+func (UnimplementedFilesystemServer) testEmbeddedByValue()                    {}
+
+// UnsafeFilesystemServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FilesystemServer will
+// result in compilation errors.
+type UnsafeFilesystemServer interface {
+	mustEmbedUnimplementedFilesystemServer()
+}
+
+func RegisterFilesystemServer(s grpc.ServiceRegistrar, srv FilesystemServer) {
+	// If the following call pancis, it indicates UnimplementedFilesystemServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Filesystem_ServiceDesc, srv)
+}
+
+func _Filesystem_GetFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilesystemServer).GetFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Filesystem_GetFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilesystemServer).GetFile(ctx, req.(*GetFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Filesystem_CreateFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilesystemServer).CreateFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Filesystem_CreateFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilesystemServer).CreateFile(ctx, req.(*CreateFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Filesystem_List_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FilesystemServer).List(m, &grpc.GenericServerStream[ListRequest, Entry]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Filesystem_ListServer = grpc.ServerStreamingServer[Entry]
+
+func _Filesystem_Query_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FilesystemServer).Query(m, &grpc.GenericServerStream[QueryRequest, QueryResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Filesystem_QueryServer = grpc.ServerStreamingServer[QueryResponse]
+
+func _Filesystem_Begin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BeginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilesystemServer).Begin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Filesystem_Begin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilesystemServer).Begin(ctx, req.(*BeginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Filesystem_Commit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilesystemServer).Commit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Filesystem_Commit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilesystemServer).Commit(ctx, req.(*TransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Filesystem_Rollback_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FilesystemServer).Rollback(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Filesystem_Rollback_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FilesystemServer).Rollback(ctx, req.(*TransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Filesystem_ServiceDesc is the grpc.ServiceDesc for Filesystem service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Filesystem_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dbos.filesystem.v1.Filesystem",
+	HandlerType: (*FilesystemServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetFile",
+			Handler:    _Filesystem_GetFile_Handler,
+		},
+		{
+			MethodName: "CreateFile",
+			Handler:    _Filesystem_CreateFile_Handler,
+		},
+		{
+			MethodName: "Begin",
+			Handler:    _Filesystem_Begin_Handler,
+		},
+		{
+			MethodName: "Commit",
+			Handler:    _Filesystem_Commit_Handler,
+		},
+		{
+			MethodName: "Rollback",
+			Handler:    _Filesystem_Rollback_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "List",
+			Handler:       _Filesystem_List_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Query",
+			Handler:       _Filesystem_Query_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "filesystem.proto",
+}
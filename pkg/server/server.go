@@ -0,0 +1,204 @@
+// Package server serves the filesystem to typed clients over gRPC. The
+// service contract is pkg/server/filesystempb/filesystem.proto: GetFile,
+// CreateFile, List, Query, Begin, Commit and Rollback, with List and Query
+// streaming their results. The service has no authentication, and Query runs
+// any statement it is sent, so Listen only accepts loopback addresses.
+package server
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative filesystempb/filesystem.proto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/filesystem"
+	"github.com/brainwavecollective/stone-os/pkg/server/filesystempb"
+)
+
+// Server serves a database's filesystem over gRPC
+type Server struct {
+	db   *database.Connection
+	fm   *filesystem.FileManager
+	user string
+
+	mu  sync.Mutex
+	txs map[string]*database.Transaction // Transactions opened with Begin
+}
+
+// New creates a Server that acts as user on db, reading and writing files
+// through fm
+func New(db *database.Connection, fm *filesystem.FileManager, user string) *Server {
+	return &Server{db: db, fm: fm, user: user, txs: make(map[string]*database.Transaction)}
+}
+
+// Register registers the Filesystem service with a gRPC server
+func (s *Server) Register(gs *grpc.Server) {
+	filesystempb.RegisterFilesystemServer(gs, &service{server: s})
+}
+
+// Serve accepts connections on l and serves the Filesystem service on them
+// until l is closed
+func (s *Server) Serve(l net.Listener) error {
+	gs := grpc.NewServer()
+	s.Register(gs)
+	return gs.Serve(l)
+}
+
+// Listen listens on the TCP address addr for Serve. Its host must be a
+// loopback one, and is localhost when left out.
+func Listen(addr string) (net.Listener, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("refusing to serve on %s: the service has no authentication, so it only listens on loopback addresses", host)
+	}
+
+	l, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return l, nil
+}
+
+// RollbackAll rolls back the transactions clients left open, returning how
+// many there were
+func (s *Server) RollbackAll() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.txs)
+	for id, tx := range s.txs {
+		tx.Rollback()
+		delete(s.txs, id)
+	}
+	return n
+}
+
+// transaction returns the open transaction with the given ID, or nil when
+// id is empty
+func (s *Server) transaction(id string) (*database.Transaction, error) {
+	if id == "" {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.txs[id]
+	if !ok {
+		return nil, fmt.Errorf("transaction %w: %s", database.ErrNotFound, id)
+	}
+	return tx, nil
+}
+
+// endTransaction removes the open transaction with the given ID and returns it
+func (s *Server) endTransaction(id string) (*database.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.txs[id]
+	if !ok {
+		return nil, fmt.Errorf("transaction %w: %s", database.ErrNotFound, id)
+	}
+	delete(s.txs, id)
+	return tx, nil
+}
+
+// write runs fn in the open transaction txID, or in a transaction of its own
+// on branch when txID is empty
+func (s *Server) write(txID, branch string, fn func(tx *database.Transaction) error) error {
+	if txID != "" {
+		tx, err := s.transaction(txID)
+		if err != nil {
+			return err
+		}
+		return fn(tx)
+	}
+
+	return s.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID(branchOrMain(branch))
+		tx.SetUserID(s.user)
+		return fn(tx)
+	})
+}
+
+// recordOperation writes an audit log entry for a call that changed
+// resources
+func (s *Server) recordOperation(tx *database.Transaction, method, kind string, resourceIDs []string) error {
+	affected, err := json.Marshal(resourceIDs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal affected resources: %w", err)
+	}
+
+	_, err = tx.Execute(`
+		INSERT INTO operations (id, user_id, command_text, timestamp, transaction_id, affected_resources, kind)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, database.GenerateUUID(), s.user, "rpc "+method, time.Now(), tx.GetID(), string(affected), kind)
+	if err != nil {
+		return fmt.Errorf("failed to record operation: %w", err)
+	}
+	return nil
+}
+
+// readOptions returns the query options for reading on branch as of at.
+// Reads within a transaction see its branch.
+func readOptions(tx *database.Transaction, branch string, at *time.Time) (database.QueryOptions, error) {
+	options := database.DefaultQueryOptions()
+	options.BranchID = branchOrMain(branch)
+	if tx != nil {
+		options.BranchID = branchOrMain(tx.GetBranchID())
+	}
+	options.PointInTime = at
+	if err := options.Validate(); err != nil {
+		return options, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return options, nil
+}
+
+// branchOrMain returns branch, or main when it is empty
+func branchOrMain(branch string) string {
+	if branch == "" {
+		return "main"
+	}
+	return branch
+}
+
+// statusError returns err as a gRPC status error, with a code for the
+// database errors clients are expected to handle
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	code := codes.Unknown
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, database.ErrAlreadyExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, database.ErrPermissionDenied):
+		code = codes.PermissionDenied
+	case errors.Is(err, database.ErrWriteConflict):
+		code = codes.Aborted
+	case errors.Is(err, database.ErrNoTransaction), errors.Is(err, database.ErrTxNotActive):
+		code = codes.FailedPrecondition
+	}
+	return status.Error(code, err.Error())
+}
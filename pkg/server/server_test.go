@@ -0,0 +1,398 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/filesystem"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+	pb "github.com/brainwavecollective/stone-os/pkg/server/filesystempb"
+)
+
+// newTestClient serves a fresh in-memory database over an in-process
+// connection and returns a client for it
+func newTestClient(t *testing.T) pb.FilesystemClient {
+	t.Helper()
+	_, client := newTestServer(t)
+	return client
+}
+
+// newTestServer is newTestClient, also returning the server
+func newTestServer(t *testing.T) (*Server, pb.FilesystemClient) {
+	t.Helper()
+	db, err := database.Connect("inmemory", t.Name())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := schema.Initialize(db); err != nil {
+		t.Fatalf("initialize schema: %v", err)
+	}
+
+	srv := New(db, filesystem.NewFileManager(db), "system")
+	l := bufconn.Listen(1 << 20)
+	go srv.Serve(l)
+	t.Cleanup(func() {
+		srv.RollbackAll()
+		l.Close()
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, pb.NewFilesystemClient(conn)
+}
+
+// createFile creates a file outside any open transaction
+func createFile(t *testing.T, client pb.FilesystemClient, path, content string) *pb.File {
+	t.Helper()
+	file, err := client.CreateFile(context.Background(), &pb.CreateFileRequest{Path: path, Content: []byte(content)})
+	if err != nil {
+		t.Fatalf("create %s: %v", path, err)
+	}
+	return file
+}
+
+// list collects the entries List streams for a request
+func list(t *testing.T, client pb.FilesystemClient, req *pb.ListRequest) []*pb.Entry {
+	t.Helper()
+	stream, err := client.List(context.Background(), req)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var entries []*pb.Entry
+	for {
+		entry, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// queryNames collects the first column of the rows Query streams for a
+// request, as strings
+func queryNames(t *testing.T, client pb.FilesystemClient, req *pb.QueryRequest) []string {
+	t.Helper()
+	stream, err := client.Query(context.Background(), req)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	var names []string
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return names
+		}
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		if resp.Row != nil {
+			names = append(names, resp.Row.Values[0].GetStringValue())
+		}
+	}
+}
+
+// wantCode fails the test unless err is a status error with code
+func wantCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != code {
+		t.Errorf("error = %v, want code %s", err, code)
+	}
+}
+
+func TestTransactionLifecycle(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	begin, err := client.Begin(ctx, &pb.BeginRequest{})
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	created, err := client.CreateFile(ctx, &pb.CreateFileRequest{
+		Path: "/notes.txt", Content: []byte("hello"), MimeType: "text/plain", TxId: begin.TxId,
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if created.Branch != "main" || created.Metadata.MimeType != "text/plain" {
+		t.Errorf("created = %v", created)
+	}
+
+	file, err := client.GetFile(ctx, &pb.GetFileRequest{Path: "/notes.txt", TxId: begin.TxId})
+	if err != nil {
+		t.Fatalf("get in transaction: %v", err)
+	}
+	if string(file.Content) != "hello" || file.Id != created.Id {
+		t.Errorf("file in transaction = %v", file)
+	}
+
+	if _, err := client.Commit(ctx, &pb.TransactionRequest{TxId: begin.TxId}); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	file, err = client.GetFile(ctx, &pb.GetFileRequest{Path: "/notes.txt"})
+	if err != nil {
+		t.Fatalf("get after commit: %v", err)
+	}
+	if string(file.Content) != "hello" {
+		t.Errorf("content after commit = %q", file.Content)
+	}
+
+	_, err = client.Commit(ctx, &pb.TransactionRequest{TxId: begin.TxId})
+	wantCode(t, err, codes.NotFound)
+}
+
+func TestRollbackDiscardsWrites(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	begin, err := client.Begin(ctx, &pb.BeginRequest{})
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, err := client.CreateFile(ctx, &pb.CreateFileRequest{Path: "/draft.txt", TxId: begin.TxId}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := client.Rollback(ctx, &pb.TransactionRequest{TxId: begin.TxId}); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+
+	_, err = client.GetFile(ctx, &pb.GetFileRequest{Path: "/draft.txt"})
+	wantCode(t, err, codes.NotFound)
+}
+
+func TestErrorCodes(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	createFile(t, client, "/a.txt", "a")
+
+	_, err := client.GetFile(ctx, &pb.GetFileRequest{Path: "/missing.txt"})
+	wantCode(t, err, codes.NotFound)
+
+	_, err = client.GetFile(ctx, &pb.GetFileRequest{Path: "/a.txt", TxId: "no-such-tx"})
+	wantCode(t, err, codes.NotFound)
+
+	_, err = client.CreateFile(ctx, &pb.CreateFileRequest{Path: "/a.txt"})
+	wantCode(t, err, codes.AlreadyExists)
+
+	stream, err := client.Query(ctx, &pb.QueryRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	wantCode(t, err, codes.InvalidArgument)
+}
+
+func TestListStreamsEntriesInNameOrder(t *testing.T) {
+	client := newTestClient(t)
+	for _, name := range []string{"c.txt", "a.txt", "b.txt"} {
+		createFile(t, client, "/"+name, name)
+	}
+
+	entries := list(t, client, &pb.ListRequest{Path: "/"})
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	// The root also holds the directories schema.Initialize creates, so
+	// only the files' relative order is checked.
+	var files []string
+	for _, name := range names {
+		if name == "a.txt" || name == "b.txt" || name == "c.txt" {
+			files = append(files, name)
+		}
+	}
+	if len(files) != 3 || files[0] != "a.txt" || files[1] != "b.txt" || files[2] != "c.txt" {
+		t.Errorf("names = %v", names)
+	}
+
+	limited := list(t, client, &pb.ListRequest{Path: "/", Limit: 2})
+	if len(limited) != 2 || limited[0].Name != names[0] || limited[1].Name != names[1] {
+		t.Errorf("limited = %v, want the first two of %v", limited, names)
+	}
+}
+
+func TestGetFileAtPointInTime(t *testing.T) {
+	client := newTestClient(t)
+	createFile(t, client, "/old.txt", "old")
+	before := time.Now().Add(-time.Hour)
+
+	_, err := client.GetFile(context.Background(), &pb.GetFileRequest{
+		Path: "/old.txt", At: timestamppb.New(before),
+	})
+	wantCode(t, err, codes.NotFound)
+
+	file, err := client.GetFile(context.Background(), &pb.GetFileRequest{
+		Path: "/old.txt", At: timestamppb.Now(),
+	})
+	if err != nil {
+		t.Fatalf("get now: %v", err)
+	}
+	if string(file.Content) != "old" {
+		t.Errorf("content = %q", file.Content)
+	}
+}
+
+func TestQueryStreamsRows(t *testing.T) {
+	client := newTestClient(t)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		createFile(t, client, "/"+name, name)
+	}
+
+	stream, err := client.Query(context.Background(), &pb.QueryRequest{
+		Sql: "SELECT name FROM resources WHERE type = $1 AND valid_to IS NULL ORDER BY name",
+		Args: []*pb.Value{
+			{Kind: &pb.Value_StringValue{StringValue: schema.ResourceTypeFile}},
+		},
+		MaxRows: 2,
+	})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+
+	var columns, rows []string
+	truncated := false
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		if resp.Columns != nil {
+			columns = resp.Columns
+		}
+		if resp.Row != nil {
+			rows = append(rows, resp.Row.Values[0].GetStringValue())
+		}
+		truncated = truncated || resp.Truncated
+	}
+
+	if len(columns) != 1 || columns[0] != "name" {
+		t.Errorf("columns = %v", columns)
+	}
+	if len(rows) != 2 || rows[0] != "a.txt" || rows[1] != "b.txt" {
+		t.Errorf("rows = %v", rows)
+	}
+	if !truncated {
+		t.Error("query was not marked truncated")
+	}
+}
+
+func TestCreateFileAfterDirectoryChange(t *testing.T) {
+	srv, client := newTestServer(t)
+	createFile(t, client, "/tmp/a.txt", "a")
+
+	// A metadata change gives /tmp a new version ID that /tmp/a.txt does
+	// not point at
+	err := srv.db.WithTransaction(func(tx *database.Transaction) error {
+		now := time.Now()
+		if _, err := tx.Execute(`
+			INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id, branch_id)
+			SELECT 'tmp-v2', type, name, parent_id, path, content, metadata, ?, ?, branch_id
+			FROM resources WHERE path = '/tmp' AND valid_to IS NULL
+		`, now, tx.GetID()); err != nil {
+			return err
+		}
+		_, err := tx.Execute(`UPDATE resources SET valid_to = ? WHERE path = '/tmp' AND id <> 'tmp-v2' AND valid_to IS NULL`, now)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.CreateFile(context.Background(), &pb.CreateFileRequest{Path: "/tmp/a.txt"})
+	wantCode(t, err, codes.AlreadyExists)
+}
+
+func TestQueryVisible(t *testing.T) {
+	client := newTestClient(t)
+	createFile(t, client, "/a.txt", "a")
+	between := time.Now()
+	createFile(t, client, "/b.txt", "b")
+
+	files := &pb.Value{Kind: &pb.Value_StringValue{StringValue: schema.ResourceTypeFile}}
+	tests := []struct {
+		name string
+		req  *pb.QueryRequest
+		want []string
+	}{
+		{"branch", &pb.QueryRequest{
+			Sql:    "SELECT name FROM visible WHERE type = ? ORDER BY name",
+			Branch: "main",
+		}, []string{"a.txt", "b.txt"}},
+		{"point in time", &pb.QueryRequest{
+			Sql: "SELECT name FROM visible WHERE type = ? ORDER BY name",
+			At:  timestamppb.New(between),
+		}, []string{"a.txt"}},
+		{"other branch", &pb.QueryRequest{
+			Sql:    "SELECT name FROM visible WHERE type = ?",
+			Branch: "feature",
+		}, nil},
+		{"own with clause", &pb.QueryRequest{
+			Sql:    "with files AS (SELECT name FROM visible WHERE type = ?) SELECT name FROM files ORDER BY name DESC",
+			Branch: "main",
+		}, []string{"b.txt", "a.txt"}},
+	}
+	for _, tt := range tests {
+		tt.req.Args = []*pb.Value{files}
+		if got := queryNames(t, client, tt.req); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: names = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Without a branch or point in time the statement runs as written
+	stream, err := client.Query(context.Background(), &pb.QueryRequest{Sql: "SELECT name FROM visible"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if err == nil {
+		t.Error("visible defined without a branch or point in time")
+	}
+}
+
+func TestListenLoopbackOnly(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:0", "localhost:0", ":0", "[::1]:0"} {
+		l, err := Listen(addr)
+		if err != nil {
+			if addr == "[::1]:0" {
+				continue // no IPv6 loopback here
+			}
+			t.Errorf("Listen(%s): %v", addr, err)
+			continue
+		}
+		if ip := l.Addr().(*net.TCPAddr).IP; !ip.IsLoopback() {
+			t.Errorf("Listen(%s) listens on %s", addr, ip)
+		}
+		l.Close()
+	}
+
+	for _, addr := range []string{"0.0.0.0:0", "[::]:0", "192.0.2.1:0", "example.com:0", "no-port"} {
+		if l, err := Listen(addr); err == nil {
+			l.Close()
+			t.Errorf("Listen(%s) succeeded", addr)
+		}
+	}
+}
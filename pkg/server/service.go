@@ -0,0 +1,326 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/filesystem"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+	pb "github.com/brainwavecollective/stone-os/pkg/server/filesystempb"
+)
+
+// service implements the Filesystem service on a Server
+type service struct {
+	pb.UnimplementedFilesystemServer
+	server *Server
+}
+
+// GetFile returns a file with its content
+func (svc *service) GetFile(ctx context.Context, req *pb.GetFileRequest) (*pb.File, error) {
+	tx, err := svc.server.transaction(req.TxId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	options, err := readOptions(tx, req.Branch, timeOf(req.At))
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := svc.server.fm.GetFile(req.Path, tx, options)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return newFile(file, options.BranchID), nil
+}
+
+// CreateFile creates a file, failing if one exists at the path
+func (svc *service) CreateFile(ctx context.Context, req *pb.CreateFileRequest) (*pb.File, error) {
+	s := svc.server
+	var reply *pb.File
+	err := s.write(req.TxId, req.Branch, func(tx *database.Transaction) error {
+		metadata := schema.NewResourceMetadata(s.user, schema.DefaultFilePermissions)
+		metadata.MimeType = req.MimeType
+		file, err := s.fm.CreateFileWithMetadata(req.Path, req.Content, metadata, tx)
+		if err != nil {
+			return err
+		}
+		if err := s.recordOperation(tx, "CreateFile", schema.OperationKindCreate, []string{file.ID}); err != nil {
+			return err
+		}
+		reply = newFile(file, branchOrMain(tx.GetBranchID()))
+		return nil
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return reply, nil
+}
+
+// List streams the entries of a directory in name order, up to the
+// request's limit
+func (svc *service) List(req *pb.ListRequest, stream pb.Filesystem_ListServer) error {
+	if req.Limit < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid limit: %d", req.Limit)
+	}
+	tx, err := svc.server.transaction(req.TxId)
+	if err != nil {
+		return statusError(err)
+	}
+	options, err := readOptions(tx, req.Branch, timeOf(req.At))
+	if err != nil {
+		return err
+	}
+
+	resources, err := svc.server.fm.ListDirectory(req.Path, tx, options)
+	if err != nil {
+		return statusError(err)
+	}
+	if req.Limit > 0 && len(resources) > int(req.Limit) {
+		resources = resources[:req.Limit]
+	}
+
+	for _, resource := range resources {
+		entry, err := newEntry(resource)
+		if err != nil {
+			return statusError(err)
+		}
+		if err := stream.Send(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query runs a SQL statement and streams its column names and then its
+// rows, up to the request's max_rows. With a branch or point in time the
+// statement can read the resource versions visible there as visible.
+func (svc *service) Query(req *pb.QueryRequest, stream pb.Filesystem_QueryServer) error {
+	if req.Sql == "" {
+		return status.Error(codes.InvalidArgument, "query required")
+	}
+	if req.MaxRows < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid max rows: %d", req.MaxRows)
+	}
+	args := make([]interface{}, len(req.Args))
+	for i, arg := range req.Args {
+		args[i] = argValue(arg)
+	}
+
+	tx, err := svc.server.transaction(req.TxId)
+	if err != nil {
+		return statusError(err)
+	}
+	query := req.Sql
+	if req.Branch != "" || req.At != nil {
+		options, err := readOptions(tx, req.Branch, timeOf(req.At))
+		if err != nil {
+			return err
+		}
+		var scopeArgs []interface{}
+		query, scopeArgs = scopeQuery(query, options)
+		args = append(scopeArgs, args...)
+	}
+
+	var it *database.RowIterator
+	if tx != nil {
+		it, err = tx.QueryStream(query, database.QueryOptions{}, args...)
+	} else {
+		it, err = svc.server.db.QueryStream(query, database.QueryOptions{}, args...)
+	}
+	if err != nil {
+		return statusError(fmt.Errorf("query execution failed: %w", err))
+	}
+	defer it.Close()
+
+	if err := stream.Send(&pb.QueryResponse{Columns: it.Columns()}); err != nil {
+		return err
+	}
+	for it.Next() {
+		if req.MaxRows > 0 && it.Count() > int(req.MaxRows) {
+			return stream.Send(&pb.QueryResponse{Truncated: true})
+		}
+		values, err := it.Values()
+		if err != nil {
+			return statusError(err)
+		}
+		row := &pb.Row{Values: make([]*pb.Value, len(values))}
+		for i, val := range values {
+			row.Values[i] = newValue(val)
+		}
+		if err := stream.Send(&pb.QueryResponse{Row: row}); err != nil {
+			return err
+		}
+	}
+	return statusError(it.Err())
+}
+
+// leadingWith matches the WITH clause a statement may start with
+var leadingWith = regexp.MustCompile(`(?is)^with(\s+recursive)?\s+`)
+
+// scopeQuery defines visible for query as the resources visible on
+// options.BranchID as of options.PointInTime, returning the statement and
+// the arguments to put before its own
+func scopeQuery(query string, options database.QueryOptions) (string, []interface{}) {
+	condition := `branch_id = ? AND valid_to IS NULL`
+	args := []interface{}{options.BranchID}
+	if options.PointInTime != nil {
+		condition = `branch_id = ? AND valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)`
+		args = append(args, *options.PointInTime, *options.PointInTime)
+	}
+	visible := `visible AS (SELECT * FROM resources WHERE ` + condition + `)`
+
+	query = strings.TrimSpace(query)
+	if with := leadingWith.FindString(query); with != "" {
+		return with + visible + ", " + query[len(with):], args
+	}
+	return "WITH " + visible + " " + query, args
+}
+
+// Begin opens a transaction that later calls can name by its ID. It stays
+// open until committed or rolled back, or until the server stops.
+func (svc *service) Begin(ctx context.Context, req *pb.BeginRequest) (*pb.BeginResponse, error) {
+	s := svc.server
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: req.ReadOnly})
+	if err != nil {
+		return nil, statusError(fmt.Errorf("failed to begin transaction: %w", err))
+	}
+	tx.SetBranchID(branchOrMain(req.Branch))
+	tx.SetUserID(s.user)
+
+	s.mu.Lock()
+	s.txs[tx.GetID()] = tx
+	s.mu.Unlock()
+
+	return &pb.BeginResponse{TxId: tx.GetID()}, nil
+}
+
+// Commit commits an open transaction
+func (svc *service) Commit(ctx context.Context, req *pb.TransactionRequest) (*pb.Empty, error) {
+	tx, err := svc.server.endTransaction(req.TxId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, statusError(fmt.Errorf("failed to commit transaction: %w", err))
+	}
+	return &pb.Empty{}, nil
+}
+
+// Rollback rolls back an open transaction
+func (svc *service) Rollback(ctx context.Context, req *pb.TransactionRequest) (*pb.Empty, error) {
+	tx, err := svc.server.endTransaction(req.TxId)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		return nil, statusError(fmt.Errorf("failed to roll back transaction: %w", err))
+	}
+	return &pb.Empty{}, nil
+}
+
+// timeOf returns the time of a timestamp, or nil when it is unset
+func timeOf(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+// newFile builds the reply for a file
+func newFile(file *filesystem.File, branch string) *pb.File {
+	return &pb.File{
+		Id:            file.ID,
+		Path:          file.Path,
+		Branch:        branch,
+		Content:       file.Content,
+		Metadata:      newMetadata(file.Metadata),
+		TransactionId: file.TransactionID,
+	}
+}
+
+// newEntry builds a List entry for a resource
+func newEntry(resource *schema.Resource) (*pb.Entry, error) {
+	metadata, err := schema.NormalizeMetadata(resource.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata of %s: %w", resource.Path, err)
+	}
+	return &pb.Entry{
+		Id:       resource.ID,
+		Type:     resource.Type,
+		Name:     resource.Name,
+		Path:     resource.Path,
+		Metadata: newMetadata(metadata),
+	}, nil
+}
+
+// newMetadata converts resource metadata, leaving out how the content is
+// stored
+func newMetadata(m schema.ResourceMetadata) *pb.Metadata {
+	return &pb.Metadata{
+		Permissions:   m.Permissions,
+		Owner:         m.Owner,
+		Group:         m.Group,
+		CreatedAt:     timestamppb.New(m.CreatedAt),
+		ModifiedAt:    timestamppb.New(m.ModifiedAt),
+		AccessedAt:    timestamppb.New(m.AccessedAt),
+		Size:          m.Size,
+		MimeType:      m.MimeType,
+		IsExecutable:  m.IsExecutable,
+		IsHidden:      m.IsHidden,
+		IsSystem:      m.IsSystem,
+		Checksum:      m.Checksum,
+		SymlinkTarget: m.SymlinkTarget,
+	}
+}
+
+// newValue converts a value read from the database
+func newValue(val interface{}) *pb.Value {
+	switch v := val.(type) {
+	case nil:
+		return &pb.Value{}
+	case bool:
+		return &pb.Value{Kind: &pb.Value_BoolValue{BoolValue: v}}
+	case int64:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: v}}
+	case float64:
+		return &pb.Value{Kind: &pb.Value_DoubleValue{DoubleValue: v}}
+	case string:
+		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: v}}
+	case []byte:
+		return &pb.Value{Kind: &pb.Value_BytesValue{BytesValue: v}}
+	case time.Time:
+		return &pb.Value{Kind: &pb.Value_TimeValue{TimeValue: timestamppb.New(v)}}
+	default:
+		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: fmt.Sprint(v)}}
+	}
+}
+
+// argValue converts a query argument to the value passed to the database
+func argValue(v *pb.Value) interface{} {
+	switch kind := v.GetKind().(type) {
+	case *pb.Value_BoolValue:
+		return kind.BoolValue
+	case *pb.Value_IntValue:
+		return kind.IntValue
+	case *pb.Value_DoubleValue:
+		return kind.DoubleValue
+	case *pb.Value_StringValue:
+		return kind.StringValue
+	case *pb.Value_BytesValue:
+		return kind.BytesValue
+	case *pb.Value_TimeValue:
+		return kind.TimeValue.AsTime()
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,228 @@
+package shell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// CountResources prints the number of resources under a path
+// (count [path] [--type f|d])
+func (s *Shell) CountResources(args []string) error {
+	path := s.state.CurrentDirectory
+	resourceType := ""
+
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--type" {
+			path = s.resolvePath(args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("--type requires f or d")
+		}
+		i++
+		switch args[i] {
+		case "f", "file":
+			resourceType = schema.ResourceTypeFile
+		case "d", "directory":
+			resourceType = schema.ResourceTypeDirectory
+		case "l", "symlink":
+			resourceType = schema.ResourceTypeSymlink
+		default:
+			return fmt.Errorf("invalid type: %s", args[i])
+		}
+	}
+
+	condition, queryArgs := descendantCondition(s.db.Dialect(), path)
+	visibility, visibilityArgs := s.visibilityCondition()
+	query := `SELECT COUNT(*) FROM resources WHERE 1=1` + condition + visibility
+	queryArgs = append(queryArgs, visibilityArgs...)
+
+	if resourceType != "" {
+		query += ` AND type = ?`
+		queryArgs = append(queryArgs, resourceType)
+	}
+
+	var count int64
+	if err := s.queryRow(query, queryArgs, &count); err != nil {
+		return fmt.Errorf("count failed: %w", err)
+	}
+
+	fmt.Println(count)
+	return nil
+}
+
+// Summarize prints resource counts by type and total file size under a path
+// (summary [path])
+func (s *Shell) Summarize(args []string) error {
+	path := s.state.CurrentDirectory
+	if len(args) > 0 {
+		path = s.resolvePath(args[0])
+	}
+
+	condition, queryArgs := descendantCondition(s.db.Dialect(), path)
+	visibility, visibilityArgs := s.visibilityCondition()
+	queryArgs = append(queryArgs, visibilityArgs...)
+
+	query := `
+		SELECT type, COUNT(*), COALESCE(SUM(CAST(` + s.db.JSONExtract("metadata", "size") + ` AS BIGINT)), 0)
+		FROM resources
+		WHERE 1=1` + condition + visibility + `
+		GROUP BY type
+		ORDER BY type ASC
+	`
+
+	var it *database.RowIterator
+	var err error
+	if s.CurrentTransaction() != nil {
+		it, err = s.CurrentTransaction().QueryStream(query, database.QueryOptions{}, queryArgs...)
+	} else {
+		it, err = s.db.QueryStream(query, database.QueryOptions{}, queryArgs...)
+	}
+	if err != nil {
+		return fmt.Errorf("summary failed: %w", err)
+	}
+	defer it.Close()
+
+	fmt.Printf("Summary of %s:\n", path)
+	var total, totalSize int64
+	for it.Next() {
+		var resType string
+		var count, size int64
+		if err := it.Scan(&resType, &count, &size); err != nil {
+			return fmt.Errorf("failed to scan summary: %w", err)
+		}
+		fmt.Printf("  %-10s %d\n", resType, count)
+		total += count
+		totalSize += size
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("  %-10s %d\n", "total", total)
+	fmt.Printf("  %-10s %s\n", "size", util.FormatByteSize(totalSize))
+	return nil
+}
+
+// DefaultBloatEntries is how many resources bloat lists by default
+const DefaultBloatEntries = 20
+
+// ShowBloat lists the resources under a path on the current branch with
+// the most versions, and the bytes stored across all of their versions
+// (bloat [path] [-n N])
+func (s *Shell) ShowBloat(args []string) error {
+	path := s.state.CurrentDirectory
+	limit := DefaultBloatEntries
+
+	for i := 0; i < len(args); i++ {
+		if args[i] != "-n" {
+			path = s.resolvePath(args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("-n requires a count")
+		}
+		i++
+		n, err := strconv.Atoi(args[i])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid count: %s", args[i])
+		}
+		limit = n
+	}
+
+	condition, queryArgs := subtreeCondition(s.db.Dialect(), path)
+	queryArgs = append([]interface{}{s.state.CurrentBranch}, queryArgs...)
+	queryArgs = append(queryArgs, limit)
+
+	query := `
+		SELECT path, COUNT(*) AS versions, COALESCE(SUM(LENGTH(content)), 0) AS stored
+		FROM resources
+		WHERE branch_id = ?` + condition + `
+		GROUP BY path
+		ORDER BY versions DESC, stored DESC, path ASC
+		LIMIT ?
+	`
+
+	var it *database.RowIterator
+	var err error
+	if s.CurrentTransaction() != nil {
+		it, err = s.CurrentTransaction().QueryStream(query, database.QueryOptions{}, queryArgs...)
+	} else {
+		it, err = s.db.QueryStream(query, database.QueryOptions{}, queryArgs...)
+	}
+	if err != nil {
+		return fmt.Errorf("bloat failed: %w", err)
+	}
+	defer it.Close()
+
+	fmt.Printf("%8s %10s  %s\n", "VERSIONS", "STORED", "PATH")
+	for it.Next() {
+		var resourcePath string
+		var versions, stored int64
+		if err := it.Scan(&resourcePath, &versions, &stored); err != nil {
+			return fmt.Errorf("failed to scan bloat: %w", err)
+		}
+		fmt.Printf("%8d %10s  %s\n", versions, util.FormatByteSize(stored), resourcePath)
+	}
+	return it.Err()
+}
+
+// queryRow runs a single-row query in the current transaction, if any, and
+// scans it into dest
+func (s *Shell) queryRow(query string, args []interface{}, dest ...interface{}) error {
+	rows, err := s.queries().ExecuteQuery(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("no rows returned")
+	}
+	return rows.Scan(dest...)
+}
+
+// descendantCondition returns a predicate matching resources strictly below
+// path. Descendants are matched on the stored path as a range in byte order,
+// the way ListSubtree matches them, so the match is case-sensitive.
+func descendantCondition(d database.Dialect, path string) (string, []interface{}) {
+	prefix, upper := subtreeRange(path)
+	column := d.ByteOrder("path")
+	return ` AND ` + column + ` >= ? AND ` + column + ` < ? AND path != ?`, []interface{}{prefix, upper, path}
+}
+
+// subtreeCondition returns a predicate matching path and everything below it
+func subtreeCondition(d database.Dialect, path string) (string, []interface{}) {
+	if path == "/" {
+		return "", nil
+	}
+	prefix, upper := subtreeRange(path)
+	column := d.ByteOrder("path")
+	return ` AND (path = ? OR (` + column + ` >= ? AND ` + column + ` < ?))`, []interface{}{path, prefix, upper}
+}
+
+// subtreeRange returns the bounds of the paths below path: they sort between
+// path with a trailing slash and path with that slash replaced by the next
+// byte, '0'
+func subtreeRange(path string) (string, string) {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	return prefix, prefix[:len(prefix)-1] + "0"
+}
+
+// visibilityCondition returns the predicate selecting the versions visible on
+// the shell's current branch and point in time
+func (s *Shell) visibilityCondition() (string, []interface{}) {
+	if s.PointInTime() != nil {
+		return ` AND branch_id = ? AND valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)`,
+			[]interface{}{s.state.CurrentBranch, *s.PointInTime(), *s.PointInTime()}
+	}
+	return ` AND branch_id = ? AND valid_to IS NULL`, []interface{}{s.state.CurrentBranch}
+}
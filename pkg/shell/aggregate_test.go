@@ -0,0 +1,57 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountMatchesPathCase(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /Docs")
+	run(t, sh, "mkdir /docs")
+	createRaw(t, sh, "/Docs/a.txt", "a")
+	createRaw(t, sh, "/docs/b.txt", "b")
+	createRaw(t, sh, "/docs/c.txt", "c")
+
+	if got := strings.TrimSpace(run(t, sh, "count /docs")); got != "2" {
+		t.Errorf("count /docs = %s, want 2", got)
+	}
+	if got := strings.TrimSpace(run(t, sh, "count /Docs")); got != "1" {
+		t.Errorf("count /Docs = %s, want 1", got)
+	}
+}
+
+func TestCountTreatsWildcardsLiterally(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /a_b")
+	run(t, sh, "mkdir /axb")
+	run(t, sh, "mkdir /a%")
+	createRaw(t, sh, "/a_b/one", "1")
+	createRaw(t, sh, "/axb/two", "2")
+	createRaw(t, sh, "/a%/three", "3")
+	createRaw(t, sh, "/a%/four", "4")
+
+	if got := strings.TrimSpace(run(t, sh, "count /a_b")); got != "1" {
+		t.Errorf("count /a_b = %s, want 1", got)
+	}
+	if got := strings.TrimSpace(run(t, sh, "count /a%")); got != "2" {
+		t.Errorf("count /a%% = %s, want 2", got)
+	}
+}
+
+func TestSubtreeCondition(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /src")
+	run(t, sh, "mkdir /SRC")
+	run(t, sh, "mkdir /src-old")
+	createRaw(t, sh, "/src/main.go", "package main")
+	createRaw(t, sh, "/SRC/main.go", "package main")
+	createRaw(t, sh, "/src-old/main.go", "package main")
+
+	condition, args := subtreeCondition(sh.db.Dialect(), "/src")
+	visibility, visibilityArgs := sh.visibilityCondition()
+	n := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE 1=1`+condition+visibility, append(args, visibilityArgs...)...)
+	if n != 2 {
+		t.Errorf("subtree of /src has %d resources, want 2", n)
+	}
+}
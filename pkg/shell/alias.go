@@ -0,0 +1,148 @@
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Alias defines, shows, or lists aliases. It takes the raw text after the
+// command name so that quoted values keep their spacing. Aliases are saved
+// for the user and loaded when their next session starts; one defined in an
+// open transaction is saved when it commits.
+//
+//	alias ll='ls -l'
+//	alias ll
+//	alias
+func (s *Shell) Alias(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		names := make([]string, 0, len(s.state.Aliases))
+		for name := range s.state.Aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("alias %s='%s'\n", name, s.state.Aliases[name])
+		}
+		return nil
+	}
+
+	eq := strings.Index(rest, "=")
+	if eq < 0 {
+		expansion, ok := s.state.Aliases[rest]
+		if !ok {
+			return fmt.Errorf("alias not found: %s", rest)
+		}
+		fmt.Printf("alias %s='%s'\n", rest, expansion)
+		return nil
+	}
+
+	name := strings.TrimSpace(rest[:eq])
+	value := unquote(strings.TrimSpace(rest[eq+1:]))
+	if name == "" || strings.ContainsAny(name, " \t'\"") {
+		return fmt.Errorf("invalid alias name: %s", name)
+	}
+	if value == "" {
+		return fmt.Errorf("alias value required")
+	}
+
+	if err := s.saveAlias(name, value); err != nil {
+		return err
+	}
+	if s.state.Aliases == nil {
+		s.state.Aliases = make(map[string]string)
+	}
+	s.state.Aliases[name] = value
+	return nil
+}
+
+// Unalias removes aliases (unalias <name>...)
+func (s *Shell) Unalias(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("alias name required")
+	}
+	for _, name := range args {
+		if _, ok := s.state.Aliases[name]; !ok {
+			return fmt.Errorf("alias not found: %s", name)
+		}
+		if _, err := s.execute(`DELETE FROM aliases WHERE username = ? AND name = ?`, s.state.User, name); err != nil {
+			return fmt.Errorf("failed to remove alias %s: %w", name, err)
+		}
+		delete(s.state.Aliases, name)
+	}
+	return nil
+}
+
+// saveAlias saves an alias for the shell user, replacing any earlier one
+// with the same name
+func (s *Shell) saveAlias(name, expansion string) error {
+	now := time.Now()
+	result, err := s.execute(`
+		UPDATE aliases SET expansion = ?, updated_at = ? WHERE username = ? AND name = ?
+	`, expansion, now, s.state.User, name)
+	if err != nil {
+		return fmt.Errorf("failed to save alias %s: %w", name, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		_, err = s.execute(`
+			INSERT INTO aliases (username, name, expansion, updated_at) VALUES (?, ?, ?, ?)
+		`, s.state.User, name, expansion, now)
+		if err != nil {
+			return fmt.Errorf("failed to save alias %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// loadAliases adds the aliases saved for the shell user to the session
+func (s *Shell) loadAliases() error {
+	rows, err := s.queries().ExecuteQuery(`SELECT name, expansion FROM aliases WHERE username = ?`, s.state.User)
+	if err != nil {
+		return fmt.Errorf("failed to load aliases: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, expansion string
+		if err := rows.Scan(&name, &expansion); err != nil {
+			return fmt.Errorf("failed to scan alias: %w", err)
+		}
+		if s.state.Aliases == nil {
+			s.state.Aliases = make(map[string]string)
+		}
+		s.state.Aliases[name] = expansion
+	}
+	return rows.Err()
+}
+
+// expandAliases replaces the first word of a command with its alias
+// expansion. A word is not expanded again once it has been expanded, so
+// self-referencing (alias ls='ls -l') and mutually recursive aliases terminate.
+func (s *Shell) expandAliases(cmdStr string) string {
+	seen := make(map[string]bool)
+
+	for {
+		cmdStr = strings.TrimSpace(cmdStr)
+		word, rest := cmdStr, ""
+		if i := strings.IndexAny(cmdStr, " \t"); i >= 0 {
+			word, rest = cmdStr[:i], cmdStr[i:]
+		}
+
+		expansion, ok := s.state.Aliases[word]
+		if !ok || seen[word] {
+			return cmdStr
+		}
+		seen[word] = true
+		cmdStr = expansion + rest
+	}
+}
+
+// unquote strips one pair of matching surrounding quotes
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
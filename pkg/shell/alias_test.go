@@ -0,0 +1,69 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAliasExpansion(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "alias ll='ls /tmp'")
+	run(t, sh, "alias ls='ls'")
+	run(t, sh, "mkdir /tmp/docs")
+
+	if output := run(t, sh, "ll"); !strings.Contains(output, "docs") {
+		t.Errorf("ll:\n%s", output)
+	}
+	if output := run(t, sh, "alias ll"); output != "alias ll='ls /tmp'\n" {
+		t.Errorf("alias ll = %q", output)
+	}
+
+	run(t, sh, "unalias ll")
+	if _, err := runErr(sh, "ll"); err == nil {
+		t.Error("ll ran after unalias")
+	}
+}
+
+// TestAliasesSavedForUser loads a user's aliases into their next session
+// and not into other users' sessions
+func TestAliasesSavedForUser(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "alias ll='ls -h'")
+	run(t, sh, "alias ll='ls --all'")
+	run(t, sh, "alias gone=ls")
+	run(t, sh, "unalias gone")
+
+	next := NewShell(sh.db)
+	next.state.User = "system"
+	if err := next.loadAliases(); err != nil {
+		t.Fatal(err)
+	}
+	if got := next.state.Aliases; len(got) != 1 || got["ll"] != "ls --all" {
+		t.Errorf("loaded aliases %v, want ll='ls --all'", got)
+	}
+
+	other := NewShell(sh.db)
+	other.state.User = "alice"
+	if err := other.loadAliases(); err != nil {
+		t.Fatal(err)
+	}
+	if len(other.state.Aliases) != 0 {
+		t.Errorf("alice loaded system's aliases: %v", other.state.Aliases)
+	}
+}
+
+func TestAliasInAbortedTransaction(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "begin")
+	run(t, sh, "alias ll='ls -h'")
+	run(t, sh, "abort")
+
+	next := NewShell(sh.db)
+	next.state.User = "system"
+	if err := next.loadAliases(); err != nil {
+		t.Fatal(err)
+	}
+	if len(next.state.Aliases) != 0 {
+		t.Errorf("alias from aborted transaction was saved: %v", next.state.Aliases)
+	}
+}
@@ -0,0 +1,125 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// DefaultLogEntries is the number of audit log entries log shows by default
+const DefaultLogEntries = 20
+
+// operationKinds are the valid values for log --kind
+var operationKinds = []string{
+	schema.OperationKindCreate,
+	schema.OperationKindUpdate,
+	schema.OperationKindDelete,
+	schema.OperationKindMove,
+	schema.OperationKindChmod,
+	schema.OperationKindChown,
+}
+
+// recordOperation records the command being run in the audit log as a
+// change of the given kind, along with the IDs of the resource versions it
+// wrote. It runs in tx so the entry commits or rolls back with the change.
+func (s *Shell) recordOperation(tx *database.Transaction, kind string, resourceIDs []string) error {
+	affected, err := json.Marshal(resourceIDs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal affected resources: %w", err)
+	}
+
+	_, err = tx.Execute(`
+		INSERT INTO operations (id, user_id, command_text, timestamp, transaction_id, affected_resources, kind)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, database.GenerateUUID(), s.state.User, s.currentCommand, time.Now(), tx.GetID(), string(affected), kind)
+	if err != nil {
+		return fmt.Errorf("failed to record operation: %w", err)
+	}
+	return nil
+}
+
+// ShowLog prints the most recent audit log entries, newest first
+// (log [--kind <kind>] [-n <count>])
+func (s *Shell) ShowLog(args []string) error {
+	var kind string
+	limit := DefaultLogEntries
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--kind":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--kind requires a value")
+			}
+			i++
+			kind = args[i]
+			if !validOperationKind(kind) {
+				return fmt.Errorf("unknown operation kind: %s", kind)
+			}
+		case "-n":
+			if i+1 >= len(args) {
+				return fmt.Errorf("-n requires a value")
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid count: %s", args[i])
+			}
+			limit = n
+		default:
+			return fmt.Errorf("usage: log [--kind <kind>] [-n <count>]")
+		}
+	}
+
+	query := `SELECT timestamp, user_id, kind, command_text FROM operations`
+	var queryArgs []interface{}
+	if kind != "" {
+		query += ` WHERE kind = ?`
+		queryArgs = append(queryArgs, kind)
+	}
+	query += ` ORDER BY timestamp DESC LIMIT ?`
+	queryArgs = append(queryArgs, limit)
+
+	var q queryExecutor = s.db
+	if s.CurrentTransaction() != nil {
+		q = s.CurrentTransaction()
+	}
+
+	rows, err := q.ExecuteQuery(query, queryArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var timestamp time.Time
+		var user, opKind, command string
+		if err := rows.Scan(&timestamp, &user, &opKind, &command); err != nil {
+			return fmt.Errorf("failed to scan operation: %w", err)
+		}
+		fmt.Printf("%s  %-12s %-7s %s\n", timestamp.Format("2006-01-02 15:04:05"), user, opKind, command)
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if count == 0 {
+		fmt.Println("No operations recorded")
+	}
+	return nil
+}
+
+// validOperationKind reports whether kind is a known operation kind
+func validOperationKind(kind string) bool {
+	for _, k := range operationKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+	sh := newTestShell(t)
+	if output := run(t, sh, "log"); output != "No operations recorded\n" {
+		t.Errorf("log of a new database = %q", output)
+	}
+
+	run(t, sh, "mkdir /a")
+	run(t, sh, "echo x > /a/f")
+	run(t, sh, "echo y > /a/f")
+	run(t, sh, "mv /a/f /a/g")
+	run(t, sh, "chmod 600 /a/g")
+	run(t, sh, "rm /a/g")
+
+	lines := strings.Split(strings.TrimSpace(run(t, sh, "log")), "\n")
+	if len(lines) != 6 || !strings.HasSuffix(lines[0], "rm /a/g") {
+		t.Fatalf("log:\n%s", strings.Join(lines, "\n"))
+	}
+	kinds := map[string]string{
+		"create": "echo x > /a/f",
+		"update": "echo y > /a/f",
+		"move":   "mv /a/f /a/g",
+		"chmod":  "chmod 600 /a/g",
+		"delete": "rm /a/g",
+	}
+	for kind, command := range kinds {
+		output := run(t, sh, "log --kind "+kind+" -n 1")
+		if !strings.Contains(output, " "+kind+" ") || !strings.HasSuffix(output, command+"\n") {
+			t.Errorf("log --kind %s = %q, want %q", kind, output, command)
+		}
+	}
+	if got := strings.Count(run(t, sh, "log -n 2"), "\n"); got != 2 {
+		t.Errorf("log -n 2 printed %d lines", got)
+	}
+	if output := run(t, sh, "log --kind chown"); output != "No operations recorded\n" {
+		t.Errorf("log --kind chown = %q", output)
+	}
+
+	for _, command := range []string{"log --kind rename", "log --kind", "log -n 0", "log -n", "log extra"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
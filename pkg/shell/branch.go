@@ -0,0 +1,635 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	pathpkg "path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// branchNamePattern restricts branch names to characters that are safe in
+// prompts and branch-qualified paths
+var branchNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// ManageBranch lists branches, creates a branch off the current one,
+// rebases the current branch, shows how branches were forked, or purges a
+// branch's data
+func (s *Shell) ManageBranch(args []string) error {
+	if len(args) == 0 {
+		return s.ListBranches(nil)
+	}
+	if args[0] == "list" {
+		return s.ListBranches(args[1:])
+	}
+	if args[0] == "rebase" {
+		return s.RebaseBranch(args[1:])
+	}
+	if args[0] == "-d" {
+		return s.DeleteBranch(args[1:])
+	}
+	if args[0] == "checkout" {
+		return s.Checkout(args[1:])
+	}
+	if args[0] == "graph" {
+		return s.ShowBranchGraph(args[1:])
+	}
+	if args[0] == "purge" {
+		return s.PurgeBranch(args[1:])
+	}
+	return s.CreateBranch(args[0])
+}
+
+// ListBranches prints branches, marking the current one. They can be
+// filtered by status and by creator
+// (branch list [--status active|merged|abandoned] [--created-by <user>]).
+func (s *Shell) ListBranches(args []string) error {
+	var conditions []string
+	var queryArgs []interface{}
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			return fmt.Errorf("usage: branch list [--status active|merged|abandoned] [--created-by <user>]")
+		}
+		switch args[i] {
+		case "--status":
+			switch args[i+1] {
+			case schema.BranchStatusActive, schema.BranchStatusMerged, schema.BranchStatusAbandoned:
+			default:
+				return fmt.Errorf("invalid branch status: %s", args[i+1])
+			}
+			conditions = append(conditions, "status = ?")
+		case "--created-by":
+			conditions = append(conditions, "created_by = ?")
+		default:
+			return fmt.Errorf("usage: branch list [--status active|merged|abandoned] [--created-by <user>]")
+		}
+		queryArgs = append(queryArgs, args[i+1])
+		i++
+	}
+
+	query := `SELECT name, status, created_by, created_at FROM branches`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY created_at ASC, name ASC`
+
+	rows, err := s.queries().ExecuteQuery(query, queryArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to list branches: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, status, createdBy string
+		var createdAt time.Time
+		if err := rows.Scan(&name, &status, &createdBy, &createdAt); err != nil {
+			return fmt.Errorf("failed to scan branch: %w", err)
+		}
+
+		marker := " "
+		if name == s.state.CurrentBranch {
+			marker = "*"
+		}
+		fmt.Printf("%s %-20s %-10s %s %s\n", marker, name, status, createdBy, createdAt.Format("2006-01-02 15:04:05"))
+	}
+
+	return rows.Err()
+}
+
+// CreateBranch creates a branch holding a copy of the current state of the
+// current branch. The branch's base state is the transaction that created it.
+func (s *Shell) CreateBranch(name string) error {
+	if !branchNamePattern.MatchString(name) || name == "rebase" || name == "checkout" || name == "list" || name == "graph" || name == "purge" {
+		return fmt.Errorf("invalid branch name: %s", name)
+	}
+	if s.CurrentTransaction() != nil {
+		return fmt.Errorf("cannot create a branch while a transaction is in progress")
+	}
+
+	exists, err := s.branchExists(s.queries(), name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("branch %w: %s", database.ErrAlreadyExists, name)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if tx.IsActive() {
+			tx.Rollback()
+		}
+	}()
+	tx.SetBranchID(s.state.CurrentBranch)
+	tx.SetUserID(s.state.User)
+
+	now := time.Now()
+	_, err = tx.Execute(`
+		INSERT INTO branches (id, name, base_state_id, created_at, created_by, status)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, name, name, tx.GetID(), now, s.state.User, schema.BranchStatusActive)
+	if err != nil {
+		return fmt.Errorf("failed to create branch: %w", err)
+	}
+
+	// Record the branch point on the source branch
+	_, err = tx.Execute(`
+		INSERT INTO transactions (id, start_time, end_time, status, user_id, branch_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, tx.GetID(), now, now, schema.TransactionStatusCommitted, s.state.User, s.state.CurrentBranch)
+	if err != nil {
+		return fmt.Errorf("failed to record branch point: %w", err)
+	}
+
+	copied, skipped, err := s.copyBranchState(tx, s.state.CurrentBranch, name)
+	if err != nil {
+		return err
+	}
+	if err := s.fm.CopyTags(s.state.CurrentBranch, name, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	fmt.Printf("Branch %s created from %s (%d resources)\n", name, s.state.CurrentBranch, copied)
+	if skipped > 0 {
+		fmt.Printf("Skipped %d unreachable resource(s) whose parent is not current on %s\n", skipped, s.state.CurrentBranch)
+	}
+	return nil
+}
+
+// DeleteBranch marks a branch as abandoned after asking for confirmation
+// (branch -d [-f|--yes] <name>). Its history is kept, but it can no longer
+// be switched to. The main branch and the current branch cannot be deleted.
+func (s *Shell) DeleteBranch(args []string) error {
+	flags, args, err := splitFlags("branch -d", args, "-f", "--yes")
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: branch -d [-f|--yes] <name>")
+	}
+
+	name := args[0]
+	if name == "main" {
+		return fmt.Errorf("cannot delete the main branch")
+	}
+	if name == s.state.CurrentBranch {
+		return fmt.Errorf("cannot delete the current branch %s", name)
+	}
+
+	exists, err := s.branchExists(s.queries(), name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("branch %w: %s", database.ErrNotFound, name)
+	}
+
+	ok, err := s.confirm(flags, fmt.Sprintf("This deletes branch %s", name))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("branch: aborted")
+		return nil
+	}
+
+	_, err = s.execute(`UPDATE branches SET status = ? WHERE name = ?`, schema.BranchStatusAbandoned, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete branch %s: %w", name, err)
+	}
+
+	fmt.Printf("Branch %s deleted\n", name)
+	return nil
+}
+
+// PurgeBranch permanently deletes a branch with all of its resource
+// versions, transactions, their audit log entries, tags and locks, to reclaim
+// the space they take (branch purge [--force] [-f|--yes] <name>). Only an
+// abandoned branch is purged unless --force is given. Content in the blobs
+// table is shared by checksum and is left in place.
+func (s *Shell) PurgeBranch(args []string) error {
+	flags, args, err := splitFlags("branch purge", args, "--force", "-f", "--yes")
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: branch purge [--force] [-f|--yes] <name>")
+	}
+
+	name := args[0]
+	if name == "main" {
+		return fmt.Errorf("cannot purge the main branch")
+	}
+	if name == s.state.CurrentBranch {
+		return fmt.Errorf("cannot purge the current branch %s", name)
+	}
+	if s.CurrentTransaction() != nil {
+		return fmt.Errorf("cannot purge a branch while a transaction is in progress")
+	}
+
+	rows, err := s.queries().ExecuteQuery(`SELECT status FROM branches WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("failed to look up branch %s: %w", name, err)
+	}
+	var status string
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&status)
+	}
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("failed to scan branch %s: %w", name, err)
+	}
+	if !found {
+		return fmt.Errorf("branch %w: %s", database.ErrNotFound, name)
+	}
+	if status != schema.BranchStatusAbandoned && !flags["--force"] {
+		return fmt.Errorf("branch %s is %s, not abandoned; delete it with branch -d first or use --force", name, status)
+	}
+
+	ok, err := s.confirm(flags, fmt.Sprintf("This permanently deletes all data on branch %s", name))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("branch: aborted")
+		return nil
+	}
+
+	var versions, transactions int64
+	err = s.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID(s.state.CurrentBranch)
+		tx.SetUserID(s.state.User)
+
+		statements := []struct {
+			query string
+			args  []interface{}
+			count *int64
+		}{
+			{`DELETE FROM resources WHERE branch_id = ?`, []interface{}{name}, &versions},
+			{`DELETE FROM operations WHERE transaction_id IN (SELECT id FROM transactions WHERE branch_id = ?)`, []interface{}{name}, nil},
+			{`DELETE FROM transactions WHERE branch_id = ?`, []interface{}{name}, &transactions},
+			{`DELETE FROM tags WHERE substr(resource_lineage, 1, ?) = ?`, []interface{}{len(name) + 1, name + ":"}, nil},
+			{`DELETE FROM locks WHERE branch_id = ?`, []interface{}{name}, nil},
+			{`DELETE FROM branches WHERE name = ?`, []interface{}{name}, nil},
+		}
+		for _, stmt := range statements {
+			result, err := tx.Execute(stmt.query, stmt.args...)
+			if err != nil {
+				return fmt.Errorf("failed to purge branch %s: %w", name, err)
+			}
+			if stmt.count != nil {
+				if *stmt.count, err = result.RowsAffected(); err != nil {
+					return err
+				}
+			}
+		}
+		return s.recordOperation(tx, schema.OperationKindDelete, []string{})
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Branch %s purged: %d resource version(s), %d transaction(s) removed\n", name, versions, transactions)
+	return nil
+}
+
+// copyBranchState copies the current resources of one branch into another,
+// giving each copy a new ID and re-pointing parents at the copied
+// directories. Files share their content with the source instead of
+// repeating it: content kept in a source row is put in the inline store,
+// once per checksum, and the copy reads it from there. It returns how many resources were copied and how many were skipped
+// because their parent is not current on the source branch.
+func (s *Shell) copyBranchState(tx *database.Transaction, fromBranch, toBranch string) (copied, skipped int, err error) {
+	// Parents sort before their children by path length
+	rows, err := tx.ExecuteQuery(`
+		SELECT id, type, parent_id FROM resources
+		WHERE branch_id = ? AND valid_to IS NULL
+		ORDER BY LENGTH(path) ASC, path ASC
+	`, fromBranch)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read branch %s: %w", fromBranch, err)
+	}
+
+	type sourceRow struct {
+		id, resourceType, parentID string
+	}
+	var sources []sourceRow
+	for rows.Next() {
+		var row sourceRow
+		var parentID *string
+		if err := rows.Scan(&row.id, &row.resourceType, &parentID); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan resource: %w", err)
+		}
+		if parentID != nil {
+			row.parentID = *parentID
+		}
+		sources = append(sources, row)
+	}
+	rows.Close()
+
+	idMap := make(map[string]string, len(sources))
+	for _, src := range sources {
+		var parentID interface{}
+		if src.parentID != "" {
+			mapped, ok := idMap[src.parentID]
+			if !ok {
+				// The parent is not current on the source branch; the resource
+				// is unreachable there and is left behind, as are its children
+				skipped++
+				continue
+			}
+			parentID = mapped
+		}
+
+		newID, err := newResourceID(tx, src.resourceType)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		insert := `
+			INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id, branch_id)
+			SELECT ?, type, name, ?, path, content, metadata, valid_from, ?, ?
+			FROM resources WHERE id = ?
+		`
+		args := []interface{}{newID, parentID, tx.GetID(), toBranch, src.id}
+		if src.resourceType == schema.ResourceTypeFile {
+			metadata, shared, err := s.shareFileContent(tx, src.id)
+			if err != nil {
+				return 0, 0, err
+			}
+			if shared {
+				insert = `
+					INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id, branch_id)
+					SELECT ?, type, name, ?, path, NULL, ?, valid_from, ?, ?
+					FROM resources WHERE id = ?
+				`
+				args = []interface{}{newID, parentID, metadata, tx.GetID(), toBranch, src.id}
+			}
+		}
+
+		if _, err := tx.Execute(insert, args...); err != nil {
+			return 0, 0, fmt.Errorf("failed to copy resource %s: %w", src.id, err)
+		}
+		idMap[src.id] = newID
+	}
+
+	return len(idMap), skipped, nil
+}
+
+// shareFileContent makes the content of a file version shareable by its
+// copies. It returns the metadata for a copy, and whether the copy should
+// use it and leave its content column empty rather than copy the column.
+func (s *Shell) shareFileContent(tx *database.Transaction, id string) (string, bool, error) {
+	rows, err := tx.ExecuteQuery(`SELECT content, metadata FROM resources WHERE id = ?`, id)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read resource %s: %w", id, err)
+	}
+	var content []byte
+	var rawMetadata string
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&content, &rawMetadata)
+	}
+	rows.Close()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to scan resource %s: %w", id, err)
+	}
+	if !found {
+		return "", false, fmt.Errorf("resource %w: %s", database.ErrNotFound, id)
+	}
+
+	metadata, err := schema.NormalizeMetadata(json.RawMessage(rawMetadata))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to unmarshal metadata of %s: %w", id, err)
+	}
+	shared, err := s.fm.ShareContent(content, metadata, tx)
+	if err != nil {
+		return "", false, err
+	}
+	if shared.Store == "" {
+		return "", false, nil
+	}
+	metadataJSON, err := json.Marshal(shared)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return string(metadataJSON), true, nil
+}
+
+// Checkout switches to a branch, or with -b creates a branch from the
+// current state and switches to it (checkout [-b] <name>)
+func (s *Shell) Checkout(args []string) error {
+	if len(args) == 2 && args[0] == "-b" {
+		if err := s.CreateBranch(args[1]); err != nil {
+			return err
+		}
+		return s.SwitchBranch(args[1:])
+	}
+	if len(args) != 1 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("usage: checkout [-b] <name>")
+	}
+	return s.SwitchBranch(args)
+}
+
+// SwitchBranch switches to a different branch
+func (s *Shell) SwitchBranch(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("branch name required")
+	}
+	name := args[0]
+
+	if s.CurrentTransaction() != nil {
+		return fmt.Errorf("cannot switch branches while a transaction is in progress")
+	}
+
+	rows, err := s.queries().ExecuteQuery(`SELECT status FROM branches WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("failed to look up branch: %w", err)
+	}
+	var status string
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&status)
+	}
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("failed to scan branch: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("branch %w: %s", database.ErrNotFound, name)
+	}
+	if status != schema.BranchStatusActive {
+		return fmt.Errorf("branch %s is %s", name, status)
+	}
+
+	s.state.CurrentBranch = name
+	if err := s.updateSessionBranch(); err != nil {
+		return err
+	}
+
+	// Stay in the current directory if it exists on the new branch
+	if dir, err := s.lookupResource(s.queries(), s.state.CurrentDirectory); err != nil || dir.Type != schema.ResourceTypeDirectory {
+		s.state.CurrentDirectory = "/"
+	}
+
+	fmt.Printf("Switched to branch %s\n", name)
+	return nil
+}
+
+// CherryPick copies the current version of a resource, or a whole subtree,
+// from another branch onto the current branch as new versions
+// (cherry-pick [--force] <branch> <path>)
+func (s *Shell) CherryPick(args []string) error {
+	flags, args, err := splitFlags("cherry-pick", args, "--force")
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("usage: cherry-pick [--force] <branch> <path>")
+	}
+
+	source, path := args[0], s.resolvePath(args[1])
+	if source == s.state.CurrentBranch {
+		return fmt.Errorf("cannot cherry-pick from the current branch")
+	}
+
+	exists, err := s.branchExists(s.queries(), source)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("branch %w: %s", database.ErrNotFound, source)
+	}
+
+	sourceOptions, err := branchOptions(source, nil)
+	if err != nil {
+		return err
+	}
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		// Collect the source subtree first, then write, so that the walk
+		// does not observe our own changes
+		var picked []resourceEntry
+		err := s.walkSubtree(tx, &resourceEntry{Path: path}, sourceOptions, s.getWalkLimits(flags["--force"]), func(entry *resourceEntry, depth int) error {
+			picked = append(picked, *entry)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s on branch %s: %w", path, source, err)
+		}
+
+		now := time.Now()
+		var created, updated []string
+		for _, src := range picked {
+			target, err := s.lookupResource(tx, src.Path)
+			if err == nil {
+				if target.Type != src.Type {
+					return fmt.Errorf("cannot cherry-pick %s: it is a %s on %s but a %s here", src.Path, src.Type, source, target.Type)
+				}
+				if src.Type == schema.ResourceTypeDirectory {
+					// Existing directories are kept; their contents are merged
+					continue
+				}
+				newID, err := s.copyResourceVersion(tx, src.ID, target.ID, now)
+				if err != nil {
+					return err
+				}
+				updated = append(updated, newID)
+				continue
+			}
+
+			parentPath := pathpkg.Dir(src.Path)
+			parent, err := s.lookupResource(tx, parentPath)
+			if err != nil || parent.Type != schema.ResourceTypeDirectory {
+				return fmt.Errorf("cannot cherry-pick %s: parent directory %s does not exist on %s", src.Path, parentPath, s.state.CurrentBranch)
+			}
+
+			newID, err := s.insertResourceCopy(tx, src.ID, src.Type, parent.ID, now)
+			if err != nil {
+				return err
+			}
+			created = append(created, newID)
+		}
+
+		if len(created) > 0 {
+			if err := s.recordOperation(tx, schema.OperationKindCreate, created); err != nil {
+				return err
+			}
+		}
+		if len(updated) > 0 {
+			if err := s.recordOperation(tx, schema.OperationKindUpdate, updated); err != nil {
+				return err
+			}
+		}
+
+		out.Printf("Cherry-picked %s from %s (%d created, %d updated)\n", path, source, len(created), len(updated))
+		return nil
+	})
+}
+
+// copyResourceVersion replaces the current version targetID with a copy of
+// the resource sourceID, keeping the target's place in the tree, and returns
+// the new version's ID
+func (s *Shell) copyResourceVersion(tx *database.Transaction, sourceID, targetID string, now time.Time) (string, error) {
+	rows, err := tx.ExecuteQuery(`SELECT type, parent_id FROM resources WHERE id = ?`, targetID)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", targetID, err)
+	}
+	var resourceType, parentID string
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&resourceType, &parentID)
+	}
+	rows.Close()
+	if err != nil || !found {
+		return "", fmt.Errorf("failed to read %s: %v", targetID, err)
+	}
+
+	_, err = tx.Execute(`UPDATE resources SET valid_to = ? WHERE id = ? AND valid_to IS NULL`, now, targetID)
+	if err != nil {
+		return "", fmt.Errorf("failed to close old version: %w", err)
+	}
+
+	return s.insertResourceCopy(tx, sourceID, resourceType, parentID, now)
+}
+
+// insertResourceCopy inserts a copy of the resource sourceID on the current
+// branch under parentID and returns the copy's ID
+func (s *Shell) insertResourceCopy(tx *database.Transaction, sourceID, resourceType, parentID string, now time.Time) (string, error) {
+	newID, err := newResourceID(tx, resourceType)
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Execute(`
+		INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id, branch_id)
+		SELECT ?, type, name, ?, path, content, metadata, ?, ?, ?
+		FROM resources WHERE id = ?
+	`, newID, parentID, now, tx.GetID(), s.state.CurrentBranch, sourceID)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy resource %s: %w", sourceID, err)
+	}
+	return newID, nil
+}
+
+// branchExists reports whether a branch with the given name exists
+func (s *Shell) branchExists(q queryExecutor, name string) (bool, error) {
+	rows, err := q.ExecuteQuery(`SELECT 1 FROM branches WHERE name = ?`, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up branch: %w", err)
+	}
+	defer rows.Close()
+
+	return rows.Next(), nil
+}
@@ -0,0 +1,322 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// countRows returns the single number a query yields
+func countRows(t *testing.T, sh *Shell, query string, args ...interface{}) int {
+	t.Helper()
+	rows, err := sh.db.ExecuteQuery(query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var n int
+	for rows.Next() {
+		if err := rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return n
+}
+
+func TestCreateBranchSharesContent(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir -p /docs/old")
+	run(t, sh, "echo hello > /docs/a.txt")
+	run(t, sh, "echo hello > /docs/old/b.txt")
+
+	current := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE branch_id = 'main' AND valid_to IS NULL`)
+	output := run(t, sh, "branch feature")
+	if want := fmt.Sprintf("(%d resources)", current); !strings.Contains(output, want) {
+		t.Errorf("output %q does not report %s", output, want)
+	}
+
+	copies := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE branch_id = 'feature' AND type = 'file' AND content IS NOT NULL`)
+	if copies != 0 {
+		t.Errorf("%d copied file(s) repeat their content", copies)
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM blobs`); n != 1 {
+		t.Errorf("blobs holds %d entries for one distinct content, want 1", n)
+	}
+
+	run(t, sh, "checkout feature")
+	if got := readFile(t, sh, "/docs/old/b.txt"); got != "hello\n" {
+		t.Errorf("content on the branch = %q, want %q", got, "hello\n")
+	}
+	run(t, sh, "echo changed > /docs/a.txt")
+	run(t, sh, "checkout main")
+	if got := readFile(t, sh, "/docs/a.txt"); got != "hello\n" {
+		t.Errorf("content on main = %q after writing the branch", got)
+	}
+}
+
+// TestCreateBranchReportsSkipped ends a directory's current version without
+// ending its children, leaving them unreachable
+func TestCreateBranchReportsSkipped(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir -p /lost/deeper")
+	run(t, sh, "echo x > /lost/deeper/f")
+	run(t, sh, "echo y > /kept")
+	if _, err := sh.db.ExecuteStatement(`UPDATE resources SET valid_to = valid_from WHERE path = '/lost' AND valid_to IS NULL`); err != nil {
+		t.Fatal(err)
+	}
+
+	current := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE branch_id = 'main' AND valid_to IS NULL`)
+	output := run(t, sh, "branch feature")
+	if want := fmt.Sprintf("(%d resources)", current-2); !strings.Contains(output, want) {
+		t.Errorf("output %q does not report %s", output, want)
+	}
+	if !strings.Contains(output, "Skipped 2 unreachable resource(s)") {
+		t.Errorf("skipped resources not reported: %q", output)
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE branch_id = 'feature' AND path LIKE '/lost%'`); n != 0 {
+		t.Errorf("%d unreachable resource(s) copied", n)
+	}
+}
+
+func TestCherryPick(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "checkout -b feature")
+	run(t, sh, "echo new > /file.txt")
+	run(t, sh, "mkdir -p /tree/sub")
+	run(t, sh, "echo leaf > /tree/sub/leaf")
+	run(t, sh, "checkout main")
+
+	run(t, sh, "cherry-pick feature /file.txt")
+	if got := readFile(t, sh, "/file.txt"); got != "new\n" {
+		t.Errorf("/file.txt = %q, want %q", got, "new\n")
+	}
+	run(t, sh, "cherry-pick feature /tree")
+	if got := readFile(t, sh, "/tree/sub/leaf"); got != "leaf\n" {
+		t.Errorf("/tree/sub/leaf = %q, want %q", got, "leaf\n")
+	}
+
+	if _, err := runErr(sh, "cherry-pick feature /missing"); err == nil {
+		t.Error("cherry-picking a missing path succeeded")
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM transactions WHERE user_id = '' OR branch_id = ''`); n != 0 {
+		t.Errorf("%d transactions recorded without a user or branch", n)
+	}
+}
+
+// TestRebaseAfterSharedCopy rebases a branch whose files share their
+// content with main, which must not count as changes
+func TestRebaseAfterSharedCopy(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo one > /a")
+	run(t, sh, "checkout -b feature")
+	run(t, sh, "echo two > /b")
+	run(t, sh, "checkout main")
+	run(t, sh, "echo three > /c")
+	run(t, sh, "checkout feature")
+
+	output := run(t, sh, "branch rebase main")
+	if strings.Contains(output, "onflict") {
+		t.Fatalf("rebase found conflicts:\n%s", output)
+	}
+	for path, want := range map[string]string{"/a": "one\n", "/b": "two\n", "/c": "three\n"} {
+		if got := readFile(t, sh, path); got != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+}
+
+// TestRebaseClean rebases a branch whose changes do not overlap those on the
+// branch it is rebased onto
+func TestRebaseClean(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo base > /a")
+	run(t, sh, "echo base > /b")
+	run(t, sh, "checkout -b feature")
+	run(t, sh, "echo ours > /a")
+	run(t, sh, "checkout main")
+	run(t, sh, "echo theirs > /b")
+	run(t, sh, "echo new > /c")
+	run(t, sh, "checkout feature")
+
+	output := run(t, sh, "branch rebase main")
+	if !strings.Contains(output, "Rebased feature onto main") {
+		t.Fatalf("rebase output:\n%s", output)
+	}
+	for path, want := range map[string]string{"/a": "ours\n", "/b": "theirs\n", "/c": "new\n"} {
+		if got := readFile(t, sh, path); got != want {
+			t.Errorf("%s on feature = %q, want %q", path, got, want)
+		}
+	}
+	if _, err := runErr(sh, "branch rebase --continue"); err == nil {
+		t.Error("continue succeeded after a clean rebase")
+	}
+
+	run(t, sh, "checkout main")
+	for path, want := range map[string]string{"/a": "base\n", "/b": "theirs\n", "/c": "new\n"} {
+		if got := readFile(t, sh, path); got != want {
+			t.Errorf("%s on main = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRebaseConflictAbort(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo base > /f")
+	run(t, sh, "checkout -b feature")
+	run(t, sh, "echo ours > /f")
+	run(t, sh, "checkout main")
+	run(t, sh, "echo theirs > /f")
+	run(t, sh, "checkout feature")
+
+	output := run(t, sh, "branch rebase main")
+	if !strings.Contains(output, "1 conflict(s) marked") {
+		t.Fatalf("rebase output:\n%s", output)
+	}
+	want := "<<<<<<< feature\nours\n||||||| base\nbase\n=======\ntheirs\n>>>>>>> main\n"
+	if got := readFile(t, sh, "/f"); got != want {
+		t.Errorf("/f with conflict markers = %q, want %q", got, want)
+	}
+
+	run(t, sh, "echo half resolved > /f")
+	output = run(t, sh, "branch rebase --abort")
+	if !strings.Contains(output, "Restored 1 file(s)") {
+		t.Errorf("abort output:\n%s", output)
+	}
+	if got := readFile(t, sh, "/f"); got != "ours\n" {
+		t.Errorf("/f after abort = %q, want the version from before the rebase", got)
+	}
+	if _, err := runErr(sh, "branch rebase --abort"); err == nil {
+		t.Error("second abort succeeded")
+	}
+}
+
+func TestCheckoutNewBranch(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /work")
+	run(t, sh, "cd /work")
+
+	output := run(t, sh, "checkout -b feature")
+	if !strings.Contains(output, "Branch feature created from main") || !strings.HasSuffix(output, "Switched to branch feature\n") {
+		t.Errorf("checkout -b = %q", output)
+	}
+	if sh.state.CurrentBranch != "feature" || sh.state.CurrentDirectory != "/work" {
+		t.Errorf("on %s in %s, want feature in /work", sh.state.CurrentBranch, sh.state.CurrentDirectory)
+	}
+
+	// A failed create does not switch
+	run(t, sh, "checkout main")
+	if _, err := runErr(sh, "checkout -b feature"); err == nil {
+		t.Error("checkout -b of an existing branch succeeded")
+	}
+	if sh.state.CurrentBranch != "main" {
+		t.Errorf("on %s after a failed checkout -b", sh.state.CurrentBranch)
+	}
+	for _, command := range []string{"checkout", "checkout -x feature", "checkout missing", "checkout -b list"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
+
+func TestListBranchesFilters(t *testing.T) {
+	sh := newTestShell(t)
+	addTestUser(t, sh, "alice")
+	run(t, sh, "branch one")
+	sh.state.User = "alice"
+	run(t, sh, "branch two")
+	sh.state.User = "system"
+	run(t, sh, "branch -d -f one")
+
+	names := func(command string) []string {
+		var names []string
+		for _, line := range strings.Split(strings.TrimSpace(run(t, sh, command)), "\n") {
+			if fields := strings.Fields(strings.TrimPrefix(line, "*")); len(fields) > 0 {
+				names = append(names, fields[0])
+			}
+		}
+		return names
+	}
+	if got := strings.Join(names("branch list --created-by alice"), ","); got != "two" {
+		t.Errorf("branches created by alice = %s", got)
+	}
+	if got := strings.Join(names("branch list --status abandoned"), ","); got != "one" {
+		t.Errorf("abandoned branches = %s", got)
+	}
+	if got := strings.Join(names("branch list --status active --created-by system"), ","); got != "main" {
+		t.Errorf("active branches created by system = %s", got)
+	}
+	for _, command := range []string{"branch list --status open", "branch list --status", "branch list --owner alice"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
+
+func TestPurgeBranch(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "checkout -b doomed")
+	run(t, sh, "echo only here > /tmp/doomed.txt")
+	run(t, sh, "tag /tmp/doomed.txt gone")
+	run(t, sh, "checkout main")
+
+	if _, err := runErr(sh, "branch purge -f doomed"); err == nil || !strings.Contains(err.Error(), "not abandoned") {
+		t.Errorf("purging an active branch: %v", err)
+	}
+	for _, command := range []string{"branch purge -f main", "branch purge -f missing", "branch purge"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+
+	run(t, sh, "branch -d -f doomed")
+	output := run(t, sh, "branch purge -f doomed")
+	if !strings.HasPrefix(output, "Branch doomed purged: ") {
+		t.Errorf("branch purge = %q", output)
+	}
+	for _, table := range []string{"resources", "transactions", "locks"} {
+		if n := countRows(t, sh, `SELECT COUNT(*) FROM `+table+` WHERE branch_id = 'doomed'`); n != 0 {
+			t.Errorf("%d row(s) of %s left on the purged branch", n, table)
+		}
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM tags WHERE resource_lineage LIKE 'doomed:%'`); n != 0 {
+		t.Errorf("%d tag(s) left on the purged branch", n)
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM branches WHERE name = 'doomed'`); n != 0 {
+		t.Error("purged branch is still listed")
+	}
+
+	// An active branch goes with --force
+	run(t, sh, "branch kept")
+	run(t, sh, "branch purge --force -f kept")
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM branches WHERE name = 'kept'`); n != 0 {
+		t.Error("branch purge --force left the branch")
+	}
+}
+
+func TestBranchGraph(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "checkout -b feature")
+	run(t, sh, "branch nested")
+	run(t, sh, "checkout main")
+	run(t, sh, "branch other")
+	run(t, sh, "checkout feature")
+
+	lines := strings.Split(strings.TrimRight(run(t, sh, "branch graph"), "\n"), "\n")
+	want := []string{
+		"  main [active]",
+		"* +-- feature [active] forked from main at ",
+		"  |   `-- nested [active] forked from feature at ",
+		"  `-- other [active] forked from main at ",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("branch graph:\n%s", strings.Join(lines, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(lines[i], want[i]) {
+			t.Errorf("line %d = %q, want prefix %q", i, lines[i], want[i])
+		}
+	}
+	if _, err := runErr(sh, "branch graph extra"); err == nil {
+		t.Error("branch graph with an argument succeeded")
+	}
+}
@@ -0,0 +1,159 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/filesystem"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// DefaultMaxCatBytes is the largest file cat prints without --force
+const DefaultMaxCatBytes = 1 << 20
+
+// CatFile writes the contents of one or more files to standard output in
+// order (cat [-n] [--at <time>] <file>...). Paths may be branch-qualified, as in
+// feature:/x, and "-" reads standard input. Files that cannot be read are reported on
+// standard error and skipped. With -n, output lines are numbered
+// continuously across all files. Files larger than the maxcat setting are
+// refused unless --force is given, as are files that look binary. Ctrl-C
+// stops the output.
+func (s *Shell) CatFile(args []string) error {
+	args, at, err := s.takeAtFlag(args)
+	if err != nil {
+		return err
+	}
+
+	flags, args, err := splitFlags("cat", args, "-n", "--force")
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("file path required")
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	line := 0
+	failed := 0
+	for _, arg := range args {
+		limit := s.state.MaxCatBytes
+		if flags["--force"] {
+			limit = 0
+		}
+		content, err := s.catContent(arg, at, limit)
+		if err != nil {
+			out.Flush()
+			fmt.Fprintf(os.Stderr, "cat: %s: %v\n", arg, err)
+			failed++
+			continue
+		}
+
+		if util.IsBinary(content) && !flags["--force"] {
+			out.Flush()
+			fmt.Fprintf(os.Stderr, "cat: %s: binary file (use cat --force or export-dir)\n", arg)
+			failed++
+			continue
+		}
+
+		if !flags["-n"] {
+			if err := writeInterruptible(s.ctx, out, content); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, text := range splitLines(content) {
+			if s.ctx.Err() != nil {
+				return ErrInterrupted
+			}
+			line++
+			fmt.Fprintf(out, "%6d\t%s", line, text)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d file(s) could not be read", failed, len(args))
+	}
+	return nil
+}
+
+// catContent returns the content cat prints for one argument: standard
+// input for "-", otherwise the file's content. Content larger than limit
+// bytes is refused, for a stored file before its content is read; a limit
+// of 0 allows any size.
+func (s *Shell) catContent(arg string, at *time.Time, limit int) ([]byte, error) {
+	if arg == "-" {
+		content, err := s.readInput()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkCatSize(int64(len(content)), limit); err != nil {
+			return nil, err
+		}
+		return content, nil
+	}
+
+	p := s.parseBranchPath(arg)
+	if limit > 0 {
+		options, err := s.readOptions(p, at)
+		if err != nil {
+			return nil, err
+		}
+		resource, err := s.fm.Stat(p.Path, s.CurrentTransaction(), options)
+		if err == nil && resource.Type == schema.ResourceTypeFile {
+			metadata, err := schema.NormalizeMetadata(resource.Metadata)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkCatSize(metadata.Size, limit); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	file, err := s.readFile(p, at)
+	if err != nil {
+		return nil, err
+	}
+	return file.Content, nil
+}
+
+// checkCatSize returns an error if size is over limit, unless limit is 0
+func checkCatSize(size int64, limit int) error {
+	if limit > 0 && size > int64(limit) {
+		return fmt.Errorf("file is %s, use --force or head", util.FormatByteSize(size))
+	}
+	return nil
+}
+
+// readFile reads a file on the branch named by its path argument as of the
+// given point in time
+func (s *Shell) readFile(p branchPath, at *time.Time) (*filesystem.File, error) {
+	options, err := s.readOptions(p, at)
+	if err != nil {
+		return nil, err
+	}
+	return s.fm.GetFile(p.Path, s.CurrentTransaction(), options)
+}
+
+// splitLines splits content into lines, keeping each line's terminating
+// newline. A final line without a newline is returned as is.
+func splitLines(content []byte) [][]byte {
+	var lines [][]byte
+	for len(content) > 0 {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			lines = append(lines, content)
+			break
+		}
+		lines = append(lines, content[:i+1])
+		content = content[i+1:]
+	}
+	return lines
+}
@@ -0,0 +1,103 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCatMaxCatUsesStoredSize refuses a file by the size in its metadata,
+// which cat checks before it reads the content
+func TestCatMaxCatUsesStoredSize(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo hello > /tmp/big")
+	_, err := sh.execute(`
+		UPDATE resources SET metadata = json_set(metadata, '$.size', 5000000)
+		WHERE path = ? AND valid_to IS NULL
+	`, "/tmp/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, sh, "set maxcat 1000")
+
+	output, err := runErr(sh, "cat /tmp/big")
+	if err == nil || output != "" {
+		t.Errorf("cat over maxcat: output %q, error %v", output, err)
+	}
+	if output := run(t, sh, "cat --force /tmp/big"); output != "hello\n" {
+		t.Errorf("cat --force = %q, want %q", output, "hello\n")
+	}
+
+	run(t, sh, "set maxcat 0")
+	if output := run(t, sh, "cat /tmp/big"); output != "hello\n" {
+		t.Errorf("cat with maxcat off = %q, want %q", output, "hello\n")
+	}
+}
+
+func TestCatMaxCatStdin(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "set maxcat 10")
+
+	setInput(sh, strings.Repeat("x", 20))
+	if _, err := runErr(sh, "cat -"); err == nil {
+		t.Error("cat - over maxcat succeeded")
+	}
+
+	setInput(sh, "short")
+	if output := run(t, sh, "cat -"); output != "short" {
+		t.Errorf("cat - = %q, want %q", output, "short")
+	}
+}
+
+func TestCatMultipleFiles(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo one > /tmp/a")
+	createRaw(t, sh, "/tmp/b", "two\nthree")
+
+	if got := run(t, sh, "cat /tmp/a /tmp/b"); got != "one\ntwo\nthree" {
+		t.Errorf("cat of two files = %q", got)
+	}
+	if got := run(t, sh, "cat -n /tmp/a /tmp/b"); got != "     1\tone\n     2\ttwo\n     3\tthree" {
+		t.Errorf("cat -n numbers across files = %q", got)
+	}
+
+	// A file that cannot be read is skipped and the others are printed
+	output, err := runErr(sh, "cat /tmp/a /tmp/missing /tmp/b")
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 file(s)") {
+		t.Errorf("cat with a missing file: %v", err)
+	}
+	if output != "one\ntwo\nthree" {
+		t.Errorf("cat with a missing file printed %q", output)
+	}
+}
+
+func TestCatBinary(t *testing.T) {
+	sh := newTestShell(t)
+	createRaw(t, sh, "/tmp/image", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	output, err := runErr(sh, "cat /tmp/image")
+	if err == nil || output != "" {
+		t.Errorf("cat of a binary file: output %q, error %v", output, err)
+	}
+	if output := run(t, sh, "cat --force /tmp/image"); output != "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR" {
+		t.Errorf("cat --force of a binary file = %q", output)
+	}
+}
+
+func TestCatBranchQualified(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo main > /tmp/a")
+	run(t, sh, "branch feature")
+	run(t, sh, "switch feature")
+	run(t, sh, "echo feature > /tmp/a")
+
+	if got := run(t, sh, "cat main:/tmp/a /tmp/a"); got != "main\nfeature\n" {
+		t.Errorf("cat main:/tmp/a /tmp/a on feature = %q", got)
+	}
+	run(t, sh, "switch main")
+	if got := run(t, sh, "cat feature:/tmp/a"); got != "feature\n" {
+		t.Errorf("cat feature:/tmp/a on main = %q", got)
+	}
+	if _, err := runErr(sh, "cat nobranch:/tmp/a"); err == nil {
+		t.Error("cat on a missing branch succeeded")
+	}
+}
@@ -0,0 +1,105 @@
+package shell
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// changeCounts is how many resources a transaction created, updated and
+// deleted
+type changeCounts struct {
+	Created, Updated, Deleted int
+}
+
+// String formats the counts as "3 created, 1 updated, 1 deleted", or "no
+// changes"
+func (c changeCounts) String() string {
+	if c == (changeCounts{}) {
+		return "no changes"
+	}
+	return fmt.Sprintf("%d created, %d updated, %d deleted", c.Created, c.Updated, c.Deleted)
+}
+
+// countChanges compares each path tx has written or closed a version at
+// with its state when tx began. A path with no version then and one now was
+// created; one with a version then and a version written by tx now was
+// updated; one with a version then and none now was deleted. A moved
+// resource counts as deleted at its old path and created at its new one.
+func countChanges(tx *database.Transaction) (changeCounts, error) {
+	var counts changeCounts
+	start := tx.GetStartTime()
+	branch := tx.GetBranchID()
+
+	rows, err := tx.ExecuteQuery(`
+		SELECT path, transaction_id, valid_from, valid_to FROM resources
+		WHERE branch_id = ? AND path IN (
+			SELECT path FROM resources
+			WHERE branch_id = ? AND (transaction_id = ? OR valid_to > ?)
+		)
+		ORDER BY path
+	`, branch, branch, tx.GetID(), start)
+	if err != nil {
+		return counts, fmt.Errorf("failed to count changes: %w", err)
+	}
+	defer rows.Close()
+
+	type pathState struct {
+		before, after, written bool
+	}
+	var paths []string
+	states := make(map[string]*pathState)
+	for rows.Next() {
+		var path, txID string
+		var validFrom time.Time
+		var validTo sql.NullTime
+		if err := rows.Scan(&path, &txID, &validFrom, &validTo); err != nil {
+			return counts, fmt.Errorf("failed to scan resource: %w", err)
+		}
+
+		state, ok := states[path]
+		if !ok {
+			state = &pathState{}
+			states[path] = state
+			paths = append(paths, path)
+		}
+		if !validFrom.After(start) && (!validTo.Valid || validTo.Time.After(start)) {
+			state.before = true
+		}
+		if !validTo.Valid {
+			state.after = true
+			state.written = txID == tx.GetID()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return counts, err
+	}
+
+	for _, path := range paths {
+		state := states[path]
+		switch {
+		case !state.before && state.after:
+			counts.Created++
+		case state.before && state.after && state.written:
+			counts.Updated++
+		case state.before && !state.after:
+			counts.Deleted++
+		}
+	}
+	return counts, nil
+}
+
+// changeSummary returns the changes tx made as " (3 created, 1 updated,
+// 1 deleted)" to follow a commit or abort message. A failure to count is
+// only warned about, so that it never stands in the way of ending tx.
+func (s *Shell) changeSummary(tx *database.Transaction) string {
+	counts, err := countChanges(tx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return ""
+	}
+	return " (" + counts.String() + ")"
+}
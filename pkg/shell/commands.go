@@ -0,0 +1,116 @@
+package shell
+
+import "strings"
+
+// builtinCommands maps each built-in command name to its handler. It is
+// filled in by init because handlers such as find's -exec dispatch commands
+// through it.
+var builtinCommands map[string]CommandHandler
+
+func init() {
+	builtinCommands = map[string]CommandHandler{
+		"exit":         exitShell,
+		"quit":         exitShell,
+		"help":         helpCommand,
+		"set":          (*Shell).SetOption,
+		"alias":        aliasCommand,
+		"unalias":      (*Shell).Unalias,
+		"set-var":      (*Shell).SetVariable,
+		"cd":           (*Shell).ChangeDirectory,
+		"ls":           (*Shell).ListDirectory,
+		"mkdir":        (*Shell).MakeDirectory,
+		"touch":        (*Shell).TouchFile,
+		"truncate":     (*Shell).TruncateFile,
+		"cp":           (*Shell).CopyFile,
+		"mv":           (*Shell).MoveResource,
+		"rm":           (*Shell).RemoveResource,
+		"cat":          (*Shell).CatFile,
+		"echo":         (*Shell).Echo,
+		"write":        (*Shell).WriteFile,
+		"chmod":        (*Shell).ChangeMode,
+		"chown":        (*Shell).ChangeOwner,
+		"umask":        (*Shell).SetUmask,
+		"lock":         (*Shell).LockResource,
+		"unlock":       (*Shell).UnlockResource,
+		"tag":          (*Shell).TagResource,
+		"untag":        (*Shell).UntagResource,
+		"tags":         (*Shell).ShowTags,
+		"find":         (*Shell).FindResources,
+		"paths":        (*Shell).ListPaths,
+		"mount":        (*Shell).MountDirectory,
+		"export-dir":   (*Shell).ExportDirectory,
+		"stat":         (*Shell).StatResource,
+		"test":         (*Shell).TestResource,
+		"begin":        (*Shell).BeginTransaction,
+		"tx":           (*Shell).ShowTransaction,
+		"savepoint":    savepointCommand("savepoint"),
+		"rollback-to":  savepointCommand("rollback-to"),
+		"release":      savepointCommand("release"),
+		"commit":       withoutArgs((*Shell).CommitTransaction),
+		"abort":        withoutArgs((*Shell).AbortTransaction),
+		"rollback":     withoutArgs((*Shell).AbortTransaction),
+		"branch":       (*Shell).ManageBranch,
+		"switch":       (*Shell).SwitchBranch,
+		"checkout":     (*Shell).Checkout,
+		"cherry-pick":  (*Shell).CherryPick,
+		"restore":      (*Shell).RestoreResource,
+		"diff":         (*Shell).DiffResources,
+		"history":      (*Shell).ShowHistory,
+		"state-at":     (*Shell).SetPointInTime,
+		"now":          withoutArgs((*Shell).ResetPointInTime),
+		"query":        queryCommand,
+		"search":       (*Shell).SearchResources,
+		"reindex":      (*Shell).Reindex,
+		"fsck":         (*Shell).CheckFilesystem,
+		"refresh-meta": (*Shell).RefreshMetadata,
+		"user":         (*Shell).ManageUsers,
+		"sessions":     withoutArgs((*Shell).ListSessions),
+		"info":         withoutArgs((*Shell).ShowInfo),
+		"describe":     withoutArgs((*Shell).DescribeResources),
+		"log":          (*Shell).ShowLog,
+		"replay":       (*Shell).ReplayTransaction,
+		"transactions": (*Shell).ListTransactions,
+		"count":        (*Shell).CountResources,
+		"summary":      (*Shell).Summarize,
+		"bloat":        (*Shell).ShowBloat,
+		"empty-trash":  (*Shell).EmptyTrash,
+		"gc":           (*Shell).CollectGarbage,
+	}
+}
+
+// exitShell stops the shell's read loop
+func exitShell(s *Shell, args []string) error {
+	s.running = false
+	return nil
+}
+
+// helpCommand prints the command reference
+func helpCommand(s *Shell, args []string) error {
+	s.ShowHelp()
+	return nil
+}
+
+// aliasCommand passes the unsplit command line to Alias so quoting in the
+// alias body is kept
+func aliasCommand(s *Shell, args []string) error {
+	return s.Alias(strings.TrimPrefix(s.currentCommand, "alias"))
+}
+
+// queryCommand passes the raw SQL text to ExecuteQuery
+func queryCommand(s *Shell, args []string) error {
+	return s.ExecuteQuery(s.rawArgs(args))
+}
+
+// savepointCommand returns the handler for one of the savepoint commands
+func savepointCommand(name string) CommandHandler {
+	return func(s *Shell, args []string) error {
+		return s.ManageSavepoint(name, args)
+	}
+}
+
+// withoutArgs adapts a command that takes no arguments
+func withoutArgs(fn func(*Shell) error) CommandHandler {
+	return func(s *Shell, args []string) error {
+		return fn(s)
+	}
+}
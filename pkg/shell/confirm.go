@@ -0,0 +1,75 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SetInteractive sets whether the shell may prompt the user for input.
+// Destructive commands are not confirmed when the shell is non-interactive.
+func (s *Shell) SetInteractive(interactive bool) {
+	s.state.IsInteractive = interactive
+}
+
+// stdinIsTerminal reports whether standard input is a terminal rather than
+// a pipe or a file
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks the user to confirm a destructive operation described by
+// action. It returns true without asking when -f or --yes is among flags or
+// the shell is non-interactive.
+func (s *Shell) confirm(flags map[string]bool, action string) (bool, error) {
+	if flags["-f"] || flags["--yes"] || !s.state.IsInteractive {
+		return true, nil
+	}
+
+	fmt.Printf("%s. Are you sure? [y/N] ", action)
+	answer, err := s.readLine()
+	if err != nil && answer == "" {
+		fmt.Println()
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read confirmation: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// ErrLineTooLong is returned by readLine for a line longer than
+// MaxInputLine. The rest of the line is discarded.
+var ErrLineTooLong = fmt.Errorf("line longer than %d bytes", MaxInputLine)
+
+// readLine reads one line from the shell's input without its line ending.
+// At the end of input it returns the last, unterminated line with io.EOF.
+func (s *Shell) readLine() (string, error) {
+	var line []byte
+	for {
+		chunk, err := s.input.ReadSlice('\n')
+		if len(line)+len(chunk) > MaxInputLine {
+			for err == bufio.ErrBufferFull {
+				_, err = s.input.ReadSlice('\n')
+			}
+			return "", ErrLineTooLong
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		text := strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r")
+		return text, err
+	}
+}
@@ -0,0 +1,77 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConfirm(t *testing.T) {
+	sh := newTestShell(t)
+	sh.SetInteractive(true)
+
+	tests := []struct {
+		input string
+		flags map[string]bool
+		want  bool
+	}{
+		{"y\n", nil, true},
+		{"YES\n", nil, true},
+		{"n\n", nil, false},
+		{"\n", nil, false},
+		{"", nil, false},
+		{"", map[string]bool{"-f": true}, true},
+		{"", map[string]bool{"--yes": true}, true},
+	}
+	for _, tt := range tests {
+		setInput(sh, tt.input)
+		var got bool
+		output, err := captureOutput(func() error {
+			var err error
+			got, err = sh.confirm(tt.flags, "Remove /x")
+			return err
+		})
+		if err != nil || got != tt.want {
+			t.Errorf("confirm with input %q and flags %v = %v, %v; want %v", tt.input, tt.flags, got, err, tt.want)
+		}
+		asked := strings.Contains(string(output), "Remove /x. Are you sure? [y/N]")
+		if asked == (tt.flags != nil) {
+			t.Errorf("confirm with flags %v: asked %v", tt.flags, asked)
+		}
+	}
+
+	// A non-interactive shell never asks
+	sh.SetInteractive(false)
+	setInput(sh, "n\n")
+	if ok, err := sh.confirm(nil, "Remove /x"); !ok || err != nil {
+		t.Errorf("non-interactive confirm = %v, %v", ok, err)
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	sh := newTestShell(t)
+	setInput(sh, "first\r\nsecond\nlast")
+	for _, want := range []string{"first", "second"} {
+		if got, err := sh.readLine(); got != want || err != nil {
+			t.Errorf("readLine = %q, %v; want %q", got, err, want)
+		}
+	}
+	if got, err := sh.readLine(); got != "last" || err != io.EOF {
+		t.Errorf("last line = %q, %v; want %q with EOF", got, err, "last")
+	}
+}
+
+func TestReadLineTooLong(t *testing.T) {
+	sh := newTestShell(t)
+	sh.input = bufio.NewReaderSize(strings.NewReader(strings.Repeat("x", MaxInputLine+1)+"\nnext\n"), 4096)
+
+	if _, err := sh.readLine(); !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("long line: %v, want ErrLineTooLong", err)
+	}
+	// The rest of the long line is discarded
+	if got, err := sh.readLine(); got != "next" || err != nil {
+		t.Errorf("line after a long line = %q, %v", got, err)
+	}
+}
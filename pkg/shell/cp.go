@@ -0,0 +1,87 @@
+package shell
+
+import (
+	"fmt"
+	pathpkg "path"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// CopyFile copies a file to a new path, or into a directory under its own
+// name (cp [-p] [--type <mime>] <source> <dest>). The copy is a new file
+// owned by the shell user with fresh timestamps and the umask's
+// permissions; with -p it keeps the source's permissions and created,
+// modified and accessed times. Its MIME type is detected from its name
+// unless given with --type. The content is copied as stored, without
+// passing through the shell.
+func (s *Shell) CopyFile(args []string) error {
+	args, mimeType, err := takeTypeFlag(args)
+	if err != nil {
+		return err
+	}
+	flags, args, err := splitFlags("cp", args, "-p")
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: cp [-p] [--type <mime>] <source> <dest>")
+	}
+
+	source, err := s.resolveLocalPath(args[0])
+	if err != nil {
+		return err
+	}
+	dest, err := s.resolveLocalPath(args[1])
+	if err != nil {
+		return err
+	}
+
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		src, err := s.lookupResource(tx, source)
+		if err != nil {
+			return err
+		}
+		if src.Type != schema.ResourceTypeFile {
+			return fmt.Errorf("%s is a %s; cp copies files", source, src.Type)
+		}
+
+		target := dest
+		if existing, err := s.lookupResource(tx, dest); err == nil {
+			if existing.Type != schema.ResourceTypeDirectory {
+				return fmt.Errorf("file %w: %s", database.ErrAlreadyExists, dest)
+			}
+			target = pathpkg.Join(dest, src.Name)
+			if _, err := s.lookupResource(tx, target); err == nil {
+				return fmt.Errorf("file %w: %s", database.ErrAlreadyExists, target)
+			}
+		}
+
+		metadata := schema.NewResourceMetadata(s.state.User, s.filePermissions())
+		if flags["-p"] {
+			metadata = copyPreservedMetadata(metadata, src.Metadata)
+		}
+		metadata.MimeType = mimeType
+
+		created, err := s.fm.CopyFile(source, target, metadata, tx)
+		if err != nil {
+			return err
+		}
+		if err := s.recordOperation(tx, schema.OperationKindCreate, []string{created.ID}); err != nil {
+			return err
+		}
+		out.Printf("Copied %s to %s\n", source, target)
+		return nil
+	})
+}
+
+// copyPreservedMetadata returns the metadata of a new copy with the
+// permissions and timestamps that cp -p carries over from the source
+func copyPreservedMetadata(metadata, source schema.ResourceMetadata) schema.ResourceMetadata {
+	metadata.Permissions = source.Permissions
+	metadata.IsExecutable = source.IsExecutable
+	metadata.CreatedAt = source.CreatedAt
+	metadata.ModifiedAt = source.ModifiedAt
+	metadata.AccessedAt = source.AccessedAt
+	return metadata
+}
@@ -0,0 +1,129 @@
+package shell
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+func TestCopy(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo hello > /tmp/a.txt")
+	run(t, sh, "mkdir /tmp/dir")
+
+	run(t, sh, "cp /tmp/a.txt /tmp/b.html")
+	if got := readFile(t, sh, "/tmp/b.html"); got != "hello\n" {
+		t.Errorf("copy content = %q", got)
+	}
+	if m := metadataOf(t, sh, "/tmp/b.html"); m.MimeType != "text/html" {
+		t.Errorf("copy MIME type = %q, want detected from the new name", m.MimeType)
+	}
+
+	run(t, sh, "cp /tmp/a.txt /tmp/dir")
+	if got := readFile(t, sh, "/tmp/dir/a.txt"); got != "hello\n" {
+		t.Errorf("copy into a directory = %q", got)
+	}
+
+	run(t, sh, "cp --type text/CSV /tmp/a.txt /tmp/c")
+	if m := metadataOf(t, sh, "/tmp/c"); m.MimeType != "text/csv" {
+		t.Errorf("cp --type MIME type = %q", m.MimeType)
+	}
+
+	if _, err := runErr(sh, "cp /tmp/a.txt /tmp/b.html"); !errors.Is(err, database.ErrAlreadyExists) {
+		t.Errorf("copy over a file: %v, want ErrAlreadyExists", err)
+	}
+	if _, err := runErr(sh, "cp /tmp/a.txt /tmp/dir"); !errors.Is(err, database.ErrAlreadyExists) {
+		t.Errorf("copy into a directory holding the name: %v, want ErrAlreadyExists", err)
+	}
+	if _, err := runErr(sh, "cp /tmp/dir /tmp/dir2"); err == nil {
+		t.Error("copying a directory succeeded")
+	}
+	if _, err := runErr(sh, "cp --type plain /tmp/a.txt /tmp/d"); err == nil {
+		t.Error("invalid MIME type accepted")
+	}
+}
+
+func TestCopyPreserve(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "touch /tmp/a")
+	run(t, sh, "chmod 700 /tmp/a")
+	source := metadataOf(t, sh, "/tmp/a")
+	time.Sleep(5 * time.Millisecond)
+
+	run(t, sh, "cp /tmp/a /tmp/fresh")
+	fresh := metadataOf(t, sh, "/tmp/fresh")
+	if fresh.Permissions != 0644 || !fresh.CreatedAt.After(source.CreatedAt) {
+		t.Errorf("plain copy: %04o created %v", fresh.Permissions, fresh.CreatedAt)
+	}
+
+	run(t, sh, "cp -p /tmp/a /tmp/kept")
+	kept := metadataOf(t, sh, "/tmp/kept")
+	if kept.Permissions != 0700 || !kept.IsExecutable || !kept.CreatedAt.Equal(source.CreatedAt) || !kept.ModifiedAt.Equal(source.ModifiedAt) {
+		t.Errorf("cp -p: %+v, source %+v", kept, source)
+	}
+	if kept.Owner != "system" {
+		t.Errorf("cp -p owner = %s, want the shell user", kept.Owner)
+	}
+}
+
+// TestCopySharesTableContent copies a file whose content is kept in its own
+// row, which the copy must refer to by checksum rather than store again
+func TestCopySharesTableContent(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "set compress on")
+	run(t, sh, "set compressmin 1")
+	content := strings.Repeat("compressed ", 100)
+	createRaw(t, sh, "/tmp/a", content)
+
+	run(t, sh, "cp /tmp/a /tmp/b")
+	run(t, sh, "cp /tmp/a /tmp/c")
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM blobs`); n != 1 {
+		t.Errorf("%d blobs after two copies, want 1", n)
+	}
+	query := `SELECT COUNT(*) FROM resources WHERE path IN ('/tmp/b', '/tmp/c') AND content IS NOT NULL AND LENGTH(content) > 0`
+	if n := countRows(t, sh, query); n != 0 {
+		t.Errorf("%d copies store the content in their own row", n)
+	}
+	if m := metadataOf(t, sh, "/tmp/b"); m.Store != "inline" || m.Checksum != metadataOf(t, sh, "/tmp/a").Checksum {
+		t.Errorf("copy store %q checksum %q, want the source's content by checksum", m.Store, m.Checksum)
+	}
+	if got := readFile(t, sh, "/tmp/b"); got != content {
+		t.Errorf("copy content = %q", got)
+	}
+
+	// Editing a copy diverges from the shared content
+	run(t, sh, "echo edited > /tmp/b")
+	if got := readFile(t, sh, "/tmp/c"); got != content {
+		t.Errorf("other copy changed after an edit: %q", got)
+	}
+	if got := readFile(t, sh, "/tmp/b"); got != "edited\n" {
+		t.Errorf("edited copy = %q", got)
+	}
+}
+
+func TestCopySharesInlineBlob(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "set store inline")
+	createRaw(t, sh, "/tmp/large", strings.Repeat("large content\n", 10000))
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM blobs`); n != 1 {
+		t.Fatalf("%d blobs after writing one file, want 1", n)
+	}
+
+	// A copy refers to the source's blob instead of storing the content again
+	run(t, sh, "cp /tmp/large /tmp/copy")
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM blobs`); n != 1 {
+		t.Errorf("%d blobs after cp, want 1", n)
+	}
+
+	// Editing the copy stores its new content and leaves the source alone
+	run(t, sh, "echo edited > /tmp/copy")
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM blobs`); n != 2 {
+		t.Errorf("%d blobs after editing the copy, want 2", n)
+	}
+	if got := readFile(t, sh, "/tmp/large"); got != strings.Repeat("large content\n", 10000) {
+		t.Errorf("source changed after editing the copy: %d bytes", len(got))
+	}
+}
@@ -0,0 +1,427 @@
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+const diffUsage = "usage: diff <path> [--branch <a> [--branch <b>]] [--at <t1> [--at <t2>]]"
+
+// diffSide is one of the two states a diff compares: a path on a branch,
+// as of a point in time or currently
+type diffSide struct {
+	branchPath
+	At *time.Time
+}
+
+// String names the side in diff output, as "branch" or "branch at <time>"
+func (d diffSide) String() string {
+	if d.At == nil {
+		return d.Branch
+	}
+	return d.Branch + " at " + d.At.Local().Format("2006-01-02 15:04:05")
+}
+
+// label names the side's path in a unified diff header
+func (d diffSide) label() string {
+	if d.At == nil {
+		return d.branchPath.String()
+	}
+	return d.branchPath.String() + "@" + d.At.Local().Format("2006-01-02T15:04:05")
+}
+
+// DiffResources compares a path between two branches, two points in time or
+// both (diff <path> [--branch <a> [--branch <b>]] [--at <t1> [--at <t2>]]).
+// With one branch it is compared with the current branch; with one time it
+// is compared with the present. Files are shown as a unified diff;
+// directories as the entries that exist on only one side, were renamed,
+// differ in type or differ in content.
+func (s *Shell) DiffResources(args []string) error {
+	var target string
+	var branches []string
+	var ats []*time.Time
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--branch" && i+1 < len(args):
+			branches = append(branches, args[i+1])
+			i++
+		case args[i] == "--at" && i+1 < len(args):
+			t, err := util.ParseTimeSpec(args[i+1])
+			if err != nil {
+				return err
+			}
+			ats = append(ats, &t)
+			i++
+		case target == "" && !strings.HasPrefix(args[i], "-"):
+			target = args[i]
+		default:
+			return fmt.Errorf(diffUsage)
+		}
+	}
+	if target == "" || len(branches)+len(ats) == 0 || len(branches) > 2 || len(ats) > 2 {
+		return fmt.Errorf(diffUsage)
+	}
+	switch len(branches) {
+	case 0:
+		branches = []string{s.state.CurrentBranch, s.state.CurrentBranch}
+	case 1:
+		branches = []string{s.state.CurrentBranch, branches[0]}
+	}
+	switch len(ats) {
+	case 0:
+		ats = []*time.Time{nil, nil}
+	case 1:
+		ats = []*time.Time{ats[0], nil}
+	}
+
+	path := s.resolvePath(target)
+	a := diffSide{branchPath{Branch: branches[0], Path: path, Qualified: true}, ats[0]}
+	b := diffSide{branchPath{Branch: branches[1], Path: path, Qualified: true}, ats[1]}
+
+	typeA, err := s.resourceTypeAt(a.branchPath, a.At)
+	if err != nil {
+		return err
+	}
+	typeB, err := s.resourceTypeAt(b.branchPath, b.At)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case typeA == "" && typeB == "":
+		return fmt.Errorf("resource not found on %s or %s: %s", a, b, path)
+	case typeA == "":
+		fmt.Printf("Only in %s: %s\n", b, path)
+	case typeB == "":
+		fmt.Printf("Only in %s: %s\n", a, path)
+	case typeA != typeB:
+		fmt.Printf("%s is a %s on %s and a %s on %s\n", path, typeA, a, typeB, b)
+	case typeA == schema.ResourceTypeDirectory:
+		return s.diffDirectories(a, b)
+	default:
+		return s.diffFiles(a, b)
+	}
+	return nil
+}
+
+// diffFiles prints the unified diff between two versions of a file
+func (s *Shell) diffFiles(a, b diffSide) error {
+	fileA, err := s.readFile(a.branchPath, a.At)
+	if err != nil {
+		return err
+	}
+	fileB, err := s.readFile(b.branchPath, b.At)
+	if err != nil {
+		return err
+	}
+	contentA, contentB := fileA.Content, fileB.Content
+
+	if util.IsBinary(contentA) || util.IsBinary(contentB) {
+		if string(contentA) != string(contentB) {
+			fmt.Printf("Binary files %s and %s differ\n", a.label(), b.label())
+		}
+		return nil
+	}
+
+	diff, err := util.UnifiedDiff(a.label(), b.label(), contentA, contentB)
+	if err != nil {
+		return fmt.Errorf("diff: %w", err)
+	}
+	fmt.Print(diff)
+	return nil
+}
+
+// diffEntry is a resource under a compared directory
+type diffEntry struct {
+	resourceType string
+	checksum     string
+	target       string
+	validFrom    time.Time
+}
+
+// differs reports whether two entries of the same type have different
+// content
+func (e diffEntry) differs(other diffEntry) bool {
+	return e.checksum != other.checksum || e.target != other.target
+}
+
+// diffDirectories prints the structural differences between two versions of
+// a directory tree
+func (s *Shell) diffDirectories(a, b diffSide) error {
+	entriesA, err := s.diffEntries(a)
+	if err != nil {
+		return err
+	}
+	entriesB, err := s.diffEntries(b)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	for path := range entriesA {
+		paths = append(paths, path)
+	}
+	for path := range entriesB {
+		if _, ok := entriesA[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	renames, err := s.detectRenames(a, b, entriesA, entriesB)
+	if err != nil {
+		return err
+	}
+	renamedTo := make(map[string]bool, len(renames))
+	for _, to := range renames {
+		renamedTo[to] = true
+	}
+
+	for _, path := range paths {
+		entryA, inA := entriesA[path]
+		entryB, inB := entriesB[path]
+		switch {
+		case !inB:
+			to, renamed := renames[path]
+			switch {
+			case !renamed:
+				fmt.Printf("Only in %s: %s\n", a, path)
+			case impliedRename(renames, path, to):
+				// Moved along with a renamed directory above it
+				if entryA.differs(entriesB[to]) {
+					fmt.Printf("Differs: %s -> %s\n", path, to)
+				}
+			case entryA.differs(entriesB[to]):
+				fmt.Printf("Renamed %s -> %s (content differs)\n", path, to)
+			default:
+				fmt.Printf("Renamed %s -> %s\n", path, to)
+			}
+		case !inA:
+			if !renamedTo[path] {
+				fmt.Printf("Only in %s: %s\n", b, path)
+			}
+		case entryA.resourceType != entryB.resourceType:
+			fmt.Printf("%s is a %s on %s and a %s on %s\n", path, entryA.resourceType, a, entryB.resourceType, b)
+		case entryA.differs(entryB):
+			fmt.Printf("Differs: %s\n", path)
+		}
+	}
+	return nil
+}
+
+// diffEntries returns the resources under a directory keyed by path
+func (s *Shell) diffEntries(d diffSide) (map[string]diffEntry, error) {
+	options, err := s.readOptions(d.branchPath, d.At)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := s.fm.ListSubtree(d.Path, s.CurrentTransaction(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]diffEntry, len(resources))
+	for _, resource := range resources {
+		metadata, err := schema.NormalizeMetadata(resource.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata for %s: %w", resource.Path, err)
+		}
+		entries[resource.Path] = diffEntry{
+			resourceType: resource.Type,
+			checksum:     metadata.Checksum,
+			target:       metadata.SymlinkTarget,
+			validFrom:    resource.ValidFrom,
+		}
+	}
+	return entries, nil
+}
+
+// renameEdge is a move recorded in a branch's history: the version at From
+// was closed at At and continued at To
+type renameEdge struct {
+	From, To string
+	At       time.Time
+	Since    time.Time // When the moved version at From became current
+}
+
+// detectRenames pairs entries found only on side a with entries found only
+// on side b that are the same resource under another path. A resource keeps
+// no identity across a move, so the pairing follows the moves recorded in
+// the history of b's branch forward from a, and those of a's branch forward
+// from b for when a is the later side. The result maps paths on a to paths
+// on b.
+func (s *Shell) detectRenames(a, b diffSide, entriesA, entriesB map[string]diffEntry) (map[string]string, error) {
+	var onlyA, onlyB []string
+	for path := range entriesA {
+		if _, ok := entriesB[path]; !ok {
+			onlyA = append(onlyA, path)
+		}
+	}
+	for path := range entriesB {
+		if _, ok := entriesA[path]; !ok {
+			onlyB = append(onlyB, path)
+		}
+	}
+	renames := make(map[string]string)
+	if len(onlyA) == 0 || len(onlyB) == 0 {
+		return renames, nil
+	}
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+
+	edgesA, err := s.renameEdges(a)
+	if err != nil {
+		return nil, err
+	}
+	edgesB, err := s.renameEdges(b)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make(map[string]bool)
+	for _, from := range onlyA {
+		to, ok := followRenames(edgesB, from, entriesA[from].validFrom)
+		if !ok || matched[to] {
+			continue
+		}
+		if entry, ok := entriesB[to]; ok && !isIn(entriesA, to) && entry.resourceType == entriesA[from].resourceType {
+			renames[from] = to
+			matched[to] = true
+		}
+	}
+	for _, to := range onlyB {
+		if matched[to] {
+			continue
+		}
+		from, ok := followRenames(edgesA, to, entriesB[to].validFrom)
+		if !ok {
+			continue
+		}
+		if _, taken := renames[from]; taken {
+			continue
+		}
+		if entry, ok := entriesA[from]; ok && !isIn(entriesB, from) && entry.resourceType == entriesB[to].resourceType {
+			renames[from] = to
+			matched[to] = true
+		}
+	}
+	return renames, nil
+}
+
+// isIn reports whether path has an entry
+func isIn(entries map[string]diffEntry, path string) bool {
+	_, ok := entries[path]
+	return ok
+}
+
+// impliedRename reports whether a rename of from to to is the move of a
+// directory above from that was itself renamed
+func impliedRename(renames map[string]string, from, to string) bool {
+	for parent, parentTo := range renames {
+		if strings.HasPrefix(from, parent+"/") && to == parentTo+strings.TrimPrefix(from, parent) {
+			return true
+		}
+	}
+	return false
+}
+
+// renameEdges returns the moves in the history of a side's branch up to its
+// point in time, and in the branches it was forked from up to the fork,
+// oldest first. A move closes a version and writes a copy of it at another
+// path with the same metadata at the same instant, which is what is matched
+// here.
+func (s *Shell) renameEdges(d diffSide) ([]renameEdge, error) {
+	var q queryExecutor = s.db
+	if s.CurrentTransaction() != nil {
+		q = s.CurrentTransaction()
+	}
+
+	ancestry, err := branchAncestry(q, d.Branch)
+	if err != nil {
+		return nil, err
+	}
+
+	var edges []renameEdge
+	for i, ancestor := range ancestry {
+		until := d.At
+		if i > 0 && (until == nil || ancestor.Until.Before(*until)) {
+			until = &ancestor.Until
+		}
+		branchEdges, err := branchRenameEdges(q, ancestor.Branch, until)
+		if err != nil {
+			return nil, err
+		}
+		edges = append(edges, branchEdges...)
+	}
+	sort.SliceStable(edges, func(i, j int) bool { return edges[i].At.Before(edges[j].At) })
+	return edges, nil
+}
+
+// branchRenameEdges returns the moves on one branch, up to until when it is
+// set
+func branchRenameEdges(q queryExecutor, branch string, until *time.Time) ([]renameEdge, error) {
+	query := `
+		SELECT o.path, n.path, o.valid_to, o.valid_from
+		FROM resources o JOIN resources n
+			ON n.branch_id = o.branch_id AND n.valid_from = o.valid_to
+			AND n.path <> o.path AND n.type = o.type AND n.metadata = o.metadata
+		WHERE o.branch_id = ?`
+	args := []interface{}{branch}
+	if until != nil {
+		query += ` AND o.valid_to <= ?`
+		args = append(args, *until)
+	}
+
+	rows, err := q.ExecuteQuery(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up moves on %s: %w", branch, err)
+	}
+	defer rows.Close()
+
+	var edges []renameEdge
+	for rows.Next() {
+		var edge renameEdge
+		if err := rows.Scan(&edge.From, &edge.To, &edge.At, &edge.Since); err != nil {
+			return nil, fmt.Errorf("failed to scan move: %w", err)
+		}
+		edges = append(edges, edge)
+	}
+	return edges, rows.Err()
+}
+
+// followRenames follows the moves of the resource whose version at path
+// became current at since, returning the path it was last moved to. The
+// latest move of that version or a later one is taken first, then each
+// following move of the path it landed on.
+func followRenames(edges []renameEdge, path string, since time.Time) (string, bool) {
+	var last *renameEdge
+	for i := range edges {
+		edge := &edges[i]
+		if edge.From == path && !edge.Since.Before(since) {
+			last = edge
+		}
+	}
+	if last == nil {
+		return "", false
+	}
+
+	for {
+		var next *renameEdge
+		for i := range edges {
+			edge := &edges[i]
+			if edge.From == last.To && edge.At.After(last.At) && !edge.Since.Before(last.At) {
+				next = edge
+				break
+			}
+		}
+		if next == nil {
+			return last.To, true
+		}
+		last = next
+	}
+}
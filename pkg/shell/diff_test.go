@@ -0,0 +1,85 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffFileBetweenBranches(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo same > /tmp/f")
+	run(t, sh, "checkout -b feature")
+	run(t, sh, "echo changed > /tmp/f")
+
+	output := run(t, sh, "diff /tmp/f --branch main")
+	for _, want := range []string{"--- feature:/tmp/f", "+++ main:/tmp/f", "-changed", "+same"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("diff lacks %q:\n%s", want, output)
+		}
+	}
+	if output := run(t, sh, "diff /tmp/f --branch main --branch main"); output != "" {
+		t.Errorf("diff of a branch with itself = %q", output)
+	}
+
+	run(t, sh, "touch /tmp/new")
+	if output := run(t, sh, "diff /tmp/new --branch main"); output != "Only in feature: /tmp/new\n" {
+		t.Errorf("diff of a file on one branch = %q", output)
+	}
+	run(t, sh, "mkdir /tmp/kind")
+	run(t, sh, "checkout main")
+	run(t, sh, "touch /tmp/kind")
+	if output := run(t, sh, "diff /tmp/kind --branch feature"); output != "/tmp/kind is a file on main and a directory on feature\n" {
+		t.Errorf("diff of different types = %q", output)
+	}
+
+	for _, command := range []string{"diff /tmp/f", "diff --branch main", "diff /tmp/missing --branch feature", "diff /tmp/f --branch a --branch b --branch c"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
+
+func TestDiffAt(t *testing.T) {
+	sh := newTestShell(t)
+	at := pastState(t, sh)
+
+	output := run(t, sh, "diff / --at "+at)
+	for _, want := range []string{"Renamed /old.txt -> /old.bak\n", "Only in main: /new.txt\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("diff --at lacks %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "Only in main at") {
+		t.Errorf("a renamed file is reported as only on one side:\n%s", output)
+	}
+}
+
+func TestDiffDirectories(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir -p /d/sub")
+	run(t, sh, "echo a > /d/a")
+	run(t, sh, "echo b > /d/sub/b")
+	run(t, sh, "echo c > /d/c")
+	run(t, sh, "checkout -b feature")
+	run(t, sh, "echo changed > /d/a")
+	run(t, sh, "mv /d/sub /d/moved")
+	run(t, sh, "rm /d/c")
+
+	output := run(t, sh, "diff /d --branch main")
+	want := "Differs: /d/a\nOnly in main: /d/c\nRenamed /d/moved -> /d/sub\n"
+	if output != want {
+		t.Errorf("diff of directories =\n%s\nwant\n%s", output, want)
+	}
+}
+
+func TestDiffBinary(t *testing.T) {
+	sh := newTestShell(t)
+	createRaw(t, sh, "/tmp/bin", "\x00\x01")
+	run(t, sh, "checkout -b feature")
+	run(t, sh, "rm /tmp/bin")
+	createRaw(t, sh, "/tmp/bin", "\x00\x02")
+
+	if output := run(t, sh, "diff /tmp/bin --branch main"); output != "Binary files feature:/tmp/bin and main:/tmp/bin differ\n" {
+		t.Errorf("diff of binary files = %q", output)
+	}
+}
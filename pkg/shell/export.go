@@ -0,0 +1,120 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// ExportDirectory writes a subtree onto the host filesystem
+// (export-dir [--force] [--at <time>] <path> <hostdir>). Directories, files
+// and symlinks are recreated with their stored permissions and modification
+// times. The path may be branch-qualified. Existing host files are only
+// overwritten with --force.
+func (s *Shell) ExportDirectory(args []string) error {
+	args, at, err := s.takeAtFlag(args)
+	if err != nil {
+		return err
+	}
+	flags, args, err := splitFlags("export-dir", args, "--force")
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: export-dir [--force] [--at <time>] <path> <hostdir>")
+	}
+
+	p := s.parseBranchPath(args[0])
+	options, err := s.readOptions(p, at)
+	if err != nil {
+		return err
+	}
+	hostRoot := filepath.Clean(args[1])
+
+	// Directory permissions and times are applied last, deepest first, so
+	// that restrictive modes and new children do not get in the way
+	type pendingDir struct {
+		hostPath string
+		metadata schema.ResourceMetadata
+	}
+	var dirs []pendingDir
+	var files, symlinks int
+
+	err = s.fm.Walk(p.Path, s.CurrentTransaction(), options, func(resource *schema.Resource) error {
+		metadata, err := schema.NormalizeMetadata(resource.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal metadata for %s: %w", resource.Path, err)
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(resource.Path, p.Path), "/")
+		hostPath := filepath.Join(hostRoot, filepath.FromSlash(rel))
+
+		switch resource.Type {
+		case schema.ResourceTypeDirectory:
+			if err := os.MkdirAll(hostPath, 0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, pendingDir{hostPath, metadata})
+			return nil
+
+		case schema.ResourceTypeSymlink:
+			if err := prepareHostPath(hostPath, flags["--force"]); err != nil {
+				return err
+			}
+			symlinks++
+			return os.Symlink(metadata.SymlinkTarget, hostPath)
+
+		default:
+			file, err := s.fm.GetFile(resource.Path, s.CurrentTransaction(), options)
+			if err != nil {
+				return err
+			}
+			if err := prepareHostPath(hostPath, flags["--force"]); err != nil {
+				return err
+			}
+			if err := os.WriteFile(hostPath, file.Content, os.FileMode(metadata.Permissions)); err != nil {
+				return err
+			}
+			if err := os.Chmod(hostPath, os.FileMode(metadata.Permissions)); err != nil {
+				return err
+			}
+			files++
+			return os.Chtimes(hostPath, metadata.AccessedAt, metadata.ModifiedAt)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("export-dir: %w", err)
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := dirs[i]
+		if err := os.Chmod(dir.hostPath, os.FileMode(dir.metadata.Permissions)); err != nil {
+			return fmt.Errorf("export-dir: %w", err)
+		}
+		if err := os.Chtimes(dir.hostPath, dir.metadata.AccessedAt, dir.metadata.ModifiedAt); err != nil {
+			return fmt.Errorf("export-dir: %w", err)
+		}
+	}
+
+	fmt.Printf("Exported %s to %s: %d directories, %d files, %d symlinks\n", p, hostRoot, len(dirs), files, symlinks)
+	return nil
+}
+
+// prepareHostPath makes sure a file can be written at hostPath: an existing
+// file or symlink there is removed when force is set and is an error
+// otherwise
+func prepareHostPath(hostPath string, force bool) error {
+	if _, err := os.Lstat(hostPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !force {
+		return fmt.Errorf("%s already exists, use --force to overwrite", hostPath)
+	}
+	return os.Remove(hostPath)
+}
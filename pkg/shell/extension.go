@@ -0,0 +1,45 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CommandHandler runs a built-in command or one registered by an embedder.
+// It receives the shell and the command's arguments after alias and
+// variable expansion.
+type CommandHandler func(s *Shell, args []string) error
+
+// RegisterCommand adds a custom command to the shell. Registered commands
+// are dispatched after the built-ins, so a name can be registered only once
+// and never over a built-in command.
+func (s *Shell) RegisterCommand(name string, handler CommandHandler) error {
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("invalid command name: %q", name)
+	}
+	if handler == nil {
+		return fmt.Errorf("command %s: handler required", name)
+	}
+	if _, ok := builtinCommands[name]; ok {
+		return fmt.Errorf("command %s is a built-in command", name)
+	}
+	if _, ok := s.commands[name]; ok {
+		return fmt.Errorf("command %s is already registered", name)
+	}
+
+	if s.commands == nil {
+		s.commands = make(map[string]CommandHandler)
+	}
+	s.commands[name] = handler
+	return nil
+}
+
+// runRegisteredCommand dispatches a command that is not a built-in to its
+// registered handler
+func (s *Shell) runRegisteredCommand(cmd string, args []string) error {
+	handler, ok := s.commands[cmd]
+	if !ok {
+		return fmt.Errorf("unknown command: %s", cmd)
+	}
+	return handler(s, args)
+}
@@ -0,0 +1,56 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegisterCommand(t *testing.T) {
+	sh := newTestShell(t)
+
+	var got []string
+	err := sh.RegisterCommand("greet", func(s *Shell, args []string) error {
+		got = args
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	run(t, sh, "greet a b")
+	if strings.Join(got, " ") != "a b" {
+		t.Errorf("greet got args %q, want [a b]", got)
+	}
+
+	noop := func(s *Shell, args []string) error { return nil }
+	for _, name := range []string{"greet", "ls", "gc", "", "two words"} {
+		if err := sh.RegisterCommand(name, noop); err == nil {
+			t.Errorf("RegisterCommand(%q) succeeded, want error", name)
+		}
+	}
+	if err := sh.RegisterCommand("other", nil); err == nil {
+		t.Error("RegisterCommand with nil handler succeeded, want error")
+	}
+}
+
+func TestBuiltinCommandsDispatch(t *testing.T) {
+	for name, handler := range builtinCommands {
+		if handler == nil {
+			t.Errorf("built-in %s has no handler", name)
+		}
+	}
+
+	sh := newTestShell(t)
+	if _, err := runErr(sh, "no-such-command"); err == nil ||
+		!strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("unknown command: got %v", err)
+	}
+	run(t, sh, "mkdir /docs")
+	if out := run(t, sh, "ls /"); !strings.Contains(out, "docs") {
+		t.Errorf("ls / after mkdir:\n%s", out)
+	}
+	sh.running = true
+	run(t, sh, "exit")
+	if sh.running {
+		t.Error("exit left the shell running")
+	}
+}
@@ -0,0 +1,213 @@
+package shell
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// findUsage is the usage message of find
+const findUsage = "usage: find [-L] [--at <time>] [path] [--tag <label>] [-name <glob>] [-exec <command> {}]"
+
+// FindResources lists the resources under a directory that match the given
+// criteria (find [-L] [--at <time>] [path] [--tag <label>] [-name <glob>]
+// [-exec <command>]). -name matches resource names against a glob. The tree
+// is searched as of --at, or the shell's point in time, when one is set;
+// labels are matched as they are now. Symlinks are
+// matched as themselves unless -L/--follow-symlinks is given; then what they
+// point to is matched under the link's path, and directories they point to
+// are searched. With -exec the command runs once per match with {} replaced
+// by its path, all in one transaction that is rolled back if any command
+// fails.
+func (s *Shell) FindResources(args []string) error {
+	var root, tag, pattern string
+	var exec []string
+	var follow bool
+	at := s.PointInTime()
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-L" || args[i] == "--follow-symlinks":
+			follow = true
+		case args[i] == "--at" && i+1 < len(args):
+			// Taken here rather than by takeAtFlag, which would also take
+			// an --at meant for the -exec command
+			t, err := util.ParseTimeSpec(args[i+1])
+			if err != nil {
+				return err
+			}
+			at = &t
+			i++
+		case args[i] == "--tag" && i+1 < len(args):
+			tag = args[i+1]
+			i++
+		case args[i] == "-name" && i+1 < len(args):
+			pattern = args[i+1]
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern: %s", pattern)
+			}
+			i++
+		case args[i] == "-exec" && i+1 < len(args):
+			exec = args[i+1:]
+			i = len(args)
+		case root == "" && !strings.HasPrefix(args[i], "-"):
+			root = args[i]
+		default:
+			return fmt.Errorf(findUsage)
+		}
+	}
+	if tag == "" && pattern == "" {
+		return fmt.Errorf(findUsage)
+	}
+	if root == "" {
+		root = s.state.CurrentDirectory
+	}
+
+	p := s.parseBranchPath(root)
+	options, err := s.readOptions(p, at)
+	if err != nil {
+		return err
+	}
+	if exec != nil && p.Branch != s.state.CurrentBranch {
+		return fmt.Errorf("find: -exec only runs on the current branch")
+	}
+	if exec != nil && at != nil {
+		return fmt.Errorf("find: -exec only runs on the current state")
+	}
+
+	matches, err := s.findMatches(p, options, tag, pattern, follow)
+	if err != nil {
+		return err
+	}
+
+	if exec != nil {
+		return s.execEach(exec, matches)
+	}
+	for _, match := range matches {
+		fmt.Println(branchPath{Branch: p.Branch, Path: match, Qualified: p.Qualified})
+	}
+	return nil
+}
+
+// findMatches returns the paths under p that carry tag and whose names match
+// pattern, where either criterion may be empty. With follow, symlinks are
+// followed and resources reached through them are returned under the
+// link's path.
+func (s *Shell) findMatches(p branchPath, options database.QueryOptions, tag, pattern string, follow bool) ([]string, error) {
+	var tagged map[string]bool
+	if tag != "" {
+		paths, err := s.fm.FindTagged(tag, p.Path, p.Branch, s.CurrentTransaction())
+		if err != nil {
+			return nil, err
+		}
+		if pattern == "" && !follow && options.PointInTime == nil {
+			return paths, nil
+		}
+		tagged = make(map[string]bool, len(paths))
+		for _, path := range paths {
+			tagged[path] = true
+		}
+	}
+
+	if follow {
+		var matches []string
+		root := &resourceEntry{Path: p.Path}
+		err := s.walkSubtreeFollowing(s.CurrentTransaction(), root, options, walkLimits{}, func(entry *resourceEntry, depth int) error {
+			if depth == 0 || (tagged != nil && !tagged[entry.RealPath]) {
+				return nil
+			}
+			if ok, _ := path.Match(pattern, entry.Name); ok || pattern == "" {
+				matches = append(matches, entry.Path)
+			}
+			return nil
+		})
+		return matches, err
+	}
+
+	resources, err := s.fm.ListSubtree(p.Path, s.CurrentTransaction(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, resource := range resources {
+		if tagged != nil && !tagged[resource.Path] {
+			continue
+		}
+		if ok, _ := path.Match(pattern, resource.Name); ok || pattern == "" {
+			matches = append(matches, resource.Path)
+		}
+	}
+	return matches, nil
+}
+
+// execEach runs command once per path with {} replaced by the path. The
+// commands share one transaction: the current one, protected by a savepoint,
+// or a new one on the current branch. On the first failure every command's
+// changes are rolled back.
+func (s *Shell) execEach(command []string, paths []string) error {
+	if tx := s.CurrentTransaction(); tx != nil {
+		const savepoint = "find_exec"
+		if err := tx.Savepoint(savepoint); err != nil {
+			return err
+		}
+		if err := s.runEach(command, paths); err != nil {
+			if rbErr := tx.RollbackToSavepoint(savepoint); rbErr != nil {
+				return fmt.Errorf("%v (rollback failed: %w)", err, rbErr)
+			}
+			tx.ReleaseSavepoint(savepoint)
+			return err
+		}
+		return tx.ReleaseSavepoint(savepoint)
+	}
+
+	if err := s.checkAutocommit(); err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	tx.SetBranchID(s.state.CurrentBranch)
+	tx.SetUserID(s.state.User)
+
+	s.setTransaction(tx)
+	err = s.runEach(command, paths)
+	s.setTransaction(nil)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// runEach runs command for each path, stopping at the first error. The
+// command is dispatched with its words as they are, after replacing {} and
+// expanding an alias in its name, rather than re-parsed as a command line:
+// a path holding spaces, quotes, $ or > stays one argument.
+func (s *Shell) runEach(command []string, paths []string) error {
+	name := strings.Fields(s.expandAliases(command[0]))
+	if len(name) == 0 {
+		return fmt.Errorf(findUsage)
+	}
+
+	line := s.currentCommand
+	defer func() { s.currentCommand = line }()
+
+	for _, p := range paths {
+		words := append([]string(nil), name...)
+		for _, word := range command[1:] {
+			words = append(words, strings.ReplaceAll(word, "{}", p))
+		}
+		s.currentCommand = strings.Join(words, " ")
+		if err := s.runCommand(words[0], words[1:]); err != nil {
+			return fmt.Errorf("find: %s: %w", s.currentCommand, err)
+		}
+	}
+	return nil
+}
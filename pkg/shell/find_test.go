@@ -0,0 +1,127 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// pastState writes /old.txt, renames it to /old.bak after a moment, writes
+// /new.txt, and returns a time at which /old.txt existed and /new.txt did
+// not
+func pastState(t *testing.T, sh *Shell) string {
+	t.Helper()
+	run(t, sh, "echo old > /old.txt")
+	time.Sleep(5 * time.Millisecond)
+	at := time.Now().UTC().Format(time.RFC3339Nano)
+	time.Sleep(5 * time.Millisecond)
+	run(t, sh, "mv /old.txt /old.bak")
+	run(t, sh, "echo new > /new.txt")
+	return at
+}
+
+func TestFindAt(t *testing.T) {
+	sh := newTestShell(t)
+	at := pastState(t, sh)
+
+	if got := run(t, sh, "find / -name *.txt"); got != "/new.txt\n" {
+		t.Errorf("find now = %q, want %q", got, "/new.txt\n")
+	}
+	if got := run(t, sh, "find --at "+at+" / -name *.txt"); got != "/old.txt\n" {
+		t.Errorf("find --at = %q, want %q", got, "/old.txt\n")
+	}
+}
+
+func TestFindInPointInTime(t *testing.T) {
+	sh := newTestShell(t)
+	at := pastState(t, sh)
+
+	run(t, sh, "state-at "+at)
+	if got := run(t, sh, "find / -name *.txt"); got != "/old.txt\n" {
+		t.Errorf("find = %q, want %q", got, "/old.txt\n")
+	}
+}
+
+func TestFindExecRejectsPastState(t *testing.T) {
+	sh := newTestShell(t)
+	at := pastState(t, sh)
+
+	_, err := runErr(sh, "find --at "+at+" / -name *.txt -exec cat {}")
+	if err == nil || !strings.Contains(err.Error(), "current state") {
+		t.Errorf("err = %v, want a refusal to run -exec on a past state", err)
+	}
+}
+
+// createRaw creates a file without going through a command line, for names
+// the shell's word splitting cannot type
+func createRaw(t *testing.T, sh *Shell, path, content string) {
+	t.Helper()
+	err := sh.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID(sh.state.CurrentBranch)
+		tx.SetUserID(sh.state.User)
+		_, err := sh.fm.CreateFile(path, []byte(content), tx, sh.state.User)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindExecKeepsPathsWhole(t *testing.T) {
+	sh := newTestShell(t)
+	for _, path := range []string{"/my notes.txt", "/a>b.txt", "/$HOME.txt"} {
+		createRaw(t, sh, path, path+"\n")
+	}
+
+	output := run(t, sh, "find / -name *.txt -exec cat {}")
+	for _, path := range []string{"/my notes.txt", "/a>b.txt", "/$HOME.txt"} {
+		if !strings.Contains(output, path+"\n") {
+			t.Errorf("-exec cat did not print %s:\n%s", path, output)
+		}
+	}
+}
+
+func TestFindExecExpandsAlias(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo hi > /one.txt")
+	run(t, sh, "alias show='cat'")
+
+	if got := run(t, sh, "find / -name one.txt -exec show {}"); got != "hi\n" {
+		t.Errorf("got %q, want %q", got, "hi\n")
+	}
+}
+
+// TestFindExecRemove removes every match with -exec rm, and none of them
+// when one removal fails
+func TestFindExecRemove(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir -p /d/sub")
+	run(t, sh, "mkdir -p /e/z.log")
+	for _, path := range []string{"/d/a.log", "/d/sub/b.log", "/d/keep.txt", "/e/a.log", "/e/z.log/f"} {
+		run(t, sh, "echo "+path+" > "+path)
+	}
+
+	run(t, sh, "find /d -name *.log -exec rm {}")
+	if got := run(t, sh, "find / -name *.log"); got != "/e/a.log\n/e/z.log\n" {
+		t.Errorf("logs left after -exec rm = %q", got)
+	}
+	if got := readFile(t, sh, "/d/keep.txt"); got != "/d/keep.txt\n" {
+		t.Errorf("/d/keep.txt = %q", got)
+	}
+
+	// rm of the non-empty directory /e/z.log fails after /e/a.log is removed
+	for _, begin := range []bool{false, true} {
+		if begin {
+			run(t, sh, "begin")
+		}
+		if _, err := runErr(sh, "find /e -name *.log -exec rm {}"); err == nil {
+			t.Fatal("-exec rm of a directory succeeded")
+		}
+		if got := readFile(t, sh, "/e/a.log"); got != "/e/a.log\n" {
+			t.Errorf("/e/a.log after the failed -exec (begin %v) = %q", begin, got)
+		}
+	}
+	run(t, sh, "rollback")
+}
@@ -0,0 +1,304 @@
+package shell
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	pathpkg "path"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// LostFoundPath is the directory that fsck --repair reattaches orphans under
+const LostFoundPath = "/lost+found"
+
+// lostFoundPermissions restricts lost+found to its owner
+const lostFoundPermissions = 0700
+
+// orphan is a current resource that cannot be reached by its path
+type orphan struct {
+	resourceEntry
+	ParentID string
+	Reason   string
+}
+
+// CheckFilesystem reports resources on the current branch whose parent is
+// missing, is not a directory, or no longer exists at its path. With
+// --repair the orphans, and everything below them, are moved under
+// /lost+found. (fsck [--repair] [--force])
+func (s *Shell) CheckFilesystem(args []string) error {
+	flags, _, err := splitFlags("fsck", args, "--repair", "--force")
+	if err != nil {
+		return err
+	}
+
+	var q queryExecutor = s.db
+	if s.CurrentTransaction() != nil {
+		q = s.CurrentTransaction()
+	}
+
+	orphans, err := s.findOrphans(q)
+	if err != nil {
+		return err
+	}
+
+	if len(orphans) == 0 {
+		fmt.Println("fsck: no orphaned resources found")
+		return nil
+	}
+
+	for _, o := range orphans {
+		fmt.Printf("orphan: %s (%s, %s)\n", o.Path, o.Type, o.Reason)
+	}
+
+	if !flags["--repair"] {
+		fmt.Printf("fsck: %d orphaned resource(s) found, use --repair to move them to %s\n", len(orphans), LostFoundPath)
+		return nil
+	}
+
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		lostFound, err := s.ensureLostFound(tx)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, o := range orphans {
+			target, moved, err := s.reattachOrphan(tx, &o, lostFound.ID, s.getWalkLimits(flags["--force"]), now)
+			if err != nil {
+				return err
+			}
+			if err := s.recordOperation(tx, schema.OperationKindMove, moved); err != nil {
+				return err
+			}
+			out.Printf("repaired: %s -> %s\n", o.Path, target)
+		}
+		return nil
+	})
+}
+
+// findOrphans returns the orphaned resources on the current branch.
+// Children may reference any version of their parent directory, so a parent
+// counts as present while a current directory exists at its path.
+func (s *Shell) findOrphans(q queryExecutor) ([]orphan, error) {
+	rows, err := q.ExecuteQuery(`
+		SELECT r.id, r.type, r.name, r.path, r.metadata, r.parent_id, p.type, p.path
+		FROM resources r
+		LEFT JOIN resources p ON p.id = r.parent_id
+		WHERE r.branch_id = ? AND r.valid_to IS NULL AND r.parent_id IS NOT NULL
+		AND (p.id IS NULL OR p.type <> 'directory' OR NOT EXISTS (
+			SELECT 1 FROM resources c
+			WHERE c.path = p.path AND c.type = 'directory'
+			AND c.branch_id = r.branch_id AND c.valid_to IS NULL
+		))
+		ORDER BY r.path ASC
+	`, s.state.CurrentBranch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for orphans: %w", err)
+	}
+	defer rows.Close()
+
+	var orphans []orphan
+	for rows.Next() {
+		var o orphan
+		var metadataStr string
+		var parentType, parentPath sql.NullString
+
+		err := rows.Scan(&o.ID, &o.Type, &o.Name, &o.Path, &metadataStr, &o.ParentID, &parentType, &parentPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan resource: %w", err)
+		}
+		if o.Metadata, err = schema.NormalizeMetadata(json.RawMessage(metadataStr)); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata for %s: %w", o.Path, err)
+		}
+
+		switch {
+		case !parentType.Valid:
+			o.Reason = "parent " + o.ParentID + " does not exist"
+		case parentType.String != schema.ResourceTypeDirectory:
+			o.Reason = "parent " + parentPath.String + " is not a directory"
+		default:
+			o.Reason = "parent directory " + parentPath.String + " no longer exists"
+		}
+		orphans = append(orphans, o)
+	}
+
+	return orphans, rows.Err()
+}
+
+// ensureLostFound returns /lost+found on the current branch, creating it
+// if needed
+func (s *Shell) ensureLostFound(tx *database.Transaction) (*resourceEntry, error) {
+	if dir, err := s.lookupResource(tx, LostFoundPath); err == nil {
+		if dir.Type != schema.ResourceTypeDirectory {
+			return nil, fmt.Errorf("%s exists and is not a directory", LostFoundPath)
+		}
+		return dir, nil
+	}
+
+	root, err := s.lookupResource(tx, "/")
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := schema.NewDirectoryMetadata(s.state.User, lostFoundPermissions)
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal directory metadata: %w", err)
+	}
+
+	dirID, err := newResourceID(tx, schema.ResourceTypeDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := &resourceEntry{
+		ID:       dirID,
+		Type:     schema.ResourceTypeDirectory,
+		Name:     pathpkg.Base(LostFoundPath),
+		Path:     LostFoundPath,
+		Metadata: metadata,
+	}
+	_, err = tx.Execute(`
+		INSERT INTO resources (id, type, name, parent_id, path, metadata, valid_from, transaction_id, branch_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, dir.ID, dir.Type, dir.Name, root.ID, dir.Path, string(metadataJSON), time.Now(), tx.GetID(), s.state.CurrentBranch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", LostFoundPath, err)
+	}
+
+	return dir, nil
+}
+
+// orphanEntry is a resource moved with an orphan. ParentID is the resource
+// it is moved with as a child, the current version of its directory; its
+// own parent_id may name an earlier version.
+type orphanEntry struct {
+	resourceEntry
+	ParentID string
+	Depth    int
+}
+
+// reattachOrphan moves an orphan and its descendants under lost+found as new
+// versions, returning the orphan's new path and the IDs of the new versions.
+// The name is suffixed when it is already taken.
+func (s *Shell) reattachOrphan(tx *database.Transaction, o *orphan, lostFoundID string, limits walkLimits, now time.Time) (string, []string, error) {
+	name := o.Name
+	for n := 1; ; n++ {
+		if _, err := s.lookupResource(tx, pathpkg.Join(LostFoundPath, name)); err != nil {
+			break
+		}
+		name = fmt.Sprintf("%s.%d", o.Name, n)
+	}
+	target := pathpkg.Join(LostFoundPath, name)
+
+	// Collect the subtree before writing so the search does not observe
+	// the moved versions
+	entries, err := s.orphanSubtree(tx, &o.resourceEntry, limits)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", o.Path, err)
+	}
+
+	// Each entry is placed under the new version of the entry it was
+	// found under, by ID
+	newIDs := map[string]string{}
+	newPaths := map[string]string{}
+	var moved []string
+	for i, entry := range entries {
+		parentID, newPath := lostFoundID, target
+		if i > 0 {
+			parentID = newIDs[entry.ParentID]
+			newPath = pathpkg.Join(newPaths[entry.ParentID], entry.Name)
+		}
+
+		newID, err := newResourceID(tx, entry.Type)
+		if err != nil {
+			return "", nil, err
+		}
+		newIDs[entry.ID], newPaths[entry.ID] = newID, newPath
+
+		_, err = tx.Execute(`UPDATE resources SET valid_to = ? WHERE id = ? AND valid_to IS NULL`, now, entry.ID)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to close old version of %s: %w", entry.Path, err)
+		}
+
+		_, err = tx.Execute(`
+			INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id, branch_id)
+			SELECT ?, type, ?, ?, ?, content, metadata, ?, ?, branch_id
+			FROM resources WHERE id = ?
+		`, newID, pathpkg.Base(newPath), parentID, newPath, now, tx.GetID(), entry.ID)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to move %s: %w", entry.Path, err)
+		}
+		moved = append(moved, newID)
+	}
+
+	return target, moved, nil
+}
+
+// orphanSubtree returns root and the current resources below it, each
+// directory before its children. Children are found through parent_id,
+// which may name the directory's current version or an earlier one.
+func (s *Shell) orphanSubtree(tx *database.Transaction, root *resourceEntry, limits walkLimits) ([]orphanEntry, error) {
+	entries := []orphanEntry{{resourceEntry: *root}}
+	seen := map[string]bool{root.ID: true}
+
+	for i := 0; i < len(entries); i++ {
+		dir := entries[i]
+		if dir.Type != schema.ResourceTypeDirectory {
+			continue
+		}
+
+		children, err := s.childEntries(tx, dir.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			if seen[child.ID] {
+				continue
+			}
+			seen[child.ID] = true
+
+			if limits.MaxDepth > 0 && dir.Depth+1 > limits.MaxDepth {
+				return nil, fmt.Errorf("operation exceeds depth limit of %d, use --force", limits.MaxDepth)
+			}
+			entries = append(entries, orphanEntry{resourceEntry: *child, ParentID: dir.ID, Depth: dir.Depth + 1})
+			if limits.MaxNodes > 0 && len(entries) > limits.MaxNodes {
+				return nil, fmt.Errorf("operation exceeds limit of %d resources, use --force", limits.MaxNodes)
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// childEntries returns the current resources whose parent is any version of
+// the directory with the given version ID
+func (s *Shell) childEntries(tx *database.Transaction, dirID string) ([]*resourceEntry, error) {
+	rows, err := tx.ExecuteQuery(`
+		SELECT c.id, c.type, c.name, c.path, c.metadata
+		FROM resources c
+		WHERE c.branch_id = ? AND c.valid_to IS NULL AND c.parent_id IN (
+			SELECT v.id FROM resources v
+			JOIN resources d ON d.path = v.path AND d.branch_id = v.branch_id
+			WHERE d.id = ? AND v.type = 'directory'
+		)
+		ORDER BY c.name ASC
+	`, s.state.CurrentBranch, dirID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list children: %w", err)
+	}
+	defer rows.Close()
+
+	var children []*resourceEntry
+	for rows.Next() {
+		entry, err := scanResourceEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, entry)
+	}
+	return children, rows.Err()
+}
@@ -0,0 +1,69 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+// orphanDirectory makes /a/b an orphan by closing the current version of
+// /a, leaving /a/b/f below it. /a/b is versioned by chmod first, so f
+// references an earlier version of its directory.
+func orphanDirectory(t *testing.T, sh *Shell) {
+	t.Helper()
+	run(t, sh, "mkdir -p /a/b")
+	run(t, sh, "echo hello > /a/b/f")
+	run(t, sh, "chmod 700 /a/b")
+	if _, err := sh.execute(`UPDATE resources SET valid_to = ? WHERE path = ? AND valid_to IS NULL`, "2000-01-01", "/a"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFsckFindsOrphan(t *testing.T) {
+	sh := newTestShell(t)
+	if output := run(t, sh, "fsck"); !strings.Contains(output, "no orphaned resources") {
+		t.Errorf("fsck on a clean tree:\n%s", output)
+	}
+
+	orphanDirectory(t, sh)
+	output := run(t, sh, "fsck")
+	if !strings.Contains(output, "orphan: /a/b (directory, parent directory /a no longer exists)") {
+		t.Errorf("fsck did not report /a/b:\n%s", output)
+	}
+	if strings.Contains(output, "/a/b/f") {
+		t.Errorf("fsck reported f, which is reachable from its orphaned directory:\n%s", output)
+	}
+}
+
+func TestFsckRepair(t *testing.T) {
+	sh := newTestShell(t)
+	orphanDirectory(t, sh)
+
+	output := run(t, sh, "fsck --repair")
+	if !strings.Contains(output, "repaired: /a/b -> /lost+found/b") {
+		t.Errorf("fsck --repair:\n%s", output)
+	}
+	if got := readFile(t, sh, "/lost+found/b/f"); got != "hello\n" {
+		t.Errorf("/lost+found/b/f = %q, want %q", got, "hello\n")
+	}
+	if output := run(t, sh, "fsck"); !strings.Contains(output, "no orphaned resources") {
+		t.Errorf("fsck after repair:\n%s", output)
+	}
+}
+
+// TestFsckRepairPlacesChildrenByParent moves a child under its directory
+// even when its stored path does not match the directory's
+func TestFsckRepairPlacesChildrenByParent(t *testing.T) {
+	sh := newTestShell(t)
+	orphanDirectory(t, sh)
+	if _, err := sh.execute(`UPDATE resources SET path = ? WHERE path = ? AND valid_to IS NULL`, "/elsewhere/f", "/a/b/f"); err != nil {
+		t.Fatal(err)
+	}
+
+	run(t, sh, "fsck --repair")
+	if got := readFile(t, sh, "/lost+found/b/f"); got != "hello\n" {
+		t.Errorf("/lost+found/b/f = %q, want %q", got, "hello\n")
+	}
+	if output := run(t, sh, "fsck"); !strings.Contains(output, "no orphaned resources") {
+		t.Errorf("fsck after repair:\n%s", output)
+	}
+}
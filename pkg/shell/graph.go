@@ -0,0 +1,118 @@
+package shell
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// branchNode is a branch in the branch graph
+type branchNode struct {
+	Name     string
+	Status   string
+	Parent   string // Branch it was forked from, or rebased onto last
+	ForkedAt time.Time
+	Children []*branchNode
+}
+
+// ShowBranchGraph prints the branches as a tree, each under the branch its
+// base state was taken from, with the time it was forked and its status
+// (branch graph). A rebased branch is shown under the branch it was rebased
+// onto.
+func (s *Shell) ShowBranchGraph(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: branch graph")
+	}
+
+	rows, err := s.queries().ExecuteQuery(`
+		SELECT b.name, b.status, t.branch_id, t.start_time
+		FROM branches b LEFT JOIN transactions t ON t.id = b.base_state_id
+		ORDER BY b.created_at ASC, b.name ASC
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to list branches: %w", err)
+	}
+	defer rows.Close()
+
+	var nodes []*branchNode
+	byName := make(map[string]*branchNode)
+	for rows.Next() {
+		var node branchNode
+		var parent sql.NullString
+		var forkedAt sql.NullTime
+		if err := rows.Scan(&node.Name, &node.Status, &parent, &forkedAt); err != nil {
+			return fmt.Errorf("failed to scan branch: %w", err)
+		}
+		node.Parent, node.ForkedAt = parent.String, forkedAt.Time
+		nodes = append(nodes, &node)
+		byName[node.Name] = &node
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// A branch whose base state is on another known branch hangs under it;
+	// the rest, like main, are roots
+	var roots []*branchNode
+	for _, node := range nodes {
+		parent, ok := byName[node.Parent]
+		if !ok || parent == node {
+			node.Parent = ""
+			roots = append(roots, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+
+	printed := make(map[string]bool)
+	for _, root := range roots {
+		s.printBranchTree(root, "", "", printed)
+	}
+
+	// Rebasing branches onto each other can leave a cycle that no root
+	// reaches; each is broken at its oldest branch
+	for _, node := range nodes {
+		if !printed[node.Name] {
+			s.printBranchTree(node, "", "", printed)
+		}
+	}
+	return nil
+}
+
+// printBranchTree prints a branch on a line starting with prefix and
+// connector, then its children indented below it
+func (s *Shell) printBranchTree(node *branchNode, prefix, connector string, printed map[string]bool) {
+	printed[node.Name] = true
+
+	marker := " "
+	if node.Name == s.state.CurrentBranch {
+		marker = "*"
+	}
+	line := fmt.Sprintf("%s %s%s%s [%s]", marker, prefix, connector, node.Name, node.Status)
+	if node.Parent != "" {
+		line += fmt.Sprintf(" forked from %s at %s", node.Parent, node.ForkedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	fmt.Println(line)
+
+	// Children line up under their parent's name
+	switch connector {
+	case "+-- ":
+		prefix += "|   "
+	case "`-- ":
+		prefix += "    "
+	}
+
+	var children []*branchNode
+	for _, child := range node.Children {
+		if !printed[child.Name] {
+			children = append(children, child)
+		}
+	}
+	for i, child := range children {
+		if i == len(children)-1 {
+			s.printBranchTree(child, prefix, "`-- ", printed)
+		} else {
+			s.printBranchTree(child, prefix, "+-- ", printed)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// resourceVersion is one historical version of a resource
+type resourceVersion struct {
+	Number        int
+	ID            string
+	Type          string
+	ValidFrom     time.Time
+	ValidTo       *time.Time
+	TransactionID string
+	Metadata      schema.ResourceMetadata
+}
+
+// ShowResourceHistory shows the versions of the resource at a path on the
+// current branch (history <path> [--since <time>] [--until <time>])
+func (s *Shell) ShowResourceHistory(args []string) error {
+	var path string
+	var since, until *time.Time
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--since", "--until":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a time", args[i])
+			}
+			t, err := util.ParseTimeSpec(args[i+1])
+			if err != nil {
+				return err
+			}
+			if args[i] == "--since" {
+				since = &t
+			} else {
+				until = &t
+			}
+			i++
+		default:
+			path = s.resolvePath(args[i])
+		}
+	}
+
+	if path == "" {
+		return fmt.Errorf("path required")
+	}
+
+	versions, err := s.resourceVersions(s.queries(), path)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("no history for %s", path)
+	}
+
+	fmt.Printf("History of %s:\n", path)
+	shown := 0
+	for _, v := range versions {
+		if since != nil && v.ValidFrom.Before(*since) {
+			continue
+		}
+		if until != nil && v.ValidFrom.After(*until) {
+			continue
+		}
+
+		validTo := "current"
+		if v.ValidTo != nil {
+			validTo = util.FormatTimestamp(*v.ValidTo)
+		}
+		fmt.Printf("%4d  %s -> %-19s  %-20s  %04o  %-10s %s\n",
+			v.Number, util.FormatTimestamp(v.ValidFrom), validTo, txLabel(v.TransactionID),
+			v.Metadata.Permissions, v.Metadata.Owner, util.FormatByteSize(v.Metadata.Size))
+		shown++
+	}
+
+	if shown == 0 {
+		fmt.Println("(no versions in the given window)")
+	}
+	return nil
+}
+
+// resourceVersions returns all versions stored at a path on the current
+// branch, oldest first and numbered from 1
+func (s *Shell) resourceVersions(q queryExecutor, path string) ([]resourceVersion, error) {
+	rows, err := q.ExecuteQuery(`
+		SELECT id, type, valid_from, valid_to, transaction_id, metadata
+		FROM resources
+		WHERE path = ? AND branch_id = ?
+		ORDER BY valid_from ASC, id ASC
+	`, path, s.state.CurrentBranch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []resourceVersion
+	for rows.Next() {
+		var v resourceVersion
+		var validTo *time.Time
+		var metadataStr string
+		if err := rows.Scan(&v.ID, &v.Type, &v.ValidFrom, &validTo, &v.TransactionID, &metadataStr); err != nil {
+			return nil, fmt.Errorf("failed to scan version: %w", err)
+		}
+		metadata, err := schema.NormalizeMetadata(json.RawMessage(metadataStr))
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+		v.Metadata = metadata
+		v.ValidTo = validTo
+		v.Number = len(versions) + 1
+		versions = append(versions, v)
+	}
+
+	return versions, rows.Err()
+}
@@ -0,0 +1,60 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// historyLines returns the version lines history prints for a path
+func historyLines(t *testing.T, sh *Shell, args string) []string {
+	t.Helper()
+	lines := strings.Split(strings.TrimRight(run(t, sh, "history "+args), "\n"), "\n")
+	return lines[1:]
+}
+
+func TestResourceHistory(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo one > /tmp/f")
+	time.Sleep(5 * time.Millisecond)
+	middle := time.Now().UTC().Format(time.RFC3339Nano)
+	time.Sleep(5 * time.Millisecond)
+	run(t, sh, "echo two > /tmp/f")
+	run(t, sh, "chmod 600 /tmp/f")
+
+	output := run(t, sh, "history /tmp/f")
+	if !strings.HasPrefix(output, "History of /tmp/f:\n") {
+		t.Errorf("history = %q", output)
+	}
+	lines := historyLines(t, sh, "/tmp/f")
+	if len(lines) != 3 {
+		t.Fatalf("history lists %d versions:\n%s", len(lines), output)
+	}
+	if !strings.HasPrefix(lines[0], "   1  ") || !strings.Contains(lines[2], "current") || !strings.Contains(lines[2], "0600") {
+		t.Errorf("history:\n%s", output)
+	}
+
+	if got := historyLines(t, sh, "/tmp/f --until "+middle); len(got) != 1 {
+		t.Errorf("history --until lists %d versions", len(got))
+	}
+	if got := historyLines(t, sh, "--since "+middle+" /tmp/f"); len(got) != 2 || !strings.HasPrefix(got[0], "   2  ") {
+		t.Errorf("history --since = %q", got)
+	}
+	if got := historyLines(t, sh, "/tmp/f --since 2999-01-01T00:00:00Z"); len(got) != 1 || got[0] != "(no versions in the given window)" {
+		t.Errorf("history with an empty window = %q", got)
+	}
+
+	for _, command := range []string{"history /tmp/missing", "history /tmp/f --since", "history /tmp/f --until soon"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
+
+func TestCommandHistory(t *testing.T) {
+	sh := newTestShell(t)
+	sh.history = []string{"ls /", "cd /tmp"}
+	if output := run(t, sh, "history"); output != "1: ls /\n2: cd /tmp\n" {
+		t.Errorf("history = %q", output)
+	}
+}
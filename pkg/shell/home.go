@@ -0,0 +1,143 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	pathpkg "path"
+	"strings"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// userProfile is where a user's sessions start
+type userProfile struct {
+	HomeDir       string
+	DefaultBranch string
+	// Registered is false for users without a row in the users table, who
+	// get the defaults
+	Registered bool
+}
+
+// loadUserProfile reads the shell user's home directory and default branch
+func (s *Shell) loadUserProfile() (*userProfile, error) {
+	profile := &userProfile{
+		HomeDir:       "/home/" + s.state.User,
+		DefaultBranch: "main",
+	}
+
+	rows, err := s.db.ExecuteQuery(`SELECT home_dir, default_branch FROM users WHERE username = ?`, s.state.User)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return profile, rows.Err()
+	}
+	var homeDir, defaultBranch string
+	if err := rows.Scan(&homeDir, &defaultBranch); err != nil {
+		return nil, fmt.Errorf("failed to scan user: %w", err)
+	}
+	profile.Registered = true
+	if homeDir != "" {
+		profile.HomeDir = pathpkg.Clean(homeDir)
+	}
+	if defaultBranch != "" {
+		profile.DefaultBranch = defaultBranch
+	}
+	return profile, nil
+}
+
+// enterHome puts a new session on the user's default branch and in their
+// home directory, creating the home directory and any missing parents, owned
+// by the user, if they do not exist. /home itself is created, owned by
+// system, with the schema. A default branch that no longer exists or is not
+// active is reported and the session stays on its current branch.
+func (s *Shell) enterHome() error {
+	if s.state.User == "" || strings.Contains(s.state.User, "/") {
+		return nil
+	}
+
+	profile, err := s.loadUserProfile()
+	if err != nil {
+		return err
+	}
+
+	if profile.DefaultBranch != s.state.CurrentBranch {
+		status, err := s.branchStatus(profile.DefaultBranch)
+		if err != nil {
+			return err
+		}
+		if status == schema.BranchStatusActive {
+			s.state.CurrentBranch = profile.DefaultBranch
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: default branch %s is unavailable, staying on %s\n", profile.DefaultBranch, s.state.CurrentBranch)
+		}
+	}
+
+	// Most sessions find the home directory in place and write nothing
+	home, err := s.lookupResource(s.db, profile.HomeDir)
+	switch {
+	case err == nil && home.Type != schema.ResourceTypeDirectory:
+		return fmt.Errorf("home directory %s is not a directory", profile.HomeDir)
+	case errors.Is(err, database.ErrNotFound):
+		err = s.db.WithTransaction(func(tx *database.Transaction) error {
+			tx.SetBranchID(s.state.CurrentBranch)
+			tx.SetUserID(s.state.User)
+			// Login is quiet about the directories it creates
+			_, err := s.makeDirectories(tx, &txOutput{}, profile.HomeDir)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create home directory %s: %w", profile.HomeDir, err)
+		}
+	case err != nil:
+		return err
+	}
+
+	s.state.CurrentDirectory = profile.HomeDir
+	return nil
+}
+
+// branchStatus returns the status of a branch, or "" if it does not exist
+func (s *Shell) branchStatus(name string) (string, error) {
+	rows, err := s.queries().ExecuteQuery(`SELECT status FROM branches WHERE name = ?`, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up branch: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", rows.Err()
+	}
+	var status string
+	if err := rows.Scan(&status); err != nil {
+		return "", fmt.Errorf("failed to scan branch: %w", err)
+	}
+	return status, nil
+}
+
+// setDefaultBranch stores the branch the shell user's sessions start on
+func (s *Shell) setDefaultBranch(name string) error {
+	status, err := s.branchStatus(name)
+	if err != nil {
+		return err
+	}
+	if status == "" {
+		return fmt.Errorf("branch %w: %s", database.ErrNotFound, name)
+	}
+	if status != schema.BranchStatusActive {
+		return fmt.Errorf("branch %s is %s", name, status)
+	}
+
+	result, err := s.execute(`UPDATE users SET default_branch = ? WHERE username = ?`, name, s.state.User)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("user %w: %s", database.ErrNotFound, s.state.User)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// addTestUser registers a user directly, without going through user add
+func addTestUser(t *testing.T, sh *Shell, name string) {
+	t.Helper()
+	now := time.Now()
+	_, err := sh.db.ExecuteStatement(`
+		INSERT INTO users (id, username, password, created_at, updated_at, is_active, is_admin)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, name, name, "", now, now, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnterHomeCreatesHomeDirectory(t *testing.T) {
+	sh := newTestShell(t)
+	addTestUser(t, sh, "alice")
+	sh.state.User = "alice"
+
+	if err := sh.enterHome(); err != nil {
+		t.Fatal(err)
+	}
+	if sh.state.CurrentDirectory != "/home/alice" {
+		t.Errorf("current directory = %s, want /home/alice", sh.state.CurrentDirectory)
+	}
+
+	home, err := sh.lookupResource(sh.db, "/home/alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if home.Metadata.Owner != "alice" {
+		t.Errorf("/home/alice owner = %s, want alice", home.Metadata.Owner)
+	}
+	parent, err := sh.lookupResource(sh.db, "/home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent.Metadata.Owner != "system" {
+		t.Errorf("/home owner = %s, want system", parent.Metadata.Owner)
+	}
+}
+
+func TestEnterHomeSkipsWriteWhenHomeExists(t *testing.T) {
+	sh := newTestShell(t)
+	addTestUser(t, sh, "alice")
+	sh.state.User = "alice"
+
+	if err := sh.enterHome(); err != nil {
+		t.Fatal(err)
+	}
+	versions := countRows(t, sh, `SELECT COUNT(*) FROM resources`)
+	transactions := countRows(t, sh, `SELECT COUNT(*) FROM transactions`)
+
+	sh.state.CurrentDirectory = "/"
+	if err := sh.enterHome(); err != nil {
+		t.Fatal(err)
+	}
+	if sh.state.CurrentDirectory != "/home/alice" {
+		t.Errorf("current directory = %s, want /home/alice", sh.state.CurrentDirectory)
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM resources`); n != versions {
+		t.Errorf("second session wrote %d resource version(s)", n-versions)
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM transactions`); n != transactions {
+		t.Errorf("second session recorded %d transaction(s)", n-transactions)
+	}
+}
+
+func TestEnterHomeRejectsFile(t *testing.T) {
+	sh := newTestShell(t)
+	addTestUser(t, sh, "alice")
+	createRaw(t, sh, "/home/alice", "not a directory")
+	sh.state.User = "alice"
+
+	if err := sh.enterHome(); err == nil {
+		t.Fatal("enterHome succeeded with a file at the home directory")
+	}
+}
+
+// TestLoginDefaultBranch starts sessions for a user whose default branch is
+// available and then abandoned
+func TestLoginDefaultBranch(t *testing.T) {
+	sh := newTestShell(t)
+	addTestUser(t, sh, "alice")
+	run(t, sh, "branch work")
+	sh.state.User = "alice"
+	run(t, sh, "set defaultbranch work")
+
+	if _, err := captureOutput(sh.StartSession); err != nil {
+		t.Fatal(err)
+	}
+	if sh.state.CurrentBranch != "work" || sh.state.CurrentDirectory != "/home/alice" {
+		t.Errorf("logged in on %s in %s, want work in /home/alice", sh.state.CurrentBranch, sh.state.CurrentDirectory)
+	}
+	if home := run(t, sh, "stat /home/alice"); !strings.Contains(home, "directory") {
+		t.Errorf("home directory on work:\n%s", home)
+	}
+	if err := sh.EndSession(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sh.db.ExecuteStatement(`UPDATE branches SET status = ? WHERE name = 'work'`, schema.BranchStatusAbandoned); err != nil {
+		t.Fatal(err)
+	}
+	sh.state.CurrentBranch, sh.state.CurrentDirectory = "main", "/"
+	if _, err := captureOutput(sh.StartSession); err != nil {
+		t.Fatal(err)
+	}
+	defer sh.EndSession()
+	if sh.state.CurrentBranch != "main" || sh.state.CurrentDirectory != "/home/alice" {
+		t.Errorf("logged in on %s in %s, want main in /home/alice", sh.state.CurrentBranch, sh.state.CurrentDirectory)
+	}
+}
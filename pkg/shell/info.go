@@ -0,0 +1,79 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// ShowInfo prints the schema version, database details, and table counts
+func (s *Shell) ShowInfo() error {
+	version, err := schema.GetVersion(s.queries())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Schema version:  %d (%s, applied %s)\n", version.Version, version.Description, version.AppliedAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Database type:   %s\n", s.db.GetDatabaseType())
+	fmt.Printf("Connection ID:   %s\n", s.db.GetConnectionID())
+
+	if path := s.db.GetPath(); path != "" {
+		fmt.Printf("Database file:   %s\n", path)
+		if size, err := databaseFileSize(path); err == nil {
+			fmt.Printf("Size on disk:    %s\n", util.FormatByteSize(size))
+		} else {
+			fmt.Printf("Size on disk:    unavailable (%v)\n", err)
+		}
+	} else {
+		fmt.Println("Database file:   none")
+	}
+
+	var current, versions int64
+	err = s.queryRow(`
+		SELECT COUNT(CASE WHEN valid_to IS NULL THEN 1 END), COUNT(*) FROM resources
+	`, nil, &current, &versions)
+	if err != nil {
+		return fmt.Errorf("failed to count resources: %w", err)
+	}
+	fmt.Printf("Resources:       %d current, %d versions\n", current, versions)
+
+	var branches, transactions int64
+	if err := s.queryRow(`SELECT COUNT(*) FROM branches`, nil, &branches); err != nil {
+		return fmt.Errorf("failed to count branches: %w", err)
+	}
+	if err := s.queryRow(`SELECT COUNT(*) FROM transactions`, nil, &transactions); err != nil {
+		return fmt.Errorf("failed to count transactions: %w", err)
+	}
+	fmt.Printf("Branches:        %d\n", branches)
+	fmt.Printf("Transactions:    %d\n", transactions)
+
+	return nil
+}
+
+// databaseFileSize returns the size of a SQLite database file together with
+// its write-ahead log, if there is one
+func databaseFileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+
+	if wal, err := os.Stat(path + "-wal"); err == nil {
+		size += wal.Size()
+	}
+	return size, nil
+}
+
+// DescribeResources prints the resource types and the JSON schema of
+// resource metadata (describe)
+func (s *Shell) DescribeResources() error {
+	description, err := schema.DescribeResourcesJSON()
+	if err != nil {
+		return fmt.Errorf("failed to describe resources: %w", err)
+	}
+	fmt.Println(string(description))
+	return nil
+}
@@ -0,0 +1,44 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+func TestInfo(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo one > /tmp/a")
+	run(t, sh, "echo two > /tmp/a")
+
+	output := run(t, sh, "info")
+	for _, want := range []string{
+		fmt.Sprintf("Schema version:  %d (", schema.CurrentSchemaVersion),
+		"Database type:   inmemory",
+		"Database file:   none",
+		"Branches:        1",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("info lacks %q:\n%s", want, output)
+		}
+	}
+	current := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE valid_to IS NULL`)
+	versions := countRows(t, sh, `SELECT COUNT(*) FROM resources`)
+	if want := fmt.Sprintf("Resources:       %d current, %d versions", current, versions); !strings.Contains(output, want) {
+		t.Errorf("info lacks %q:\n%s", want, output)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	sh := newTestShell(t)
+	var description schema.ResourceDescription
+	if err := json.Unmarshal([]byte(run(t, sh, "describe")), &description); err != nil {
+		t.Fatal(err)
+	}
+	if len(description.Types) != 3 || description.Metadata.Properties["owner"] == nil {
+		t.Errorf("describe = %+v", description)
+	}
+}
@@ -0,0 +1,110 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrInterrupted is returned by a command stopped with Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// errTerminated is returned by nextLine when the shell is told to terminate
+var errTerminated = errors.New("terminated")
+
+// outputChunk is how much long-running output is written between checks
+// for an interrupt
+const outputChunk = 64 << 10
+
+// SetTerminate makes Run stop when a signal, such as SIGTERM, arrives on c:
+// the running command is interrupted and Run returns without reading more
+// input. The caller then rolls back with Shutdown on the goroutine that ran
+// Run, so the rollback never races a command using the transaction.
+func (s *Shell) SetTerminate(c <-chan os.Signal) {
+	s.terminate = c
+}
+
+// runInterruptible runs fn with s.ctx cancelled if an interrupt arrives on
+// interrupts before fn returns, or a signal on s.terminate, which also sets
+// s.terminated. Interrupts received while no command was running are
+// discarded first.
+func (s *Shell) runInterruptible(interrupts <-chan os.Signal, fn func() error) error {
+	for drained := false; !drained; {
+		select {
+		case <-interrupts:
+		default:
+			drained = true
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		select {
+		case <-interrupts:
+			cancel()
+		case <-s.terminate:
+			s.terminated = true
+			cancel()
+		case <-done:
+		}
+	}()
+
+	s.ctx = ctx
+	err := fn()
+	close(done)
+	<-watched
+	cancel()
+	s.ctx = context.Background()
+	return err
+}
+
+// writeInterruptible writes data to w in chunks, stopping with
+// ErrInterrupted once ctx is cancelled
+func writeInterruptible(ctx context.Context, w io.Writer, data []byte) error {
+	for len(data) > 0 {
+		if ctx.Err() != nil {
+			return ErrInterrupted
+		}
+		n := len(data)
+		if n > outputChunk {
+			n = outputChunk
+		}
+		if _, err := w.Write(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// nextLine reads the next line of input like readLine, but returns
+// errTerminated, and sets s.terminated, as soon as a signal arrives on
+// s.terminate. The read then carries on in the background, so no more input
+// may be read.
+func (s *Shell) nextLine() (string, error) {
+	if s.terminate == nil {
+		return s.readLine()
+	}
+
+	type result struct {
+		line string
+		err  error
+	}
+	lines := make(chan result, 1)
+	go func() {
+		line, err := s.readLine()
+		lines <- result{line, err}
+	}()
+
+	select {
+	case r := <-lines:
+		return r.line, r.err
+	case <-s.terminate:
+		s.terminated = true
+		return "", errTerminated
+	}
+}
@@ -0,0 +1,126 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+func TestRunInterruptible(t *testing.T) {
+	sh := newTestShell(t)
+	interrupts := make(chan os.Signal, 2)
+
+	// An interrupt that arrived before the command is discarded
+	interrupts <- syscall.SIGINT
+	err := sh.runInterruptible(interrupts, func() error {
+		return sh.ctx.Err()
+	})
+	if err != nil {
+		t.Errorf("stale interrupt cancelled the command: %v", err)
+	}
+
+	err = sh.runInterruptible(interrupts, func() error {
+		interrupts <- syscall.SIGINT
+		select {
+		case <-sh.ctx.Done():
+			return ErrInterrupted
+		case <-time.After(5 * time.Second):
+			return errors.New("not interrupted")
+		}
+	})
+	if !errors.Is(err, ErrInterrupted) {
+		t.Errorf("interrupted command: %v", err)
+	}
+	if sh.ctx.Err() != nil {
+		t.Error("context still cancelled after the command")
+	}
+}
+
+// TestRunInterruptibleTerminate cancels a running command with a signal on
+// the shell's terminate channel
+func TestRunInterruptibleTerminate(t *testing.T) {
+	sh := newTestShell(t)
+	terminate := make(chan os.Signal, 1)
+	sh.SetTerminate(terminate)
+
+	err := sh.runInterruptible(nil, func() error {
+		terminate <- syscall.SIGTERM
+		select {
+		case <-sh.ctx.Done():
+			return ErrInterrupted
+		case <-time.After(5 * time.Second):
+			return errors.New("not interrupted")
+		}
+	})
+	if !errors.Is(err, ErrInterrupted) || !sh.terminated {
+		t.Errorf("terminated command: %v, terminated %v", err, sh.terminated)
+	}
+}
+
+// TestRunTerminate stops Run with a signal while it waits for input with a
+// transaction open, which Shutdown then rolls back on the same goroutine
+func TestRunTerminate(t *testing.T) {
+	sh := newTestShell(t)
+	r, w := io.Pipe()
+	t.Cleanup(func() { w.Close() })
+	sh.input = bufio.NewReader(r)
+	terminate := make(chan os.Signal, 1)
+	sh.SetTerminate(terminate)
+
+	finished := make(chan error, 1)
+	go func() {
+		_, err := captureOutput(func() error { sh.Run(); return nil })
+		finished <- err
+	}()
+	// Each write returns once Run has read it, so the last one returns
+	// after the commands before it have run
+	for _, line := range []string{"begin\n", "mkdir /pending\n", "\n"} {
+		if _, err := io.WriteString(w, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	terminate <- syscall.SIGTERM
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not stop")
+	}
+	if err := sh.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if sh.CurrentTransaction() != nil {
+		t.Error("transaction still open after Shutdown")
+	}
+	if _, err := sh.lookupResource(sh.db, "/pending"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("/pending after rollback: %v, want ErrNotFound", err)
+	}
+}
+
+func TestWriteInterruptible(t *testing.T) {
+	data := []byte(strings.Repeat("x", 3*outputChunk))
+
+	var buf bytes.Buffer
+	if err := writeInterruptible(context.Background(), &buf, data); err != nil || buf.Len() != len(data) {
+		t.Errorf("wrote %d bytes, %v", buf.Len(), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	buf.Reset()
+	if err := writeInterruptible(ctx, &buf, data); !errors.Is(err, ErrInterrupted) || buf.Len() != 0 {
+		t.Errorf("cancelled write: %d bytes, %v", buf.Len(), err)
+	}
+}
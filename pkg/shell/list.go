@@ -0,0 +1,91 @@
+package shell
+
+import (
+	"fmt"
+	pathpkg "path"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// ListRecursive lists a directory and all of its subdirectories, one section
+// per directory, as seen on the current branch at the given point in time
+// (ls -R [-L] [--force] [-h] [--at <time>] [path]). With -L, symlinks to
+// directories are listed as directories and descended into.
+func (s *Shell) ListRecursive(args []string, at *time.Time, force, human, follow bool) error {
+	path := s.state.CurrentDirectory
+	if len(args) > 0 {
+		path = s.resolvePath(args[0])
+	}
+
+	options, err := branchOptions(s.state.CurrentBranch, at)
+	if err != nil {
+		return err
+	}
+
+	// Group entries by directory; the walk visits each directory before its
+	// contents, so directories are collected in the order they are listed
+	var dirs []string
+	contents := make(map[string][]resourceEntry)
+	walk := s.walkSubtree
+	if follow {
+		walk = s.walkSubtreeFollowing
+	}
+	err = walk(s.CurrentTransaction(), &resourceEntry{Path: path}, options, s.getWalkLimits(force), func(entry *resourceEntry, depth int) error {
+		if depth == 0 && entry.Type != schema.ResourceTypeDirectory {
+			return fmt.Errorf("directory %w: %s", database.ErrNotFound, path)
+		}
+		if depth > 0 {
+			parent := pathpkg.Dir(entry.Path)
+			contents[parent] = append(contents[parent], *entry)
+		}
+		if entry.Type == schema.ResourceTypeDirectory {
+			dirs = append(dirs, entry.Path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, dir := range dirs {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s:\n", dir)
+		if len(contents[dir]) == 0 {
+			fmt.Println("(empty directory)")
+			continue
+		}
+		for _, entry := range contents[dir] {
+			fmt.Println(formatListEntry(&entry, human))
+		}
+	}
+
+	return nil
+}
+
+// formatListEntry formats a resource the way ls shows it, with human-readable
+// file sizes when human is set
+func formatListEntry(entry *resourceEntry, human bool) string {
+	switch entry.Type {
+	case schema.ResourceTypeDirectory:
+		return entry.Name + "/"
+	case schema.ResourceTypeFile:
+		return fmt.Sprintf("%s (%s)", entry.Name, formatListSize(entry.Metadata.Size, human))
+	case schema.ResourceTypeSymlink:
+		return fmt.Sprintf("%s -> %s", entry.Name, entry.Metadata.SymlinkTarget)
+	default:
+		return fmt.Sprintf("%s (%s)", entry.Name, entry.Type)
+	}
+}
+
+// formatListSize formats a file size for ls, in bytes or human-readable
+func formatListSize(size int64, human bool) string {
+	if human {
+		return util.FormatByteSize(size)
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,45 @@
+package shell
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+func TestListRecursive(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir -p /tmp/docs/empty")
+	run(t, sh, "echo hi > /tmp/docs/a")
+	run(t, sh, "echo top > /tmp/b")
+
+	want := "/tmp:\nb (4 B)\ndocs/\n\n/tmp/docs:\na (3 B)\nempty/\n\n/tmp/docs/empty:\n(empty directory)\n"
+	if got := run(t, sh, "ls -R /tmp"); got != want {
+		t.Errorf("ls -R = %q, want %q", got, want)
+	}
+	if _, err := runErr(sh, "ls -R /tmp/b"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("ls -R of a file: %v, want ErrNotFound", err)
+	}
+}
+
+func TestListRecursiveAt(t *testing.T) {
+	sh := newTestShell(t)
+	at := pastState(t, sh)
+	run(t, sh, "mkdir /tmp/later")
+
+	got := run(t, sh, "ls -R --at "+at+" /tmp")
+	if got != "/tmp:\n(empty directory)\n" {
+		t.Errorf("ls -R --at = %q", got)
+	}
+}
+
+func TestListOrder(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /tmp/a")
+	run(t, sh, "echo x > /tmp/b")
+	run(t, sh, "mkdir /tmp/c")
+
+	if got := run(t, sh, "ls /tmp"); got != "Contents of /tmp:\nb (2 B)\na/\nc/\n" {
+		t.Errorf("ls = %q", got)
+	}
+}
@@ -0,0 +1,62 @@
+package shell
+
+import (
+	"fmt"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// LockResource places an advisory lock on a resource (lock <path>)
+func (s *Shell) LockResource(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("path required")
+	}
+	path, err := s.resolveLocalPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		lock, err := s.fm.LockResource(path, tx)
+		if err != nil {
+			return err
+		}
+		out.Printf("Locked %s by %s\n", lock.Path, lock.LockedBy)
+		return nil
+	})
+}
+
+// UnlockResource releases an advisory lock (unlock [--force] <path>).
+// Only administrators may release another user's lock with --force.
+func (s *Shell) UnlockResource(args []string) error {
+	flags, args, err := splitFlags("unlock", args, "--force", "-f")
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("path required")
+	}
+	path, err := s.resolveLocalPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		force := flags["--force"] || flags["-f"]
+		if force {
+			admin, err := s.isAdmin(tx)
+			if err != nil {
+				return err
+			}
+			if !admin {
+				return fmt.Errorf("%w: only administrators can force-unlock", database.ErrPermissionDenied)
+			}
+		}
+
+		if err := s.fm.UnlockResource(path, tx, force); err != nil {
+			return err
+		}
+		out.Printf("Unlocked %s\n", path)
+		return nil
+	})
+}
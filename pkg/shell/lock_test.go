@@ -0,0 +1,45 @@
+package shell
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+func TestLockCommands(t *testing.T) {
+	sh := newTestShell(t)
+	addTestUser(t, sh, "alice")
+	run(t, sh, "echo draft > /tmp/notes")
+
+	if output := run(t, sh, "lock /tmp/notes"); output != "Locked /tmp/notes by system\n" {
+		t.Errorf("lock output = %q", output)
+	}
+
+	sh.state.User = "alice"
+	if _, err := runErr(sh, "echo mine > /tmp/notes"); !errors.Is(err, database.ErrPermissionDenied) {
+		t.Errorf("writing a file locked by another user: %v, want ErrPermissionDenied", err)
+	}
+	if _, err := runErr(sh, "unlock /tmp/notes"); !errors.Is(err, database.ErrPermissionDenied) {
+		t.Errorf("unlocking another user's lock: %v, want ErrPermissionDenied", err)
+	}
+	if _, err := runErr(sh, "unlock --force /tmp/notes"); err == nil || !strings.Contains(err.Error(), "only administrators") {
+		t.Errorf("force unlock by a non-administrator: %v", err)
+	}
+
+	sh.state.User = "system"
+	run(t, sh, "unlock /tmp/notes")
+	if _, err := runErr(sh, "unlock /tmp/notes"); err == nil {
+		t.Error("unlocking an unlocked file succeeded")
+	}
+
+	sh.state.User = "alice"
+	run(t, sh, "lock /tmp/notes")
+	sh.state.User = "system"
+	run(t, sh, "unlock -f /tmp/notes")
+	run(t, sh, "echo mine > /tmp/notes")
+	if got := readFile(t, sh, "/tmp/notes"); got != "mine\n" {
+		t.Errorf("content after a forced unlock = %q", got)
+	}
+}
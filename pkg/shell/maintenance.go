@@ -0,0 +1,23 @@
+package shell
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// Reindex rebuilds the database indexes and refreshes planner statistics
+func (s *Shell) Reindex(args []string) error {
+	if s.CurrentTransaction() != nil {
+		return fmt.Errorf("cannot run maintenance while a transaction is in progress")
+	}
+
+	start := time.Now()
+	if err := schema.Reindex(s.db); err != nil {
+		return err
+	}
+
+	fmt.Printf("Reindexed and analyzed database in %s\n", time.Since(start).Round(time.Millisecond))
+	return nil
+}
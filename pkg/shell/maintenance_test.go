@@ -0,0 +1,45 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReindexCommand(t *testing.T) {
+	sh := newTestShell(t)
+	if output := run(t, sh, "reindex"); !strings.HasPrefix(output, "Reindexed and analyzed database in ") {
+		t.Errorf("reindex = %q", output)
+	}
+
+	run(t, sh, "begin")
+	if _, err := runErr(sh, "reindex"); err == nil {
+		t.Error("reindex inside a transaction succeeded")
+	}
+	run(t, sh, "abort")
+}
+
+func TestBloat(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /d")
+	for _, content := range []string{"one", "two", "three"} {
+		run(t, sh, "echo "+content+" > /d/busy")
+	}
+	run(t, sh, "echo quiet > /d/quiet")
+	run(t, sh, "echo elsewhere > /tmp/elsewhere")
+
+	lines := strings.Split(strings.TrimRight(run(t, sh, "bloat /d"), "\n"), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[0], "VERSIONS") {
+		t.Fatalf("bloat /d:\n%s", strings.Join(lines, "\n"))
+	}
+	if fields := strings.Fields(lines[1]); fields[0] != "3" || fields[len(fields)-1] != "/d/busy" {
+		t.Errorf("first entry = %q, want /d/busy with 3 versions", lines[1])
+	}
+	if got := strings.Count(run(t, sh, "bloat /d -n 1"), "\n"); got != 2 {
+		t.Errorf("bloat -n 1 printed %d lines", got)
+	}
+	for _, command := range []string{"bloat -n 0", "bloat -n"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// mountStats counts what a mount imported
+type mountStats struct {
+	dirs, files, symlinks, skipped int
+}
+
+// MountDirectory imports a host directory tree under a path in one
+// transaction (mount <hostdir> <path>). Files keep their content,
+// permissions and modification times, and symlinks keep their targets.
+// Devices, sockets and other special files are skipped with a warning. The
+// mount point is created if it does not exist; nothing under it may.
+func (s *Shell) MountDirectory(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: mount <hostdir> <path>")
+	}
+
+	hostRoot := filepath.Clean(args[0])
+	info, err := os.Stat(hostRoot)
+	if err != nil {
+		return fmt.Errorf("mount: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("mount: %s is not a directory", hostRoot)
+	}
+
+	mountPoint, err := s.resolveLocalPath(args[1])
+	if err != nil {
+		return err
+	}
+
+	var stats mountStats
+	err = s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		stats = mountStats{}
+		created, err := s.mountTree(tx, out, hostRoot, mountPoint, &stats)
+		if err != nil {
+			return err
+		}
+		if len(created) == 0 {
+			return nil
+		}
+		return s.recordOperation(tx, schema.OperationKindCreate, created)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Mounted %s at %s: %d directories, %d files, %d symlinks, %d skipped\n",
+		hostRoot, mountPoint, stats.dirs, stats.files, stats.symlinks, stats.skipped)
+	return nil
+}
+
+// mountTree walks hostRoot and creates the corresponding resources under
+// mountPoint, returning the IDs of the created resources. Skipped special
+// files are reported to out.
+func (s *Shell) mountTree(tx *database.Transaction, out *txOutput, hostRoot, mountPoint string, stats *mountStats) ([]string, error) {
+	var created []string
+
+	// dirIDs maps each directory path inside DBOS to its resource ID
+	dirIDs := make(map[string]string)
+
+	err := filepath.WalkDir(hostRoot, func(hostPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(hostRoot, hostPath)
+		if err != nil {
+			return err
+		}
+		path := pathpkg.Join(mountPoint, filepath.ToSlash(rel))
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if hostPath == hostRoot {
+			if existing, err := s.lookupResource(tx, path); err == nil {
+				if existing.Type != schema.ResourceTypeDirectory {
+					return fmt.Errorf("mount point is not a directory: %s", path)
+				}
+				dirIDs[path] = existing.ID
+				return nil
+			}
+			parent, err := s.lookupResource(tx, pathpkg.Dir(path))
+			if err != nil || parent.Type != schema.ResourceTypeDirectory {
+				return fmt.Errorf("parent directory %w: %s", database.ErrNotFound, pathpkg.Dir(path))
+			}
+			dirIDs[pathpkg.Dir(path)] = parent.ID
+		} else if _, err := s.lookupResource(tx, path); err == nil {
+			return fmt.Errorf("resource %w: %s", database.ErrAlreadyExists, path)
+		}
+
+		parentID := dirIDs[pathpkg.Dir(path)]
+		metadata := schema.NewResourceMetadata(s.state.User, uint32(info.Mode().Perm()))
+		metadata.ModifiedAt = info.ModTime()
+		metadata.AccessedAt = info.ModTime()
+
+		switch {
+		case d.IsDir():
+			metadata.IsExecutable = false
+			id, err := insertResource(tx, schema.ResourceTypeDirectory, parentID, path, metadata)
+			if err != nil {
+				return err
+			}
+			dirIDs[path] = id
+			created = append(created, id)
+			stats.dirs++
+
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(hostPath)
+			if err != nil {
+				return err
+			}
+			metadata.SymlinkTarget = target
+			id, err := insertResource(tx, schema.ResourceTypeSymlink, parentID, path, metadata)
+			if err != nil {
+				return err
+			}
+			created = append(created, id)
+			stats.symlinks++
+
+		case d.Type().IsRegular():
+			content, err := os.ReadFile(hostPath)
+			if err != nil {
+				return err
+			}
+			metadata.MimeType = mimeTypeForName(path)
+			file, err := s.fm.CreateFileWithMetadata(path, content, metadata, tx)
+			if err != nil {
+				return err
+			}
+			created = append(created, file.ID)
+			stats.files++
+
+		default:
+			out.Warnf("mount: skipping special file %s\n", hostPath)
+			stats.skipped++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mount: %w", err)
+	}
+
+	return created, nil
+}
+
+// insertResource inserts a resource without content, such as a directory or
+// symlink, under parentID on the branch of tx
+func insertResource(tx *database.Transaction, resourceType, parentID, path string, metadata schema.ResourceMetadata) (string, error) {
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	id, err := newResourceID(tx, resourceType)
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Execute(`
+		INSERT INTO resources (id, type, name, parent_id, path, metadata, valid_from, transaction_id, branch_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, id, resourceType, pathpkg.Base(path), parentID, path, string(metadataJSON), time.Now(), tx.GetID(), tx.GetBranchID())
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	return id, nil
+}
@@ -0,0 +1,106 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMountDirectory(t *testing.T) {
+	host := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(host, "sub"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(host, "sub", "run.sh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(host, "sub", "run.sh"), modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/run.sh", filepath.Join(host, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	sh := newTestShell(t)
+	output := run(t, sh, "mount "+host+" /mnt")
+	if !strings.HasSuffix(output, " at /mnt: 2 directories, 1 files, 1 symlinks, 0 skipped\n") {
+		t.Errorf("mount = %q", output)
+	}
+	if got := readFile(t, sh, "/mnt/sub/run.sh"); got != "#!/bin/sh\n" {
+		t.Errorf("mounted content = %q", got)
+	}
+	metadata := metadataOf(t, sh, "/mnt/sub/run.sh")
+	if metadata.Permissions != 0755 || !metadata.ModifiedAt.Equal(modified) {
+		t.Errorf("mounted file metadata = %04o modified %s", metadata.Permissions, metadata.ModifiedAt)
+	}
+	if got := metadataOf(t, sh, "/mnt/sub").Permissions; got != 0750 {
+		t.Errorf("mounted directory permissions = %04o", got)
+	}
+	if got := metadataOf(t, sh, "/mnt/link").SymlinkTarget; got != "sub/run.sh" {
+		t.Errorf("mounted symlink target = %q", got)
+	}
+
+	// Nothing under the mount point may exist yet
+	if _, err := runErr(sh, "mount "+host+" /mnt"); err == nil {
+		t.Error("mounting over existing resources succeeded")
+	}
+	for _, command := range []string{"mount " + host, "mount " + filepath.Join(host, "missing") + " /x", "mount " + filepath.Join(host, "link") + " /x", "mount " + host + " /missing/x"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
+
+func TestExportDirectory(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir -p /src/sub")
+	run(t, sh, "echo hello > /src/sub/f")
+	run(t, sh, "chmod 600 /src/sub/f")
+	createSymlink(t, sh, "/src/link", "sub/f")
+
+	host := filepath.Join(t.TempDir(), "out")
+	output := run(t, sh, "export-dir /src "+host)
+	if output != "Exported /src to "+host+": 2 directories, 1 files, 1 symlinks\n" {
+		t.Errorf("export-dir = %q", output)
+	}
+	content, err := os.ReadFile(filepath.Join(host, "sub", "f"))
+	if err != nil || string(content) != "hello\n" {
+		t.Errorf("exported content = %q, %v", content, err)
+	}
+	if info, err := os.Stat(filepath.Join(host, "sub", "f")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("exported file mode = %v, %v", info.Mode(), err)
+	}
+	if target, err := os.Readlink(filepath.Join(host, "link")); err != nil || target != "sub/f" {
+		t.Errorf("exported symlink = %q, %v", target, err)
+	}
+
+	if _, err := runErr(sh, "export-dir /src "+host); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("export-dir over existing files: %v", err)
+	}
+	run(t, sh, "echo changed > /src/sub/f")
+	run(t, sh, "export-dir --force /src "+host)
+	if content, _ := os.ReadFile(filepath.Join(host, "sub", "f")); string(content) != "changed\n" {
+		t.Errorf("content after export-dir --force = %q", content)
+	}
+}
+
+func TestMountExportRoundTrip(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir -p /src/a/b")
+	run(t, sh, "echo one > /src/a/one")
+	run(t, sh, "echo two > /src/a/b/two")
+
+	host := filepath.Join(t.TempDir(), "tree")
+	run(t, sh, "export-dir /src "+host)
+	run(t, sh, "mount "+host+" /copy")
+
+	if got := readFile(t, sh, "/copy/a/b/two"); got != "two\n" {
+		t.Errorf("round-tripped content = %q", got)
+	}
+	if got, want := metadataOf(t, sh, "/copy/a/one").Checksum, metadataOf(t, sh, "/src/a/one").Checksum; got != want {
+		t.Errorf("round-tripped checksum = %s, want %s", got, want)
+	}
+}
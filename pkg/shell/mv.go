@@ -0,0 +1,43 @@
+package shell
+
+import (
+	"fmt"
+	pathpkg "path"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// MoveResource renames a file or directory, or moves it into an existing
+// directory under its own name (mv <source> <dest>). A directory is moved
+// with everything below it. An existing destination is not overwritten.
+func (s *Shell) MoveResource(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: mv <source> <dest>")
+	}
+	source, err := s.resolveLocalPath(args[0])
+	if err != nil {
+		return err
+	}
+	dest, err := s.resolveLocalPath(args[1])
+	if err != nil {
+		return err
+	}
+
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		target := dest
+		if existing, err := s.lookupResource(tx, dest); err == nil && existing.Type == schema.ResourceTypeDirectory {
+			target = pathpkg.Join(dest, pathpkg.Base(source))
+		}
+
+		moved, err := s.fm.Move(source, target, tx)
+		if err != nil {
+			return err
+		}
+		if err := s.recordOperation(tx, schema.OperationKindMove, []string{moved.ID}); err != nil {
+			return err
+		}
+		out.Printf("Moved %s to %s\n", source, moved.Path)
+		return nil
+	})
+}
@@ -0,0 +1,44 @@
+package shell
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+func TestMove(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir -p /tmp/src/sub")
+	run(t, sh, "echo a > /tmp/src/sub/a")
+	run(t, sh, "mkdir /tmp/dest")
+	run(t, sh, "echo b > /tmp/b")
+
+	if output := run(t, sh, "mv /tmp/src /tmp/dest"); output != "Moved /tmp/src to /tmp/dest/src\n" {
+		t.Errorf("mv into a directory = %q", output)
+	}
+	if got := readFile(t, sh, "/tmp/dest/src/sub/a"); got != "a\n" {
+		t.Errorf("moved content = %q", got)
+	}
+	if output := run(t, sh, "ls /tmp"); strings.Contains(output, "src/") {
+		t.Errorf("source still listed:\n%s", output)
+	}
+
+	run(t, sh, "cd /tmp")
+	run(t, sh, "mv b renamed")
+	if got := readFile(t, sh, "/tmp/renamed"); got != "b\n" {
+		t.Errorf("renamed content = %q", got)
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM operations WHERE kind = 'move'`); n != 2 {
+		t.Errorf("%d move operations recorded, want 2", n)
+	}
+
+	run(t, sh, "touch /tmp/other")
+	if _, err := runErr(sh, "mv /tmp/renamed /tmp/other"); !errors.Is(err, database.ErrAlreadyExists) {
+		t.Errorf("mv over a file: %v, want ErrAlreadyExists", err)
+	}
+	if _, err := runErr(sh, "mv /tmp/dest /tmp/dest/src/inner"); err == nil {
+		t.Error("moving a directory into itself succeeded")
+	}
+}
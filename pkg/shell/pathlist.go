@@ -0,0 +1,52 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ListPaths prints the path of every resource, one per line in path order,
+// for feeding to fuzzy finders and other tools (paths [--at <time>]
+// [prefix]). With a prefix only paths starting with it are printed; a
+// relative prefix is taken from the current directory, and a trailing slash
+// limits the list to what is inside a directory. A prefix qualified with a
+// branch lists that branch, and paths are printed qualified. Paths are
+// printed as they are read, and Ctrl-C stops the output.
+func (s *Shell) ListPaths(args []string) error {
+	args, at, err := s.takeAtFlag(args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("usage: paths [--at <time>] [prefix]")
+	}
+
+	p := branchPath{Branch: s.state.CurrentBranch}
+	var prefix string
+	if len(args) == 1 {
+		p = s.parseBranchPath(args[0])
+		prefix = p.Path
+		if strings.HasSuffix(args[0], "/") && prefix != "/" {
+			prefix += "/"
+		}
+	}
+
+	options, err := s.readOptions(p, at)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	return s.fm.AllPaths(prefix, s.CurrentTransaction(), options, func(path string) error {
+		if s.ctx.Err() != nil {
+			return ErrInterrupted
+		}
+		p.Path = path
+		_, err := fmt.Fprintln(out, p)
+		return err
+	})
+}
@@ -0,0 +1,114 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListPaths(t *testing.T) {
+	sh := newTestShell(t)
+	at := pastState(t, sh)
+	run(t, sh, "mkdir /docs")
+	run(t, sh, "touch /docs/a")
+	run(t, sh, "touch /docsx")
+
+	output := run(t, sh, "paths")
+	for _, want := range []string{"/\n", "/docs\n", "/docs/a\n", "/new.txt\n", "/tmp\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("paths lacks %q", want)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i-1] >= lines[i] {
+			t.Errorf("paths out of order: %q before %q", lines[i-1], lines[i])
+		}
+	}
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"paths /docs", "/docs\n/docs/a\n/docsx\n"},
+		{"paths /docs/", "/docs/a\n"},
+		{"paths --at " + at + " /old", "/old.txt\n"},
+		{"paths main:/docs/", "main:/docs/a\n"},
+	}
+	for _, tt := range tests {
+		if got := run(t, sh, tt.command); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+
+	run(t, sh, "cd /docs")
+	if got := run(t, sh, "paths a"); got != "/docs/a\n" {
+		t.Errorf("paths with a relative prefix = %q", got)
+	}
+	for _, command := range []string{"paths /a /b", "paths missing:/x", "paths --at"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	sh := newTestShell(t)
+	sh.state.CurrentDirectory = "/home/alice"
+
+	tests := []struct {
+		in, want string
+	}{
+		{"docs", "/home/alice/docs"},
+		{"../bob/./x", "/home/bob/x"},
+		{"/tmp//a/", "/tmp/a"},
+		{"../../..", "/"},
+		{".", "/home/alice"},
+		{`dir\name`, `/home/alice/dir\name`},
+	}
+	for _, tt := range tests {
+		if got := sh.resolvePath(tt.in); got != tt.want {
+			t.Errorf("resolvePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParentDirectoryPaths(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir -p /a/b")
+	run(t, sh, "cd /a/b")
+	run(t, sh, "echo up > ../f")
+	if got := readFile(t, sh, "/a/f"); got != "up\n" {
+		t.Errorf("content written through .. = %q", got)
+	}
+	run(t, sh, "cd ../..")
+	if sh.state.CurrentDirectory != "/" {
+		t.Errorf("cd ../.. left the shell in %s", sh.state.CurrentDirectory)
+	}
+	if got := run(t, sh, "cat a/b/../f"); got != "up\n" {
+		t.Errorf("cat through .. = %q", got)
+	}
+}
+
+func TestParseBranchPath(t *testing.T) {
+	sh := newTestShell(t)
+	sh.state.CurrentDirectory = "/tmp"
+
+	tests := []struct {
+		in   string
+		want branchPath
+	}{
+		{"feature:/x", branchPath{Branch: "feature", Path: "/x", Qualified: true}},
+		{"feature:/x/../y", branchPath{Branch: "feature", Path: "/y", Qualified: true}},
+		{"feature:x", branchPath{Branch: "main", Path: "/tmp/feature:x"}},
+		{"bad name:/x", branchPath{Branch: "main", Path: "/tmp/bad name:/x"}},
+		{"/a:b", branchPath{Branch: "main", Path: "/a:b"}},
+	}
+	for _, tt := range tests {
+		if got := sh.parseBranchPath(tt.in); got != tt.want {
+			t.Errorf("parseBranchPath(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+	if _, err := sh.resolveLocalPath("feature:/x"); err == nil {
+		t.Error("resolveLocalPath accepted another branch")
+	}
+}
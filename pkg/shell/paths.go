@@ -0,0 +1,146 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// branchPath is a path argument, optionally qualified with the branch it
+// refers to, as in feature:/home/x
+type branchPath struct {
+	Branch    string // the qualifying branch, or the current branch
+	Path      string // clean absolute path
+	Qualified bool   // whether the argument named a branch
+}
+
+// parseBranchPath splits an optional "branch:" qualifier from a path
+// argument. Only a valid branch name followed by an absolute path is taken as
+// a qualifier; anything else is an ordinary path on the current branch.
+func (s *Shell) parseBranchPath(arg string) branchPath {
+	if i := strings.Index(arg, ":"); i > 0 {
+		branch, path := arg[:i], arg[i+1:]
+		if branchNamePattern.MatchString(branch) && strings.HasPrefix(path, "/") {
+			return branchPath{Branch: branch, Path: s.resolvePath(path), Qualified: true}
+		}
+	}
+	return branchPath{Branch: s.state.CurrentBranch, Path: s.resolvePath(arg)}
+}
+
+// String formats the path with its qualifier, if it had one
+func (p branchPath) String() string {
+	if p.Qualified {
+		return p.Branch + ":" + p.Path
+	}
+	return p.Path
+}
+
+// readOptions returns the query options for reading a path argument on its
+// branch at the given point in time. A qualified branch must exist.
+func (s *Shell) readOptions(p branchPath, at *time.Time) (database.QueryOptions, error) {
+	if p.Branch != s.state.CurrentBranch {
+		exists, err := s.branchExists(s.queries(), p.Branch)
+		if err != nil {
+			return database.QueryOptions{}, err
+		}
+		if !exists {
+			return database.QueryOptions{}, fmt.Errorf("branch %w: %s", database.ErrNotFound, p.Branch)
+		}
+	}
+
+	return branchOptions(p.Branch, at)
+}
+
+// branchOptions returns the query options for reading a branch as of a point
+// in time, or its current state when at is nil
+func branchOptions(branch string, at *time.Time) (database.QueryOptions, error) {
+	builder := database.NewQueryOptions().WithBranch(branch)
+	if at != nil {
+		builder = builder.AsOf(*at)
+	}
+	return builder.Build()
+}
+
+// resolveLocalPath resolves a path argument for a command that changes the
+// tree or the shell's position in it. Changes only reach another branch
+// through cherry-pick, so a qualifier naming another branch is refused.
+func (s *Shell) resolveLocalPath(arg string) (string, error) {
+	p := s.parseBranchPath(arg)
+	if p.Branch != s.state.CurrentBranch {
+		return "", fmt.Errorf("%s is on branch %s, not the current branch %s; switch branches or use cherry-pick", p, p.Branch, s.state.CurrentBranch)
+	}
+	return p.Path, nil
+}
+
+// takeAtFlag removes a "--at <time>" option from args. It returns the
+// remaining arguments and the point in time a read command should use: the
+// option's time for this command only, or else the shell's point in time.
+func (s *Shell) takeAtFlag(args []string) ([]string, *time.Time, error) {
+	at := s.PointInTime()
+	rest := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--at" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, nil, fmt.Errorf("--at requires a time")
+		}
+		t, err := util.ParseTimeSpec(args[i+1])
+		if err != nil {
+			return nil, nil, err
+		}
+		at = &t
+		i++
+	}
+
+	return rest, at, nil
+}
+
+// statDirectory returns the directory at path on the current branch as of
+// at, reading in tx when it is open. The error wraps database.ErrNotFound
+// when there is no directory at path.
+func (s *Shell) statDirectory(tx *database.Transaction, path string, at *time.Time) (*schema.Resource, error) {
+	options, err := branchOptions(s.state.CurrentBranch, at)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := s.fm.Stat(path, tx, options)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return nil, err
+	}
+	if resource == nil || resource.Type != schema.ResourceTypeDirectory {
+		return nil, fmt.Errorf("directory %w: %s", database.ErrNotFound, path)
+	}
+	return resource, nil
+}
+
+// statParent returns the directory a resource is to be created in, or an
+// error naming it as the missing parent directory
+func (s *Shell) statParent(tx *database.Transaction, path string) (*schema.Resource, error) {
+	parent, err := s.statDirectory(tx, path, nil)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, fmt.Errorf("parent directory %w: %s", database.ErrNotFound, path)
+	}
+	return parent, err
+}
+
+// statCurrent returns the current resource at path on the current branch,
+// or nil if there is none
+func (s *Shell) statCurrent(tx *database.Transaction, path string) (*schema.Resource, error) {
+	options, err := branchOptions(s.state.CurrentBranch, nil)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := s.fm.Stat(path, tx, options)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
+	return resource, err
+}
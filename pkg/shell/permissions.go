@@ -0,0 +1,393 @@
+package shell
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	pathpkg "path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// queryExecutor is implemented by both database.Connection and database.Transaction
+type queryExecutor interface {
+	ExecuteQuery(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queries returns what the shell reads through: the current transaction, if
+// any, so that reads see its changes, or else the connection. An in-memory
+// database has a single connection, which an open transaction holds.
+func (s *Shell) queries() queryExecutor {
+	if tx := s.CurrentTransaction(); tx != nil {
+		return tx
+	}
+	return s.db
+}
+
+// execute runs a statement in the current transaction, if any, or else on
+// the connection
+func (s *Shell) execute(statement string, args ...interface{}) (sql.Result, error) {
+	if tx := s.CurrentTransaction(); tx != nil {
+		return tx.Execute(statement, args...)
+	}
+	return s.db.ExecuteStatement(statement, args...)
+}
+
+// resourceEntry is the current version of a resource as seen by mutating commands
+type resourceEntry struct {
+	ID       string
+	Type     string
+	Name     string
+	Path     string
+	Metadata schema.ResourceMetadata
+	RealPath string // Set by walks: where the resource is when Path goes through a followed symlink
+}
+
+// ChangeMode changes the permissions of a resource (chmod [-R] [--dry-run]
+// <mode> <path>)
+func (s *Shell) ChangeMode(args []string) error {
+	flags, args, err := splitFlags("chmod", args, "-R", "--force", "--dry-run")
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("usage: chmod [-R] [--force] [--dry-run] <mode> <path>")
+	}
+
+	mode, err := strconv.ParseUint(args[0], 8, 32)
+	if err != nil || mode > 0777 {
+		return fmt.Errorf("invalid mode: %s", args[0])
+	}
+
+	path, err := s.resolveLocalPath(args[1])
+	if err != nil {
+		return err
+	}
+
+	return s.changePermissions("chmod", path, flags, func(metadata *schema.ResourceMetadata) {
+		metadata.Permissions = uint32(mode)
+		metadata.IsExecutable = mode&0111 != 0
+	}, func(metadata schema.ResourceMetadata) string {
+		return fmt.Sprintf("%04o", metadata.Permissions)
+	})
+}
+
+// ChangeOwner changes the owner of a resource (chown [-R] [--dry-run]
+// <owner> <path>)
+func (s *Shell) ChangeOwner(args []string) error {
+	flags, args, err := splitFlags("chown", args, "-R", "--force", "--dry-run")
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("usage: chown [-R] [--force] [--dry-run] <owner> <path>")
+	}
+
+	owner := args[0]
+	if owner == "" {
+		return fmt.Errorf("owner required")
+	}
+
+	path, err := s.resolveLocalPath(args[1])
+	if err != nil {
+		return err
+	}
+
+	return s.changePermissions("chown", path, flags, func(metadata *schema.ResourceMetadata) {
+		metadata.Owner = owner
+	}, func(metadata schema.ResourceMetadata) string {
+		return metadata.Owner
+	})
+}
+
+// changePermissions applies a metadata change to a resource, and to its whole
+// subtree when -R is given. Resources the user is not allowed to modify
+// are skipped and reported instead of aborting the operation. With
+// --dry-run nothing is written; each resource that would change is listed
+// with its old and new value as given by describe.
+func (s *Shell) changePermissions(cmd string, path string, flags map[string]bool, apply func(*schema.ResourceMetadata), describe func(schema.ResourceMetadata) string) error {
+	dryRun := flags["--dry-run"]
+
+	change := func(tx *database.Transaction, out *txOutput) error {
+		root, err := s.lookupResource(tx, path)
+		if err != nil {
+			return err
+		}
+
+		targets := []resourceEntry{*root}
+		if flags["-R"] || flags["-r"] {
+			targets = nil
+			options, err := branchOptions(s.state.CurrentBranch, nil)
+			if err != nil {
+				return err
+			}
+			err = s.walkSubtree(tx, root, options, s.getWalkLimits(flags["--force"]), func(entry *resourceEntry, depth int) error {
+				targets = append(targets, *entry)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		admin, err := s.isAdmin(tx)
+		if err != nil {
+			return err
+		}
+
+		var changed []string
+		var skipped, unchanged int
+		now := time.Now()
+		for _, target := range targets {
+			if !admin && target.Metadata.Owner != s.state.User {
+				out.Printf("%s: skipped %s: permission denied (owner %s)\n", cmd, target.Path, target.Metadata.Owner)
+				skipped++
+				continue
+			}
+
+			if err := s.fm.CheckLock(target.Path, tx); err != nil {
+				out.Printf("%s: skipped %s: %v\n", cmd, target.Path, err)
+				skipped++
+				continue
+			}
+
+			metadata := target.Metadata
+			apply(&metadata)
+			if dryRun {
+				before, after := describe(target.Metadata), describe(metadata)
+				if before == after {
+					unchanged++
+					continue
+				}
+				out.Printf("%s: %s: %s -> %s\n", cmd, target.Path, before, after)
+				changed = append(changed, target.ID)
+				continue
+			}
+			metadata.ModifiedAt = now
+
+			newID, err := writeMetadataVersion(tx, target.ID, target.Type, metadata, now)
+			if err != nil {
+				return fmt.Errorf("failed to update %s: %w", target.Path, err)
+			}
+			changed = append(changed, newID)
+		}
+
+		if dryRun {
+			out.Printf("%s: would update %d resource(s), %d already set, skipped %d (dry run, nothing written)\n", cmd, len(changed), unchanged, skipped)
+			return nil
+		}
+
+		// The command name doubles as the operation kind
+		if len(changed) > 0 {
+			if err := s.recordOperation(tx, cmd, changed); err != nil {
+				return err
+			}
+		}
+
+		out.Printf("%s: updated %d resource(s), skipped %d\n", cmd, len(changed), skipped)
+		return nil
+	}
+
+	if dryRun {
+		return s.withDryRunTransaction(change)
+	}
+	return s.withImplicitTransaction(change)
+}
+
+// SetUmask shows or sets the mask applied to the permissions of new
+// files and directories (umask [mode])
+func (s *Shell) SetUmask(args []string) error {
+	if len(args) == 0 {
+		fmt.Printf("%04o\n", s.state.Umask)
+		return nil
+	}
+
+	mask, err := strconv.ParseUint(args[0], 8, 32)
+	if err != nil || mask > 0777 {
+		return fmt.Errorf("invalid umask: %s", args[0])
+	}
+
+	s.state.Umask = uint32(mask)
+	return nil
+}
+
+// filePermissions returns the permissions for a new file under the umask
+func (s *Shell) filePermissions() uint32 {
+	return schema.DefaultFilePermissions &^ s.state.Umask
+}
+
+// directoryPermissions returns the permissions for a new directory under the umask
+func (s *Shell) directoryPermissions() uint32 {
+	return schema.DefaultDirectoryPermissions &^ s.state.Umask
+}
+
+// lookupResource finds the current version of the resource at path on the
+// current branch, reading in q when it is a transaction
+func (s *Shell) lookupResource(q queryExecutor, path string) (*resourceEntry, error) {
+	tx, _ := q.(*database.Transaction)
+	resource, err := s.statCurrent(tx, path)
+	if err != nil {
+		return nil, err
+	}
+	if resource == nil {
+		return nil, fmt.Errorf("resource %w: %s", database.ErrNotFound, path)
+	}
+	return newResourceEntry(resource)
+}
+
+// isAdmin reports whether the shell user is an active administrator
+func (s *Shell) isAdmin(q queryExecutor) (bool, error) {
+	rows, err := q.ExecuteQuery(`
+		SELECT is_admin FROM users WHERE username = ? AND is_active = ?
+	`, s.state.User, true)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up user: %w", err)
+	}
+	defer rows.Close()
+
+	var admin bool
+	if rows.Next() {
+		if err := rows.Scan(&admin); err != nil {
+			return false, fmt.Errorf("failed to scan user: %w", err)
+		}
+	}
+	return admin, nil
+}
+
+// newResourceEntry builds the entry for a resource, decoding its metadata
+func newResourceEntry(resource *schema.Resource) (*resourceEntry, error) {
+	metadata, err := schema.NormalizeMetadata(resource.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata for %s: %w", resource.Path, err)
+	}
+	return &resourceEntry{
+		ID:       resource.ID,
+		Type:     resource.Type,
+		Name:     resource.Name,
+		Path:     resource.Path,
+		Metadata: metadata,
+	}, nil
+}
+
+// scanResourceEntry scans an (id, type, name, path, metadata) row
+func scanResourceEntry(rows *sql.Rows) (*resourceEntry, error) {
+	var entry resourceEntry
+	var metadataStr string
+
+	if err := rows.Scan(&entry.ID, &entry.Type, &entry.Name, &entry.Path, &metadataStr); err != nil {
+		return nil, fmt.Errorf("failed to scan resource: %w", err)
+	}
+	metadata, err := schema.NormalizeMetadata(json.RawMessage(metadataStr))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata for %s: %w", entry.Path, err)
+	}
+	entry.Metadata = metadata
+	return &entry, nil
+}
+
+// writeMetadataVersion closes the current version of a resource and inserts a
+// new version carrying the same content with the given metadata, returning
+// the new version's ID
+func writeMetadataVersion(tx *database.Transaction, id, resourceType string, metadata schema.ResourceMetadata, now time.Time) (string, error) {
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	_, err = tx.Execute(`UPDATE resources SET valid_to = ? WHERE id = ? AND valid_to IS NULL`, now, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to close old version: %w", err)
+	}
+
+	newID, err := newResourceID(tx, resourceType)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Execute(`
+		INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id, branch_id)
+		SELECT ?, type, name, parent_id, path, content, ?, ?, ?, branch_id
+		FROM resources WHERE id = ?
+	`, newID, string(metadataJSON), now, tx.GetID(), id)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert new version: %w", err)
+	}
+
+	return newID, nil
+}
+
+// updateMetadataInPlace replaces the metadata of the current version of a
+// resource without writing a new version. It is only for changes that are
+// not kept as history, such as access times.
+func updateMetadataInPlace(tx *database.Transaction, id string, metadata schema.ResourceMetadata) error {
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	_, err = tx.Execute(`UPDATE resources SET metadata = ? WHERE id = ? AND valid_to IS NULL`, string(metadataJSON), id)
+	if err != nil {
+		return fmt.Errorf("failed to update metadata: %w", err)
+	}
+	return nil
+}
+
+// versionTransaction returns the ID of the transaction that wrote a
+// resource version
+func versionTransaction(q queryExecutor, id string) (string, error) {
+	rows, err := q.ExecuteQuery(`SELECT transaction_id FROM resources WHERE id = ?`, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up version %s: %w", id, err)
+	}
+	defer rows.Close()
+
+	var txID string
+	if rows.Next() {
+		if err := rows.Scan(&txID); err != nil {
+			return "", fmt.Errorf("failed to scan version %s: %w", id, err)
+		}
+	}
+	return txID, rows.Err()
+}
+
+// newResourceID generates the ID for a new resource version under the ID
+// scheme of the transaction's connection
+func newResourceID(tx *database.Transaction, resourceType string) (string, error) {
+	prefix := "file"
+	if resourceType == schema.ResourceTypeDirectory {
+		prefix = "dir"
+	}
+	return tx.NextID(prefix)
+}
+
+// splitFlags separates leading-dash flags from positional arguments. A flag
+// that is not among known is rejected, so that a mistyped flag such as
+// --forc is reported instead of ignored.
+func splitFlags(cmd string, args []string, known ...string) (map[string]bool, []string, error) {
+	flags := make(map[string]bool)
+	var rest []string
+	for _, arg := range args {
+		if len(arg) > 1 && strings.HasPrefix(arg, "-") {
+			if !slices.Contains(known, arg) {
+				return nil, nil, fmt.Errorf("unknown %s option: %s", cmd, arg)
+			}
+			flags[arg] = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	return flags, rest, nil
+}
+
+// resolvePath turns a possibly relative path into a clean absolute path
+func (s *Shell) resolvePath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = pathpkg.Join(s.state.CurrentDirectory, path)
+	}
+	return pathpkg.Clean(path)
+}
@@ -0,0 +1,163 @@
+package shell
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitFlags(t *testing.T) {
+	flags, rest, err := splitFlags("cp", []string{"-p", "a", "-", "b"}, "-p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !flags["-p"] || len(flags) != 1 {
+		t.Errorf("flags = %v, want -p", flags)
+	}
+	if want := []string{"a", "-", "b"}; !reflect.DeepEqual(rest, want) {
+		t.Errorf("rest = %q, want %q", rest, want)
+	}
+
+	_, _, err = splitFlags("cp", []string{"--forc", "a"}, "-p", "--force")
+	if err == nil || !strings.Contains(err.Error(), "--forc") {
+		t.Errorf("unknown flag: got %v", err)
+	}
+}
+
+// TestMistypedFlagIsRejected reports a mistyped flag instead of running the
+// command without it
+func TestMistypedFlagIsRejected(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /tmp/docs")
+	run(t, sh, "touch /tmp/docs/a")
+
+	if _, err := runErr(sh, "rm -r --yse /tmp/docs"); err == nil ||
+		!strings.Contains(err.Error(), "unknown rm option: --yse") {
+		t.Errorf("rm with mistyped flag: got %v", err)
+	}
+	if output := run(t, sh, "ls /tmp/docs"); !strings.Contains(output, "a") {
+		t.Errorf("rm with mistyped flag removed files:\n%s", output)
+	}
+
+	for _, command := range []string{"ls --al /", "cat --forc /tmp/docs/a", "chmod --dryrun 600 /tmp/docs/a", "fsck --repiar"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
+
+func TestChangeMode(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /tmp/docs")
+	run(t, sh, "touch /tmp/docs/a")
+	run(t, sh, "touch /tmp/docs/b")
+
+	run(t, sh, "chmod 700 /tmp/docs/a")
+	if m := metadataOf(t, sh, "/tmp/docs/a"); m.Permissions != 0700 || !m.IsExecutable {
+		t.Errorf("after chmod 700: permissions %04o executable %v", m.Permissions, m.IsExecutable)
+	}
+	if m := metadataOf(t, sh, "/tmp/docs/b"); m.Permissions != 0644 {
+		t.Errorf("chmod without -R changed a sibling: %04o", m.Permissions)
+	}
+
+	output := run(t, sh, "chmod -R 640 /tmp/docs")
+	if !strings.Contains(output, "updated 3 resource(s)") {
+		t.Errorf("chmod -R output:\n%s", output)
+	}
+	for _, path := range []string{"/tmp/docs", "/tmp/docs/a", "/tmp/docs/b"} {
+		if m := metadataOf(t, sh, path); m.Permissions != 0640 || m.IsExecutable {
+			t.Errorf("%s after chmod -R 640: %04o executable %v", path, m.Permissions, m.IsExecutable)
+		}
+	}
+
+	for _, mode := range []string{"999", "1777", "rw"} {
+		if _, err := runErr(sh, "chmod "+mode+" /tmp/docs/a"); err == nil {
+			t.Errorf("chmod %s succeeded", mode)
+		}
+	}
+}
+
+func TestChangeModeDryRun(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /tmp/docs")
+	run(t, sh, "touch /tmp/docs/a")
+	run(t, sh, "chmod 600 /tmp/docs/a")
+
+	output := run(t, sh, "chmod -R --dry-run 600 /tmp/docs")
+	if !strings.Contains(output, "/tmp/docs: 0755 -> 0600") || strings.Contains(output, "/tmp/docs/a:") {
+		t.Errorf("dry run lists the wrong changes:\n%s", output)
+	}
+	if !strings.Contains(output, "would update 1 resource(s), 1 already set") {
+		t.Errorf("dry run summary:\n%s", output)
+	}
+	if m := metadataOf(t, sh, "/tmp/docs"); m.Permissions != 0755 {
+		t.Errorf("dry run changed permissions to %04o", m.Permissions)
+	}
+}
+
+func TestChangeOwner(t *testing.T) {
+	sh := newTestShell(t)
+	addTestUser(t, sh, "alice")
+	run(t, sh, "mkdir /tmp/docs")
+	run(t, sh, "touch /tmp/docs/a")
+	run(t, sh, "touch /tmp/docs/b")
+	run(t, sh, "chown alice /tmp/docs/a")
+	if m := metadataOf(t, sh, "/tmp/docs/a"); m.Owner != "alice" {
+		t.Errorf("owner = %s, want alice", m.Owner)
+	}
+
+	// A user who is not an administrator only changes what they own
+	sh.state.User = "alice"
+	output := run(t, sh, "chmod -R 600 /tmp/docs")
+	if !strings.Contains(output, "skipped /tmp/docs/b: permission denied") || !strings.Contains(output, "updated 1 resource(s), skipped 2") {
+		t.Errorf("chmod -R by a non-owner:\n%s", output)
+	}
+	if m := metadataOf(t, sh, "/tmp/docs/a"); m.Permissions != 0600 {
+		t.Errorf("owned file permissions = %04o, want 0600", m.Permissions)
+	}
+	if m := metadataOf(t, sh, "/tmp/docs/b"); m.Permissions != 0644 {
+		t.Errorf("unowned file permissions = %04o, want 0644", m.Permissions)
+	}
+}
+
+func TestChangeModeSkipsLocked(t *testing.T) {
+	sh := newTestShell(t)
+	addTestUser(t, sh, "alice")
+	run(t, sh, "touch /tmp/a")
+	run(t, sh, "chown alice /tmp/a")
+	run(t, sh, "lock /tmp/a")
+
+	// Owning a file does not override another user's lock on it
+	sh.state.User = "alice"
+	output := run(t, sh, "chmod 600 /tmp/a")
+	if !strings.Contains(output, "skipped /tmp/a") || !strings.Contains(output, "locked by system") {
+		t.Errorf("chmod of a locked file:\n%s", output)
+	}
+	if m := metadataOf(t, sh, "/tmp/a"); m.Permissions != 0644 {
+		t.Errorf("chmod of a locked file changed permissions to %04o", m.Permissions)
+	}
+}
+
+func TestUmask(t *testing.T) {
+	sh := newTestShell(t)
+	if got := run(t, sh, "umask"); got != "0022\n" {
+		t.Errorf("default umask = %q", got)
+	}
+
+	run(t, sh, "umask 077")
+	if got := run(t, sh, "umask"); got != "0077\n" {
+		t.Errorf("umask after setting 077 = %q", got)
+	}
+	run(t, sh, "touch /tmp/private")
+	run(t, sh, "mkdir /tmp/secret")
+	if m := metadataOf(t, sh, "/tmp/private"); m.Permissions != 0600 {
+		t.Errorf("file under umask 077: %04o, want 0600", m.Permissions)
+	}
+	if m := metadataOf(t, sh, "/tmp/secret"); m.Permissions != 0700 {
+		t.Errorf("directory under umask 077: %04o, want 0700", m.Permissions)
+	}
+
+	if _, err := runErr(sh, "umask 1000"); err == nil {
+		t.Error("umask 1000 succeeded")
+	}
+}
@@ -2,33 +2,28 @@ package shell
 
 import (
 	"bufio"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"os/signal"
+	pathpkg "path"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/filesystem"
 	"github.com/brainwavecollective/stone-os/pkg/schema"
 )
 
-// formatSize formats a size in bytes to a human-readable string
-func formatSize(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
-
-// ShellState represents the current state of the shell
+// ShellState represents the current state of the shell. CurrentTransaction
+// and PointInTime are read and set through the Shell methods of the same
+// names, which may be called from other goroutines.
 type ShellState struct {
 	CurrentTransaction *database.Transaction
 	CurrentBranch      string
@@ -37,15 +32,67 @@ type ShellState struct {
 	PointInTime        *time.Time
 	IsInteractive      bool
 	Verbose            bool
+	Aliases            map[string]string
+	Variables          map[string]string
+	NoUnset            bool
+	Umask              uint32
+	MaxWalkNodes       int
+	MaxWalkDepth       int
+	MaxCatBytes        int
+	MaxQueryRows       int
+	Autocommit         bool
+	// TouchVersions makes touch record a modification time change as a new
+	// version; when off, touch only ever updates timestamps in place
+	TouchVersions      bool
+	// Timing prints how long each command took after its output
+	Timing             bool
 }
 
 // Shell represents the interactive shell
 type Shell struct {
 	db        *database.Connection
+	fm        *filesystem.FileManager
 	state     ShellState
+	// mu guards the open transaction, the point in time and the session,
+	// which goroutines other than the command loop read and end. Use the
+	// accessors in state.go rather than the fields.
+	mu        sync.Mutex
 	history   []string
 	running   bool
-	promptFmt string
+	// promptTemplate is the prompt with {name} placeholders
+	promptTemplate string
+
+	// input is where commands, confirmations and content are read from.
+	// Everything reads through the one buffer so no input is lost between
+	// commands.
+	input *bufio.Reader
+
+	// currentCommand is the command being processed, after alias and
+	// variable expansion, as recorded in the audit log
+	currentCommand string
+
+	sessionID     string
+	stopHeartbeat chan struct{}
+
+	// rebase is the rebase waiting on conflict resolution, if any
+	rebase *pendingRebase
+
+	// temp is the temporary resources of the open transaction
+	temp *tempSet
+
+	// commands are the custom commands added with RegisterCommand
+	commands map[string]CommandHandler
+
+	// ctx is cancelled when the running command is interrupted with Ctrl-C
+	ctx context.Context
+
+	// terminate stops Run when a signal arrives on it (see SetTerminate),
+	// and terminated records that one has
+	terminate  <-chan os.Signal
+	terminated bool
+
+	// exitStatus is 1 when the last command Run processed failed
+	exitStatus int
 }
 
 // NewShell creates a new interactive shell
@@ -57,151 +104,184 @@ func NewShell(db *database.Connection) *Shell {
 		CurrentDirectory:   "/",
 		User:               os.Getenv("USER"),
 		PointInTime:        nil,
-		IsInteractive:      true,
+		IsInteractive:      stdinIsTerminal(),
 		Verbose:            true,
+		Aliases:            make(map[string]string),
+		Variables:          make(map[string]string),
+		Umask:              schema.DefaultUmask,
+		MaxWalkNodes:       DefaultMaxWalkNodes,
+		MaxWalkDepth:       DefaultMaxWalkDepth,
+		MaxCatBytes:        DefaultMaxCatBytes,
+		MaxQueryRows:       DefaultMaxQueryRows,
+		Autocommit:         true,
+		TouchVersions:      true,
 	}
 
-	// Default prompt format
-	promptFmt := "[%s] %s@%s:%s%s> "
-
 	return &Shell{
-		db:        db,
-		state:     state,
-		history:   []string{},
-		running:   false,
-		promptFmt: promptFmt,
+		db:             db,
+		fm:             filesystem.NewFileManager(db),
+		state:          state,
+		history:        []string{},
+		running:        false,
+		promptTemplate: DefaultPromptTemplate,
+		input:          bufio.NewReader(os.Stdin),
+		commands:       make(map[string]CommandHandler),
+		ctx:            context.Background(),
 	}
 }
 
+// MaxInputLine is the longest command line Run accepts, so that long
+// pasted queries and content are read in full
+const MaxInputLine = 16 << 20
+
 // Run starts the interactive shell
 func (s *Shell) Run() {
 	s.running = true
 
+	if err := s.StartSession(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	// A transaction left open, as when the shell is terminated, may hold up
+	// removing the session, so Shutdown ends it after rolling back
+	defer func() {
+		if s.CurrentTransaction() == nil {
+			s.EndSession()
+		}
+	}()
+
+	// Ctrl-C interrupts the running command rather than the shell
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
 	for s.running {
 		prompt := s.GetPrompt()
 		fmt.Print(prompt)
 
-		// Read a full line of input including spaces
-		var input string
-		scanner := bufio.NewScanner(os.Stdin)
-		if scanner.Scan() {
-			input = scanner.Text()
-		} else {
-			if err := scanner.Err(); err != nil {
-				fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
-			}
-			continue
+		line, err := s.nextLine()
+		if err == errTerminated {
+			fmt.Println()
+			return
 		}
-
-		input = strings.TrimSpace(input)
-		if input == "" {
+		if err == ErrLineTooLong {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
 			continue
 		}
+		if err != nil && err != io.EOF {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			return
+		}
 
-		// Add to history
-		s.AddToHistory(input)
+		if input := strings.TrimSpace(line); input != "" {
+			// Add to history
+			s.AddToHistory(input)
+
+			// Process command
+			err := s.runInterruptible(interrupts, func() error {
+				return s.ProcessCommand(input)
+			})
+			if s.terminated {
+				fmt.Println()
+				return
+			}
+			s.exitStatus = 0
+			if err != nil {
+				s.exitStatus = 1
+				// A false test is a result, not a failure to report
+				if !errors.Is(err, ErrTestFalse) {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				}
+			}
+		}
 
-		// Process command
-		if err := s.ProcessCommand(input); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if err == io.EOF {
+			fmt.Println()
+			return
 		}
 	}
 }
 
+// ExitStatus returns the exit status for the process once Run returns, as
+// in sh: 1 when the last command failed, including a false test, and 0
+// otherwise
+func (s *Shell) ExitStatus() int {
+	return s.exitStatus
+}
+
 // GetPrompt returns the shell prompt string
 func (s *Shell) GetPrompt() string {
-	txIndicator := ""
-	if s.state.CurrentTransaction != nil {
-		txIndicator = fmt.Sprintf("(T%s)", s.state.CurrentTransaction.GetID()[:8])
-	}
-
-	timeIndicator := ""
-	if s.state.PointInTime != nil {
-		timeIndicator = fmt.Sprintf("@%s", s.state.PointInTime.Format("2006-01-02T15:04:05"))
-	}
-
-	return fmt.Sprintf(
-		s.promptFmt,
-		s.state.CurrentBranch,
-		s.state.User,
-		timeIndicator,
-		s.state.CurrentDirectory,
-		txIndicator,
-	)
+	return s.renderPrompt(s.promptTemplate)
 }
 
 // ProcessCommand processes a command string
 func (s *Shell) ProcessCommand(cmdStr string) error {
-	// Split command and arguments
-	parts := strings.Fields(cmdStr)
-	if len(parts) == 0 {
-		return nil
-	}
-
-	cmd := parts[0]
-	args := parts[1:]
+	// Expand aliases in the command word, then variables. The SQL given to
+	// query is passed through as typed, since $ is part of SQL syntax such
+	// as Postgres placeholders and dollar quoting.
+	cmdStr = s.expandAliases(cmdStr)
 
-	// Handle built-in commands
-	switch cmd {
-	case "exit", "quit":
-		s.running = false
-		return nil
+	if commandName(cmdStr) != "query" {
+		var err error
+		if cmdStr, err = s.expandVariables(cmdStr); err != nil {
+			return err
+		}
+	}
+	s.currentCommand = strings.TrimSpace(cmdStr)
 
-	case "help":
-		s.ShowHelp()
+	// Split command and arguments, removing quotes
+	words, err := splitWords(cmdStr)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
 		return nil
+	}
+	cmd := words[0].text
 
-	case "cd":
-		return s.ChangeDirectory(args)
-
-	case "ls":
-		return s.ListDirectory(args)
-
-	case "mkdir":
-		return s.MakeDirectory(args)
-
-	case "touch":
-		return s.TouchFile(args)
-
-	case "rm":
-		return s.RemoveResource(args)
-
-	case "cat":
-		return s.CatFile(args)
-
-	case "echo":
-		return s.Echo(args)
-
-	case "begin":
-		return s.BeginTransaction()
-
-	case "commit":
-		return s.CommitTransaction()
-
-	case "abort", "rollback":
-		return s.AbortTransaction()
-
-	case "branch":
-		return s.ManageBranch(args)
-
-	case "switch":
-		return s.SwitchBranch(args)
-
-	case "history":
-		return s.ShowHistory(args)
+	// Query reports its own timing, covering only the database's work. A
+	// command that turns timing off is not timed.
+	if s.state.Timing && cmd != "query" {
+		start := time.Now()
+		defer func() {
+			if s.state.Timing {
+				printTiming(time.Since(start))
+			}
+		}()
+	}
 
-	case "state-at":
-		return s.SetPointInTime(args)
+	rest, target, appendMode, err := splitRedirect(words[1:], cmd == "query")
+	if err != nil {
+		return err
+	}
+	args := wordTexts(rest)
+	if target != "" {
+		// Echo writes the file itself so that it can read stdin into it
+		if cmd == "echo" {
+			return s.echo(args, target, appendMode)
+		}
+		return s.runRedirected(cmd, args, target, appendMode)
+	}
 
-	case "now":
-		return s.ResetPointInTime()
+	return s.runCommand(cmd, args)
+}
 
-	case "query":
-		return s.ExecuteQuery(args)
+// Execute runs one command given as words, such as the arguments of the
+// process, without splitting them again or expanding aliases and variables
+// in them. Its output is not redirected.
+func (s *Shell) Execute(words []string) error {
+	if len(words) == 0 {
+		return nil
+	}
+	s.currentCommand = strings.Join(words, " ")
+	return s.runCommand(words[0], words[1:])
+}
 
-	default:
-		return fmt.Errorf("unknown command: %s", cmd)
+// runCommand dispatches a command to its built-in or registered handler
+func (s *Shell) runCommand(cmd string, args []string) error {
+	if handler, ok := builtinCommands[cmd]; ok {
+		return handler(s, args)
 	}
+	return s.runRegisteredCommand(cmd, args)
 }
 
 // AddToHistory adds a command to history
@@ -215,108 +295,144 @@ func (s *Shell) ShowHelp() {
 	fmt.Println("=============")
 	fmt.Println()
 	fmt.Println("File Operations:")
-	fmt.Println("  ls [path]                 List directory contents")
+	fmt.Println("  ls [--all] [-h] [path]    List directory contents (--all: past maxrows entries,")
+	fmt.Println("                            -h: human-readable sizes)")
+	fmt.Println("  ls -R [-L] [path]         List a directory and its subdirectories (-L: follow symlinks)")
+	fmt.Println("                            (ls and cat take --at <time> to read as of a past time)")
 	fmt.Println("  cd [path]                 Change current directory")
-	fmt.Println("  mkdir <dir>               Create a directory")
-	fmt.Println("  touch <file>              Create an empty file")
-	fmt.Println("  rm <resource>             Remove a resource")
-	fmt.Println("  cat <file>                Display file contents")
-	fmt.Println("  echo <text> > <file>      Write text to file")
+	fmt.Println("  mkdir [-p] <dir>          Create a directory (-p: and missing parents)")
+	fmt.Println("  touch [-p] [-a|-m] <file> Create an empty file (-p: and missing parents), or set")
+	fmt.Println("                            an existing one's access (-a) or modification (-m) time")
+	fmt.Println("  mkdir/touch --temp <path> Create under /tmp in the open transaction; removed on commit")
+	fmt.Println("  truncate <file> [--size N] Cut a file to N bytes (default 0), zero-padding to grow")
+	fmt.Println("  cp [-p] <src> <dest>      Copy a file (-p: keep its permissions and timestamps)")
+	fmt.Println("  mv <src> <dest>           Rename or move a file or directory")
+	fmt.Println("  rm [-r] [-f] <path>...    Remove resources (-r: a directory and everything under it,")
+	fmt.Println("                            asking for confirmation unless -f/--yes)")
+	fmt.Println("  cat [-n] <file>...        Display and concatenate file contents (- for stdin)")
+	fmt.Println("                            (--force prints files larger than maxcat)")
+	fmt.Println("  echo <text> >|>> <file>   Write or append text to a file (stdin when no text)")
+	fmt.Println("  write [-a] <file>         Write stdin to a file (interactive: end with \".\")")
+	fmt.Println("                            (echo, write and cp take --type <mime> to set the MIME type)")
+	fmt.Println("  <command> >|>> <file>     Write or append any command's output to a file")
+	fmt.Println("  chmod [-R] <mode> <path>  Change permissions (octal mode)")
+	fmt.Println("  chown [-R] <owner> <path> Change owner")
+	fmt.Println("  chmod/chown --dry-run ... List each change as old -> new without writing")
+	fmt.Println("                            (--force lifts the recursion limits)")
+	fmt.Println("  umask [mode]              Show or set the creation mask (octal)")
+	fmt.Println("  lock <path>               Place an advisory lock on a resource")
+	fmt.Println("  unlock [--force] <path>   Release a lock (--force: admin only)")
+	fmt.Println("  tag <path> <label>...     Attach labels to a resource")
+	fmt.Println("  untag <path> <label>...   Remove labels from a resource")
+	fmt.Println("  tags <path>               List a resource's labels")
+	fmt.Println("  find [path] --tag <label> Find resources with a label")
+	fmt.Println("  find [path] -name <glob>  Find resources by name; add -exec <command {}> to run")
+	fmt.Println("                            a command on each match in one transaction")
+	fmt.Println("                            (find -L follows symlinks, skipping cycles;")
+	fmt.Println("                            find --at <time> searches a past state)")
+	fmt.Println("  paths [--at <time>] [prefix]  List every path, one per line (for fuzzy finders)")
+	fmt.Println("  test -e|-f|-d <path>      Check that a path exists / is a file / is a directory")
+	fmt.Println("  stat [--json] <path>      Show a resource's fields and metadata")
+	fmt.Println("  mount <hostdir> <path>    Import a host directory tree under a path")
+	fmt.Println("  export-dir <path> <hostdir>  Write a subtree to a host directory (--at, --force)")
 	fmt.Println()
 	fmt.Println("Transaction Management:")
-	fmt.Println("  begin                     Start a transaction")
+	fmt.Println("  begin                     Start a transaction (--read-committed, --repeatable-read,")
+	fmt.Println("                            --serializable, --read-only)")
 	fmt.Println("  commit                    Commit current transaction")
 	fmt.Println("  abort, rollback           Abort current transaction")
+	fmt.Println("  tx                        Show the current transaction's status")
+	fmt.Println("  transactions [--branch <b>] [--user <u>] [--status committed|aborted] [-n <n>]")
+	fmt.Println("                            List recorded transactions, most recent first")
+	fmt.Println("  savepoint <name>          Mark a savepoint in the current transaction")
+	fmt.Println("  rollback-to <name>        Undo changes made since a savepoint")
+	fmt.Println("  release <name>            Forget a savepoint, keeping its changes")
 	fmt.Println()
 	fmt.Println("Branching:")
 	fmt.Println("  branch <name>             Create a new branch")
+	fmt.Println("  branch -d [-f] <name>     Abandon a branch (asks for confirmation unless -f/--yes)")
 	fmt.Println("  branch                    List branches")
+	fmt.Println("  branch list [--status <s>] [--created-by <user>]  List branches by status or creator")
+	fmt.Println("  branch graph              Show branches as a tree of where each was forked from")
+	fmt.Println("  branch purge [--force] <name>  Permanently delete an abandoned branch and all its data")
 	fmt.Println("  switch <branch>           Switch to a branch")
+	fmt.Println("  checkout [-b] <branch>    Switch to a branch (-b: create it first)")
+	fmt.Println("  branch rebase <onto>      Replay this branch's changes on top of another branch")
+	fmt.Println("    [--continue [--ours] | --abort]  Resume or cancel a rebase stopped on conflicts")
+	fmt.Println("                            (conflicting text files get <<<<<<< markers to resolve)")
+	fmt.Println("  cherry-pick <branch> <path>  Copy a resource or subtree from a branch")
+	fmt.Println("  <branch>:<path>           Read a path on another branch (e.g. cat feature:/x)")
 	fmt.Println()
 	fmt.Println("Time Travel:")
 	fmt.Println("  state-at <time>           View system at point in time")
 	fmt.Println("  now                       Return to present time")
+	fmt.Println("  diff <path> --branch <a> [--branch <b>]  Compare a path between two branches")
+	fmt.Println("                            (--at <t1> [--at <t2>] compares points in time; renames are detected)")
 	fmt.Println("  history [resource]        Show history of a resource")
+	fmt.Println("  restore <path> --version <n> | --at <time>  Make a past version current again")
+	fmt.Println("  log [--kind <k>] [-n <n>] Show the audit log (create, update, delete, move, chmod, chown)")
+	fmt.Println("  replay <txid> [--onto <b>] Re-run a recorded transaction on this or another branch")
+	fmt.Println("    [--since <t>] [--until <t>]  Restrict to versions created in a window")
 	fmt.Println()
 	fmt.Println("Query:")
 	fmt.Println("  query <sql>               Execute a SQL query")
+	fmt.Println("  query --explain <sql>     Show the query plan")
+	fmt.Println("  query --timing <sql>      Also print how long the database took")
+	fmt.Println("  search --meta <f>=<v> [path]  Find resources by metadata field")
+	fmt.Println("  reindex                   Rebuild indexes and refresh statistics")
+	fmt.Println("  fsck [--repair]           Find orphaned resources (--repair: move to /lost+found)")
+	fmt.Println("  refresh-meta [path]       Recompute file sizes and checksums from their content")
+	fmt.Println("  count [path] [--type f|d] Count resources under a path")
+	fmt.Println("  summary [path]            Show counts by type and total size")
+	fmt.Println("  bloat [path] [-n N]       List resources by version count and stored bytes")
+	fmt.Println("  empty-trash [-f]          Permanently delete the history of removed resources")
+	fmt.Println("  gc [-f]                   Delete stored content no version uses")
 	fmt.Println()
 	fmt.Println("Shell:")
 	fmt.Println("  help                      Show this help")
+	fmt.Println("  sessions                  List shells connected to the database")
+	fmt.Println("  user add [--admin] <name> Add a user, reading the password from input (admin)")
+	fmt.Println("  user passwd [name]        Change your password, or another user's (admin)")
+	fmt.Println("  user disable <name>       Disable a user (admin)")
+	fmt.Println("  user list                 List users with their last login (admin)")
+	fmt.Println("  info                      Show schema version and database details")
+	fmt.Println("  describe                  Print resource types and the metadata JSON schema")
+	fmt.Println("  set [<name> <value>]      Show or change settings (maxnodes, maxdepth, maxcat,")
+	fmt.Println("                            maxrows, nounset, autocommit, compress, compressmin,")
+	fmt.Println("                            touchversions, store, timing, defaultbranch, prompt)")
+	fmt.Println("                            prompt placeholders: {branch} {user} {dir} {time}")
+	fmt.Println("                            {tx} {host} {schema_version}")
+	fmt.Println("                            compress and compressmin are saved in the database")
+	fmt.Println("  alias [name[='value']]    Define or list aliases")
+	fmt.Println("  unalias <name>            Remove an alias")
+	fmt.Println("  set-var [NAME VALUE]      Set or list variables ($NAME, ${NAME})")
 	fmt.Println("  exit, quit                Exit the shell")
+
+	if len(s.commands) > 0 {
+		names := make([]string, 0, len(s.commands))
+		for name := range s.commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Println()
+		fmt.Println("Extensions:")
+		fmt.Printf("  %s\n", strings.Join(names, ", "))
+	}
 }
 
 // ChangeDirectory changes the current directory
 func (s *Shell) ChangeDirectory(args []string) error {
 	path := "/"
 	if len(args) > 0 {
-		path = args[0]
-	}
-
-	// Handle relative paths
-	if !strings.HasPrefix(path, "/") {
-		path = filepath.Join(s.state.CurrentDirectory, path)
-	}
-
-	// Special case for "cd .." - go up one directory
-	if path == ".." || path == "../" {
-		if s.state.CurrentDirectory == "/" {
-			return nil // Already at root
+		var err error
+		if path, err = s.resolveLocalPath(args[0]); err != nil {
+			return err
 		}
-		path = filepath.Dir(s.state.CurrentDirectory)
 	}
 
-	// Normalize path
-	path = filepath.Clean(path)
-	if path == "." {
-		path = s.state.CurrentDirectory
-	}
-
-	// Verify directory exists by querying the database directly
-	var query string
-	if s.state.PointInTime != nil {
-		query = `
-			SELECT 1 FROM resources 
-			WHERE type = 'directory' AND path = ? AND valid_from <= ? 
-			AND (valid_to IS NULL OR valid_to > ?)
-		`
-	} else {
-		query = `
-			SELECT 1 FROM resources 
-			WHERE type = 'directory' AND path = ? AND valid_to IS NULL
-		`
-	}
-	
-	var rows *sql.Rows
-	var err error
-	
-	if s.state.PointInTime != nil {
-		pointInTime := *s.state.PointInTime
-		if s.state.CurrentTransaction != nil {
-			rows, err = s.state.CurrentTransaction.ExecuteQuery(query, path, pointInTime, pointInTime)
-		} else {
-			rows, err = s.db.ExecuteQuery(query, path, pointInTime, pointInTime)
-		}
-	} else {
-		if s.state.CurrentTransaction != nil {
-			rows, err = s.state.CurrentTransaction.ExecuteQuery(query, path)
-		} else {
-			rows, err = s.db.ExecuteQuery(query, path)
-		}
-	}
-	
-	if err != nil {
-		return fmt.Errorf("failed to check directory: %w", err)
-	}
-	defer rows.Close()
-	
-	var exists bool
-	if rows.Next() {
-		exists = true
-	}
-	
-	if !exists {
-		return fmt.Errorf("directory not found: %s", path)
+	if _, err := s.statDirectory(s.CurrentTransaction(), path, s.PointInTime()); err != nil {
+		return err
 	}
 
 	// Update current directory
@@ -324,8 +440,24 @@ func (s *Shell) ChangeDirectory(args []string) error {
 	return nil
 }
 
-// ListDirectory lists the contents of a directory
+// ListDirectory lists the contents of a directory (ls [--all] [-h] [path]).
+// Listings stop after maxrows entries unless --all is given. File sizes are
+// in bytes, or human-readable with -h. Entries written by the open
+// transaction are marked "(uncommitted)".
 func (s *Shell) ListDirectory(args []string) error {
+	args, at, err := s.takeAtFlag(args)
+	if err != nil {
+		return err
+	}
+
+	flags, args, err := splitFlags("ls", args, "-R", "--all", "--force", "-h", "-L", "--follow-symlinks")
+	if err != nil {
+		return err
+	}
+	if flags["-R"] {
+		return s.ListRecursive(args, at, flags["--force"], flags["-h"], flags["-L"] || flags["--follow-symlinks"])
+	}
+
 	// Determine path to list
 	path := s.state.CurrentDirectory
 	if len(args) > 0 {
@@ -334,154 +466,102 @@ func (s *Shell) ListDirectory(args []string) error {
 			path = args[0]
 		} else {
 			// Relative path
-			path = filepath.Join(s.state.CurrentDirectory, args[0])
+			path = pathpkg.Join(s.state.CurrentDirectory, args[0])
 		}
 	}
-	path = filepath.Clean(path)
+	path = pathpkg.Clean(path)
 
-	// First, verify the directory exists and get its ID
-	var query string
-	if s.state.PointInTime != nil {
-		query = `
-			SELECT id FROM resources 
-			WHERE type = 'directory' AND path = ? AND valid_from <= ? 
-			AND (valid_to IS NULL OR valid_to > ?)
-		`
-	} else {
-		query = `
-			SELECT id FROM resources 
-			WHERE type = 'directory' AND path = ? AND valid_to IS NULL
-		`
-	}
-	
-	var rows *sql.Rows
-	var err error
-	
-	if s.state.PointInTime != nil {
-		pointInTime := *s.state.PointInTime
-		if s.state.CurrentTransaction != nil {
-			rows, err = s.state.CurrentTransaction.ExecuteQuery(query, path, pointInTime, pointInTime)
-		} else {
-			rows, err = s.db.ExecuteQuery(query, path, pointInTime, pointInTime)
-		}
-	} else {
-		if s.state.CurrentTransaction != nil {
-			rows, err = s.state.CurrentTransaction.ExecuteQuery(query, path)
-		} else {
-			rows, err = s.db.ExecuteQuery(query, path)
-		}
-	}
-	
+	options, err := branchOptions(s.state.CurrentBranch, at)
 	if err != nil {
-		return fmt.Errorf("failed to check directory: %w", err)
-	}
-	
-	var dirID string
-	var dirExists bool
-	
-	if rows.Next() {
-		if err := rows.Scan(&dirID); err != nil {
-			rows.Close()
-			return fmt.Errorf("failed to scan directory ID: %w", err)
-		}
-		dirExists = true
-	}
-	rows.Close()
-	
-	if !dirExists {
-		return fmt.Errorf("directory not found: %s", path)
-	}
-	
-	// Now list the contents of the directory
-	if s.state.PointInTime != nil {
-		query = `
-			SELECT id, type, name, metadata
-			FROM resources
-			WHERE parent_id = ? AND valid_from <= ? 
-			AND (valid_to IS NULL OR valid_to > ?)
-			ORDER BY type DESC, name ASC
-		`
-	} else {
-		query = `
-			SELECT id, type, name, metadata
-			FROM resources
-			WHERE parent_id = ? AND valid_to IS NULL
-			ORDER BY type DESC, name ASC
-		`
+		return err
 	}
-	
-	// Execute query to get the directory contents
-	if s.state.PointInTime != nil {
-		pointInTime := *s.state.PointInTime
-		if s.state.CurrentTransaction != nil {
-			rows, err = s.state.CurrentTransaction.ExecuteQuery(query, dirID, pointInTime, pointInTime)
-		} else {
-			rows, err = s.db.ExecuteQuery(query, dirID, pointInTime, pointInTime)
-		}
-	} else {
-		if s.state.CurrentTransaction != nil {
-			rows, err = s.state.CurrentTransaction.ExecuteQuery(query, dirID)
-		} else {
-			rows, err = s.db.ExecuteQuery(query, dirID)
-		}
-	}
-	
+	entries, err := s.fm.ListDirectory(path, s.CurrentTransaction(), options)
 	if err != nil {
-		return fmt.Errorf("failed to list directory: %w", err)
+		return err
 	}
-	defer rows.Close()
+	// Symlinks, then files, then directories, each in name order
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Type > entries[j].Type
+	})
 	
 	// Display the directory contents
 	fmt.Printf("Contents of %s:\n", path)
-	var hasContents bool
+	limit := s.state.MaxQueryRows
+	if flags["--all"] {
+		limit = 0
+	}
 	
-	for rows.Next() {
-		hasContents = true
-		var id, resType, name string
-		var metadataStr string
-		
-		if err := rows.Scan(&id, &resType, &name, &metadataStr); err != nil {
-			return fmt.Errorf("failed to scan resource: %w", err)
+	for i, entry := range entries {
+		if limit > 0 && i == limit {
+			fmt.Printf("(%d more entries, use --all or query)\n", len(entries)-limit)
+			break
+		}
+		// Resources written by the open transaction are marked as pending
+		pending := ""
+		if s.isUncommitted(entry.TransactionID) {
+			pending = " (uncommitted)"
 		}
 		
 		// Display based on type
-		if resType == "directory" {
-			fmt.Printf("%s/\n", name)
-		} else if resType == "file" {
+		if entry.Type == "directory" {
+			fmt.Printf("%s/%s\n", entry.Name, pending)
+		} else if entry.Type == "file" {
 			// Try to parse metadata for size
-			var metadata schema.ResourceMetadata
-			if err := json.Unmarshal([]byte(metadataStr), &metadata); err == nil {
-				fmt.Printf("%s (%s)\n", name, formatSize(metadata.Size))
+			if metadata, err := schema.NormalizeMetadata(entry.Metadata); err == nil {
+				fmt.Printf("%s (%s)%s\n", entry.Name, formatListSize(metadata.Size, flags["-h"]), pending)
 			} else {
-				fmt.Printf("%s\n", name)
+				fmt.Printf("%s%s\n", entry.Name, pending)
 			}
-		} else if resType == "symlink" {
+		} else if entry.Type == "symlink" {
 			// Try to parse metadata for target
-			var metadata schema.ResourceMetadata
-			if err := json.Unmarshal([]byte(metadataStr), &metadata); err == nil {
-				fmt.Printf("%s -> %s\n", name, metadata.SymlinkTarget)
+			if metadata, err := schema.NormalizeMetadata(entry.Metadata); err == nil {
+				fmt.Printf("%s -> %s%s\n", entry.Name, metadata.SymlinkTarget, pending)
 			} else {
-				fmt.Printf("%s (symlink)\n", name)
+				fmt.Printf("%s (symlink)%s\n", entry.Name, pending)
 			}
 		} else {
-			fmt.Printf("%s (%s)\n", name, resType)
+			fmt.Printf("%s (%s)%s\n", entry.Name, entry.Type, pending)
 		}
 	}
 	
-	if !hasContents {
+	if len(entries) == 0 {
 		fmt.Println("(empty directory)")
 	}
 	
 	return nil
 }
 
-// BeginTransaction starts a new transaction
-func (s *Shell) BeginTransaction() error {
-	if s.state.CurrentTransaction != nil {
+// isolationFlags map begin's flags to isolation levels
+var isolationFlags = map[string]sql.IsolationLevel{
+	"--read-committed":  sql.LevelReadCommitted,
+	"--repeatable-read": sql.LevelRepeatableRead,
+	"--serializable":    sql.LevelSerializable,
+}
+
+// BeginTransaction starts a new transaction (begin [--read-committed |
+// --repeatable-read | --serializable] [--read-only])
+func (s *Shell) BeginTransaction(args []string) error {
+	if s.CurrentTransaction() != nil {
 		return fmt.Errorf("transaction already in progress")
 	}
 
-	tx, err := s.db.Begin()
+	opts := &sql.TxOptions{}
+	for _, arg := range args {
+		if level, ok := isolationFlags[arg]; ok {
+			if opts.Isolation != sql.LevelDefault {
+				return fmt.Errorf("only one isolation level may be given")
+			}
+			opts.Isolation = level
+		} else if arg == "--read-only" {
+			opts.ReadOnly = true
+		} else {
+			return fmt.Errorf("usage: begin [--read-committed|--repeatable-read|--serializable] [--read-only]")
+		}
+	}
+
+	// The transaction stays open between commands, so it only takes the
+	// write lock once it writes
+	tx, err := s.db.BeginDeferred(context.Background(), opts)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -489,41 +569,73 @@ func (s *Shell) BeginTransaction() error {
 	tx.SetBranchID(s.state.CurrentBranch)
 	tx.SetUserID(s.state.User)
 
-	s.state.CurrentTransaction = tx
+	s.setTransaction(tx)
 
-	fmt.Printf("Transaction T%s started\n", tx.GetID()[:8])
+	fmt.Printf("Transaction %s started%s\n", txLabel(tx.GetID()), transactionMode(tx))
 	return nil
 }
 
-// CommitTransaction commits the current transaction
+// transactionMode describes a transaction's isolation level and read-only
+// mode when they differ from the defaults, as " (serializable, read-only)"
+func transactionMode(tx *database.Transaction) string {
+	var mode []string
+	if level := tx.GetIsolation(); level != sql.LevelDefault {
+		mode = append(mode, strings.ToLower(level.String()))
+	}
+	if tx.IsReadOnly() {
+		mode = append(mode, "read-only")
+	}
+	if len(mode) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(mode, ", ") + ")"
+}
+
+// isUncommitted reports whether a resource version was written by the open
+// transaction, so that it is not yet visible to anyone else
+func (s *Shell) isUncommitted(txID string) bool {
+	tx := s.CurrentTransaction()
+	return tx != nil && txID == tx.GetID()
+}
+
+// CommitTransaction commits the current transaction and reports how many
+// resources it created, updated and deleted
 func (s *Shell) CommitTransaction() error {
-	if s.state.CurrentTransaction == nil {
+	if s.CurrentTransaction() == nil {
 		return fmt.Errorf("no transaction in progress")
 	}
 
-	err := s.state.CurrentTransaction.Commit()
+	if err := s.removeTemp(s.CurrentTransaction()); err != nil {
+		return err
+	}
+	summary := s.changeSummary(s.CurrentTransaction())
+
+	err := s.CurrentTransaction().Commit()
 	if err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	fmt.Printf("Transaction T%s committed\n", s.state.CurrentTransaction.GetID()[:8])
-	s.state.CurrentTransaction = nil
+	fmt.Printf("Transaction %s committed%s\n", txLabel(s.CurrentTransaction().GetID()), summary)
+	s.setTransaction(nil)
 	return nil
 }
 
-// AbortTransaction aborts the current transaction
+// AbortTransaction aborts the current transaction and reports how many
+// resource changes were discarded
 func (s *Shell) AbortTransaction() error {
-	if s.state.CurrentTransaction == nil {
+	if s.CurrentTransaction() == nil {
 		return fmt.Errorf("no transaction in progress")
 	}
 
-	err := s.state.CurrentTransaction.Rollback()
+	summary := s.changeSummary(s.CurrentTransaction())
+	err := s.CurrentTransaction().Rollback()
 	if err != nil {
 		return fmt.Errorf("failed to abort transaction: %w", err)
 	}
 
-	fmt.Printf("Transaction T%s aborted\n", s.state.CurrentTransaction.GetID()[:8])
-	s.state.CurrentTransaction = nil
+	fmt.Printf("Transaction %s aborted%s\n", txLabel(s.CurrentTransaction().GetID()), summary)
+	s.setTransaction(nil)
+	s.temp = nil
 	return nil
 }
 
@@ -531,7 +643,7 @@ func (s *Shell) AbortTransaction() error {
 func (s *Shell) ShowHistory(args []string) error {
 	// If args provided, show resource history
 	if len(args) > 0 {
-		return s.ShowResourceHistory(args[0])
+		return s.ShowResourceHistory(args)
 	}
 
 	// Otherwise show command history
@@ -541,82 +653,126 @@ func (s *Shell) ShowHistory(args []string) error {
 	return nil
 }
 
-// ShowResourceHistory shows the history of a resource
-func (s *Shell) ShowResourceHistory(path string) error {
-	// Implementation omitted for brevity
-	// In a real implementation, this would query the database for resource history
-	fmt.Printf("History for %s would appear here\n", path)
-	return nil
-}
+// DefaultMaxQueryRows is how many rows query prints before truncating
+const DefaultMaxQueryRows = 1000
+
+// queryTableSample is how many rows of a query result size its columns
+const queryTableSample = 100
+
+// ExecuteQuery executes a SQL query, printing at most maxrows rows. It takes
+// the raw text after the command name so that the statement reaches the
+// database as typed, with the spacing of its string literals intact.
+func (s *Shell) ExecuteQuery(text string) error {
+	explain, timing := false, s.state.Timing
+	for strings.HasPrefix(text, "--") {
+		option := strings.Fields(text)[0]
+		switch option {
+		case "--explain":
+			explain = true
+		case "--timing":
+			timing = true
+		default:
+			return fmt.Errorf("unknown query option: %s", option)
+		}
+		text = strings.TrimSpace(strings.TrimPrefix(text, option))
+	}
 
-// ExecuteQuery executes a SQL query
-func (s *Shell) ExecuteQuery(args []string) error {
-	if len(args) == 0 {
+	if text == "" {
 		return fmt.Errorf("query required")
 	}
 
-	query := strings.Join(args, " ")
+	query := text
+	if explain {
+		query = s.db.ExplainStatement(query)
+	}
 
-	var result *database.QueryResult
+	var it *database.RowIterator
 	var err error
 
-	options := database.DefaultQueryOptions()
-	options.BranchID = s.state.CurrentBranch
-	options.PointInTime = s.state.PointInTime
+	// The statement is passed through as written; branch and time filters only
+	// apply to the structured lookups, not to raw SQL.
+	options := database.QueryOptions{}
 
-	if s.state.CurrentTransaction != nil {
-		result, err = s.state.CurrentTransaction.Query(query, options)
+	// elapsed counts only the time spent in the database, executing the
+	// statement and fetching rows, not printing them
+	var elapsed time.Duration
+	start := time.Now()
+	if s.CurrentTransaction() != nil {
+		it, err = s.CurrentTransaction().QueryStream(query, options)
 	} else {
-		result, err = s.db.Query(query, options)
+		it, err = s.db.QueryStream(query, options)
 	}
+	elapsed += time.Since(start)
 
 	if err != nil {
 		return fmt.Errorf("query execution failed: %w", err)
 	}
+	defer it.Close()
+	if timing {
+		defer func() { printTiming(elapsed) }()
+	}
+
+	// Rows are printed as a table as they are read, with columns sized to
+	// fit the first queryTableSample of them. At most maxrows rows are read,
+	// whatever LIMIT the statement has.
+	table := database.NewTableWriter(os.Stdout, it.Columns(), queryTableSample)
+	count := 0
+	truncated := false
+	for {
+		start = time.Now()
+		more := it.Next()
+		elapsed += time.Since(start)
+		if !more {
+			break
+		}
+		if s.state.MaxQueryRows > 0 && it.Count() > s.state.MaxQueryRows {
+			truncated = true
+			break
+		}
 
-	// Format and display results
-	if result.Count == 0 {
-		fmt.Println("No results")
-		return nil
+		start = time.Now()
+		row, err := it.Values()
+		elapsed += time.Since(start)
+		if err != nil {
+			return err
+		}
+		if err := table.WriteRow(row); err != nil {
+			return err
+		}
+		count++
 	}
 
-	// Print column headers
-	for i, col := range result.Columns {
-		if i > 0 {
-			fmt.Print("\t")
-		}
-		fmt.Print(col)
+	if err := it.Err(); err != nil {
+		return err
 	}
-	fmt.Println()
 
-	// Print separator
-	for i := 0; i < len(result.Columns); i++ {
-		if i > 0 {
-			fmt.Print("\t")
-		}
-		fmt.Print("--------")
+	if count == 0 {
+		fmt.Println("No results")
+		return nil
+	}
+	if err := table.Flush(); err != nil {
+		return err
 	}
-	fmt.Println()
 
-	// Print rows
-	for _, row := range result.Rows {
-		for i, val := range row {
-			if i > 0 {
-				fmt.Print("\t")
-			}
-			if val == nil {
-				fmt.Print("NULL")
-			} else {
-				fmt.Print(val)
-			}
-		}
-		fmt.Println()
+	if truncated {
+		fmt.Printf("(truncated at %d rows; use set maxrows to raise the limit)\n", s.state.MaxQueryRows)
+		return nil
 	}
 
-	fmt.Printf("%d row(s) returned\n", result.Count)
+	fmt.Printf("%d row(s) returned\n", count)
 	return nil
 }
 
+// printTiming prints how long an operation took, as "(took 12ms)"
+func printTiming(d time.Duration) {
+	if d >= time.Millisecond {
+		d = d.Round(time.Millisecond)
+	} else {
+		d = d.Round(time.Microsecond)
+	}
+	fmt.Printf("(took %s)\n", d)
+}
+
 // SetPointInTime sets the point in time for time travel
 func (s *Shell) SetPointInTime(args []string) error {
 	if len(args) == 0 {
@@ -652,405 +808,382 @@ func (s *Shell) SetPointInTime(args []string) error {
 		}
 	}
 
-	s.state.PointInTime = &t
+	s.setPointInTime(&t)
 	fmt.Printf("Time travel mode: viewing system state as of %s\n", t.Format(time.RFC3339))
 	return nil
 }
 
 // ResetPointInTime returns to present time
 func (s *Shell) ResetPointInTime() error {
-	s.state.PointInTime = nil
+	s.setPointInTime(nil)
 	fmt.Println("Returned to present time")
 	return nil
 }
 
-// ManageBranch manages branches
-func (s *Shell) ManageBranch(args []string) error {
-	// Implementation omitted for brevity
-	fmt.Println("Branch management would appear here")
-	return nil
-}
-
-// SwitchBranch switches to a different branch
-func (s *Shell) SwitchBranch(args []string) error {
-	// Implementation omitted for brevity
-	fmt.Println("Branch switching would appear here")
-	return nil
-}
-
-// MakeDirectory creates a new directory
+// MakeDirectory creates a directory (mkdir [-p] [--temp] <dir>). With -p,
+// missing parent directories are created too and an existing directory is
+// not an error. With --temp, the directory and everything created in it is
+// removed when the open transaction commits.
 func (s *Shell) MakeDirectory(args []string) error {
+	flags, args, err := splitFlags("mkdir", args, "-p", "--temp")
+	if err != nil {
+		return err
+	}
 	if len(args) == 0 {
 		return fmt.Errorf("directory name required")
 	}
 	
-	dirName := args[0]
-	var path string
-	
-	// Handle absolute vs relative paths
-	if strings.HasPrefix(dirName, "/") {
-		path = dirName
-	} else {
-		path = filepath.Join(s.state.CurrentDirectory, dirName)
-	}
-	
-	// Normalize path
-	path = filepath.Clean(path)
-	
-	// Extract the parent directory path and the new directory name
-	parentPath := filepath.Dir(path)
-	newDirName := filepath.Base(path)
-	
-	// Verify parent directory exists
-	query := `
-		SELECT id FROM resources 
-		WHERE type = 'directory' AND path = ? AND valid_to IS NULL
-	`
-	
-	rows, err := s.db.ExecuteQuery(query, parentPath)
+	path, err := s.resolveLocalPath(args[0])
 	if err != nil {
-		return fmt.Errorf("failed to check parent directory: %w", err)
+		return err
 	}
-	
-	var parentID string
-	var parentExists bool
-	
-	if rows.Next() {
-		if err := rows.Scan(&parentID); err != nil {
-			rows.Close()
-			return fmt.Errorf("failed to scan parent directory ID: %w", err)
+	if flags["--temp"] {
+		if err := s.checkTemp(path); err != nil {
+			return err
 		}
-		parentExists = true
 	}
-	rows.Close()
-	
-	if !parentExists {
-		return fmt.Errorf("parent directory not found: %s", parentPath)
-	}
-	
-	// Check if directory already exists
-	query = `
-		SELECT 1 FROM resources 
-		WHERE parent_id = ? AND name = ? AND type = 'directory' AND valid_to IS NULL
-	`
 	
-	existsRows, err := s.db.ExecuteQuery(query, parentID, newDirName)
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		temp := path
+		if flags["--temp"] && flags["-p"] {
+			temp = s.firstMissing(tx, path)
+		}
+		
+		var created []string
+		if flags["-p"] {
+			if created, err = s.makeDirectories(tx, out, path); err != nil {
+				return err
+			}
+		} else {
+			parentPath := pathpkg.Dir(path)
+			parent, err := s.statParent(tx, parentPath)
+			if err != nil {
+				return err
+			}
+			existing, err := s.statCurrent(tx, path)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				if existing.Type == schema.ResourceTypeDirectory {
+					return fmt.Errorf("directory %w: %s", database.ErrAlreadyExists, path)
+				}
+				return fmt.Errorf("file exists: %s", path)
+			}
+			
+			dirID, err := s.createDirectory(tx, out, parent.ID, path)
+			if err != nil {
+				return err
+			}
+			created = append(created, dirID)
+		}
+		
+		if len(created) == 0 {
+			return nil
+		}
+		if err := s.recordOperation(tx, schema.OperationKindCreate, created); err != nil {
+			return err
+		}
+		if flags["--temp"] {
+			s.markTemp(tx, temp)
+		}
+		return nil
+	})
+}
+
+// makeDirectories creates the directory at path and any missing parents,
+// returning the IDs of the directories it created
+func (s *Shell) makeDirectories(tx *database.Transaction, out *txOutput, path string) ([]string, error) {
+	root, err := s.lookupResource(tx, "/")
 	if err != nil {
-		return fmt.Errorf("failed to check if directory exists: %w", err)
-	}
-	
-	var exists bool
-	if existsRows.Next() {
-		exists = true
-	}
-	existsRows.Close()
-	
-	if exists {
-		return fmt.Errorf("directory already exists: %s", path)
+		return nil, err
 	}
 	
-	// Start a transaction if one isn't already active
-	var tx *database.Transaction
-	var newTx bool
-	
-	if s.state.CurrentTransaction != nil {
-		tx = s.state.CurrentTransaction
-	} else {
-		var err error
-		tx, err = s.db.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction: %w", err)
+	var created []string
+	parentID := root.ID
+	current := "/"
+	for _, name := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if name == "" {
+			continue
 		}
-		newTx = true
-		defer func() {
-			if newTx && tx.IsActive() {
-				tx.Rollback()
+		current = pathpkg.Join(current, name)
+		
+		existing, err := s.lookupResource(tx, current)
+		if err == nil {
+			if existing.Type != schema.ResourceTypeDirectory {
+				return nil, fmt.Errorf("not a directory: %s", current)
 			}
-		}()
+			parentID = existing.ID
+			continue
+		}
+		
+		dirID, err := s.createDirectory(tx, out, parentID, current)
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, dirID)
+		parentID = dirID
 	}
 	
-	// Create the directory
-	dirID := fmt.Sprintf("dir-%d", time.Now().UnixNano())
+	return created, nil
+}
+
+// createDirectory inserts a directory at path under parentID
+func (s *Shell) createDirectory(tx *database.Transaction, out *txOutput, parentID, path string) (string, error) {
+	dirID, err := newResourceID(tx, schema.ResourceTypeDirectory)
+	if err != nil {
+		return "", err
+	}
 	
 	// Create directory metadata
-	metadata := schema.NewDirectoryMetadata(s.state.User)
+	metadata := schema.NewDirectoryMetadata(s.state.User, s.directoryPermissions())
 	metadataJSON, err := json.Marshal(metadata)
 	if err != nil {
-		return fmt.Errorf("failed to marshal directory metadata: %w", err)
+		return "", fmt.Errorf("failed to marshal directory metadata: %w", err)
 	}
 	
 	// Insert the directory
-	now := time.Now()
 	_, err = tx.Execute(`
-		INSERT INTO resources (id, type, name, parent_id, path, metadata, valid_from, transaction_id)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`, dirID, schema.ResourceTypeDirectory, newDirName, parentID, path, string(metadataJSON), now, tx.GetID())
-	
+		INSERT INTO resources (id, type, name, parent_id, path, metadata, valid_from, transaction_id, branch_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, dirID, schema.ResourceTypeDirectory, pathpkg.Base(path), parentID, path, string(metadataJSON), time.Now(), tx.GetID(), s.state.CurrentBranch)
 	if err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-	
-	// If we started a new transaction, commit it
-	if newTx {
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit transaction: %w", err)
-		}
+		return "", fmt.Errorf("failed to create directory: %w", err)
 	}
 	
-	fmt.Printf("Directory created: %s\n", path)
-	return nil
+	out.Printf("Directory created: %s\n", path)
+	return dirID, nil
 }
 
-// TouchFile creates an empty file
+// TouchFile creates an empty file, or updates the timestamps of an existing
+// one (touch [-p] [-a] [-m] [--temp] <file>). -a sets only the access time
+// and -m only the modification time; by default both are set. With -p,
+// missing parent directories are created in the same transaction. With
+// --temp, the new file is removed when the open transaction commits.
 func (s *Shell) TouchFile(args []string) error {
+	flags, args, err := splitFlags("touch", args, "-p", "-a", "-m", "--temp")
+	if err != nil {
+		return err
+	}
 	if len(args) == 0 {
 		return fmt.Errorf("file name required")
 	}
-	
-	fileName := args[0]
-	var path string
-	
-	// Handle absolute vs relative paths
-	if strings.HasPrefix(fileName, "/") {
-		path = fileName
-	} else {
-		path = filepath.Join(s.state.CurrentDirectory, fileName)
+	setAccess, setModify := true, true
+	if flags["-a"] != flags["-m"] {
+		setAccess, setModify = flags["-a"], flags["-m"]
 	}
 	
-	// Normalize path
-	path = filepath.Clean(path)
-	
-	// Extract the parent directory path and the new file name
-	parentPath := filepath.Dir(path)
-	newFileName := filepath.Base(path)
-	
-	// Verify parent directory exists
-	query := `
-		SELECT id FROM resources 
-		WHERE type = 'directory' AND path = ? AND valid_to IS NULL
-	`
-	
-	rows, err := s.db.ExecuteQuery(query, parentPath)
+	path, err := s.resolveLocalPath(args[0])
 	if err != nil {
-		return fmt.Errorf("failed to check parent directory: %w", err)
+		return err
 	}
-	
-	var parentID string
-	var parentExists bool
-	
-	if rows.Next() {
-		if err := rows.Scan(&parentID); err != nil {
-			rows.Close()
-			return fmt.Errorf("failed to scan parent directory ID: %w", err)
+	parentPath := pathpkg.Dir(path)
+	if flags["--temp"] {
+		if err := s.checkTemp(path); err != nil {
+			return err
 		}
-		parentExists = true
-	}
-	rows.Close()
-	
-	if !parentExists {
-		return fmt.Errorf("parent directory not found: %s", parentPath)
-	}
-	
-	// Check if file already exists
-	query = `
-		SELECT 1 FROM resources 
-		WHERE parent_id = ? AND name = ? AND valid_to IS NULL
-	`
-	
-	existsRows, err := s.db.ExecuteQuery(query, parentID, newFileName)
-	if err != nil {
-		return fmt.Errorf("failed to check if file exists: %w", err)
-	}
-	
-	var exists bool
-	if existsRows.Next() {
-		exists = true
 	}
-	existsRows.Close()
 	
-	if exists {
-		// File exists, update its timestamp
-		query = `
-			UPDATE resources 
-			SET valid_to = ?
-			WHERE parent_id = ? AND name = ? AND valid_to IS NULL
-		`
-		
-		now := time.Now()
-		
-		// Start a transaction if one isn't already active
-		var tx *database.Transaction
-		var newTx bool
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		temp := path
+		if flags["--temp"] && flags["-p"] {
+			temp = s.firstMissing(tx, path)
+		}
 		
-		if s.state.CurrentTransaction != nil {
-			tx = s.state.CurrentTransaction
-		} else {
-			tx, err = s.db.Begin()
+		if flags["-p"] {
+			created, err := s.makeDirectories(tx, out, parentPath)
 			if err != nil {
-				return fmt.Errorf("failed to begin transaction: %w", err)
+				return err
 			}
-			newTx = true
-			defer func() {
-				if newTx && tx.IsActive() {
-					tx.Rollback()
+			if len(created) > 0 {
+				if err := s.recordOperation(tx, schema.OperationKindCreate, created); err != nil {
+					return err
 				}
-			}()
-		}
-		
-		// Mark the old version as obsolete
-		_, err = tx.Execute(query, now, parentID, newFileName)
-		if err != nil {
-			return fmt.Errorf("failed to update file: %w", err)
-		}
-		
-		// Get the old file's details
-		query = `
-			SELECT id, content, metadata
-			FROM resources 
-			WHERE parent_id = ? AND name = ? AND valid_to = ?
-		`
-		
-		detailRows, err := tx.ExecuteQuery(query, parentID, newFileName, now)
-		if err != nil {
-			return fmt.Errorf("failed to get file details: %w", err)
-		}
-		
-		var oldID string
-		var content []byte
-		var metadataStr string
-		
-		if detailRows.Next() {
-			if err := detailRows.Scan(&oldID, &content, &metadataStr); err != nil {
-				detailRows.Close()
-				return fmt.Errorf("failed to scan file details: %w", err)
 			}
 		}
-		detailRows.Close()
-		
-		// Parse metadata to update timestamp
-		var metadata schema.ResourceMetadata
-		if err := json.Unmarshal([]byte(metadataStr), &metadata); err != nil {
-			return fmt.Errorf("failed to unmarshal metadata: %w", err)
-		}
 		
-		metadata.ModifiedAt = now
-		metadata.AccessedAt = now
-		
-		metadataJSON, err := json.Marshal(metadata)
+		parent, err := s.statParent(tx, parentPath)
 		if err != nil {
-			return fmt.Errorf("failed to marshal metadata: %w", err)
+			return err
 		}
 		
-		// Create a new version of the file
-		fileID := fmt.Sprintf("file-%d", time.Now().UnixNano())
-		
-		_, err = tx.Execute(`
-			INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`, fileID, schema.ResourceTypeFile, newFileName, parentID, path, content, string(metadataJSON), now, tx.GetID())
-		
+		existing, err := s.statCurrent(tx, path)
 		if err != nil {
-			return fmt.Errorf("failed to create new file version: %w", err)
+			return err
 		}
-		
-		// If we started a new transaction, commit it
-		if newTx {
-			if err := tx.Commit(); err != nil {
-				return fmt.Errorf("failed to commit transaction: %w", err)
+		if existing != nil {
+			if flags["--temp"] {
+				return fmt.Errorf("file %w: %s (--temp only creates new files)", database.ErrAlreadyExists, path)
 			}
-		}
-		
-		fmt.Printf("File updated: %s\n", path)
-	} else {
-		// File doesn't exist, create it
-		fileID := fmt.Sprintf("file-%d", time.Now().UnixNano())
-		
-		// Create file metadata
-		metadata := schema.NewResourceMetadata(s.state.User)
-		metadata.Size = 0 // Empty file
-		
-		// Determine MIME type based on extension
-		ext := strings.ToLower(filepath.Ext(newFileName))
-		switch ext {
-		case ".txt":
-			metadata.MimeType = "text/plain"
-		case ".html", ".htm":
-			metadata.MimeType = "text/html"
-		case ".json":
-			metadata.MimeType = "application/json"
-		case ".md":
-			metadata.MimeType = "text/markdown"
-		case ".go":
-			metadata.MimeType = "text/x-go"
-		default:
-			metadata.MimeType = "application/octet-stream"
-		}
-		
-		metadataJSON, err := json.Marshal(metadata)
-		if err != nil {
-			return fmt.Errorf("failed to marshal metadata: %w", err)
-		}
-		
-		// Start a transaction if one isn't already active
-		var tx *database.Transaction
-		var newTx bool
-		
-		if s.state.CurrentTransaction != nil {
-			tx = s.state.CurrentTransaction
-		} else {
-			tx, err = s.db.Begin()
+			entry, err := newResourceEntry(existing)
 			if err != nil {
-				return fmt.Errorf("failed to begin transaction: %w", err)
+				return err
 			}
-			newTx = true
-			defer func() {
-				if newTx && tx.IsActive() {
-					tx.Rollback()
-				}
-			}()
+			return s.touchExisting(tx, out, entry, setAccess, setModify)
 		}
-		
-		// Insert the file
-		now := time.Now()
-		_, err = tx.Execute(`
-			INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-		`, fileID, schema.ResourceTypeFile, newFileName, parentID, path, []byte{}, string(metadataJSON), now, tx.GetID())
-		
+		if err := s.createEmptyFile(tx, out, parent.ID, path); err != nil {
+			return err
+		}
+		if flags["--temp"] {
+			s.markTemp(tx, temp)
+		}
+		return nil
+	})
+}
+
+// touchExisting sets the access and modification times of an existing
+// resource to now. Access times are not history, so they are updated in
+// place on the current version. A new modification time is recorded as a
+// new version, unless the current version was written by this transaction
+// or the touchversions setting is off; then it too is updated in place.
+func (s *Shell) touchExisting(tx *database.Transaction, out *txOutput, existing *resourceEntry, setAccess, setModify bool) error {
+	if err := s.fm.CheckLock(existing.Path, tx); err != nil {
+		return err
+	}
+	
+	now := time.Now()
+	metadata := existing.Metadata
+	if setAccess {
+		metadata.AccessedAt = now
+	}
+	if setModify {
+		metadata.ModifiedAt = now
+	}
+	
+	inPlace := !setModify || !s.state.TouchVersions
+	if !inPlace {
+		writtenBy, err := versionTransaction(tx, existing.ID)
 		if err != nil {
-			return fmt.Errorf("failed to create file: %w", err)
+			return err
 		}
-		
-		// If we started a new transaction, commit it
-		if newTx {
-			if err := tx.Commit(); err != nil {
-				return fmt.Errorf("failed to commit transaction: %w", err)
-			}
+		inPlace = writtenBy == tx.GetID()
+	}
+	if inPlace {
+		if err := updateMetadataInPlace(tx, existing.ID, metadata); err != nil {
+			return fmt.Errorf("failed to update file: %w", err)
 		}
-		
-		fmt.Printf("File created: %s\n", path)
+		out.Printf("File updated: %s\n", existing.Path)
+		return nil
 	}
 	
+	newID, err := writeMetadataVersion(tx, existing.ID, existing.Type, metadata, now)
+	if err != nil {
+		return fmt.Errorf("failed to update file: %w", err)
+	}
+	if err := s.recordOperation(tx, schema.OperationKindUpdate, []string{newID}); err != nil {
+		return err
+	}
+	
+	out.Printf("File updated: %s\n", existing.Path)
 	return nil
 }
 
-// RemoveResource removes a resource
-func (s *Shell) RemoveResource(args []string) error {
-	// Implementation omitted for brevity
-	fmt.Println("Resource removal would appear here")
+// createEmptyFile inserts an empty file at path under parentID
+func (s *Shell) createEmptyFile(tx *database.Transaction, out *txOutput, parentID, path string) error {
+	fileID, err := newResourceID(tx, schema.ResourceTypeFile)
+	if err != nil {
+		return err
+	}
+	name := pathpkg.Base(path)
+	
+	// Create file metadata
+	metadata := schema.NewResourceMetadata(s.state.User, s.filePermissions())
+	metadata.Size = 0 // Empty file
+	metadata.MimeType = mimeTypeForName(name)
+	
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	
+	// Insert the file
+	_, err = tx.Execute(`
+		INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id, branch_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, fileID, schema.ResourceTypeFile, name, parentID, path, []byte{}, string(metadataJSON), time.Now(), tx.GetID(), s.state.CurrentBranch)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	
+	if err := s.recordOperation(tx, schema.OperationKindCreate, []string{fileID}); err != nil {
+		return err
+	}
+	
+	out.Printf("File created: %s\n", path)
 	return nil
 }
 
-// CatFile displays file contents
-func (s *Shell) CatFile(args []string) error {
-	// Implementation omitted for brevity
-	fmt.Println("File contents would appear here")
-	return nil
+// mimeTypeForName determines a file's MIME type from its extension
+func mimeTypeForName(name string) string {
+	switch strings.ToLower(pathpkg.Ext(name)) {
+	case ".txt":
+		return "text/plain"
+	case ".html", ".htm":
+		return "text/html"
+	case ".json":
+		return "application/json"
+	case ".md":
+		return "text/markdown"
+	case ".go":
+		return "text/x-go"
+	default:
+		return "application/octet-stream"
+	}
 }
 
-// Echo writes text to a file
-func (s *Shell) Echo(args []string) error {
-	// Implementation omitted for brevity
-	fmt.Println("Echo command would appear here")
-	return nil
-}
\ No newline at end of file
+// RemoveResource removes resources from the current branch
+// (rm [-r] [-f|--yes] <path>...). A directory with anything in it needs -r,
+// which asks for confirmation in interactive mode. What rm removes stays in
+// its path's history until empty-trash.
+func (s *Shell) RemoveResource(args []string) error {
+	flags, args, err := splitFlags("rm", args, "-r", "-R", "-f", "--yes")
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: rm [-r] [-f|--yes] <path>...")
+	}
+	recursive := flags["-r"] || flags["-R"]
+
+	paths := make([]string, len(args))
+	for i, arg := range args {
+		path, err := s.resolveLocalPath(arg)
+		if err != nil {
+			return err
+		}
+		paths[i] = path
+	}
+
+	if recursive {
+		ok, err := s.confirm(flags, fmt.Sprintf("This removes %s and everything under it", strings.Join(paths, ", ")))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("rm: aborted")
+			return nil
+		}
+	}
+
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		for _, path := range paths {
+			removed, err := s.fm.Remove(path, recursive, tx)
+			if err != nil {
+				return err
+			}
+			ids := make([]string, len(removed))
+			for i, resource := range removed {
+				ids[i] = resource.ID
+			}
+			if err := s.recordOperation(tx, schema.OperationKindDelete, ids); err != nil {
+				return err
+			}
+			if len(removed) > 1 {
+				out.Printf("Removed %s (%d resources)\n", path, len(removed))
+			} else {
+				out.Printf("Removed %s\n", path)
+			}
+		}
+		return nil
+	})
+}
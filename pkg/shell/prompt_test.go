@@ -0,0 +1,168 @@
+package shell
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+func TestListDirectory(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /tmp/docs")
+	run(t, sh, "echo hello > /tmp/a.txt")
+
+	want := "Contents of /tmp:\na.txt (6 B)\ndocs/\n"
+	if got := run(t, sh, "ls /tmp"); got != want {
+		t.Errorf("ls /tmp = %q, want %q", got, want)
+	}
+	run(t, sh, "cd /tmp")
+	if got := run(t, sh, "ls"); got != want {
+		t.Errorf("ls in /tmp = %q, want %q", got, want)
+	}
+	if got := run(t, sh, "ls docs"); got != "Contents of /tmp/docs:\n(empty directory)\n" {
+		t.Errorf("ls of an empty directory = %q", got)
+	}
+	if _, err := runErr(sh, "ls /tmp/a.txt"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("ls of a file: %v, want ErrNotFound", err)
+	}
+}
+
+func TestListDirectoryHumanSizes(t *testing.T) {
+	sh := newTestShell(t)
+	createRaw(t, sh, "/tmp/big", strings.Repeat("x", 1536))
+
+	if got := run(t, sh, "ls /tmp"); !strings.Contains(got, "big (1536 B)") {
+		t.Errorf("ls:\n%s", got)
+	}
+	if got := run(t, sh, "ls -h /tmp"); !strings.Contains(got, "big (1.5 KB)") {
+		t.Errorf("ls -h:\n%s", got)
+	}
+	if got := run(t, sh, "ls -R -h /tmp"); !strings.Contains(got, "big (1.5 KB)") {
+		t.Errorf("ls -R -h:\n%s", got)
+	}
+}
+
+func TestListDirectoryLimit(t *testing.T) {
+	sh := newTestShell(t)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		run(t, sh, "touch /tmp/"+name)
+	}
+
+	run(t, sh, "set maxrows 2")
+	got := run(t, sh, "ls /tmp")
+	if !strings.Contains(got, "a (0 B)\nb (0 B)\n(2 more entries, use --all or query)") || strings.Contains(got, "c (") {
+		t.Errorf("ls with maxrows 2:\n%s", got)
+	}
+	if got := run(t, sh, "ls --all /tmp"); !strings.Contains(got, "d (0 B)") {
+		t.Errorf("ls --all:\n%s", got)
+	}
+}
+
+func TestListDirectoryMarksUncommitted(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "touch /tmp/old")
+	run(t, sh, "begin")
+	run(t, sh, "touch /tmp/new")
+	run(t, sh, "mkdir /tmp/dir")
+
+	got := run(t, sh, "ls /tmp")
+	if !strings.Contains(got, "dir/ (uncommitted)") || !strings.Contains(got, "new (0 B) (uncommitted)") || strings.Contains(got, "old (0 B) (uncommitted)") {
+		t.Errorf("ls in a transaction:\n%s", got)
+	}
+	run(t, sh, "commit")
+	if got := run(t, sh, "ls /tmp"); strings.Contains(got, "uncommitted") {
+		t.Errorf("ls after commit:\n%s", got)
+	}
+}
+
+func TestListDirectoryAt(t *testing.T) {
+	sh := newTestShell(t)
+	at := pastState(t, sh)
+
+	got := run(t, sh, "ls --at "+at+" /")
+	if !strings.Contains(got, "old.txt") || strings.Contains(got, "new.txt") {
+		t.Errorf("ls --at:\n%s", got)
+	}
+	if got := run(t, sh, "ls /"); !strings.Contains(got, "new.txt") || strings.Contains(got, "old.txt") {
+		t.Errorf("ls now:\n%s", got)
+	}
+	if _, err := runErr(sh, "ls --at"); err == nil {
+		t.Error("--at without a time accepted")
+	}
+}
+
+func TestMakeDirectory(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /tmp/a")
+	if _, err := runErr(sh, "mkdir /tmp/a"); !errors.Is(err, database.ErrAlreadyExists) {
+		t.Errorf("mkdir of an existing directory: %v, want ErrAlreadyExists", err)
+	}
+	if _, err := runErr(sh, "mkdir /tmp/x/y"); err == nil {
+		t.Error("mkdir without a parent succeeded")
+	}
+
+	output := run(t, sh, "mkdir -p /tmp/x/y/z")
+	if strings.Count(output, "Directory created") != 3 {
+		t.Errorf("mkdir -p output:\n%s", output)
+	}
+	if output := run(t, sh, "mkdir -p /tmp/x/y/z"); strings.Contains(output, "created") {
+		t.Errorf("mkdir -p of an existing path created something:\n%s", output)
+	}
+	run(t, sh, "touch /tmp/file")
+	if _, err := runErr(sh, "mkdir -p /tmp/file/sub"); err == nil {
+		t.Error("mkdir -p through a file succeeded")
+	}
+}
+
+func TestTouch(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "touch /tmp/page.html")
+	if m := metadataOf(t, sh, "/tmp/page.html"); m.Size != 0 || m.MimeType != "text/html" {
+		t.Errorf("new file metadata = %+v", m)
+	}
+
+	output := run(t, sh, "touch -p /tmp/a/b/c.txt")
+	if strings.Count(output, "Directory created") != 2 || !strings.Contains(output, "File created: /tmp/a/b/c.txt") {
+		t.Errorf("touch -p output:\n%s", output)
+	}
+	if _, err := runErr(sh, "touch /tmp/missing/c.txt"); err == nil {
+		t.Error("touch without a parent succeeded")
+	}
+}
+
+func TestTouchTimes(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "touch /tmp/a")
+	before := metadataOf(t, sh, "/tmp/a")
+	versions := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE path = '/tmp/a'`)
+
+	time.Sleep(5 * time.Millisecond)
+	run(t, sh, "touch -a /tmp/a")
+	after := metadataOf(t, sh, "/tmp/a")
+	if !after.AccessedAt.After(before.AccessedAt) || !after.ModifiedAt.Equal(before.ModifiedAt) {
+		t.Errorf("touch -a: accessed %v -> %v, modified %v -> %v", before.AccessedAt, after.AccessedAt, before.ModifiedAt, after.ModifiedAt)
+	}
+	// Access times are not history
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE path = '/tmp/a'`); n != versions {
+		t.Errorf("touch -a wrote a version: %d versions, want %d", n, versions)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	run(t, sh, "touch -m /tmp/a")
+	latest := metadataOf(t, sh, "/tmp/a")
+	if !latest.ModifiedAt.After(after.ModifiedAt) || !latest.AccessedAt.Equal(after.AccessedAt) {
+		t.Errorf("touch -m: accessed %v -> %v, modified %v -> %v", after.AccessedAt, latest.AccessedAt, after.ModifiedAt, latest.ModifiedAt)
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE path = '/tmp/a'`); n != versions+1 {
+		t.Errorf("touch -m: %d versions, want %d", n, versions+1)
+	}
+
+	run(t, sh, "set touchversions off")
+	run(t, sh, "touch /tmp/a")
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE path = '/tmp/a'`); n != versions+1 {
+		t.Errorf("touch with touchversions off wrote a version: %d versions", n)
+	}
+}
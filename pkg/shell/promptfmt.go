@@ -0,0 +1,75 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// DefaultPromptTemplate is the prompt shown until it is changed with
+// set prompt
+const DefaultPromptTemplate = "[{branch}] {user}@{time}:{dir}{tx}> "
+
+// promptPlaceholder matches a {name} placeholder in a prompt template
+var promptPlaceholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// promptPlaceholders are the placeholders a prompt template may use. {time}
+// is "@<time>" when reading as of a point in time and {tx} is "(T<id>)"
+// while a transaction is active; both are empty otherwise.
+var promptPlaceholders = map[string]bool{
+	"branch": true, "user": true, "dir": true, "time": true,
+	"tx": true, "host": true, "schema_version": true,
+}
+
+// setPrompt changes the prompt template after checking its placeholders
+func (s *Shell) setPrompt(template string) error {
+	for _, match := range promptPlaceholder.FindAllStringSubmatch(template, -1) {
+		if !promptPlaceholders[match[1]] {
+			return fmt.Errorf("unknown prompt placeholder: %s", match[0])
+		}
+	}
+	s.promptTemplate = template
+	return nil
+}
+
+// renderPrompt replaces the placeholders in a prompt template with their
+// current values. Unknown placeholders are left as they are.
+func (s *Shell) renderPrompt(template string) string {
+	return promptPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		switch placeholder[1 : len(placeholder)-1] {
+		case "branch":
+			return s.state.CurrentBranch
+		case "user":
+			return s.state.User
+		case "dir":
+			return s.state.CurrentDirectory
+		case "time":
+			if s.PointInTime() == nil {
+				return ""
+			}
+			return "@" + s.PointInTime().Format("2006-01-02T15:04:05")
+		case "tx":
+			if s.CurrentTransaction() == nil {
+				return ""
+			}
+			return "(" + txLabel(s.CurrentTransaction().GetID()) + ")"
+		case "host":
+			hostname, err := os.Hostname()
+			if err != nil {
+				return "unknown"
+			}
+			return hostname
+		case "schema_version":
+			version, err := schema.GetVersion(s.queries())
+			if err != nil {
+				return "?"
+			}
+			return strconv.Itoa(version.Version)
+		default:
+			return placeholder
+		}
+	})
+}
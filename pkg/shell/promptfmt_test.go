@@ -0,0 +1,45 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPromptTemplate(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "cd /tmp")
+	if got := sh.GetPrompt(); got != "[main] system@:/tmp> " {
+		t.Errorf("default prompt = %q", got)
+	}
+
+	run(t, sh, "begin")
+	if got := sh.GetPrompt(); !strings.HasSuffix(got, ":/tmp("+txLabel(sh.CurrentTransaction().GetID())+")> ") {
+		t.Errorf("prompt in a transaction = %q", got)
+	}
+	run(t, sh, "abort")
+
+	run(t, sh, `set prompt "{user} on {branch} v{schema_version} $ "`)
+	if got := sh.GetPrompt(); !strings.HasPrefix(got, "system on main v") || !strings.HasSuffix(got, " $ ") {
+		t.Errorf("custom prompt = %q", got)
+	}
+	run(t, sh, "set prompt {dir}>")
+	if got := sh.GetPrompt(); got != "/tmp>" {
+		t.Errorf("unquoted prompt = %q", got)
+	}
+
+	run(t, sh, "state-at 2020-01-02T03:04:05Z")
+	run(t, sh, "set prompt {time}")
+	if got := sh.GetPrompt(); !strings.HasPrefix(got, "@2020-01-0") {
+		t.Errorf("prompt at a point in time = %q", got)
+	}
+
+	if _, err := runErr(sh, "set prompt {cwd}> "); err == nil {
+		t.Error("unknown placeholder accepted")
+	}
+	if got := sh.GetPrompt(); !strings.HasPrefix(got, "@") {
+		t.Errorf("prompt changed by a rejected template: %q", got)
+	}
+	if output := run(t, sh, "set"); !strings.Contains(output, `prompt        = "{time}"`) {
+		t.Errorf("set does not list the prompt:\n%s", output)
+	}
+}
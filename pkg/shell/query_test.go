@@ -0,0 +1,95 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestQueryPrintsTable(t *testing.T) {
+	sh := newTestShell(t)
+	output := run(t, sh, "query SELECT 'a' AS name, 1 AS n UNION ALL SELECT 'longer', 22 ORDER BY n")
+
+	want := "" +
+		"name   | n \n" +
+		"-------+---\n" +
+		"a      |  1\n" +
+		"longer | 22\n" +
+		"2 row(s) returned\n"
+	if output != want {
+		t.Errorf("got\n%s\nwant\n%s", output, want)
+	}
+}
+
+// TestQueryUnlimitedRows reads more rows than the table sample with maxrows
+// off
+func TestQueryUnlimitedRows(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "set maxrows 0")
+	n := queryTableSample*3 + 7
+	output := run(t, sh, fmt.Sprintf("query WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM c WHERE x < %d) SELECT x FROM c", n))
+
+	if !strings.HasSuffix(output, fmt.Sprintf("%d row(s) returned\n", n)) {
+		t.Errorf("output does not end with the row count:\n%s", output[max(0, len(output)-200):])
+	}
+	if got := strings.Count(output, "\n"); got != n+3 {
+		t.Errorf("printed %d lines, want %d", got, n+3)
+	}
+}
+
+func TestQueryTruncatedAtMaxRows(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "set maxrows 5")
+	output := run(t, sh, "query WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM c WHERE x < 50) SELECT x FROM c")
+
+	if !strings.Contains(output, "(truncated at 5 rows") {
+		t.Errorf("output not truncated:\n%s", output)
+	}
+	if got := strings.Count(output, "\n"); got != 5+3 {
+		t.Errorf("printed %d lines, want %d:\n%s", got, 5+3, output)
+	}
+}
+
+func TestQueryOptions(t *testing.T) {
+	sh := newTestShell(t)
+
+	output := run(t, sh, "query --explain SELECT * FROM resources WHERE path = '/tmp'")
+	if !strings.Contains(output, "SEARCH") && !strings.Contains(output, "SCAN") {
+		t.Errorf("query --explain does not show a plan:\n%s", output)
+	}
+	output = run(t, sh, "query --timing SELECT 1 AS one")
+	if !strings.Contains(output, "one") || !strings.Contains(output, "(took ") {
+		t.Errorf("query --timing =\n%s", output)
+	}
+	if _, err := runErr(sh, "query --verbose SELECT 1"); err == nil {
+		t.Error("query with an unknown option succeeded")
+	}
+	if _, err := runErr(sh, "query --timing"); err == nil {
+		t.Error("query with only options succeeded")
+	}
+}
+
+func TestQueryKeepsLiteralSpacing(t *testing.T) {
+	sh := newTestShell(t)
+	output := run(t, sh, "query SELECT 'a   b' AS s")
+	if !strings.Contains(output, "a   b") {
+		t.Errorf("query collapsed the spacing of a literal:\n%s", output)
+	}
+}
+
+func TestTimingSetting(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "set timing on")
+	if output := run(t, sh, "mkdir /x"); !strings.HasSuffix(strings.TrimRight(output, "\n"), ")") || !strings.Contains(output, "\n(took ") {
+		t.Errorf("mkdir with timing on = %q", output)
+	}
+	if output := run(t, sh, "query SELECT 1 AS one"); strings.Count(output, "(took ") != 1 {
+		t.Errorf("query with timing on is not timed once:\n%s", output)
+	}
+	if output := run(t, sh, "set timing off"); output != "" {
+		t.Errorf("set timing off = %q", output)
+	}
+	if output := run(t, sh, "mkdir /y"); strings.Contains(output, "(took ") {
+		t.Errorf("mkdir with timing off = %q", output)
+	}
+}
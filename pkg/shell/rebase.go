@@ -0,0 +1,599 @@
+package shell
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	pathpkg "path"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// pendingRebase is a rebase stopped on conflicts, waiting for --continue
+// or --abort
+type pendingRebase struct {
+	Branch string
+	Onto   string
+
+	// Marked holds the conflicting files that were written to the branch
+	// with conflict markers. On --continue the branch's version of each is
+	// taken once its markers have been removed.
+	Marked map[string]bool
+
+	// Before holds the branch's version of each marked file from before the
+	// rebase wrote it, for --abort to restore
+	Before snapshot
+}
+
+// snapshotEntry is one resource in a branch snapshot
+type snapshotEntry struct {
+	ID       string
+	Type     string
+	Content  []byte
+	Metadata string
+}
+
+// snapshot maps paths to the resources present on a branch at some time
+type snapshot map[string]*snapshotEntry
+
+// sameResource reports whether two snapshot entries hold the same resource
+// state. Either may be nil for an absent path. How content is stored is not
+// part of the state: versions whose content is compressed differently or
+// kept in different content stores are the same when their checksums and
+// the rest of their metadata match.
+func sameResource(a, b *snapshotEntry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Type != b.Type {
+		return false
+	}
+	if a.Metadata == b.Metadata && bytes.Equal(a.Content, b.Content) {
+		return true
+	}
+
+	am, err := schema.NormalizeMetadata(json.RawMessage(a.Metadata))
+	if err != nil {
+		return false
+	}
+	bm, err := schema.NormalizeMetadata(json.RawMessage(b.Metadata))
+	if err != nil {
+		return false
+	}
+	if am.Checksum == "" || am.Checksum != bm.Checksum {
+		return false
+	}
+	am.Encoding, am.Store, am.Chunks = "", "", nil
+	bm.Encoding, bm.Store, bm.Chunks = "", "", nil
+	return reflect.DeepEqual(am, bm)
+}
+
+// RebaseBranch replays the changes made on the current branch since its
+// merge base with another branch on top of that branch's current state
+// (branch rebase <onto> | --continue [--ours] | --abort). A path changed
+// differently on both sides is a conflict: the rebase stops without
+// applying anything until it is continued or aborted. Conflicting text
+// files are merged line by line and written to the branch, with the lines
+// both sides changed between conflict markers, for the user to resolve.
+func (s *Shell) RebaseBranch(args []string) error {
+	flags, args, err := splitFlags("branch rebase", args, "--abort", "--continue", "--ours")
+	if err != nil {
+		return err
+	}
+
+	if flags["--abort"] {
+		if s.rebase == nil {
+			return fmt.Errorf("no rebase in progress")
+		}
+		if s.CurrentTransaction() != nil {
+			return fmt.Errorf("cannot abort a rebase while a transaction is in progress")
+		}
+		restored, err := s.restoreBeforeRebase()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rebase of %s onto %s aborted\n", s.rebase.Branch, s.rebase.Onto)
+		if restored > 0 {
+			fmt.Printf("Restored %d file(s) written with conflict markers\n", restored)
+		}
+		s.rebase = nil
+		return nil
+	}
+
+	var onto string
+	marked := make(map[string]bool)
+	before := make(snapshot)
+	if flags["--continue"] {
+		if s.rebase == nil {
+			return fmt.Errorf("no rebase in progress")
+		}
+		if s.rebase.Branch != s.state.CurrentBranch {
+			return fmt.Errorf("rebase in progress on branch %s; switch to it to continue", s.rebase.Branch)
+		}
+		onto = s.rebase.Onto
+		for path := range s.rebase.Marked {
+			marked[path] = true
+		}
+		for path, entry := range s.rebase.Before {
+			before[path] = entry
+		}
+	} else {
+		if len(args) == 0 {
+			return fmt.Errorf("usage: branch rebase <onto> | --continue [--ours] | --abort")
+		}
+		if s.rebase != nil {
+			return fmt.Errorf("rebase of %s onto %s in progress; use --continue or --abort", s.rebase.Branch, s.rebase.Onto)
+		}
+		onto = args[0]
+	}
+
+	branch := s.state.CurrentBranch
+	if onto == branch {
+		return fmt.Errorf("cannot rebase a branch onto itself")
+	}
+	if s.CurrentTransaction() != nil {
+		return fmt.Errorf("cannot rebase while a transaction is in progress")
+	}
+	exists, err := s.branchExists(s.queries(), onto)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("branch %w: %s", database.ErrNotFound, onto)
+	}
+
+	var created, updated, deleted []string
+	var conflicts []string
+	var merged map[string]int
+	var ours snapshot
+	err = s.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID(branch)
+		tx.SetUserID(s.state.User)
+
+		baseBranch, baseTime, err := mergeBase(tx, branch, onto)
+		if err != nil {
+			return err
+		}
+
+		base, err := loadSnapshot(tx, baseBranch, &baseTime)
+		if err != nil {
+			return err
+		}
+		ours, err = loadSnapshot(tx, branch, nil)
+		if err != nil {
+			return err
+		}
+		theirs, err := loadSnapshot(tx, onto, nil)
+		if err != nil {
+			return err
+		}
+
+		// The result is their state with our changes laid over it
+		result := make(snapshot, len(theirs))
+		for path, entry := range theirs {
+			result[path] = entry
+		}
+		conflicts, merged = nil, nil
+		for _, path := range unionPaths(base, ours, theirs) {
+			ourChange := !sameResource(ours[path], base[path])
+			theirChange := !sameResource(theirs[path], base[path])
+			if !ourChange {
+				continue
+			}
+			if theirChange && !sameResource(ours[path], theirs[path]) {
+				if marked[path] {
+					if err := s.checkResolved(tx, branch, path, ours[path]); err != nil {
+						return err
+					}
+				} else if !flags["--ours"] {
+					conflicts = append(conflicts, path)
+					continue
+				}
+			}
+			if ours[path] == nil {
+				delete(result, path)
+			} else {
+				result[path] = ours[path]
+			}
+		}
+		if len(conflicts) > 0 {
+			// Only the conflicting files are written; the rest is applied
+			// once the conflicts are resolved
+			merged, err = s.writeConflicts(tx, branch, onto, conflicts, base, ours, theirs)
+			if err != nil {
+				return err
+			}
+			if len(merged) == 0 {
+				return errRebaseConflict
+			}
+			return nil
+		}
+
+		now := time.Now()
+		created, updated, deleted, err = s.applySnapshot(tx, branch, ours, result, now)
+		if err != nil {
+			return err
+		}
+
+		for kind, ids := range map[string][]string{
+			schema.OperationKindCreate: created,
+			schema.OperationKindUpdate: updated,
+			schema.OperationKindDelete: deleted,
+		} {
+			if len(ids) == 0 {
+				continue
+			}
+			if err := s.recordOperation(tx, kind, ids); err != nil {
+				return err
+			}
+		}
+
+		// Move the branch point so the next merge base is this rebase
+		_, err = tx.Execute(`
+			INSERT INTO transactions (id, start_time, end_time, status, user_id, branch_id)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, tx.GetID(), now, now, schema.TransactionStatusCommitted, s.state.User, onto)
+		if err != nil {
+			return fmt.Errorf("failed to record rebase point: %w", err)
+		}
+		_, err = tx.Execute(`UPDATE branches SET base_state_id = ? WHERE name = ?`, tx.GetID(), branch)
+		if err != nil {
+			return fmt.Errorf("failed to update branch base: %w", err)
+		}
+		return nil
+	})
+
+	if err == errRebaseConflict || (err == nil && len(conflicts) > 0) {
+		for path := range merged {
+			marked[path] = true
+			if _, ok := before[path]; !ok {
+				before[path] = ours[path]
+			}
+		}
+		s.rebase = &pendingRebase{Branch: branch, Onto: onto, Marked: marked, Before: before}
+		for _, path := range conflicts {
+			n, ok := merged[path]
+			switch {
+			case !ok:
+				fmt.Printf("conflict: %s changed on both %s and %s\n", path, branch, onto)
+			case n == 0:
+				fmt.Printf("conflict: %s changed on both %s and %s; merged without conflicting lines\n", path, branch, onto)
+			default:
+				fmt.Printf("conflict: %s changed on both %s and %s; %d conflict(s) marked in the file\n", path, branch, onto, n)
+			}
+		}
+		fmt.Printf("Rebase stopped with %d conflict(s). Resolve them on %s and run 'branch rebase --continue',\n", len(conflicts), branch)
+		fmt.Println("keep this branch's versions with 'branch rebase --continue --ours', or cancel with 'branch rebase --abort'")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.rebase = nil
+	fmt.Printf("Rebased %s onto %s (%d created, %d updated, %d deleted)\n", branch, onto, len(created), len(updated), len(deleted))
+	return nil
+}
+
+// errRebaseConflict rolls back a rebase that found conflicts
+var errRebaseConflict = fmt.Errorf("rebase conflict")
+
+// restoreBeforeRebase writes back, in one transaction, the versions the
+// stopped rebase's files had before it wrote them with conflict markers,
+// returning how many needed restoring
+func (s *Shell) restoreBeforeRebase() (int, error) {
+	if len(s.rebase.Before) == 0 {
+		return 0, nil
+	}
+
+	branch := s.rebase.Branch
+	var restored []string
+	err := s.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID(branch)
+		tx.SetUserID(s.state.User)
+
+		current, err := loadSnapshot(tx, branch, nil)
+		if err != nil {
+			return err
+		}
+		target := make(snapshot, len(current))
+		for path, entry := range current {
+			target[path] = entry
+		}
+		for path, entry := range s.rebase.Before {
+			target[path] = entry
+		}
+
+		created, updated, _, err := s.applySnapshot(tx, branch, current, target, time.Now())
+		if err != nil {
+			return err
+		}
+		restored = append(created, updated...)
+		if len(restored) == 0 {
+			return nil
+		}
+		return s.recordOperation(tx, schema.OperationKindUpdate, restored)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore files from before the rebase: %w", err)
+	}
+	return len(restored), nil
+}
+
+// writeConflicts merges each conflicting path that is a text file on both
+// branches and writes the result to the branch as a new version, with the
+// lines changed on both sides between conflict markers. It returns the
+// number of conflicts marked in each file written.
+func (s *Shell) writeConflicts(tx *database.Transaction, branch, onto string, paths []string, base, ours, theirs snapshot) (map[string]int, error) {
+	merged := make(map[string]int)
+	var ids []string
+	for _, path := range paths {
+		if !isFileEntry(ours[path]) || !isFileEntry(theirs[path]) || (base[path] != nil && !isFileEntry(base[path])) {
+			continue
+		}
+
+		ourContent, err := s.snapshotContent(tx, ours[path])
+		if err != nil {
+			return nil, err
+		}
+		theirContent, err := s.snapshotContent(tx, theirs[path])
+		if err != nil {
+			return nil, err
+		}
+		var baseContent []byte
+		if base[path] != nil {
+			if baseContent, err = s.snapshotContent(tx, base[path]); err != nil {
+				return nil, err
+			}
+		}
+		if util.IsBinary(ourContent) || util.IsBinary(theirContent) || util.IsBinary(baseContent) {
+			continue
+		}
+
+		content, n, err := util.Merge3(baseContent, ourContent, theirContent, "base", branch, onto)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge %s: %w", path, err)
+		}
+		file, err := s.fm.UpdateFile(path, content, tx)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, file.ID)
+		merged[path] = n
+	}
+
+	if len(ids) > 0 {
+		if err := s.recordOperation(tx, schema.OperationKindUpdate, ids); err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
+
+// checkResolved refuses to take a file written with conflict markers until
+// the markers have been removed
+func (s *Shell) checkResolved(tx *database.Transaction, branch, path string, entry *snapshotEntry) error {
+	if !isFileEntry(entry) {
+		return nil
+	}
+	content, err := s.snapshotContent(tx, entry)
+	if err != nil {
+		return err
+	}
+	if util.HasConflictMarkers(content) {
+		return fmt.Errorf("%s on %s still has conflict markers; resolve them before continuing", path, branch)
+	}
+	return nil
+}
+
+// isFileEntry reports whether a snapshot entry is a file
+func isFileEntry(entry *snapshotEntry) bool {
+	return entry != nil && entry.Type == schema.ResourceTypeFile
+}
+
+// snapshotContent returns the decoded content of a file in a snapshot
+func (s *Shell) snapshotContent(tx *database.Transaction, entry *snapshotEntry) ([]byte, error) {
+	var metadata schema.ResourceMetadata
+	if entry.Metadata != "" {
+		var err error
+		if metadata, err = schema.NormalizeMetadata(json.RawMessage(entry.Metadata)); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+	return s.fm.LoadContent(entry.Content, metadata, tx)
+}
+
+// mergeBase returns the branch and time of the most recent state shared by
+// two branches. Each branch's history is its own rows followed by those of
+// the branch it was created from, up to the branch point.
+func mergeBase(q queryExecutor, a, b string) (string, time.Time, error) {
+	chainA, err := branchAncestry(q, a)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	chainB, err := branchAncestry(q, b)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	for _, ancestorA := range chainA {
+		for _, ancestorB := range chainB {
+			if ancestorA.Branch != ancestorB.Branch {
+				continue
+			}
+			// A zero time means the branch's current state
+			switch {
+			case ancestorA.Until.IsZero():
+				return ancestorA.Branch, ancestorB.Until, nil
+			case ancestorB.Until.IsZero() || ancestorA.Until.Before(ancestorB.Until):
+				return ancestorA.Branch, ancestorA.Until, nil
+			default:
+				return ancestorA.Branch, ancestorB.Until, nil
+			}
+		}
+	}
+
+	return "", time.Time{}, fmt.Errorf("branches %s and %s have no common history", a, b)
+}
+
+// branchAncestor is a branch whose history is shared up to Until
+type branchAncestor struct {
+	Branch string
+	Until  time.Time
+}
+
+// branchAncestry returns a branch followed by the branches it descends from,
+// each with the time at which the history below it was branched off
+func branchAncestry(q queryExecutor, name string) ([]branchAncestor, error) {
+	chain := []branchAncestor{{Branch: name}}
+	seen := map[string]bool{name: true}
+
+	for current := name; ; {
+		rows, err := q.ExecuteQuery(`
+			SELECT t.branch_id, t.start_time
+			FROM branches b JOIN transactions t ON t.id = b.base_state_id
+			WHERE b.name = ?
+		`, current)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up branch point of %s: %w", current, err)
+		}
+		var parent string
+		var forkedAt time.Time
+		found := rows.Next()
+		if found {
+			err = rows.Scan(&parent, &forkedAt)
+		}
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan branch point of %s: %w", current, err)
+		}
+		if !found || seen[parent] {
+			return chain, nil
+		}
+
+		chain = append(chain, branchAncestor{Branch: parent, Until: forkedAt})
+		seen[parent] = true
+		current = parent
+	}
+}
+
+// loadSnapshot reads the resources on a branch as of the given time, or the
+// current ones when at is nil
+func loadSnapshot(q queryExecutor, branch string, at *time.Time) (snapshot, error) {
+	query := `SELECT id, type, path, content, metadata FROM resources WHERE branch_id = ?`
+	args := []interface{}{branch}
+	if at != nil {
+		query += ` AND valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)`
+		args = append(args, *at, *at)
+	} else {
+		query += ` AND valid_to IS NULL`
+	}
+
+	rows, err := q.ExecuteQuery(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read branch %s: %w", branch, err)
+	}
+	defer rows.Close()
+
+	snap := make(snapshot)
+	for rows.Next() {
+		var entry snapshotEntry
+		var path string
+		if err := rows.Scan(&entry.ID, &entry.Type, &path, &entry.Content, &entry.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to scan resource: %w", err)
+		}
+		snap[path] = &entry
+	}
+	return snap, rows.Err()
+}
+
+// unionPaths returns every path present in any of the snapshots, sorted
+func unionPaths(snapshots ...snapshot) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, snap := range snapshots {
+		for path := range snap {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// applySnapshot writes new versions on branch so that its current state,
+// given as current, becomes target. It returns the IDs of the created and
+// updated versions and of the closed versions of deleted resources.
+func (s *Shell) applySnapshot(tx *database.Transaction, branch string, current, target snapshot, now time.Time) (created, updated, deleted []string, err error) {
+	// Parents are written before their children
+	paths := unionPaths(current, target)
+	sort.SliceStable(paths, func(i, j int) bool {
+		return pathDepth(paths[i]) < pathDepth(paths[j])
+	})
+
+	dirIDs := make(map[string]string)
+	for path, entry := range current {
+		if entry.Type == schema.ResourceTypeDirectory {
+			dirIDs[path] = entry.ID
+		}
+	}
+
+	for _, path := range paths {
+		have, want := current[path], target[path]
+		if sameResource(have, want) {
+			continue
+		}
+
+		if have != nil {
+			_, err := tx.Execute(`UPDATE resources SET valid_to = ? WHERE id = ? AND valid_to IS NULL`, now, have.ID)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to close old version of %s: %w", path, err)
+			}
+			delete(dirIDs, path)
+			if want == nil {
+				deleted = append(deleted, have.ID)
+				continue
+			}
+		}
+
+		var parentID interface{}
+		if path != "/" {
+			id, ok := dirIDs[pathpkg.Dir(path)]
+			if !ok {
+				return nil, nil, nil, fmt.Errorf("cannot write %s: parent directory is missing", path)
+			}
+			parentID = id
+		}
+
+		newID, err := newResourceID(tx, want.Type)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		_, err = tx.Execute(`
+			INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id, branch_id)
+			SELECT ?, type, name, ?, path, content, metadata, ?, ?, ?
+			FROM resources WHERE id = ?
+		`, newID, parentID, now, tx.GetID(), branch, want.ID)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+
+		if want.Type == schema.ResourceTypeDirectory {
+			dirIDs[path] = newID
+		}
+		if have == nil {
+			created = append(created, newID)
+		} else {
+			updated = append(updated, newID)
+		}
+	}
+
+	return created, updated, deleted, nil
+}
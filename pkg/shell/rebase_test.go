@@ -0,0 +1,59 @@
+package shell
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// fileEntry returns a snapshot entry for a file with the given content
+// column and metadata
+func fileEntry(t *testing.T, content string, metadata schema.ResourceMetadata) *snapshotEntry {
+	t.Helper()
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &snapshotEntry{Type: schema.ResourceTypeFile, Content: []byte(content), Metadata: string(raw)}
+}
+
+func TestSameResourceIgnoresStorage(t *testing.T) {
+	metadata := schema.ResourceMetadata{Owner: "system", ModifiedAt: time.Unix(1700000000, 0).UTC(), Size: 5, Checksum: "0123456789abcdef"}
+	inline := fileEntry(t, "hello", metadata)
+
+	compressed := metadata
+	compressed.Encoding = schema.ContentEncodingGzip
+	if !sameResource(inline, fileEntry(t, "\x1f\x8b...", compressed)) {
+		t.Error("a compressed copy differs from the original")
+	}
+
+	stored := metadata
+	stored.Store = "inline"
+	if !sameResource(inline, fileEntry(t, "", stored)) {
+		t.Error("a copy kept in a content store differs from the original")
+	}
+
+	changed := metadata
+	changed.Checksum = "fedcba9876543210"
+	if sameResource(inline, fileEntry(t, "", changed)) {
+		t.Error("versions with different checksums are the same")
+	}
+
+	chmod := metadata
+	chmod.Permissions = 0o600
+	if sameResource(inline, fileEntry(t, "hello", chmod)) {
+		t.Error("versions with different permissions are the same")
+	}
+}
+
+func TestSameResourceAbsent(t *testing.T) {
+	entry := &snapshotEntry{Type: schema.ResourceTypeDirectory, Metadata: "{}"}
+	if !sameResource(nil, nil) {
+		t.Error("two absent paths differ")
+	}
+	if sameResource(entry, nil) || sameResource(nil, entry) {
+		t.Error("an absent path is the same as a present one")
+	}
+}
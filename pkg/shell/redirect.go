@@ -0,0 +1,83 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// splitRedirect separates a trailing "> file" or ">> file" from the words
+// of a command line. The redirect must be the last two words, and a quoted
+// ">" is not one. When pathsOnly is set, as for query where ">" is also a
+// comparison, a trailing "> x" is only a redirect if x is not quoted and
+// contains a slash.
+func splitRedirect(words []word, pathsOnly bool) ([]word, string, bool, error) {
+	for i, w := range words {
+		if w.quoted || (w.text != ">" && w.text != ">>") {
+			continue
+		}
+		if pathsOnly && (i != len(words)-2 || !strings.Contains(words[i+1].text, "/") || words[i+1].quoted) {
+			continue
+		}
+		if i != len(words)-2 {
+			return nil, "", false, fmt.Errorf("%s must be followed by exactly one file at the end of the command", w.text)
+		}
+		return words[:i], words[i+1].text, w.text == ">>", nil
+	}
+	return words, "", false, nil
+}
+
+// runRedirected runs a command with its standard output captured and writes
+// the output to a file, replacing or appending to its content. Nothing is
+// written if the command fails.
+func (s *Shell) runRedirected(cmd string, args []string, target string, appendMode bool) error {
+	output, err := captureOutput(func() error {
+		return s.runCommand(cmd, args)
+	})
+	if err != nil {
+		return err
+	}
+	return s.writeContent(target, output, appendMode, "")
+}
+
+// captureOutput runs fn with os.Stdout redirected into a buffer and returns
+// what it printed. os.Stdout is restored even if fn panics.
+func captureOutput(fn func() error) (output []byte, err error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture output: %w", err)
+	}
+
+	var buf bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&buf, r)
+		r.Close()
+		close(done)
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = stdout
+		w.Close()
+		<-done
+		output = buf.Bytes()
+	}()
+
+	return nil, fn()
+}
+
+// rawArgs returns a command's arguments as typed, with their quotes,
+// spacing and tabs intact. args are the words of the current command after
+// its name; words dropped from their end, such as a redirect, are dropped
+// from the text too.
+func (s *Shell) rawArgs(args []string) string {
+	words, err := splitWords(s.currentCommand)
+	if err != nil || len(args) == 0 || len(words) <= len(args) {
+		return ""
+	}
+	return s.currentCommand[words[1].start:words[len(args)].end]
+}
@@ -0,0 +1,75 @@
+package shell
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// splitLine splits a command line into words for splitRedirect
+func splitLine(t *testing.T, line string) []word {
+	t.Helper()
+	words, err := splitWords(line)
+	if err != nil {
+		t.Fatalf("%q: %v", line, err)
+	}
+	return words
+}
+
+func TestSplitRedirect(t *testing.T) {
+	tests := []struct {
+		line       string
+		pathsOnly  bool
+		rest       []string
+		target     string
+		appendMode bool
+	}{
+		{"ls / > /out", false, []string{"ls", "/"}, "/out", false},
+		{"ls / >> /out", false, []string{"ls", "/"}, "/out", true},
+		{"ls /", false, []string{"ls", "/"}, "", false},
+		{"echo 'a > b'", false, []string{"echo", "a > b"}, "", false},
+		{"echo a '>' b", false, []string{"echo", "a", ">", "b"}, "", false},
+		{`echo "a b" > '/my file'`, false, []string{"echo", "a b"}, "/my file", false},
+		{"query SELECT 1 > /out", true, []string{"query", "SELECT", "1"}, "/out", false},
+		{"query SELECT * FROM t WHERE size > 10", true, strings.Fields("query SELECT * FROM t WHERE size > 10"), "", false},
+		{"query SELECT path FROM resources WHERE path > '/home'", true, strings.Fields("query SELECT path FROM resources WHERE path > /home"), "", false},
+		{"query SELECT 'x > /y'", true, []string{"query", "SELECT", "x > /y"}, "", false},
+	}
+	for _, tt := range tests {
+		rest, target, appendMode, err := splitRedirect(splitLine(t, tt.line), tt.pathsOnly)
+		if err != nil {
+			t.Errorf("%q: %v", tt.line, err)
+			continue
+		}
+		if got := wordTexts(rest); !reflect.DeepEqual(got, tt.rest) || target != tt.target || appendMode != tt.appendMode {
+			t.Errorf("%q: got %q, %q, %v; want %q, %q, %v", tt.line, got, target, appendMode, tt.rest, tt.target, tt.appendMode)
+		}
+	}
+}
+
+func TestSplitRedirectMisplaced(t *testing.T) {
+	if _, _, _, err := splitRedirect(splitLine(t, "ls > /out /"), false); err == nil {
+		t.Error("a redirect before the last word was accepted")
+	}
+}
+
+func TestQueryComparesWithPath(t *testing.T) {
+	sh := newTestShell(t)
+	output := run(t, sh, "query SELECT COUNT(*) AS n FROM resources WHERE path > '/home'")
+	if !strings.Contains(output, "1 row(s) returned") {
+		t.Errorf("query was not run as written:\n%s", output)
+	}
+}
+
+func TestCaptureOutputRestoresStdoutOnPanic(t *testing.T) {
+	stdout := os.Stdout
+	func() {
+		defer func() { recover() }()
+		captureOutput(func() error { panic("boom") })
+	}()
+	if os.Stdout != stdout {
+		os.Stdout = stdout
+		t.Fatal("os.Stdout was left redirected")
+	}
+}
@@ -0,0 +1,90 @@
+package shell
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// RefreshMetadata recomputes the size and checksum of every file under a
+// path on the current branch from its stored content, and writes a new
+// version of each file whose metadata was wrong (refresh-meta [path]). The
+// path defaults to the current directory. Files that are already correct
+// are left alone, so running it again changes nothing.
+func (s *Shell) RefreshMetadata(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: refresh-meta [path]")
+	}
+	target := s.state.CurrentDirectory
+	if len(args) == 1 {
+		target = args[0]
+	}
+	path, err := s.resolveLocalPath(target)
+	if err != nil {
+		return err
+	}
+
+	options, err := branchOptions(s.state.CurrentBranch, nil)
+	if err != nil {
+		return err
+	}
+
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		root, err := s.lookupResource(tx, path)
+		if err != nil {
+			return err
+		}
+
+		var paths []string
+		if root.Type == schema.ResourceTypeFile {
+			paths = append(paths, root.Path)
+		} else if root.Type == schema.ResourceTypeDirectory {
+			resources, err := s.fm.ListSubtree(root.Path, tx, options)
+			if err != nil {
+				return err
+			}
+			for _, resource := range resources {
+				if resource.Type == schema.ResourceTypeFile {
+					paths = append(paths, resource.Path)
+				}
+			}
+		}
+
+		var changed []string
+		now := time.Now()
+		for _, path := range paths {
+			file, err := s.fm.GetFile(path, tx, options)
+			if err != nil {
+				return err
+			}
+
+			size := int64(len(file.Content))
+			checksum := util.CalculateChecksum(file.Content)
+			if file.Metadata.Size == size && file.Metadata.Checksum == checksum {
+				continue
+			}
+
+			metadata := file.Metadata
+			metadata.Size = size
+			metadata.Checksum = checksum
+			newID, err := writeMetadataVersion(tx, file.ID, schema.ResourceTypeFile, metadata, now)
+			if err != nil {
+				return fmt.Errorf("failed to update %s: %w", path, err)
+			}
+			changed = append(changed, newID)
+			out.Printf("refreshed: %s\n", path)
+		}
+
+		if len(changed) > 0 {
+			if err := s.recordOperation(tx, schema.OperationKindUpdate, changed); err != nil {
+				return err
+			}
+		}
+
+		out.Printf("refresh-meta: checked %d file(s), corrected %d\n", len(paths), len(changed))
+		return nil
+	})
+}
@@ -0,0 +1,44 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+func TestRefreshMetadata(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /d")
+	run(t, sh, "echo right > /d/right")
+	run(t, sh, "echo wrong > /d/wrong")
+	err := sh.db.WithTransaction(func(tx *database.Transaction) error {
+		_, err := tx.Execute(`UPDATE resources SET metadata = json_set(metadata, '$.size', 1, '$.checksum', 'bad') WHERE path = '/d/wrong'`)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := run(t, sh, "refresh-meta /d")
+	if output != "refreshed: /d/wrong\nrefresh-meta: checked 2 file(s), corrected 1\n" {
+		t.Errorf("refresh-meta = %q", output)
+	}
+	if metadata := metadataOf(t, sh, "/d/wrong"); metadata.Size != 6 || metadata.Checksum == "bad" {
+		t.Errorf("metadata after refresh = %+v", metadata)
+	}
+
+	// Running it again changes nothing
+	run(t, sh, "cd /d")
+	if output := run(t, sh, "refresh-meta"); !strings.HasSuffix(output, "corrected 0\n") {
+		t.Errorf("second refresh-meta = %q", output)
+	}
+	if output := run(t, sh, "refresh-meta right"); output != "refresh-meta: checked 1 file(s), corrected 0\n" {
+		t.Errorf("refresh-meta of a file = %q", output)
+	}
+	for _, command := range []string{"refresh-meta /missing", "refresh-meta /a /b"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// replayStep is one recorded command of a transaction together with the
+// kinds of change it made and the paths it touched
+type replayStep struct {
+	command string
+	kind    string
+	paths   []string
+}
+
+// ReplayTransaction re-runs the recorded commands of a past transaction in a
+// new transaction on the current branch or on the branch given with --onto
+// (replay <transactionID> [--onto <branch>]). Before each command runs, the
+// paths it created must not exist yet and the paths it changed must exist.
+// If any command or precondition fails, nothing is applied.
+func (s *Shell) ReplayTransaction(args []string) error {
+	var txID, onto string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--onto":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--onto requires a branch")
+			}
+			i++
+			onto = args[i]
+		case txID == "" && !strings.HasPrefix(args[i], "-"):
+			txID = args[i]
+		default:
+			return fmt.Errorf("usage: replay <transactionID> [--onto <branch>]")
+		}
+	}
+	if txID == "" {
+		return fmt.Errorf("usage: replay <transactionID> [--onto <branch>]")
+	}
+	if s.CurrentTransaction() != nil {
+		return fmt.Errorf("replay: commit or abort the current transaction first")
+	}
+
+	target := s.state.CurrentBranch
+	if onto != "" {
+		exists, err := s.branchExists(s.queries(), onto)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("branch %w: %s", database.ErrNotFound, onto)
+		}
+		target = onto
+	}
+
+	txID, err := s.resolveTransactionID(txID)
+	if err != nil {
+		return err
+	}
+	steps, err := s.loadReplaySteps(txID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	tx.SetBranchID(target)
+	tx.SetUserID(s.state.User)
+
+	// The recorded commands run through ProcessCommand against the target
+	// branch and share the replay transaction
+	branch := s.state.CurrentBranch
+	s.state.CurrentBranch = target
+	s.setTransaction(tx)
+	defer func() {
+		s.state.CurrentBranch = branch
+		s.setTransaction(nil)
+	}()
+
+	for _, step := range steps {
+		if err := s.checkReplayPreconditions(step); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("replay: %s: %w", step.command, err)
+		}
+		if err := s.ProcessCommand(step.command); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("replay: %s: %w", step.command, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	fmt.Printf("Replayed %d commands from %s onto %s as %s\n", len(steps), txLabel(txID), target, txLabel(tx.GetID()))
+	return nil
+}
+
+// resolveTransactionID expands a transaction ID, as printed by begin, commit
+// and transactions, or the last digits of one, to the full ID recorded in
+// the audit log. IDs are derived from the clock, so their leading digits are
+// shared by every transaction made around the same time and only the
+// trailing digits tell them apart.
+func (s *Shell) resolveTransactionID(arg string) (string, error) {
+	id := strings.TrimPrefix(arg, "T")
+	if id == "" || strings.Trim(id, "0123456789") != "" {
+		return "", fmt.Errorf("invalid transaction ID: %s", arg)
+	}
+
+	rows, err := s.queries().ExecuteQuery(`
+		SELECT DISTINCT transaction_id FROM operations
+		WHERE transaction_id = ? OR transaction_id LIKE ?
+	`, id, "%"+id)
+	if err != nil {
+		return "", fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var match string
+		if err := rows.Scan(&match); err != nil {
+			return "", fmt.Errorf("failed to scan operation: %w", err)
+		}
+		if match == id {
+			return match, nil
+		}
+		ids = append(ids, match)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("no recorded operations for transaction %s", txLabel(id))
+	case 1:
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("transaction ID %s is ambiguous: it ends %d recorded transactions", txLabel(id), len(ids))
+	}
+}
+
+// loadReplaySteps reads the operations recorded by a transaction in the order
+// they ran. Consecutive operations with the same command text were recorded
+// by a single command and become one step.
+func (s *Shell) loadReplaySteps(txID string) ([]replayStep, error) {
+	rows, err := s.queries().ExecuteQuery(`
+		SELECT command_text, kind, affected_resources FROM operations
+		WHERE transaction_id = ? ORDER BY timestamp
+	`, txID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	type operation struct {
+		command, kind string
+		ids           []string
+	}
+	var operations []operation
+	for rows.Next() {
+		var op operation
+		var affected string
+		if err := rows.Scan(&op.command, &op.kind, &affected); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan operation: %w", err)
+		}
+		if err := json.Unmarshal([]byte(affected), &op.ids); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to unmarshal affected resources: %w", err)
+		}
+		operations = append(operations, op)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []replayStep
+	for _, op := range operations {
+		if op.command == "" {
+			return nil, fmt.Errorf("transaction has an operation without a recorded command")
+		}
+		paths, err := s.resourcePaths(op.ids)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(steps); n > 0 && steps[n-1].command == op.command {
+			steps[n-1].paths = append(steps[n-1].paths, paths...)
+			continue
+		}
+		steps = append(steps, replayStep{command: op.command, kind: op.kind, paths: paths})
+	}
+	return steps, nil
+}
+
+// resourcePaths returns the paths of the given resource versions
+func (s *Shell) resourcePaths(ids []string) ([]string, error) {
+	var paths []string
+	for _, id := range ids {
+		rows, err := s.queries().ExecuteQuery(`SELECT path FROM resources WHERE id = ?`, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up resource: %w", err)
+		}
+		if rows.Next() {
+			var path string
+			if err := rows.Scan(&path); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan resource: %w", err)
+			}
+			paths = append(paths, path)
+		}
+		rows.Close()
+	}
+	return paths, nil
+}
+
+// checkReplayPreconditions verifies that a step can be applied to the
+// current branch: paths it created or moved into must be free, and paths it
+// changed must exist
+func (s *Shell) checkReplayPreconditions(step replayStep) error {
+	for _, path := range step.paths {
+		_, err := s.lookupResource(s.CurrentTransaction(), path)
+		switch step.kind {
+		case schema.OperationKindCreate, schema.OperationKindMove:
+			if err == nil {
+				return fmt.Errorf("resource %w: %s", database.ErrAlreadyExists, path)
+			}
+		default:
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+// replaySource creates branch feature, then a transaction on main that
+// creates /r and /r/f, and returns the transaction's label as printed by
+// begin
+func replaySource(t *testing.T, sh *Shell) string {
+	t.Helper()
+	run(t, sh, "branch feature")
+	label := strings.Fields(run(t, sh, "begin"))[1]
+	run(t, sh, "mkdir /r")
+	run(t, sh, "touch /r/f")
+	run(t, sh, "commit")
+	return label
+}
+
+func TestReplayByPrintedID(t *testing.T) {
+	sh := newTestShell(t)
+	// Another transaction started just before, sharing the ID's leading digits
+	run(t, sh, "mkdir /other")
+	label := replaySource(t, sh)
+
+	run(t, sh, "replay "+label+" --onto feature")
+
+	sh.state.CurrentBranch = "feature"
+	if _, err := sh.lookupResource(sh.db, "/r/f"); err != nil {
+		t.Errorf("/r/f on feature: %v", err)
+	}
+}
+
+func TestReplayBySuffix(t *testing.T) {
+	sh := newTestShell(t)
+	label := replaySource(t, sh)
+
+	run(t, sh, "replay "+label[len(label)-6:]+" --onto feature")
+
+	sh.state.CurrentBranch = "feature"
+	if _, err := sh.lookupResource(sh.db, "/r"); err != nil {
+		t.Errorf("/r on feature: %v", err)
+	}
+}
+
+func TestReplayRejectsUnknownIDs(t *testing.T) {
+	sh := newTestShell(t)
+	replaySource(t, sh)
+
+	for _, arg := range []string{"T123456789012345678901", "abc", "T", "1%"} {
+		if _, err := runErr(sh, "replay "+arg+" --onto feature"); err == nil {
+			t.Errorf("replay %s succeeded", arg)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	pathpkg "path"
+	"strconv"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// RestoreResource makes a historical version of a resource current again
+// (restore <path> --version <n> | --at <time>). Versions are numbered as in
+// history. The restored version gets the old content and metadata with a
+// new modification time, and the versions in between stay in history. A
+// resource that has since been deleted is recreated.
+func (s *Shell) RestoreResource(args []string) error {
+	var target string
+	var number int
+	var at *time.Time
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--version" && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid version: %s", args[i+1])
+			}
+			number = n
+			i++
+		case args[i] == "--at" && i+1 < len(args):
+			t, err := util.ParseTimeSpec(args[i+1])
+			if err != nil {
+				return err
+			}
+			at = &t
+			i++
+		case target == "":
+			target = args[i]
+		default:
+			return fmt.Errorf("usage: restore <path> --version <n> | --at <time>")
+		}
+	}
+	if target == "" || (number == 0) == (at == nil) {
+		return fmt.Errorf("usage: restore <path> --version <n> | --at <time>")
+	}
+
+	path, err := s.resolveLocalPath(target)
+	if err != nil {
+		return err
+	}
+
+	var restored resourceVersion
+	err = s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		versions, err := s.resourceVersions(tx, path)
+		if err != nil {
+			return err
+		}
+		version, err := pickVersion(versions, number, at)
+		if err != nil {
+			return fmt.Errorf("restore: %s: %w", path, err)
+		}
+		restored = *version
+
+		if err := s.fm.CheckLock(path, tx); err != nil {
+			return err
+		}
+		id, kind, err := s.restoreVersion(tx, path, version)
+		if err != nil {
+			return err
+		}
+		return s.recordOperation(tx, kind, []string{id})
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %s to version %d (%s)\n", path, restored.Number, util.FormatTimestamp(restored.ValidFrom))
+	return nil
+}
+
+// pickVersion returns the version with the given number, or the version
+// that was current at the given time
+func pickVersion(versions []resourceVersion, number int, at *time.Time) (*resourceVersion, error) {
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no history")
+	}
+	if at == nil {
+		if number > len(versions) {
+			return nil, fmt.Errorf("no version %d (latest is %d)", number, len(versions))
+		}
+		return &versions[number-1], nil
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := &versions[i]
+		if !v.ValidFrom.After(*at) && (v.ValidTo == nil || v.ValidTo.After(*at)) {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("did not exist at %s", util.FormatTimestamp(*at))
+}
+
+// restoreVersion closes the current version of path, if any, and inserts a
+// copy of version as the new current version, returning its ID and whether
+// the change was an update or a re-creation
+func (s *Shell) restoreVersion(tx *database.Transaction, path string, version *resourceVersion) (string, string, error) {
+	kind := schema.OperationKindCreate
+	now := time.Now()
+
+	current, err := s.lookupResource(tx, path)
+	if err == nil {
+		if current.ID == version.ID {
+			return "", "", fmt.Errorf("restore: %s: version %d is already current", path, version.Number)
+		}
+		if current.Type != version.Type {
+			return "", "", fmt.Errorf("restore: %s is now a %s, not a %s", path, current.Type, version.Type)
+		}
+		if _, err := tx.Execute(`UPDATE resources SET valid_to = ? WHERE id = ? AND valid_to IS NULL`, now, current.ID); err != nil {
+			return "", "", fmt.Errorf("failed to close current version: %w", err)
+		}
+		kind = schema.OperationKindUpdate
+	}
+
+	parent, err := s.lookupResource(tx, pathpkg.Dir(path))
+	if err != nil || parent.Type != schema.ResourceTypeDirectory {
+		return "", "", fmt.Errorf("parent directory %w: %s", database.ErrNotFound, pathpkg.Dir(path))
+	}
+
+	metadata := version.Metadata
+	metadata.ModifiedAt = now
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	newID, err := newResourceID(tx, version.Type)
+	if err != nil {
+		return "", "", err
+	}
+	_, err = tx.Execute(`
+		INSERT INTO resources (id, type, name, parent_id, path, content, metadata, valid_from, transaction_id, branch_id)
+		SELECT ?, type, name, ?, path, content, ?, ?, ?, branch_id
+		FROM resources WHERE id = ?
+	`, newID, parent.ID, string(metadataJSON), now, tx.GetID(), version.ID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to insert restored version: %w", err)
+	}
+	return newID, kind, nil
+}
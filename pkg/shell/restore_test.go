@@ -0,0 +1,55 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRestoreVersion(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo one > /tmp/f")
+	run(t, sh, "chmod 600 /tmp/f")
+	run(t, sh, "echo two > /tmp/f")
+
+	if output := run(t, sh, "restore /tmp/f --version 1"); !strings.HasPrefix(output, "Restored /tmp/f to version 1 (") {
+		t.Errorf("restore = %q", output)
+	}
+	if got := readFile(t, sh, "/tmp/f"); got != "one\n" {
+		t.Errorf("content after restore = %q", got)
+	}
+	if got := metadataOf(t, sh, "/tmp/f").Permissions; got != 0644 {
+		t.Errorf("permissions after restore = %04o, want the restored version's", got)
+	}
+	// The versions in between stay in history
+	if got := historyLines(t, sh, "/tmp/f"); len(got) != 4 {
+		t.Errorf("history after restore lists %d versions", len(got))
+	}
+
+	for _, command := range []string{
+		"restore /tmp/f --version 4",
+		"restore /tmp/f --version 9",
+		"restore /tmp/f --version 0",
+		"restore /tmp/f",
+		"restore /tmp/f --version 1 --at 2000-01-01T00:00:00Z",
+		"restore /tmp/f --at 2000-01-01T00:00:00Z",
+		"restore /tmp/missing --version 1",
+	} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
+
+func TestRestoreDeleted(t *testing.T) {
+	sh := newTestShell(t)
+	at := pastState(t, sh)
+	run(t, sh, "rm -f /old.bak")
+
+	run(t, sh, "restore /old.txt --at "+at)
+	if got := readFile(t, sh, "/old.txt"); got != "old\n" {
+		t.Errorf("restored content = %q", got)
+	}
+	if output := run(t, sh, "log -n 1"); !strings.Contains(output, " create ") {
+		t.Errorf("restoring a deleted file is not logged as a create: %q", output)
+	}
+}
@@ -0,0 +1,58 @@
+package shell
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunReadsCommandsAndAnswers(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "branch old")
+	sh.SetInteractive(true)
+	setInput(sh, "mkdir /x\n\nbranch -d old\ny\ntouch /x/f\nexit\n")
+
+	output, err := captureOutput(func() error { sh.Run(); return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The answer to the confirmation is read from the same input, not run
+	// as a command
+	want := []string{"mkdir /x", "branch -d old", "touch /x/f", "exit"}
+	if !reflect.DeepEqual(sh.history, want) {
+		t.Errorf("history = %q, want %q", sh.history, want)
+	}
+	if !strings.Contains(string(output), "Branch old deleted") {
+		t.Errorf("output:\n%s", output)
+	}
+	if _, err := sh.lookupResource(sh.db, "/x/f"); err != nil {
+		t.Errorf("/x/f: %v", err)
+	}
+}
+
+func TestRunAcceptsLongLines(t *testing.T) {
+	sh := newTestShell(t)
+	// Longer than bufio.Scanner's default limit of 64 KB
+	path := "/" + strings.Repeat("x", 100*1024)
+	setInput(sh, "mkdir "+path+"\n")
+	if _, err := captureOutput(func() error { sh.Run(); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sh.lookupResource(sh.db, path); err != nil {
+		t.Errorf("command on a long line: %v", err)
+	}
+}
+
+func TestRunSkipsLongLines(t *testing.T) {
+	sh := newTestShell(t)
+	setInput(sh, "mkdir /"+strings.Repeat("x", MaxInputLine)+"\nmkdir /after\n")
+	if _, err := captureOutput(func() error { sh.Run(); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sh.lookupResource(sh.db, "/after"); err != nil {
+		t.Errorf("command after a long line: %v", err)
+	}
+	if len(sh.history) != 1 {
+		t.Errorf("history holds %d commands", len(sh.history))
+	}
+}
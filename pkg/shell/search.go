@@ -0,0 +1,116 @@
+package shell
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// metadataFieldPattern restricts metadata paths to plain dotted identifiers,
+// since they are embedded in the generated SQL
+var metadataFieldPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// SearchResources finds resources by metadata fields
+// (search --meta <field>=<value> [--meta ...] [path])
+func (s *Shell) SearchResources(args []string) error {
+	var conditions []string
+	var conditionArgs []interface{}
+	path := s.state.CurrentDirectory
+
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--meta" {
+			path = s.resolvePath(args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("--meta requires <field>=<value>")
+		}
+		i++
+
+		condition, value, err := s.metadataCondition(args[i])
+		if err != nil {
+			return err
+		}
+		conditions = append(conditions, condition)
+		conditionArgs = append(conditionArgs, value)
+	}
+
+	if len(conditions) == 0 {
+		return fmt.Errorf("usage: search --meta <field>=<value> [path]")
+	}
+
+	query := `SELECT path, type FROM resources WHERE ` + strings.Join(conditions, " AND ")
+	queryArgs := conditionArgs
+
+	// Restrict to the subtree and the visible versions
+	subtree, subtreeArgs := subtreeCondition(s.db.Dialect(), path)
+	visibility, visibilityArgs := s.visibilityCondition()
+	query += subtree + visibility + ` ORDER BY path ASC`
+	queryArgs = append(queryArgs, subtreeArgs...)
+	queryArgs = append(queryArgs, visibilityArgs...)
+
+	var it *database.RowIterator
+	var err error
+	if s.CurrentTransaction() != nil {
+		it, err = s.CurrentTransaction().QueryStream(query, database.QueryOptions{}, queryArgs...)
+	} else {
+		it, err = s.db.QueryStream(query, database.QueryOptions{}, queryArgs...)
+	}
+	if err != nil {
+		return fmt.Errorf("search failed: %w", err)
+	}
+	defer it.Close()
+
+	for it.Next() {
+		var resPath, resType string
+		if err := it.Scan(&resPath, &resType); err != nil {
+			return fmt.Errorf("failed to scan resource: %w", err)
+		}
+		if resType == "directory" && resPath != "/" {
+			resPath += "/"
+		}
+		fmt.Println(resPath)
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d match(es)\n", it.Count())
+	return nil
+}
+
+// metadataCondition builds the SQL predicate and argument for field=value
+func (s *Shell) metadataCondition(expr string) (string, interface{}, error) {
+	eq := strings.Index(expr, "=")
+	if eq <= 0 {
+		return "", nil, fmt.Errorf("invalid metadata filter (want field=value): %s", expr)
+	}
+
+	field, value := expr[:eq], expr[eq+1:]
+	if !metadataFieldPattern.MatchString(field) {
+		return "", nil, fmt.Errorf("invalid metadata field: %s", field)
+	}
+
+	extract := s.db.JSONExtract("metadata", field)
+
+	// Postgres extracts JSON values as text, so compare textually
+	if !s.db.IsSQLite() {
+		return extract + " = ?", value, nil
+	}
+
+	// SQLite's json_extract returns native values: booleans as 0/1 and
+	// numbers as numbers
+	switch value {
+	case "true":
+		return extract + " = ?", 1, nil
+	case "false":
+		return extract + " = ?", 0, nil
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return extract + " = ?", n, nil
+	}
+	return extract + " = ?", value, nil
+}
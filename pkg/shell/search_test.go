@@ -0,0 +1,51 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearchMetadata(t *testing.T) {
+	sh := newTestShell(t)
+	addTestUser(t, sh, "alice")
+	run(t, sh, "mkdir /docs")
+	run(t, sh, "echo a > /docs/a.txt")
+	run(t, sh, "echo b > /docs/b.txt")
+	run(t, sh, "echo c > /tmp/c.txt")
+	run(t, sh, "chown alice /docs/a.txt")
+	run(t, sh, "chown alice /tmp/c.txt")
+	run(t, sh, "chmod 700 /docs/b.txt")
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"search --meta owner=alice /", "/docs/a.txt\n/tmp/c.txt\n2 match(es)\n"},
+		{"search --meta owner=alice /docs", "/docs/a.txt\n1 match(es)\n"},
+		{"search --meta owner=alice --meta size=2 /", "/docs/a.txt\n/tmp/c.txt\n2 match(es)\n"},
+		{"search --meta is_executable=true /docs", "/docs/b.txt\n1 match(es)\n"},
+		{"search --meta permissions=448 /", "/docs/b.txt\n1 match(es)\n"},
+		{"search --meta permissions=493 /tmp", "/tmp/\n1 match(es)\n"},
+		{"search --meta owner=bob /", "0 match(es)\n"},
+	}
+	for _, tt := range tests {
+		if got := run(t, sh, tt.command); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+
+	run(t, sh, "cd /docs")
+	if got := run(t, sh, "search --meta owner=system"); !strings.HasPrefix(got, "/docs/\n/docs/b.txt\n") {
+		t.Errorf("search from the current directory = %q", got)
+	}
+
+	if got := run(t, sh, "search --meta permissions=493 /"); !strings.HasPrefix(got, "/\n") {
+		t.Errorf("search listing the root = %q", got)
+	}
+
+	for _, command := range []string{"search /", "search --meta", "search --meta owner", "search --meta =x", "search --meta owner')--=x"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
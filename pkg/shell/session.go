@@ -0,0 +1,173 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// Session heartbeat timing. A session that has not sent a heartbeat within
+// SessionStaleAfter is shown as stale and removed when the next session
+// starts.
+const (
+	SessionHeartbeatInterval = 30 * time.Second
+	SessionStaleAfter        = 3 * SessionHeartbeatInterval
+)
+
+// StartSession puts the shell on the user's default branch in their home
+// directory with their saved aliases, records it in the sessions table and as the user's last login,
+// and keeps its heartbeat current until EndSession is called
+func (s *Shell) StartSession() error {
+	if s.sessionID != "" {
+		return fmt.Errorf("session already started")
+	}
+
+	if err := s.enterHome(); err != nil {
+		return err
+	}
+	if err := s.loadAliases(); err != nil {
+		return err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	now := time.Now()
+	if _, err := s.db.ExecuteStatement(`DELETE FROM sessions WHERE last_heartbeat < ?`, now.Add(-SessionStaleAfter)); err != nil {
+		return fmt.Errorf("failed to remove stale sessions: %w", err)
+	}
+
+	id := database.GenerateUUID()
+	_, err = s.db.ExecuteStatement(`
+		INSERT INTO sessions (id, user_id, branch_id, hostname, pid, started_at, last_heartbeat)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, id, s.state.User, s.state.CurrentBranch, hostname, os.Getpid(), now, now)
+	if err != nil {
+		return fmt.Errorf("failed to record session: %w", err)
+	}
+
+	if _, err := s.db.ExecuteStatement(`UPDATE users SET last_login = ? WHERE username = ?`, now, s.state.User); err != nil {
+		return fmt.Errorf("failed to record login: %w", err)
+	}
+
+	stop := make(chan struct{})
+	s.mu.Lock()
+	s.sessionID, s.stopHeartbeat = id, stop
+	s.mu.Unlock()
+	go s.heartbeat(id, stop)
+	return nil
+}
+
+// EndSession stops the heartbeat and removes this shell's session record
+func (s *Shell) EndSession() error {
+	s.mu.Lock()
+	id, stop := s.sessionID, s.stopHeartbeat
+	s.sessionID, s.stopHeartbeat = "", nil
+	s.mu.Unlock()
+	if id == "" {
+		return nil
+	}
+
+	close(stop)
+
+	if _, err := s.db.ExecuteStatement(`DELETE FROM sessions WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to remove session: %w", err)
+	}
+	return nil
+}
+
+// Shutdown prepares the shell for the process to exit. A transaction left
+// open with begin is rolled back, with a warning on standard error naming it
+// and the statements lost, and the session is ended.
+func (s *Shell) Shutdown() error {
+	if tx := s.takeTransaction(); tx != nil {
+		if tx.IsActive() {
+			fmt.Fprintf(os.Stderr, "Warning: rolling back uncommitted transaction %s (%d statement(s))\n", txLabel(tx.GetID()), tx.GetStatementCount())
+			if err := tx.Rollback(); err != nil {
+				return fmt.Errorf("failed to roll back transaction: %w", err)
+			}
+		}
+	}
+	return s.EndSession()
+}
+
+// heartbeat refreshes the session's last_heartbeat until stop is closed.
+// It only touches the sessions table, so it is safe to run alongside the
+// shell's own use of the connection.
+func (s *Shell) heartbeat(id string, stop <-chan struct{}) {
+	ticker := time.NewTicker(SessionHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			_, err := s.db.ExecuteStatement(`UPDATE sessions SET last_heartbeat = ? WHERE id = ?`, now, id)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: session heartbeat failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// updateSessionBranch records the shell's current branch on its session
+func (s *Shell) updateSessionBranch() error {
+	if s.sessionID == "" {
+		return nil
+	}
+
+	_, err := s.db.ExecuteStatement(`UPDATE sessions SET branch_id = ? WHERE id = ?`, s.state.CurrentBranch, s.sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	return nil
+}
+
+// ListSessions prints the sessions connected to the database, marking this
+// shell's own session and sessions whose heartbeat has lapsed
+func (s *Shell) ListSessions() error {
+	rows, err := s.queries().ExecuteQuery(`
+		SELECT id, user_id, branch_id, hostname, pid, started_at, last_heartbeat
+		FROM sessions ORDER BY started_at ASC
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	count := 0
+	for rows.Next() {
+		var id, user, branch, hostname string
+		var pid int
+		var startedAt, lastHeartbeat time.Time
+		if err := rows.Scan(&id, &user, &branch, &hostname, &pid, &startedAt, &lastHeartbeat); err != nil {
+			return fmt.Errorf("failed to scan session: %w", err)
+		}
+
+		marker := " "
+		if id == s.sessionID {
+			marker = "*"
+		}
+		status := "active"
+		if now.Sub(lastHeartbeat) > SessionStaleAfter {
+			status = "stale"
+		}
+
+		fmt.Printf("%s %-20s %-12s %-12s %s:%-8d %-6s since %s\n", marker, id, user, branch, hostname, pid, status, startedAt.Format("2006-01-02 15:04:05"))
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if count == 0 {
+		fmt.Println("No sessions")
+	}
+	return nil
+}
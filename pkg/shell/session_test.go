@@ -0,0 +1,67 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	sh := newTestShell(t)
+	_, err := sh.db.ExecuteStatement(`
+		INSERT INTO sessions (id, user_id, branch_id, hostname, pid, started_at, last_heartbeat)
+		VALUES ('old', 'system', 'main', 'gone', 1, ?, ?)
+	`, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output := run(t, sh, "sessions"); !strings.Contains(output, "stale") {
+		t.Errorf("lapsed session not shown as stale:\n%s", output)
+	}
+
+	if _, err := captureOutput(sh.StartSession); err != nil {
+		t.Fatal(err)
+	}
+	defer sh.EndSession()
+	if err := sh.StartSession(); err == nil {
+		t.Error("a second session started")
+	}
+
+	output := run(t, sh, "sessions")
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "* ") || !strings.Contains(lines[0], "active") {
+		t.Errorf("sessions after start (the stale one should be gone):\n%s", output)
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM users WHERE username = 'system' AND last_login IS NOT NULL`); n != 1 {
+		t.Error("login not recorded")
+	}
+
+	run(t, sh, "branch feature")
+	run(t, sh, "switch feature")
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM sessions WHERE branch_id = 'feature'`); n != 1 {
+		t.Error("session branch not updated on switch")
+	}
+
+	if err := sh.EndSession(); err != nil {
+		t.Fatal(err)
+	}
+	if output := run(t, sh, "sessions"); output != "No sessions\n" {
+		t.Errorf("sessions after end:\n%s", output)
+	}
+}
+
+func TestShutdownRollsBack(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "begin")
+	run(t, sh, "echo lost > /tmp/lost")
+
+	if _, err := captureOutput(sh.Shutdown); err != nil {
+		t.Fatal(err)
+	}
+	if sh.CurrentTransaction() != nil {
+		t.Error("transaction still open after shutdown")
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE path = '/tmp/lost'`); n != 0 {
+		t.Error("uncommitted write survived shutdown")
+	}
+}
@@ -0,0 +1,178 @@
+package shell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/brainwavecollective/stone-os/pkg/filesystem"
+)
+
+// SetOption changes a shell setting (set <name> <value>), or lists the
+// settings when called without arguments
+func (s *Shell) SetOption(args []string) error {
+	if len(args) == 0 {
+		fmt.Printf("%-13s = %d\n", "maxnodes", s.state.MaxWalkNodes)
+		fmt.Printf("%-13s = %d\n", "maxdepth", s.state.MaxWalkDepth)
+		fmt.Printf("%-13s = %d\n", "maxcat", s.state.MaxCatBytes)
+		fmt.Printf("%-13s = %d\n", "maxrows", s.state.MaxQueryRows)
+		fmt.Printf("%-13s = %s\n", "nounset", formatToggle(s.state.NoUnset))
+		fmt.Printf("%-13s = %s\n", "autocommit", formatToggle(s.state.Autocommit))
+		fmt.Printf("%-13s = %s\n", "touchversions", formatToggle(s.state.TouchVersions))
+		fmt.Printf("%-13s = %s\n", "timing", formatToggle(s.state.Timing))
+		compression := s.fm.Compression()
+		fmt.Printf("%-13s = %s\n", "compress", formatToggle(compression.Enabled))
+		fmt.Printf("%-13s = %d\n", "compressmin", compression.Threshold)
+		fmt.Printf("%-13s = %s\n", "store", s.fm.ContentStoreSetting())
+		fmt.Printf("%-13s = %q\n", "prompt", s.promptTemplate)
+		if profile, err := s.loadUserProfile(); err == nil && profile.Registered {
+			fmt.Printf("%-13s = %s\n", "defaultbranch", profile.DefaultBranch)
+		}
+		return nil
+	}
+
+	// The prompt template is taken from the raw command so that its
+	// spacing survives, and may be quoted to keep leading or trailing spaces
+	if args[0] == "prompt" && len(args) > 1 {
+		rest := strings.TrimSpace(strings.TrimPrefix(s.currentCommand, "set"))
+		return s.setPrompt(unquote(strings.TrimSpace(strings.TrimPrefix(rest, "prompt"))))
+	}
+
+	if len(args) != 2 {
+		return fmt.Errorf("usage: set <name> <value>")
+	}
+
+	name, value := args[0], args[1]
+	switch name {
+	case "maxnodes":
+		n, err := parseLimit(value)
+		if err != nil {
+			return err
+		}
+		s.state.MaxWalkNodes = n
+
+	case "maxdepth":
+		n, err := parseLimit(value)
+		if err != nil {
+			return err
+		}
+		s.state.MaxWalkDepth = n
+
+	case "maxcat":
+		n, err := parseLimit(value)
+		if err != nil {
+			return err
+		}
+		s.state.MaxCatBytes = n
+
+	case "maxrows":
+		n, err := parseLimit(value)
+		if err != nil {
+			return err
+		}
+		s.state.MaxQueryRows = n
+
+	case "nounset":
+		on, err := parseToggle(value)
+		if err != nil {
+			return err
+		}
+		s.state.NoUnset = on
+
+	case "autocommit":
+		on, err := parseToggle(value)
+		if err != nil {
+			return err
+		}
+		s.state.Autocommit = on
+
+	case "touchversions":
+		on, err := parseToggle(value)
+		if err != nil {
+			return err
+		}
+		s.state.TouchVersions = on
+
+	case "timing":
+		on, err := parseToggle(value)
+		if err != nil {
+			return err
+		}
+		s.state.Timing = on
+
+	case "compress":
+		on, err := parseToggle(value)
+		if err != nil {
+			return err
+		}
+		compression := s.fm.Compression()
+		compression.Enabled = on
+		return s.fm.SaveCompression(compression)
+
+	case "compressmin":
+		n, err := parseLimit(value)
+		if err != nil {
+			return err
+		}
+		compression := s.fm.Compression()
+		compression.Threshold = n
+		return s.fm.SaveCompression(compression)
+
+	case "store":
+		return s.SetContentStore(value)
+
+	case "defaultbranch":
+		return s.setDefaultBranch(value)
+
+	default:
+		return fmt.Errorf("unknown setting: %s", name)
+	}
+
+	return nil
+}
+
+// LoadSettings applies the settings saved in the database, such as how file
+// content is compressed
+func (s *Shell) LoadSettings() error {
+	return s.fm.LoadCompression()
+}
+
+// SetContentStore selects where file content written from now on is kept:
+// "table" for the resources table, "inline" for the database's blobs table,
+// or "dir:<path>" for files in a host directory
+func (s *Shell) SetContentStore(setting string) error {
+	name, dir, err := filesystem.ParseContentStore(setting)
+	if err != nil {
+		return err
+	}
+	return s.fm.SetContentStore(name, dir)
+}
+
+// parseLimit parses a non-negative limit where 0 means unlimited
+func parseLimit(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid limit: %s", value)
+	}
+	return n, nil
+}
+
+// parseToggle parses an on/off setting value
+func parseToggle(value string) (bool, error) {
+	switch value {
+	case "on", "true", "1":
+		return true, nil
+	case "off", "false", "0":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid value (want on or off): %s", value)
+	}
+}
+
+// formatToggle renders an on/off setting value
+func formatToggle(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
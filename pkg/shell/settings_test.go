@@ -0,0 +1,50 @@
+package shell
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompressionSettingIsSaved(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "set compress on")
+	run(t, sh, "set compressmin 100")
+
+	other := NewShell(sh.db)
+	if err := other.LoadSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if got := other.fm.Compression(); !got.Enabled || got.Threshold != 100 {
+		t.Errorf("new shell compression = %+v, want enabled above 100 bytes", got)
+	}
+}
+
+func TestStoreSetting(t *testing.T) {
+	sh := newTestShell(t)
+	dir := t.TempDir()
+	run(t, sh, "set store dir:"+dir)
+	if output := run(t, sh, "set"); !strings.Contains(output, "store         = dir:"+dir+"\n") {
+		t.Errorf("set does not list the store:\n%s", output)
+	}
+
+	run(t, sh, "echo stored outside > /tmp/f")
+	if got := metadataOf(t, sh, "/tmp/f").Store; got == "" {
+		t.Error("file written with a directory store is kept in the resources table")
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) == 0 {
+		t.Errorf("store directory holds %d entries, %v", len(entries), err)
+	}
+	if got := readFile(t, sh, "/tmp/f"); got != "stored outside\n" {
+		t.Errorf("content read from the store = %q", got)
+	}
+
+	run(t, sh, "set store table")
+	run(t, sh, "echo inside > /tmp/g")
+	if got := metadataOf(t, sh, "/tmp/g").Store; got != "" {
+		t.Errorf("file written with the table store is kept in %s", got)
+	}
+	if _, err := runErr(sh, "set store s3"); err == nil {
+		t.Error("unknown store accepted")
+	}
+}
@@ -0,0 +1,107 @@
+package shell
+
+import (
+	"bufio"
+	"database/sql"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// newTestShell returns a non-interactive shell for user "system" in / on a
+// fresh in-memory database named after the test
+func newTestShell(t *testing.T) *Shell {
+	t.Helper()
+	db, err := database.Connect("inmemory", t.Name())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := captureOutput(func() error { return schema.Initialize(db) }); err != nil {
+		t.Fatalf("initialize schema: %v", err)
+	}
+
+	sh := NewShell(db)
+	sh.state.User = "system"
+	sh.state.IsInteractive = false
+	setInput(sh, "")
+	return sh
+}
+
+// setInput makes the shell read confirmations and content from text
+func setInput(sh *Shell, text string) {
+	sh.input = bufio.NewReader(strings.NewReader(text))
+}
+
+// run runs a command, failing the test if it returns an error, and returns
+// what it printed
+func run(t *testing.T, sh *Shell, command string) string {
+	t.Helper()
+	output, err := runErr(sh, command)
+	if err != nil {
+		t.Fatalf("%s: %v\noutput:\n%s", command, err, output)
+	}
+	return output
+}
+
+// runErr runs a command and returns what it printed and its error
+func runErr(sh *Shell, command string) (string, error) {
+	output, err := captureOutput(func() error {
+		return sh.ProcessCommand(command)
+	})
+	return string(output), err
+}
+
+// readFile returns the current content of a file on the shell's branch
+func readFile(t *testing.T, sh *Shell, path string) string {
+	t.Helper()
+	options, err := branchOptions(sh.state.CurrentBranch, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := sh.fm.GetFile(path, sh.CurrentTransaction(), options)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(file.Content)
+}
+
+// holdReadLock reads table from a second connection to the test's
+// in-memory database and keeps the read open for d. Until then the shared
+// cache refuses writes to the table with SQLITE_LOCKED, so implicit
+// transactions that write it fail part way through and are retried.
+func holdReadLock(t *testing.T, table string, d time.Duration) {
+	t.Helper()
+	blocker, err := sql.Open("sqlite3", "file:"+url.PathEscape(t.Name())+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { blocker.Close() })
+	tx, err := blocker.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(d)
+		tx.Rollback()
+	}()
+}
+
+// metadataOf returns the metadata of the current version of the resource at
+// path on the shell's branch
+func metadataOf(t *testing.T, sh *Shell, path string) schema.ResourceMetadata {
+	t.Helper()
+	entry, err := sh.lookupResource(sh.queries(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry.Metadata
+}
@@ -0,0 +1,106 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/filesystem"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// resourceStat is the JSON form of stat output. Times are RFC3339.
+type resourceStat struct {
+	ID            string                  `json:"id"`
+	Type          string                  `json:"type"`
+	Path          string                  `json:"path"`
+	Branch        string                  `json:"branch"`
+	ValidFrom     time.Time               `json:"valid_from"`
+	ValidTo       *time.Time              `json:"valid_to"`
+	TransactionID string                  `json:"transaction_id"`
+	Uncommitted   bool                    `json:"uncommitted"` // Written by the open transaction
+	Metadata      schema.ResourceMetadata `json:"metadata"`
+}
+
+// StatResource prints a resource's core fields and metadata
+// (stat [--json] [--at <time>] <path>). With --json they are printed as a
+// single JSON object for scripts. The path may be branch-qualified. A
+// version written by the open transaction is marked uncommitted.
+func (s *Shell) StatResource(args []string) error {
+	args, at, err := s.takeAtFlag(args)
+	if err != nil {
+		return err
+	}
+	flags, args, err := splitFlags("stat", args, "--json")
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: stat [--json] [--at <time>] <path>")
+	}
+
+	p := s.parseBranchPath(args[0])
+	options, err := s.readOptions(p, at)
+	if err != nil {
+		return err
+	}
+
+	var resource *schema.Resource
+	err = s.fm.Walk(p.Path, s.CurrentTransaction(), options, func(r *schema.Resource) error {
+		resource = r
+		return filesystem.SkipDir
+	})
+	if err != nil {
+		return err
+	}
+
+	stat := resourceStat{
+		ID:            resource.ID,
+		Type:          resource.Type,
+		Path:          resource.Path,
+		Branch:        p.Branch,
+		ValidFrom:     resource.ValidFrom,
+		ValidTo:       resource.ValidTo,
+		TransactionID: resource.TransactionID,
+		Uncommitted:   s.isUncommitted(resource.TransactionID),
+	}
+	if stat.Metadata, err = schema.NormalizeMetadata(resource.Metadata); err != nil {
+		return fmt.Errorf("failed to unmarshal metadata for %s: %w", resource.Path, err)
+	}
+
+	if flags["--json"] {
+		out, err := json.Marshal(stat)
+		if err != nil {
+			return fmt.Errorf("failed to marshal stat: %w", err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	metadata := stat.Metadata
+	fmt.Printf("  Path: %s\n", stat.Path)
+	fmt.Printf("  Type: %s\n", stat.Type)
+	fmt.Printf("    ID: %s\n", stat.ID)
+	fmt.Printf("Branch: %s\n", stat.Branch)
+	fmt.Printf("  Size: %d\n", metadata.Size)
+	fmt.Printf("  Mode: %04o\n", metadata.Permissions)
+	fmt.Printf(" Owner: %s\n", metadata.Owner)
+	if metadata.MimeType != "" {
+		fmt.Printf("  MIME: %s\n", metadata.MimeType)
+	}
+	if metadata.SymlinkTarget != "" {
+		fmt.Printf("Target: %s\n", metadata.SymlinkTarget)
+	}
+	if metadata.Checksum != "" {
+		fmt.Printf("   Sum: %s\n", metadata.Checksum)
+	}
+	fmt.Printf("Create: %s\n", metadata.CreatedAt.Format(time.RFC3339))
+	fmt.Printf("Modify: %s\n", metadata.ModifiedAt.Format(time.RFC3339))
+	fmt.Printf("Access: %s\n", metadata.AccessedAt.Format(time.RFC3339))
+	if stat.Uncommitted {
+		fmt.Printf(" Since: %s (%s, uncommitted)\n", stat.ValidFrom.Format(time.RFC3339), txLabel(stat.TransactionID))
+	} else {
+		fmt.Printf(" Since: %s (%s)\n", stat.ValidFrom.Format(time.RFC3339), txLabel(stat.TransactionID))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package shell
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestStat(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo hello > /tmp/a.txt")
+
+	output := run(t, sh, "stat /tmp/a.txt")
+	for _, want := range []string{"  Path: /tmp/a.txt", "  Type: file", "Branch: main", "  Size: 6", "  Mode: 0644", " Owner: system", "  MIME: text/plain"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("stat lacks %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "uncommitted") {
+		t.Errorf("committed file marked uncommitted:\n%s", output)
+	}
+	if _, err := runErr(sh, "stat /tmp/missing"); err == nil {
+		t.Error("stat of a missing path succeeded")
+	}
+}
+
+func TestStatJSON(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "begin")
+	run(t, sh, "echo hello > /tmp/a.txt")
+
+	var stat resourceStat
+	if err := json.Unmarshal([]byte(run(t, sh, "stat --json /tmp/a.txt")), &stat); err != nil {
+		t.Fatal(err)
+	}
+	if stat.Path != "/tmp/a.txt" || stat.Type != "file" || stat.Branch != "main" || stat.Metadata.Size != 6 {
+		t.Errorf("stat --json = %+v", stat)
+	}
+	if !stat.Uncommitted || stat.ValidTo != nil {
+		t.Errorf("version written by the open transaction: uncommitted %v, valid_to %v", stat.Uncommitted, stat.ValidTo)
+	}
+
+	run(t, sh, "commit")
+	if err := json.Unmarshal([]byte(run(t, sh, "stat --json /tmp/a.txt")), &stat); err != nil {
+		t.Fatal(err)
+	}
+	if stat.Uncommitted {
+		t.Error("committed version marked uncommitted")
+	}
+}
+
+func TestStatBranchQualified(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo main > /tmp/a")
+	run(t, sh, "branch feature")
+	run(t, sh, "switch feature")
+	run(t, sh, "echo feature branch > /tmp/a")
+	run(t, sh, "switch main")
+
+	var stat resourceStat
+	if err := json.Unmarshal([]byte(run(t, sh, "stat --json feature:/tmp/a")), &stat); err != nil {
+		t.Fatal(err)
+	}
+	if stat.Branch != "feature" || stat.Metadata.Size != int64(len("feature branch\n")) {
+		t.Errorf("stat feature:/tmp/a = branch %s size %d", stat.Branch, stat.Metadata.Size)
+	}
+	if _, err := runErr(sh, "stat nobranch:/tmp/a"); err == nil {
+		t.Error("stat on a missing branch succeeded")
+	}
+}
@@ -0,0 +1,48 @@
+package shell
+
+import (
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// CurrentTransaction returns the transaction opened with begin, or nil. It
+// may be called from goroutines other than the one running commands.
+func (s *Shell) CurrentTransaction() *database.Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.CurrentTransaction
+}
+
+// setTransaction makes tx the open transaction; nil closes it
+func (s *Shell) setTransaction(tx *database.Transaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.CurrentTransaction = tx
+}
+
+// takeTransaction returns the open transaction and clears it in one step,
+// so that only one caller gets to end it
+func (s *Shell) takeTransaction() *database.Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := s.state.CurrentTransaction
+	s.state.CurrentTransaction = nil
+	return tx
+}
+
+// PointInTime returns the time the shell reads as of, or nil for the
+// present. It may be called from goroutines other than the one running
+// commands.
+func (s *Shell) PointInTime() *time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.PointInTime
+}
+
+// setPointInTime sets the time the shell reads as of; nil is the present
+func (s *Shell) setPointInTime(t *time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.PointInTime = t
+}
@@ -0,0 +1,82 @@
+package shell
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestStateAccessIsSynchronized runs begin, rollback, state-at and now while
+// other goroutines read the open transaction and point in time, as a
+// signal handler or watcher would. Run it with -race.
+func TestStateAccessIsSynchronized(t *testing.T) {
+	sh := newTestShell(t)
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if tx := sh.CurrentTransaction(); tx != nil {
+					tx.GetID()
+				}
+				if at := sh.PointInTime(); at != nil {
+					at.IsZero()
+				}
+				runtime.Gosched()
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		run(t, sh, "begin")
+		run(t, sh, "state-at yesterday")
+		run(t, sh, "rollback")
+		run(t, sh, "now")
+	}
+	close(stop)
+	readers.Wait()
+
+	if sh.CurrentTransaction() != nil {
+		t.Error("transaction still open after rollback")
+	}
+	if sh.PointInTime() != nil {
+		t.Error("point in time still set after now")
+	}
+}
+
+// TestTakeTransactionHasOneWinner checks that when several goroutines race
+// to end the open transaction, only one of them gets it
+func TestTakeTransactionHasOneWinner(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "begin")
+
+	var winners atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tx := sh.takeTransaction(); tx != nil {
+				winners.Add(1)
+				tx.Rollback()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := winners.Load(); n != 1 {
+		t.Errorf("%d goroutines took the transaction, want 1", n)
+	}
+	if sh.CurrentTransaction() != nil {
+		t.Error("transaction still open after it was taken")
+	}
+}
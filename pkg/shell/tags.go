@@ -0,0 +1,71 @@
+package shell
+
+import (
+	"fmt"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// TagResource attaches one or more labels to a resource (tag <path> <label>...)
+func (s *Shell) TagResource(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: tag <path> <label>...")
+	}
+	path, err := s.resolveLocalPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		for _, tag := range args[1:] {
+			if err := s.fm.TagResource(path, tag, tx); err != nil {
+				return err
+			}
+		}
+		out.Printf("Tagged %s\n", path)
+		return nil
+	})
+}
+
+// UntagResource removes one or more labels from a resource
+// (untag <path> <label>...)
+func (s *Shell) UntagResource(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: untag <path> <label>...")
+	}
+	path, err := s.resolveLocalPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		for _, tag := range args[1:] {
+			if err := s.fm.UntagResource(path, tag, tx); err != nil {
+				return err
+			}
+		}
+		out.Printf("Untagged %s\n", path)
+		return nil
+	})
+}
+
+// ShowTags lists the labels of a resource (tags <path>). The path may be
+// branch-qualified.
+func (s *Shell) ShowTags(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: tags <path>")
+	}
+	p := s.parseBranchPath(args[0])
+	if _, err := s.readOptions(p, nil); err != nil {
+		return err
+	}
+
+	tags, err := s.fm.GetTags(p.Path, p.Branch, s.CurrentTransaction())
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		fmt.Println(tag)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package shell
+
+import "testing"
+
+func TestTagCommands(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "touch /tmp/a")
+	run(t, sh, "touch /tmp/b")
+
+	run(t, sh, "tag /tmp/a draft review")
+	run(t, sh, "tag /tmp/b draft")
+	if got := run(t, sh, "tags /tmp/a"); got != "draft\nreview\n" {
+		t.Errorf("tags /tmp/a = %q", got)
+	}
+	if got := run(t, sh, "find /tmp --tag draft"); got != "/tmp/a\n/tmp/b\n" {
+		t.Errorf("find --tag draft = %q", got)
+	}
+
+	run(t, sh, "untag /tmp/a draft")
+	if got := run(t, sh, "tags /tmp/a"); got != "review\n" {
+		t.Errorf("tags after untag = %q", got)
+	}
+	if _, err := runErr(sh, "untag /tmp/a draft"); err == nil {
+		t.Error("removing a tag that is not set succeeded")
+	}
+	if _, err := runErr(sh, "tag /tmp/a 'bad tag'"); err == nil {
+		t.Error("invalid tag accepted")
+	}
+
+	// A branch carries the tags of the branch it was created from
+	run(t, sh, "branch feature")
+	if got := run(t, sh, "tags feature:/tmp/b"); got != "draft\n" {
+		t.Errorf("tags on the new branch = %q", got)
+	}
+}
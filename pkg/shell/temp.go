@@ -0,0 +1,82 @@
+package shell
+
+import (
+	"fmt"
+	pathpkg "path"
+	"strings"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// tempRoot is the directory temporary resources may be created under
+const tempRoot = "/tmp"
+
+// tempSet is the temporary resources created in a transaction, removed
+// when it commits
+type tempSet struct {
+	txID  string
+	paths []string
+}
+
+// checkTemp refuses a --temp resource at path unless it is below /tmp and
+// a transaction is open for it to live in
+func (s *Shell) checkTemp(path string) error {
+	if !strings.HasPrefix(path, tempRoot+"/") {
+		return fmt.Errorf("temporary resources must be under %s: %s", tempRoot, path)
+	}
+	if s.CurrentTransaction() == nil {
+		return fmt.Errorf("--temp requires an open transaction (use begin)")
+	}
+	return nil
+}
+
+// markTemp records that the resource at path, and everything created below
+// it, is to be removed when tx commits. With -p, pass the first path
+// component that did not exist, so that created parents go too.
+func (s *Shell) markTemp(tx *database.Transaction, path string) {
+	if s.temp == nil || s.temp.txID != tx.GetID() {
+		s.temp = &tempSet{txID: tx.GetID()}
+	}
+	s.temp.paths = append(s.temp.paths, path)
+}
+
+// firstMissing returns the shortest prefix of path that does not exist, or
+// path itself when all of its parents do
+func (s *Shell) firstMissing(tx *database.Transaction, path string) string {
+	current := "/"
+	for _, name := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		current = pathpkg.Join(current, name)
+		if _, err := s.lookupResource(tx, current); err != nil {
+			return current
+		}
+	}
+	return path
+}
+
+// removeTemp deletes the rows tx wrote for its temporary resources and
+// everything below them, so that they leave no history once it commits
+func (s *Shell) removeTemp(tx *database.Transaction) error {
+	if s.temp == nil || s.temp.txID != tx.GetID() {
+		return nil
+	}
+
+	removed := 0
+	for _, path := range s.temp.paths {
+		condition, args := subtreeCondition(s.db.Dialect(), path)
+		result, err := tx.Execute(`
+			DELETE FROM resources WHERE transaction_id = ? AND branch_id = ?`+condition,
+			append([]interface{}{tx.GetID(), tx.GetBranchID()}, args...)...)
+		if err != nil {
+			return fmt.Errorf("failed to remove temporary %s: %w", path, err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n > 0 {
+			removed++
+		}
+	}
+
+	s.temp = nil
+	if removed > 0 {
+		fmt.Printf("Removed %d temporary resource(s)\n", removed)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTempResources(t *testing.T) {
+	sh := newTestShell(t)
+	if _, err := runErr(sh, "mkdir --temp /tmp/scratch"); err == nil {
+		t.Error("mkdir --temp without a transaction succeeded")
+	}
+
+	run(t, sh, "begin")
+	if _, err := runErr(sh, "touch --temp /home/scratch"); err == nil {
+		t.Error("touch --temp outside /tmp succeeded")
+	}
+	run(t, sh, "mkdir --temp -p /tmp/a/b")
+	run(t, sh, "touch --temp /tmp/file")
+	run(t, sh, "echo inside > /tmp/a/b/inner")
+	run(t, sh, "touch /tmp/kept")
+	if got := readFile(t, sh, "/tmp/a/b/inner"); got != "inside\n" {
+		t.Errorf("temporary file inside the transaction = %q", got)
+	}
+	if _, err := runErr(sh, "touch --temp /tmp/kept"); err == nil {
+		t.Error("touch --temp of an existing file succeeded")
+	}
+	output := run(t, sh, "commit")
+	if !strings.Contains(output, "Removed 2 temporary resource(s)\n") {
+		t.Errorf("commit = %q", output)
+	}
+
+	for _, path := range []string{"/tmp/a", "/tmp/a/b/inner", "/tmp/file"} {
+		if _, err := sh.lookupResource(sh.db, path); err == nil {
+			t.Errorf("%s is left after commit", path)
+		}
+		if n := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE path = ?`, path); n != 0 {
+			t.Errorf("%s left %d version(s) in history", path, n)
+		}
+	}
+	if _, err := sh.lookupResource(sh.db, "/tmp/kept"); err != nil {
+		t.Errorf("/tmp/kept: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// ErrTestFalse is returned by test in non-interactive mode when the condition
+// does not hold, so that scripts stop or exit with a nonzero status
+var ErrTestFalse = fmt.Errorf("test: condition is false")
+
+// TestResource evaluates a condition on a path (test -e|-f|-d [--at <time>]
+// <path>): -e holds when the resource exists, -f when it is a file and -d
+// when it is a directory. The path may be branch-qualified. Interactively
+// the result is printed as true or false; otherwise a false condition is
+// returned as ErrTestFalse.
+func (s *Shell) TestResource(args []string) error {
+	args, at, err := s.takeAtFlag(args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: test -e|-f|-d [--at <time>] <path>")
+	}
+
+	predicate := args[0]
+	switch predicate {
+	case "-e", "-f", "-d":
+	default:
+		return fmt.Errorf("test: unknown condition %s", predicate)
+	}
+
+	resourceType, err := s.resourceTypeAt(s.parseBranchPath(args[1]), at)
+	if err != nil {
+		return err
+	}
+
+	var result bool
+	switch predicate {
+	case "-e":
+		result = resourceType != ""
+	case "-f":
+		result = resourceType == schema.ResourceTypeFile
+	case "-d":
+		result = resourceType == schema.ResourceTypeDirectory
+	}
+
+	if s.state.IsInteractive {
+		fmt.Println(result)
+		return nil
+	}
+	if !result {
+		return ErrTestFalse
+	}
+	return nil
+}
+
+// resourceTypeAt returns the type of the resource at a path on its branch
+// as of the given point in time, or "" if there is none
+func (s *Shell) resourceTypeAt(p branchPath, at *time.Time) (string, error) {
+	options, err := s.readOptions(p, at)
+	if err != nil {
+		return "", err
+	}
+
+	resource, err := s.fm.Stat(p.Path, s.CurrentTransaction(), options)
+	if errors.Is(err, database.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s: %w", p, err)
+	}
+	return resource.Type, nil
+}
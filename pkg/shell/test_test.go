@@ -0,0 +1,89 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestTestPredicates(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /dir")
+	run(t, sh, "echo x > /file")
+
+	tests := []struct {
+		predicate string
+		path      string
+		want      bool
+	}{
+		{"-e", "/file", true},
+		{"-e", "/dir", true},
+		{"-e", "/missing", false},
+		{"-f", "/file", true},
+		{"-f", "/dir", false},
+		{"-f", "/missing", false},
+		{"-d", "/file", false},
+		{"-d", "/dir", true},
+		{"-d", "/missing", false},
+	}
+	for _, tt := range tests {
+		command := "test " + tt.predicate + " " + tt.path
+
+		sh.SetInteractive(false)
+		_, err := runErr(sh, command)
+		if tt.want && err != nil {
+			t.Errorf("%s: %v", command, err)
+		}
+		if !tt.want && !errors.Is(err, ErrTestFalse) {
+			t.Errorf("%s: err = %v, want ErrTestFalse", command, err)
+		}
+
+		sh.SetInteractive(true)
+		want := "false\n"
+		if tt.want {
+			want = "true\n"
+		}
+		if got := run(t, sh, command); got != want {
+			t.Errorf("%s interactively printed %q, want %q", command, got, want)
+		}
+	}
+}
+
+// TestRunExitStatus runs scripts through Run, which exits with the status
+// of the last command
+func TestRunExitStatus(t *testing.T) {
+	tests := []struct {
+		script string
+		want   int
+	}{
+		{"mkdir /dir\ntest -d /dir\n", 0},
+		{"test -e /missing\n", 1},
+		{"test -e /missing\nls /\n", 0},
+		{"cat /missing\n", 1},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			sh := newTestShell(t)
+			setInput(sh, tt.script)
+			if _, err := captureOutput(func() error { sh.Run(); return nil }); err != nil {
+				t.Fatal(err)
+			}
+			if got := sh.ExitStatus(); got != tt.want {
+				t.Errorf("%q: exit status %d, want %d", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteKeepsWords(t *testing.T) {
+	sh := newTestShell(t)
+	createRaw(t, sh, "/my notes", "hello\n")
+
+	output, err := captureOutput(func() error { return sh.Execute([]string{"cat", "/my notes"}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "hello\n" {
+		t.Errorf("got %q, want %q", output, "hello\n")
+	}
+}
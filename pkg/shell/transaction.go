@@ -0,0 +1,274 @@
+package shell
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// checkAutocommit returns an error when a command needs a transaction but
+// none is active and autocommit is off
+func (s *Shell) checkAutocommit() error {
+	if s.CurrentTransaction() == nil && !s.state.Autocommit {
+		return fmt.Errorf("no transaction; autocommit is off")
+	}
+	return nil
+}
+
+// withImplicitTransaction runs fn in the current transaction or, when none
+// is active and autocommit is on, in a new transaction for the current user
+// and branch. The new transaction is committed when fn succeeds and retried
+// on transient busy errors, so fn may run more than once and must not
+// change anything outside the transaction. fn reports through out, which is
+// printed once the transaction has committed, or straight after fn in an
+// open transaction.
+func (s *Shell) withImplicitTransaction(fn func(tx *database.Transaction, out *txOutput) error) error {
+	out := &txOutput{}
+	if tx := s.CurrentTransaction(); tx != nil {
+		// What fn did stays in the open transaction even if it failed
+		err := fn(tx, out)
+		out.flush()
+		return err
+	}
+	if err := s.checkAutocommit(); err != nil {
+		return err
+	}
+
+	err := s.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID(s.state.CurrentBranch)
+		tx.SetUserID(s.state.User)
+		out.reset()
+		return fn(tx, out)
+	})
+	if err != nil {
+		return err
+	}
+	out.flush()
+	return nil
+}
+
+// withDryRunTransaction runs fn in the current transaction or, when none is
+// active, in a new transaction for the current user and branch that is
+// rolled back afterwards. It is for commands that only report what they
+// would change, so it works with autocommit off.
+func (s *Shell) withDryRunTransaction(fn func(tx *database.Transaction, out *txOutput) error) error {
+	out := &txOutput{}
+	tx := s.CurrentTransaction()
+	if tx == nil {
+		var err error
+		tx, err = s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+		tx.SetBranchID(s.state.CurrentBranch)
+		tx.SetUserID(s.state.User)
+	}
+	err := fn(tx, out)
+	out.flush()
+	return err
+}
+
+// txOutput holds what a command reports from inside withImplicitTransaction
+// until the transaction's outcome is known
+type txOutput struct {
+	lines []txLine
+}
+
+// txLine is one reported line, for standard output or standard error
+type txLine struct {
+	text   string
+	stderr bool
+}
+
+// Printf adds a line for standard output
+func (o *txOutput) Printf(format string, args ...interface{}) {
+	o.lines = append(o.lines, txLine{text: fmt.Sprintf(format, args...)})
+}
+
+// Warnf adds a line for standard error
+func (o *txOutput) Warnf(format string, args ...interface{}) {
+	o.lines = append(o.lines, txLine{text: fmt.Sprintf(format, args...), stderr: true})
+}
+
+// reset discards the lines of a failed attempt
+func (o *txOutput) reset() {
+	o.lines = nil
+}
+
+// flush prints the lines and discards them
+func (o *txOutput) flush() {
+	for _, line := range o.lines {
+		if line.stderr {
+			fmt.Fprint(os.Stderr, line.text)
+		} else {
+			fmt.Print(line.text)
+		}
+	}
+	o.lines = nil
+}
+
+// txLabel returns how a transaction ID is shown: T followed by the whole
+// ID. IDs are derived from the clock, so a shortened ID would be shared by
+// every transaction started within the same stretch of time.
+func txLabel(id string) string {
+	return "T" + id
+}
+
+// ShowTransaction prints the ID, start time, elapsed time, branch and
+// statement count of the current transaction (tx)
+func (s *Shell) ShowTransaction(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: tx")
+	}
+
+	tx := s.CurrentTransaction()
+	if tx == nil {
+		fmt.Println("no active transaction")
+		return nil
+	}
+
+	start := tx.GetStartTime()
+	fmt.Printf("Transaction %s\n", txLabel(tx.GetID()))
+	fmt.Printf("  Started:    %s\n", start.Format("2006-01-02 15:04:05"))
+	fmt.Printf("  Elapsed:    %s\n", time.Since(start).Round(time.Millisecond))
+	fmt.Printf("  Branch:     %s\n", tx.GetBranchID())
+	isolation := "default"
+	if level := tx.GetIsolation(); level != sql.LevelDefault {
+		isolation = strings.ToLower(level.String())
+	}
+	fmt.Printf("  Isolation:  %s\n", isolation)
+	fmt.Printf("  Read-only:  %t\n", tx.IsReadOnly())
+	fmt.Printf("  Statements: %d\n", tx.GetStatementCount())
+	return nil
+}
+
+// savepointName matches the savepoint names accepted by the shell. Names are
+// spliced into SQL, so only identifiers are allowed.
+var savepointName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ManageSavepoint creates, rolls back to or releases a savepoint in the
+// current transaction (savepoint, rollback-to or release <name>)
+func (s *Shell) ManageSavepoint(cmd string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s <name>", cmd)
+	}
+	name := args[0]
+	if !savepointName.MatchString(name) {
+		return fmt.Errorf("invalid savepoint name: %s", name)
+	}
+
+	tx := s.CurrentTransaction()
+	if tx == nil {
+		return fmt.Errorf("no transaction in progress")
+	}
+
+	switch cmd {
+	case "savepoint":
+		if err := tx.Savepoint(name); err != nil {
+			return err
+		}
+		fmt.Printf("Savepoint %s created\n", name)
+	case "rollback-to":
+		if err := tx.RollbackToSavepoint(name); err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back to savepoint %s\n", name)
+	case "release":
+		if err := tx.ReleaseSavepoint(name); err != nil {
+			return err
+		}
+		fmt.Printf("Savepoint %s released\n", name)
+	}
+	return nil
+}
+
+// ListTransactions prints recorded transactions, most recent first, with
+// their start and end times and duration
+// (transactions [--branch <b>] [--user <u>] [--status committed|aborted] [-n <count>])
+func (s *Shell) ListTransactions(args []string) error {
+	const usage = "usage: transactions [--branch <b>] [--user <u>] [--status committed|aborted] [-n <count>]"
+	var conditions []string
+	var queryArgs []interface{}
+	limit := DefaultLogEntries
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			return fmt.Errorf(usage)
+		}
+		value := args[i+1]
+		switch args[i] {
+		case "--branch":
+			conditions = append(conditions, "branch_id = ?")
+		case "--user":
+			conditions = append(conditions, "user_id = ?")
+		case "--status":
+			if value != schema.TransactionStatusCommitted && value != schema.TransactionStatusAborted {
+				return fmt.Errorf("invalid transaction status: %s", value)
+			}
+			conditions = append(conditions, "status = ?")
+		case "-n":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid count: %s", value)
+			}
+			limit = n
+			i++
+			continue
+		default:
+			return fmt.Errorf(usage)
+		}
+		queryArgs = append(queryArgs, value)
+		i++
+	}
+
+	query := `SELECT id, status, user_id, branch_id, start_time, end_time FROM transactions`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY start_time DESC, id DESC LIMIT ?`
+	queryArgs = append(queryArgs, limit)
+
+	var q queryExecutor = s.db
+	if s.CurrentTransaction() != nil {
+		q = s.CurrentTransaction()
+	}
+
+	rows, err := q.ExecuteQuery(query, queryArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to list transactions: %w", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var id, status, user, branch string
+		var start time.Time
+		var end sql.NullTime
+		if err := rows.Scan(&id, &status, &user, &branch, &start, &end); err != nil {
+			return fmt.Errorf("failed to scan transaction: %w", err)
+		}
+
+		ended, duration := "-", "-"
+		if end.Valid {
+			ended = end.Time.Format("2006-01-02 15:04:05")
+			duration = end.Time.Sub(start).Round(time.Millisecond).String()
+		}
+		fmt.Printf("%-20s  %-9s  %-12s %-12s %s  %s  %s\n", txLabel(id), status, user, branch, start.Format("2006-01-02 15:04:05"), ended, duration)
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if count == 0 {
+		fmt.Println("No transactions recorded")
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImplicitTransactionReportsOnceWhenRetried(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /dir")
+	run(t, sh, "touch /a")
+
+	holdReadLock(t, "resources", 25*time.Millisecond)
+	output := run(t, sh, "mkdir -p /x/y")
+	if n := strings.Count(output, "Directory created: /x\n"); n != 1 {
+		t.Errorf("reported /x %d times:\n%s", n, output)
+	}
+
+	holdReadLock(t, "resources", 25*time.Millisecond)
+	output = run(t, sh, "mv /a /dir")
+	if output != "Moved /a to /dir/a\n" {
+		t.Errorf("output = %q", output)
+	}
+	if _, err := sh.lookupResource(sh.db, "/dir/a"); err != nil {
+		t.Errorf("/dir/a: %v", err)
+	}
+}
+
+func TestImplicitTransactionRetriesPermissionsAndCherryPick(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "touch /a")
+	run(t, sh, "checkout -b feature")
+	run(t, sh, "touch /b")
+	run(t, sh, "checkout main")
+
+	holdReadLock(t, "resources", 25*time.Millisecond)
+	output := run(t, sh, "chmod 600 /a")
+	if output != "chmod: updated 1 resource(s), skipped 0\n" {
+		t.Errorf("chmod output = %q", output)
+	}
+
+	holdReadLock(t, "resources", 25*time.Millisecond)
+	output = run(t, sh, "cherry-pick feature /b")
+	if output != "Cherry-picked /b from feature (1 created, 0 updated)\n" {
+		t.Errorf("cherry-pick output = %q", output)
+	}
+	if _, err := sh.lookupResource(sh.db, "/b"); err != nil {
+		t.Errorf("/b: %v", err)
+	}
+}
+
+func TestImplicitTransactionInOpenTransaction(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "begin")
+
+	// Reported straight away, although nothing is committed yet
+	if output := run(t, sh, "mkdir /x"); output != "Directory created: /x\n" {
+		t.Errorf("output = %q", output)
+	}
+	run(t, sh, "abort")
+	if _, err := sh.lookupResource(sh.db, "/x"); err == nil {
+		t.Error("/x exists after abort")
+	}
+}
+
+func TestTransactionLabelsAreDistinct(t *testing.T) {
+	sh := newTestShell(t)
+
+	var started []string
+	for _, dir := range []string{"/a", "/b", "/c"} {
+		output := run(t, sh, "begin")
+		started = append(started, strings.Fields(output)[1])
+		if got := strings.Fields(run(t, sh, "tx"))[1]; got != started[len(started)-1] {
+			t.Errorf("tx shows %s, begin showed %s", got, started[len(started)-1])
+		}
+		run(t, sh, "mkdir "+dir)
+		run(t, sh, "commit")
+	}
+
+	listed := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(run(t, sh, "transactions")), "\n") {
+		listed[strings.Fields(line)[0]] = true
+	}
+	seen := map[string]bool{}
+	for _, label := range started {
+		if seen[label] {
+			t.Errorf("label %s shown for two transactions", label)
+		}
+		seen[label] = true
+		if !listed[label] {
+			t.Errorf("transactions does not list %s", label)
+		}
+	}
+}
+
+// TestCommandsInOpenTransaction runs commands that read outside the resource
+// tree while a transaction holds the in-memory database's only connection
+func TestCommandsInOpenTransaction(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "begin")
+	run(t, sh, "mkdir /x")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, command := range []string{"sessions", "info", "branch list", "branch graph", "user list", "transactions"} {
+			run(t, sh, command)
+		}
+		if output := run(t, sh, "count /"); output != "4\n" {
+			t.Errorf("count / = %q, want the open transaction's /x counted", output)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("commands waited on the open transaction")
+	}
+	run(t, sh, "commit")
+}
+
+func TestShowTransaction(t *testing.T) {
+	sh := newTestShell(t)
+	if output := run(t, sh, "tx"); output != "no active transaction\n" {
+		t.Errorf("tx without a transaction = %q", output)
+	}
+
+	if output := run(t, sh, "begin --serializable"); !strings.HasSuffix(output, " started (serializable)\n") {
+		t.Errorf("begin --serializable = %q", output)
+	}
+	run(t, sh, "mkdir /x")
+	run(t, sh, "touch /x/a")
+	output := run(t, sh, "tx")
+	for _, want := range []string{"  Branch:     main", "  Isolation:  serializable", "  Read-only:  false"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("tx lacks %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "  Statements: 0\n") {
+		t.Errorf("tx counts no statements after two writes:\n%s", output)
+	}
+	if _, err := runErr(sh, "begin"); err == nil {
+		t.Error("begin inside a transaction succeeded")
+	}
+	run(t, sh, "commit")
+}
+
+func TestBeginOptions(t *testing.T) {
+	sh := newTestShell(t)
+	for _, command := range []string{"begin --serializable --read-committed", "begin --dirty"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+
+	if output := run(t, sh, "begin --read-only"); !strings.HasSuffix(output, " started (read-only)\n") {
+		t.Errorf("begin --read-only = %q", output)
+	}
+	run(t, sh, "ls /")
+	if _, err := runErr(sh, "mkdir /x"); err == nil {
+		t.Error("write in a read-only transaction succeeded")
+	}
+	run(t, sh, "rollback")
+}
+
+func TestSavepoints(t *testing.T) {
+	sh := newTestShell(t)
+	if _, err := runErr(sh, "savepoint a"); err == nil {
+		t.Error("savepoint without a transaction succeeded")
+	}
+
+	run(t, sh, "begin")
+	run(t, sh, "mkdir /kept")
+	run(t, sh, "savepoint before_b")
+	run(t, sh, "mkdir /undone")
+	if output := run(t, sh, "rollback-to before_b"); output != "Rolled back to savepoint before_b\n" {
+		t.Errorf("rollback-to = %q", output)
+	}
+	run(t, sh, "savepoint second")
+	run(t, sh, "release second")
+	if _, err := runErr(sh, "rollback-to second"); err == nil {
+		t.Error("rollback to a released savepoint succeeded")
+	}
+	for _, name := range []string{"1st", "a;DROP", "a-b"} {
+		if _, err := runErr(sh, "savepoint "+name); err == nil {
+			t.Errorf("savepoint %q accepted", name)
+		}
+	}
+	run(t, sh, "commit")
+
+	if _, err := sh.lookupResource(sh.db, "/kept"); err != nil {
+		t.Errorf("/kept: %v", err)
+	}
+	if _, err := sh.lookupResource(sh.db, "/undone"); err == nil {
+		t.Error("/undone exists after rolling back to the savepoint")
+	}
+}
+
+func TestAutocommitOff(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "set autocommit off")
+
+	for _, command := range []string{"mkdir /x", "touch /a", "echo a > /a", "chmod 600 /tmp"} {
+		if _, err := runErr(sh, command); err == nil || !strings.Contains(err.Error(), "autocommit is off") {
+			t.Errorf("%s without a transaction: %v", command, err)
+		}
+	}
+	// Reading and dry runs need no transaction
+	run(t, sh, "ls /")
+	run(t, sh, "chmod --dry-run 600 /tmp")
+
+	run(t, sh, "begin")
+	run(t, sh, "mkdir /x")
+	run(t, sh, "commit")
+	if _, err := sh.lookupResource(sh.db, "/x"); err != nil {
+		t.Errorf("/x: %v", err)
+	}
+}
+
+func TestCommitReportsChanges(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo a > /tmp/updated")
+	run(t, sh, "touch /tmp/deleted")
+	run(t, sh, "touch /tmp/moved")
+
+	run(t, sh, "begin")
+	run(t, sh, "touch /tmp/new")
+	run(t, sh, "echo b > /tmp/updated")
+	run(t, sh, "echo c > /tmp/updated")
+	run(t, sh, "rm /tmp/deleted")
+	run(t, sh, "mv /tmp/moved /tmp/renamed")
+	if output := run(t, sh, "commit"); !strings.HasSuffix(output, " committed (2 created, 1 updated, 2 deleted)\n") {
+		t.Errorf("commit = %q", output)
+	}
+
+	run(t, sh, "begin")
+	run(t, sh, "touch /tmp/discarded")
+	if output := run(t, sh, "abort"); !strings.HasSuffix(output, " aborted (1 created, 0 updated, 0 deleted)\n") {
+		t.Errorf("abort = %q", output)
+	}
+	run(t, sh, "begin")
+	if output := run(t, sh, "commit"); !strings.HasSuffix(output, " committed (no changes)\n") {
+		t.Errorf("empty commit = %q", output)
+	}
+	if _, err := runErr(sh, "commit"); err == nil {
+		t.Error("commit without a transaction succeeded")
+	}
+}
+
+func TestListTransactionsFilters(t *testing.T) {
+	sh := newTestShell(t)
+	addTestUser(t, sh, "alice")
+	run(t, sh, "mkdir /a")
+	sh.state.User = "alice"
+	run(t, sh, "touch /tmp/b")
+	run(t, sh, "begin")
+	run(t, sh, "touch /tmp/c")
+	run(t, sh, "abort")
+	sh.state.User = "system"
+
+	lines := func(command string) []string {
+		return strings.Split(strings.TrimSpace(run(t, sh, command)), "\n")
+	}
+	for _, line := range lines("transactions --user alice") {
+		if !strings.Contains(line, " alice ") {
+			t.Errorf("transactions --user alice listed %q", line)
+		}
+	}
+	aborted := lines("transactions --status aborted")
+	if len(aborted) != 1 || !strings.Contains(aborted[0], "aborted") {
+		t.Errorf("transactions --status aborted = %q", aborted)
+	}
+	if got := lines("transactions -n 2"); len(got) != 2 {
+		t.Errorf("transactions -n 2 listed %d", len(got))
+	}
+	if output := run(t, sh, "transactions --branch feature"); output != "No transactions recorded\n" {
+		t.Errorf("transactions on a branch without any = %q", output)
+	}
+	for _, command := range []string{"transactions --status open", "transactions -n 0", "transactions --user", "transactions --bogus x"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// EmptyTrash permanently deletes the history of the resources removed from
+// the current branch with rm and not created again since
+// (empty-trash [-f|--yes])
+func (s *Shell) EmptyTrash(args []string) error {
+	flags, args, err := splitFlags("empty-trash", args, "-f", "--yes")
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return fmt.Errorf("usage: empty-trash [-f|--yes]")
+	}
+	if s.CurrentTransaction() != nil {
+		return fmt.Errorf("cannot empty the trash while a transaction is in progress")
+	}
+
+	paths, err := s.trashPaths(nil)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		fmt.Println("Trash is empty")
+		return nil
+	}
+
+	ok, err := s.confirm(flags, fmt.Sprintf("This permanently deletes %d removed resource(s) and their history", len(paths)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("empty-trash: aborted")
+		return nil
+	}
+
+	var resources, versions int64
+	err = s.db.WithTransaction(func(tx *database.Transaction) error {
+		tx.SetBranchID(s.state.CurrentBranch)
+		tx.SetUserID(s.state.User)
+
+		// Resources may have been removed or created again since the
+		// listing above
+		paths, err := s.trashPaths(tx)
+		if err != nil {
+			return err
+		}
+		resources, versions = int64(len(paths)), 0
+		for _, path := range paths {
+			result, err := tx.Execute(`
+				DELETE FROM resources WHERE branch_id = ? AND path = ? AND valid_to IS NOT NULL
+			`, s.state.CurrentBranch, path)
+			if err != nil {
+				return fmt.Errorf("failed to delete history of %s: %w", path, err)
+			}
+			if n, err := result.RowsAffected(); err == nil {
+				versions += n
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted %d version(s) of %d removed resource(s)\n", versions, resources)
+	return nil
+}
+
+// trashPaths returns the paths on the current branch that rm removed
+// resources from and that have no current resource, in path order, reading
+// in tx when it is not nil
+func (s *Shell) trashPaths(tx *database.Transaction) ([]string, error) {
+	var q queryExecutor = s.db
+	if tx != nil {
+		q = tx
+	}
+	rows, err := q.ExecuteQuery(`SELECT affected_resources FROM operations WHERE kind = ?`, schema.OperationKindDelete)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read removals: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var affected string
+		if err := rows.Scan(&affected); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan removal: %w", err)
+		}
+		var removed []string
+		if err := json.Unmarshal([]byte(affected), &removed); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to parse removed resources: %w", err)
+		}
+		ids = append(ids, removed...)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	for _, id := range ids {
+		path, found, err := queryString(q, `SELECT path FROM resources WHERE id = ? AND branch_id = ?`, id, s.state.CurrentBranch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up removed resource: %w", err)
+		}
+		if !found || seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		current, err := s.statCurrent(tx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up %s: %w", path, err)
+		}
+		if current == nil {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// queryString returns the first column of the first row of a query, and
+// whether there was a row
+func queryString(q queryExecutor, query string, args ...interface{}) (string, bool, error) {
+	rows, err := q.ExecuteQuery(query, args...)
+	if err != nil {
+		return "", false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", false, rows.Err()
+	}
+	var value string
+	if err := rows.Scan(&value); err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// CollectGarbage deletes the content in the inline store that no version of
+// any resource uses (gc [-f|--yes]). Content of removed resources is kept
+// until empty-trash deletes their history. Content in a directory store is
+// not collected, so gc fails while one is active.
+func (s *Shell) CollectGarbage(args []string) error {
+	flags, args, err := splitFlags("gc", args, "-f", "--yes")
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return fmt.Errorf("usage: gc [-f|--yes]")
+	}
+	if s.CurrentTransaction() != nil {
+		return fmt.Errorf("cannot collect garbage while a transaction is in progress")
+	}
+
+	unused, err := s.fm.UnusedContent(nil)
+	if err != nil {
+		return err
+	}
+	if len(unused) == 0 {
+		fmt.Println("No unused content")
+		return nil
+	}
+
+	ok, err := s.confirm(flags, fmt.Sprintf("This permanently deletes %d unused content blob(s)", len(unused)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("gc: aborted")
+		return nil
+	}
+
+	var deleted int
+	err = s.db.WithTransaction(func(tx *database.Transaction) error {
+		var err error
+		deleted, err = s.fm.CollectGarbage(tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted %d unused content blob(s)\n", deleted)
+	return nil
+}
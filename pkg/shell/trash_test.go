@@ -0,0 +1,187 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRemove(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /docs")
+	createRaw(t, sh, "/docs/a.txt", "a")
+	createRaw(t, sh, "/docs/b.txt", "b")
+	run(t, sh, "tag /docs/a.txt keep")
+
+	if _, err := runErr(sh, "rm /docs"); err == nil || !strings.Contains(err.Error(), "not empty") {
+		t.Errorf("rm of a non-empty directory: got %v", err)
+	}
+
+	if output := run(t, sh, "rm /docs/a.txt"); output != "Removed /docs/a.txt\n" {
+		t.Errorf("output = %q", output)
+	}
+	if _, err := sh.lookupResource(sh.db, "/docs/a.txt"); err == nil {
+		t.Error("/docs/a.txt still exists")
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM tags WHERE tag = ?`, "keep"); n != 0 {
+		t.Errorf("%d tag(s) left on the removed file", n)
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE path = ?`, "/docs/a.txt"); n != 1 {
+		t.Errorf("/docs/a.txt has %d version(s) in its history, want 1", n)
+	}
+
+	if output := run(t, sh, "rm -r /docs"); output != "Removed /docs (2 resources)\n" {
+		t.Errorf("output = %q", output)
+	}
+	if _, err := sh.lookupResource(sh.db, "/docs/b.txt"); err == nil {
+		t.Error("/docs/b.txt still exists")
+	}
+	if _, err := runErr(sh, "rm /"); err == nil {
+		t.Error("removed the root directory")
+	}
+}
+
+func TestRemoveConfirmation(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		input   string
+		removed bool
+	}{
+		{"yes", "rm -r /docs", "y\n", true},
+		{"no", "rm -r /docs", "n\n", false},
+		{"end of input", "rm -r /docs", "", false},
+		{"force", "rm -r -f /docs", "", true},
+		{"yes flag", "rm -r --yes /docs", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sh := newTestShell(t)
+			run(t, sh, "mkdir /docs")
+			createRaw(t, sh, "/docs/a.txt", "a")
+			sh.state.IsInteractive = true
+			setInput(sh, tt.input)
+
+			output := run(t, sh, tt.command)
+			prompted := strings.Contains(output, "Are you sure? [y/N]")
+			if forced := strings.Contains(tt.command, "-f") || strings.Contains(tt.command, "--yes"); prompted == forced {
+				t.Errorf("prompted = %v with %q", prompted, tt.command)
+			}
+			_, err := sh.lookupResource(sh.db, "/docs/a.txt")
+			if removed := err != nil; removed != tt.removed {
+				t.Errorf("removed = %v, want %v\noutput:\n%s", removed, tt.removed, output)
+			}
+			if !tt.removed && !strings.Contains(output, "rm: aborted") {
+				t.Errorf("output does not report the abort:\n%s", output)
+			}
+		})
+	}
+}
+
+func TestEmptyTrash(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir /docs")
+	createRaw(t, sh, "/docs/a.txt", "a")
+	createRaw(t, sh, "/docs/b.txt", "b")
+	createRaw(t, sh, "/keep.txt", "keep")
+	run(t, sh, "mv /keep.txt /kept.txt")
+	run(t, sh, "rm /docs/a.txt")
+
+	sh.state.IsInteractive = true
+	setInput(sh, "n\n")
+	if output := run(t, sh, "empty-trash"); !strings.Contains(output, "empty-trash: aborted") {
+		t.Errorf("output = %q", output)
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE path = ?`, "/docs/a.txt"); n != 1 {
+		t.Errorf("aborted empty-trash left %d version(s) of /docs/a.txt", n)
+	}
+
+	setInput(sh, "y\n")
+	output := run(t, sh, "empty-trash")
+	if !strings.Contains(output, "Deleted 1 version(s) of 1 removed resource(s)") {
+		t.Errorf("output = %q", output)
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE path = ?`, "/docs/a.txt"); n != 0 {
+		t.Errorf("/docs/a.txt has %d version(s) left", n)
+	}
+	// History left behind by mv is not trash
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE path = ?`, "/keep.txt"); n != 1 {
+		t.Errorf("/keep.txt has %d version(s), want its history kept", n)
+	}
+	if output := run(t, sh, "empty-trash"); output != "Trash is empty\n" {
+		t.Errorf("second empty-trash: %q", output)
+	}
+}
+
+func TestEmptyTrashSkipsRecreatedPath(t *testing.T) {
+	sh := newTestShell(t)
+	createRaw(t, sh, "/a.txt", "one")
+	run(t, sh, "rm /a.txt")
+	createRaw(t, sh, "/a.txt", "two")
+
+	if output := run(t, sh, "empty-trash"); output != "Trash is empty\n" {
+		t.Errorf("output = %q", output)
+	}
+	if got := readFile(t, sh, "/a.txt"); got != "two" {
+		t.Errorf("content = %q", got)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "set store inline")
+	createRaw(t, sh, "/a.txt", "only in a")
+	createRaw(t, sh, "/b.txt", "only in b")
+	run(t, sh, "rm /a.txt")
+
+	// The removed file's history still uses its content
+	if output := run(t, sh, "gc"); output != "No unused content\n" {
+		t.Errorf("gc before empty-trash: %q", output)
+	}
+
+	run(t, sh, "empty-trash")
+	sh.state.IsInteractive = true
+	setInput(sh, "n\n")
+	if output := run(t, sh, "gc"); !strings.Contains(output, "gc: aborted") {
+		t.Errorf("output = %q", output)
+	}
+	if output := run(t, sh, "gc -f"); output != "Deleted 1 unused content blob(s)\n" {
+		t.Errorf("output = %q", output)
+	}
+	if got := readFile(t, sh, "/b.txt"); got != "only in b" {
+		t.Errorf("content of /b.txt = %q", got)
+	}
+	if n := countRows(t, sh, `SELECT COUNT(*) FROM blobs`); n != 1 {
+		t.Errorf("%d blob(s) left, want 1", n)
+	}
+}
+
+func TestCollectGarbageDirStore(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "set store dir:"+t.TempDir())
+	_, err := runErr(sh, "gc -f")
+	if err == nil || !strings.Contains(err.Error(), "does not support") {
+		t.Errorf("gc with a directory store: %v, want an unsupported store error", err)
+	}
+}
+
+func TestDeleteBranchConfirmation(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "branch feature")
+	sh.state.IsInteractive = true
+
+	setInput(sh, "n\n")
+	if output := run(t, sh, "branch -d feature"); !strings.Contains(output, "branch: aborted") {
+		t.Errorf("output = %q", output)
+	}
+	if status, _ := sh.branchStatus("feature"); status != "active" {
+		t.Errorf("status after abort = %q", status)
+	}
+
+	setInput(sh, "")
+	if output := run(t, sh, "branch -d -f feature"); output != "Branch feature deleted\n" {
+		t.Errorf("output = %q", output)
+	}
+	if status, _ := sh.branchStatus("feature"); status != "abandoned" {
+		t.Errorf("status = %q, want abandoned", status)
+	}
+}
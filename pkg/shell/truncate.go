@@ -0,0 +1,75 @@
+package shell
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// TruncateFile writes a new version of a file cut to a size in bytes, zero
+// by default (truncate <path> [--size N]). A size beyond the current
+// content pads it with zero bytes. Earlier content stays in history.
+func (s *Shell) TruncateFile(args []string) error {
+	var size int64
+	var target string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--size":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--size requires a value")
+			}
+			i++
+			n, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid size: %s", args[i])
+			}
+			size = n
+		case target == "":
+			target = args[i]
+		default:
+			return fmt.Errorf("usage: truncate <path> [--size N]")
+		}
+	}
+	if target == "" {
+		return fmt.Errorf("usage: truncate <path> [--size N]")
+	}
+
+	path, err := s.resolveLocalPath(target)
+	if err != nil {
+		return err
+	}
+
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		existing, err := s.lookupResource(tx, path)
+		if err != nil {
+			return err
+		}
+		if existing.Type != schema.ResourceTypeFile {
+			return fmt.Errorf("%s is a %s", path, existing.Type)
+		}
+
+		options, err := branchOptions(s.state.CurrentBranch, nil)
+		if err != nil {
+			return err
+		}
+		current, err := s.fm.GetFile(path, tx, options)
+		if err != nil {
+			return err
+		}
+
+		content := make([]byte, size)
+		copy(content, current.Content)
+
+		file, err := s.fm.UpdateFile(path, content, tx)
+		if err != nil {
+			return err
+		}
+		if err := s.recordOperation(tx, schema.OperationKindUpdate, []string{file.ID}); err != nil {
+			return err
+		}
+		out.Printf("Truncated %s to %d byte(s)\n", path, size)
+		return nil
+	})
+}
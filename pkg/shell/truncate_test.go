@@ -0,0 +1,41 @@
+package shell
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTruncate(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo hello world > /tmp/a")
+	before := time.Now()
+	time.Sleep(5 * time.Millisecond)
+
+	run(t, sh, "truncate /tmp/a --size 5")
+	if got := readFile(t, sh, "/tmp/a"); got != "hello" {
+		t.Errorf("truncated to 5 = %q", got)
+	}
+	if m := metadataOf(t, sh, "/tmp/a"); m.Size != 5 {
+		t.Errorf("size after truncate = %d", m.Size)
+	}
+
+	run(t, sh, "truncate /tmp/a --size 7")
+	if got := readFile(t, sh, "/tmp/a"); got != "hello\x00\x00" {
+		t.Errorf("extended to 7 = %q", got)
+	}
+	run(t, sh, "truncate /tmp/a")
+	if got := readFile(t, sh, "/tmp/a"); got != "" {
+		t.Errorf("truncated to 0 = %q", got)
+	}
+
+	// Earlier content stays in history
+	if got := run(t, sh, "cat --at "+before.UTC().Format(time.RFC3339Nano)+" /tmp/a"); got != "hello world\n" {
+		t.Errorf("content before truncating = %q", got)
+	}
+
+	for _, command := range []string{"truncate /tmp", "truncate /tmp/missing", "truncate /tmp/a --size -1", "truncate /tmp/a --size", "truncate"} {
+		if _, err := runErr(sh, command); err == nil {
+			t.Errorf("%s succeeded", command)
+		}
+	}
+}
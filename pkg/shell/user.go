@@ -0,0 +1,242 @@
+package shell
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// userNamePattern is the allowed form of user names, which also name home
+// directories
+var userNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// ManageUsers adds, disables and lists users and changes their passwords
+// (user add [--admin] <name> | passwd [name] | disable <name> | list).
+// Everything but changing one's own password requires an administrator.
+func (s *Shell) ManageUsers(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: user add [--admin] <name> | passwd [name] | disable <name> | list")
+	}
+
+	switch args[0] {
+	case "add":
+		return s.addUser(args[1:])
+	case "passwd":
+		return s.changePassword(args[1:])
+	case "disable":
+		return s.disableUser(args[1:])
+	case "list":
+		return s.listUsers(args[1:])
+	default:
+		return fmt.Errorf("unknown user command: %s", args[0])
+	}
+}
+
+// requireAdmin refuses a user command to anyone but an active administrator
+func (s *Shell) requireAdmin(q queryExecutor, action string) error {
+	admin, err := s.isAdmin(q)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("%w: only an administrator can %s", database.ErrPermissionDenied, action)
+	}
+	return nil
+}
+
+// addUser creates an active user with a password read from the input
+func (s *Shell) addUser(args []string) error {
+	flags, args, err := splitFlags("user add", args, "--admin")
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: user add [--admin] <name>")
+	}
+	name := args[0]
+	if !userNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid user name: %s", name)
+	}
+
+	if err := s.requireAdmin(s.queries(), "add users"); err != nil {
+		return err
+	}
+	exists, err := s.userExists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("user %w: %s", database.ErrAlreadyExists, name)
+	}
+
+	hash, err := s.readPassword(name)
+	if err != nil {
+		return err
+	}
+
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		id, err := tx.NextID("user")
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		_, err = tx.Execute(`
+			INSERT INTO users (id, username, password, created_at, updated_at, is_active, is_admin)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, id, name, hash, now, now, true, flags["--admin"])
+		if err != nil {
+			return fmt.Errorf("failed to add user: %w", err)
+		}
+		out.Printf("User %s added\n", name)
+		return nil
+	})
+}
+
+// changePassword sets a user's password, by default the shell user's own.
+// Changing another user's password requires an administrator.
+func (s *Shell) changePassword(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: user passwd [name]")
+	}
+	name := s.state.User
+	if len(args) == 1 {
+		name = args[0]
+	}
+
+	if name != s.state.User {
+		if err := s.requireAdmin(s.queries(), "change another user's password"); err != nil {
+			return err
+		}
+	}
+	exists, err := s.userExists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("user %w: %s", database.ErrNotFound, name)
+	}
+
+	hash, err := s.readPassword(name)
+	if err != nil {
+		return err
+	}
+	_, err = s.execute(`UPDATE users SET password = ?, updated_at = ? WHERE username = ?`, hash, time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	fmt.Printf("Password changed for %s\n", name)
+	return nil
+}
+
+// disableUser marks a user inactive. Administrators cannot disable
+// themselves, so that one always remains.
+func (s *Shell) disableUser(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: user disable <name>")
+	}
+	name := args[0]
+
+	if err := s.requireAdmin(s.queries(), "disable users"); err != nil {
+		return err
+	}
+	if name == s.state.User {
+		return fmt.Errorf("cannot disable your own user")
+	}
+
+	result, err := s.execute(`UPDATE users SET is_active = ?, updated_at = ? WHERE username = ?`, false, time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("user %w: %s", database.ErrNotFound, name)
+	}
+
+	fmt.Printf("User %s disabled\n", name)
+	return nil
+}
+
+// listUsers prints every user with their role, status and last login
+func (s *Shell) listUsers(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: user list")
+	}
+	if err := s.requireAdmin(s.queries(), "list users"); err != nil {
+		return err
+	}
+
+	rows, err := s.queries().ExecuteQuery(`
+		SELECT username, is_admin, is_active, last_login FROM users ORDER BY username
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	fmt.Printf("%-20s %-6s %-9s %s\n", "USER", "ROLE", "STATUS", "LAST LOGIN")
+	for rows.Next() {
+		var name string
+		var admin, active bool
+		var lastLogin sql.NullTime
+		if err := rows.Scan(&name, &admin, &active, &lastLogin); err != nil {
+			return fmt.Errorf("failed to scan user: %w", err)
+		}
+
+		role, status, login := "user", "active", "never"
+		if admin {
+			role = "admin"
+		}
+		if !active {
+			status = "disabled"
+		}
+		if lastLogin.Valid {
+			login = lastLogin.Time.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-20s %-6s %-9s %s\n", name, role, status, login)
+	}
+	return rows.Err()
+}
+
+// userExists reports whether a user with the given name exists
+func (s *Shell) userExists(name string) (bool, error) {
+	rows, err := s.queries().ExecuteQuery(`SELECT 1 FROM users WHERE username = ?`, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up user: %w", err)
+	}
+	defer rows.Close()
+	return rows.Next(), rows.Err()
+}
+
+// readPassword reads a new password for a user from the input and returns
+// its hash. At an interactive prompt the password is asked for twice. The
+// input is not hidden.
+func (s *Shell) readPassword(name string) (string, error) {
+	if s.state.IsInteractive {
+		fmt.Printf("New password for %s: ", name)
+	}
+	password, err := s.readLine()
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	if password == "" {
+		return "", fmt.Errorf("password must not be empty")
+	}
+
+	if s.state.IsInteractive {
+		fmt.Printf("Retype password for %s: ", name)
+		again, err := s.readLine()
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		if again != password {
+			return "", fmt.Errorf("passwords do not match")
+		}
+	}
+
+	return util.HashPassword(password)
+}
@@ -0,0 +1,91 @@
+package shell
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/internal/util"
+	"github.com/brainwavecollective/stone-os/pkg/database"
+)
+
+// passwordHash returns the stored password hash of a user
+func passwordHash(t *testing.T, sh *Shell, name string) string {
+	t.Helper()
+	rows, err := sh.db.ExecuteQuery(`SELECT password FROM users WHERE username = ?`, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var hash string
+	if rows.Next() {
+		if err := rows.Scan(&hash); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return hash
+}
+
+func TestUserCommands(t *testing.T) {
+	sh := newTestShell(t)
+
+	setInput(sh, "secret\n")
+	run(t, sh, "user add alice")
+	if !util.VerifyPassword(passwordHash(t, sh, "alice"), "secret") {
+		t.Error("stored password does not verify")
+	}
+	setInput(sh, "other\n")
+	if _, err := runErr(sh, "user add alice"); !errors.Is(err, database.ErrAlreadyExists) {
+		t.Errorf("adding an existing user: %v, want ErrAlreadyExists", err)
+	}
+	setInput(sh, "")
+	if _, err := runErr(sh, "user add bob"); err == nil {
+		t.Error("user added with an empty password")
+	}
+	if _, err := runErr(sh, "user add ../bob"); err == nil {
+		t.Error("user added with an invalid name")
+	}
+
+	setInput(sh, "changed\n")
+	run(t, sh, "user passwd alice")
+	if !util.VerifyPassword(passwordHash(t, sh, "alice"), "changed") {
+		t.Error("password not changed")
+	}
+
+	output := run(t, sh, "user list")
+	if !strings.Contains(output, "alice") || !strings.Contains(output, "system               admin  active") {
+		t.Errorf("user list:\n%s", output)
+	}
+
+	if _, err := runErr(sh, "user disable system"); err == nil {
+		t.Error("an administrator disabled themselves")
+	}
+	run(t, sh, "user disable alice")
+	if output := run(t, sh, "user list"); !strings.Contains(output, "disabled") {
+		t.Errorf("user list after disable:\n%s", output)
+	}
+	if _, err := runErr(sh, "user disable nobody"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("disabling a missing user: %v, want ErrNotFound", err)
+	}
+}
+
+func TestUserCommandsRequireAdmin(t *testing.T) {
+	sh := newTestShell(t)
+	addTestUser(t, sh, "alice")
+	addTestUser(t, sh, "bob")
+	sh.state.User = "alice"
+
+	for _, command := range []string{"user add carol", "user passwd bob", "user disable bob", "user list"} {
+		setInput(sh, "secret\n")
+		if _, err := runErr(sh, command); !errors.Is(err, database.ErrPermissionDenied) {
+			t.Errorf("%s by a user: %v, want ErrPermissionDenied", command, err)
+		}
+	}
+
+	// Anyone may change their own password
+	setInput(sh, "mine\n")
+	run(t, sh, "user passwd")
+	if !util.VerifyPassword(passwordHash(t, sh, "alice"), "mine") {
+		t.Error("own password not changed")
+	}
+}
@@ -0,0 +1,131 @@
+package shell
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// variableNamePattern matches valid shell variable names
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SetVariable sets a shell variable (set-var NAME VALUE...), or lists all
+// variables when called without arguments
+func (s *Shell) SetVariable(args []string) error {
+	if len(args) == 0 {
+		names := make([]string, 0, len(s.state.Variables))
+		for name := range s.state.Variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s=%s\n", name, s.state.Variables[name])
+		}
+		return nil
+	}
+
+	name := args[0]
+	if !variableNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid variable name: %s", name)
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("usage: set-var NAME VALUE")
+	}
+
+	if s.state.Variables == nil {
+		s.state.Variables = make(map[string]string)
+	}
+	s.state.Variables[name] = strings.Join(args[1:], " ")
+	return nil
+}
+
+// expandVariables substitutes $NAME and ${NAME} from the shell variables.
+// Text inside single quotes is left alone and \$ produces a literal dollar
+// sign. Undefined variables expand to "" unless nounset is on.
+func (s *Shell) expandVariables(cmdStr string) (string, error) {
+	if !strings.Contains(cmdStr, "$") {
+		return cmdStr, nil
+	}
+
+	var out strings.Builder
+	inSingleQuote := false
+
+	for i := 0; i < len(cmdStr); i++ {
+		c := cmdStr[i]
+
+		switch {
+		case c == '\'':
+			inSingleQuote = !inSingleQuote
+			out.WriteByte(c)
+
+		case inSingleQuote:
+			out.WriteByte(c)
+
+		case c == '\\' && i+1 < len(cmdStr) && cmdStr[i+1] == '$':
+			out.WriteByte('$')
+			i++
+
+		case c == '$':
+			name, consumed, err := parseVariableReference(cmdStr[i+1:])
+			if err != nil {
+				return "", err
+			}
+			if consumed == 0 {
+				out.WriteByte(c)
+				continue
+			}
+
+			value, ok := s.state.Variables[name]
+			if !ok && s.state.NoUnset {
+				return "", fmt.Errorf("%s: unbound variable", name)
+			}
+			out.WriteString(value)
+			i += consumed
+
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	return out.String(), nil
+}
+
+// commandName returns the first word of a command line
+func commandName(cmdStr string) string {
+	fields := strings.Fields(cmdStr)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// parseVariableReference parses the name following a '$', returning the name
+// and the number of bytes consumed (0 when no reference follows)
+func parseVariableReference(s string) (string, int, error) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.Index(s, "}")
+		if end < 0 {
+			return "", 0, fmt.Errorf("unterminated ${ in command")
+		}
+		name := s[1:end]
+		if !variableNamePattern.MatchString(name) {
+			return "", 0, fmt.Errorf("bad substitution: ${%s}", name)
+		}
+		return name, end + 1, nil
+	}
+
+	n := 0
+	for n < len(s) && (s[n] == '_' || isAlnum(s[n])) {
+		if n == 0 && s[n] >= '0' && s[n] <= '9' {
+			break
+		}
+		n++
+	}
+	return s[:n], n, nil
+}
+
+// isAlnum reports whether c is an ASCII letter or digit
+func isAlnum(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,56 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandVariables(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "set-var NAME world")
+
+	tests := []struct {
+		in, want string
+	}{
+		{"echo $NAME", "echo world"},
+		{"echo ${NAME}s", "echo worlds"},
+		{"echo '$NAME'", "echo '$NAME'"},
+		{`echo \$NAME`, "echo $NAME"},
+		{"echo $UNSET.", "echo ."},
+		{"echo $ 5", "echo $ 5"},
+	}
+	for _, tt := range tests {
+		got, err := sh.expandVariables(tt.in)
+		if err != nil {
+			t.Errorf("expandVariables(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("expandVariables(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := sh.expandVariables("echo ${NAME"); err == nil {
+		t.Error("unterminated ${ accepted")
+	}
+	sh.state.NoUnset = true
+	if _, err := sh.expandVariables("echo $UNSET"); err == nil {
+		t.Error("unbound variable accepted with nounset on")
+	}
+}
+
+// TestQuerySkipsExpansion passes $ in SQL through to the database, here in
+// a quoted column alias
+func TestQuerySkipsExpansion(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "set-var NAME world")
+
+	output := run(t, sh, `query SELECT 1 AS "$NAME"`)
+	if !strings.Contains(output, "$NAME") {
+		t.Errorf("query expanded $NAME:\n%s", output)
+	}
+
+	if output := run(t, sh, `echo "$NAME"`); !strings.Contains(output, "world") {
+		t.Errorf("echo did not expand $NAME: %q", output)
+	}
+}
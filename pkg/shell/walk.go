@@ -0,0 +1,159 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	pathpkg "path"
+	"strings"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/filesystem"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// Default limits for recursive operations
+const (
+	DefaultMaxWalkNodes = 10000
+	DefaultMaxWalkDepth = 64
+)
+
+// walkLimits bounds a subtree traversal; zero means unlimited
+type walkLimits struct {
+	MaxNodes int
+	MaxDepth int
+}
+
+// walkFunc is called for each resource visited during a walk
+type walkFunc func(entry *resourceEntry, depth int) error
+
+// getWalkLimits returns the limits for a recursive operation, lifted entirely
+// when the command was given --force
+func (s *Shell) getWalkLimits(force bool) walkLimits {
+	if force {
+		return walkLimits{}
+	}
+	return walkLimits{
+		MaxNodes: s.state.MaxWalkNodes,
+		MaxDepth: s.state.MaxWalkDepth,
+	}
+}
+
+// walkSubtree visits root and all of its descendants, parents before
+// children, using FileManager.Walk. It is the single traversal used by
+// recursive commands and aborts once the limits are exceeded. Symlinks are
+// visited as themselves.
+func (s *Shell) walkSubtree(tx *database.Transaction, root *resourceEntry, options database.QueryOptions, limits walkLimits, fn walkFunc) error {
+	w := &subtreeWalk{shell: s, tx: tx, options: options, limits: limits, fn: fn}
+	return w.walk(root.Path, root.Path, 0, nil)
+}
+
+// walkSubtreeFollowing is walkSubtree for -L/--follow-symlinks: a symlink to
+// a file is visited as that file, and one to a directory as that directory
+// with its contents below the link's path. A link that would lead back into
+// a directory already being walked through is reported and visited as
+// itself, as are links that do not resolve.
+func (s *Shell) walkSubtreeFollowing(tx *database.Transaction, root *resourceEntry, options database.QueryOptions, limits walkLimits, fn walkFunc) error {
+	w := &subtreeWalk{shell: s, tx: tx, options: options, limits: limits, fn: fn, follow: true}
+	return w.walk(root.Path, root.Path, 0, nil)
+}
+
+// subtreeWalk is the state of a walkSubtree traversal, shared by the walks of
+// the directories it follows symlinks into
+type subtreeWalk struct {
+	shell   *Shell
+	tx      *database.Transaction
+	options database.QueryOptions
+	limits  walkLimits
+	fn      walkFunc
+	follow  bool
+	visited int
+}
+
+// walk visits the resources under root, showing them under shownRoot. A
+// walk entered through a symlink starts at baseDepth and does not visit
+// its root again; links lists the symlinks followed to get there.
+func (w *subtreeWalk) walk(root, shownRoot string, baseDepth int, links []string) error {
+	rootDepth := pathDepth(root)
+
+	return w.shell.fm.Walk(root, w.tx, w.options, func(resource *schema.Resource) error {
+		if links != nil && resource.Path == root {
+			return nil
+		}
+
+		w.visited++
+		if w.limits.MaxNodes > 0 && w.visited > w.limits.MaxNodes {
+			return fmt.Errorf("operation exceeds limit of %d resources, use --force", w.limits.MaxNodes)
+		}
+
+		depth := baseDepth + pathDepth(resource.Path) - rootDepth
+		if w.limits.MaxDepth > 0 && depth > w.limits.MaxDepth {
+			return fmt.Errorf("operation exceeds depth limit of %d, use --force", w.limits.MaxDepth)
+		}
+
+		shownPath := pathpkg.Join(shownRoot, strings.TrimPrefix(resource.Path, root))
+		if resource.Path == root {
+			shownPath = shownRoot
+		}
+
+		visit := resource
+		if w.follow && resource.Type == schema.ResourceTypeSymlink {
+			target, err := w.resolve(resource, links)
+			if err != nil {
+				return err
+			}
+			if target != nil {
+				visit = target
+			}
+		}
+
+		entry, err := newResourceEntry(visit)
+		if err != nil {
+			return err
+		}
+		entry.Name, entry.Path, entry.RealPath = resource.Name, shownPath, visit.Path
+		if err := w.fn(entry, depth); err != nil {
+			return err
+		}
+
+		if visit != resource && visit.Type == schema.ResourceTypeDirectory {
+			return w.walk(visit.Path, shownPath, depth, append(links[:len(links):len(links)], resource.Path))
+		}
+		return nil
+	})
+}
+
+// resolve returns what a symlink met during the walk points to, or nil when
+// it is to be visited as itself: when its target is missing, when the chain
+// of links loops, or when its target is a directory that holds the link or
+// one of the links followed to reach it, which would walk forever
+func (w *subtreeWalk) resolve(link *schema.Resource, links []string) (*schema.Resource, error) {
+	target, err := w.shell.fm.ResolveSymlink(link, w.tx, w.options)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return nil, nil
+	case errors.Is(err, filesystem.ErrTooManyLinks):
+		fmt.Fprintf(os.Stderr, "Warning: not following %s: %v\n", link.Path, filesystem.ErrTooManyLinks)
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if target.Type == schema.ResourceTypeDirectory {
+		for _, followed := range append(links[:len(links):len(links)], link.Path) {
+			if followed == target.Path || strings.HasPrefix(followed, strings.TrimSuffix(target.Path, "/")+"/") {
+				fmt.Fprintf(os.Stderr, "Warning: not following %s: symlink cycle through %s\n", link.Path, target.Path)
+				return nil, nil
+			}
+		}
+	}
+	return target, nil
+}
+
+// pathDepth returns the number of components in a clean absolute path
+func pathDepth(path string) int {
+	if path == "/" {
+		return 0
+	}
+	return strings.Count(path, "/")
+}
@@ -0,0 +1,91 @@
+package shell
+
+import (
+	"encoding/json"
+	pathpkg "path"
+	"strings"
+	"testing"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// createSymlink makes a symlink at path pointing to target. The shell has
+// no command that creates symlinks.
+func createSymlink(t *testing.T, sh *Shell, path, target string) {
+	t.Helper()
+	err := sh.db.WithTransaction(func(tx *database.Transaction) error {
+		parent, err := sh.lookupResource(tx, pathpkg.Dir(path))
+		if err != nil {
+			return err
+		}
+		id, err := newResourceID(tx, schema.ResourceTypeSymlink)
+		if err != nil {
+			return err
+		}
+		metadata := schema.NewResourceMetadata(sh.state.User, 0777)
+		metadata.SymlinkTarget = target
+		metadataJSON, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Execute(`
+			INSERT INTO resources (id, type, name, parent_id, path, metadata, valid_from, transaction_id, branch_id)
+			VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?, ?)
+		`, id, schema.ResourceTypeSymlink, pathpkg.Base(path), parent.ID, path, string(metadataJSON), tx.GetID(), sh.state.CurrentBranch)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWalkLimits(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir -p /tmp/a/b/c")
+	run(t, sh, "touch /tmp/a/b/c/f")
+
+	run(t, sh, "set maxnodes 3")
+	if _, err := runErr(sh, "ls -R /tmp"); err == nil || !strings.Contains(err.Error(), "limit of 3 resources") {
+		t.Errorf("ls -R over maxnodes: %v", err)
+	}
+	run(t, sh, "ls -R --force /tmp")
+
+	run(t, sh, "set maxnodes 0")
+	run(t, sh, "set maxdepth 2")
+	if _, err := runErr(sh, "chmod -R 700 /tmp"); err == nil || !strings.Contains(err.Error(), "depth limit of 2") {
+		t.Errorf("chmod -R over maxdepth: %v", err)
+	}
+	if m := metadataOf(t, sh, "/tmp/a"); m.Permissions == 0700 {
+		t.Error("chmod -R changed permissions before failing on the limit")
+	}
+	run(t, sh, "chmod -R --force 700 /tmp")
+	if m := metadataOf(t, sh, "/tmp/a/b/c/f"); m.Permissions != 0700 {
+		t.Errorf("chmod -R --force: %04o", m.Permissions)
+	}
+}
+
+func TestListFollowingSymlinks(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "mkdir -p /usr/share/docs")
+	run(t, sh, "echo readme > /usr/share/docs/README")
+	run(t, sh, "mkdir /tmp/links")
+	createSymlink(t, sh, "/tmp/links/docs", "/usr/share/docs")
+	createSymlink(t, sh, "/tmp/links/loop", "..")
+	createSymlink(t, sh, "/tmp/links/dangling", "/nowhere")
+
+	plain := run(t, sh, "ls -R /tmp/links")
+	if !strings.Contains(plain, "docs -> /usr/share/docs") || strings.Contains(plain, "README") {
+		t.Errorf("ls -R without -L:\n%s", plain)
+	}
+
+	followed := run(t, sh, "ls -R -L /tmp/links")
+	for _, want := range []string{"docs/", "/tmp/links/docs:\nREADME (7 B)", "loop -> ..", "dangling -> /nowhere"} {
+		if !strings.Contains(followed, want) {
+			t.Errorf("ls -R -L lacks %q:\n%s", want, followed)
+		}
+	}
+	if got := run(t, sh, "find -L /tmp/links -name README"); got != "/tmp/links/docs/README\n" {
+		t.Errorf("find -L = %q", got)
+	}
+}
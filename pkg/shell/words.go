@@ -0,0 +1,73 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// word is one word of a command line
+type word struct {
+	text   string // The word with its quotes removed
+	quoted bool   // Whether any part of it was quoted
+
+	// start and end are the offsets of the word as typed in the line
+	start, end int
+}
+
+// splitWords splits a command line into words at unquoted whitespace. Text
+// between single quotes is taken as it is; between double quotes too,
+// except that \" and \\ stand for " and \. The quotes themselves are
+// removed, so a pair of empty quotes is an empty word.
+func splitWords(line string) ([]word, error) {
+	var words []word
+	var text strings.Builder
+	var quote byte
+	var current *word
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if quote == 0 && strings.IndexByte(" \t\r\n", c) >= 0 {
+			if current != nil {
+				current.text, current.end = text.String(), i
+				words = append(words, *current)
+				current = nil
+				text.Reset()
+			}
+			continue
+		}
+		if current == nil {
+			current = &word{start: i}
+		}
+
+		switch {
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+			current.quoted = true
+		case c == quote:
+			quote = 0
+		case quote == '"' && c == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\'):
+			i++
+			text.WriteByte(line[i])
+		default:
+			text.WriteByte(c)
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if current != nil {
+		current.text, current.end = text.String(), len(line)
+		words = append(words, *current)
+	}
+	return words, nil
+}
+
+// wordTexts returns the text of each word
+func wordTexts(words []word) []string {
+	texts := make([]string, len(words))
+	for i, w := range words {
+		texts[i] = w.text
+	}
+	return texts
+}
@@ -0,0 +1,71 @@
+package shell
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"ls  -h\t/tmp", []string{"ls", "-h", "/tmp"}},
+		{"echo 'a  b' \"c d\"", []string{"echo", "a  b", "c d"}},
+		{"echo don\"'\"t", []string{"echo", "don't"}},
+		{`echo "say \"hi\" \\ \n"`, []string{"echo", `say "hi" \ \n`}},
+		{`echo 'no \' escape`, []string{"echo", `no \`, "escape"}},
+		{"echo '' x", []string{"echo", "", "x"}},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		words, err := splitWords(tt.line)
+		if err != nil {
+			t.Errorf("%q: %v", tt.line, err)
+			continue
+		}
+		if got := wordTexts(words); (len(got) > 0 || len(tt.want) > 0) && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{"echo 'open", `echo "open \"`} {
+		if _, err := splitWords(line); err == nil {
+			t.Errorf("%q: unterminated quote accepted", line)
+		}
+	}
+}
+
+func TestQuotedArguments(t *testing.T) {
+	sh := newTestShell(t)
+	if output := run(t, sh, `echo "a > b" 'c'`); output != "a > b c\n" {
+		t.Errorf("echo printed %q", output)
+	}
+
+	run(t, sh, `echo "hello   world" > "/tmp/my file"`)
+	if got := readFile(t, sh, "/tmp/my file"); got != "hello   world\n" {
+		t.Errorf("/tmp/my file = %q", got)
+	}
+	if output := run(t, sh, `cat '/tmp/my file'`); output != "hello   world\n" {
+		t.Errorf("cat of a quoted path printed %q", output)
+	}
+
+	run(t, sh, `ls "/tmp" > /tmp/listing`)
+	if got := readFile(t, sh, "/tmp/listing"); !strings.Contains(got, "my file") {
+		t.Errorf("redirected ls of a quoted path = %q", got)
+	}
+
+	if output := run(t, sh, `find /tmp -name "my *"`); !strings.Contains(output, "/tmp/my file") {
+		t.Errorf("find with a quoted pattern printed %q", output)
+	}
+
+	// Query takes its SQL as typed, quotes and all
+	if output := run(t, sh, `query SELECT 'a "b"' AS s`); !strings.Contains(output, `a "b"`) {
+		t.Errorf("query lost its quotes:\n%s", output)
+	}
+
+	if _, err := runErr(sh, `echo "unterminated`); err == nil {
+		t.Error("a command with an unterminated quote ran")
+	}
+}
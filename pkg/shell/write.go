@@ -0,0 +1,204 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/brainwavecollective/stone-os/pkg/database"
+	"github.com/brainwavecollective/stone-os/pkg/schema"
+)
+
+// inputSentinel ends content typed at an interactive prompt
+const inputSentinel = "."
+
+// Echo prints its arguments, or writes them to a file with "> file" or
+// appends them with ">> file" (echo [-n] [--type <mime>] [text...]
+// [>|>> file]). ProcessCommand takes the redirect off before passing the
+// rest here. When a file is given without text, the content is read from
+// standard input. Options are only recognized before the text.
+func (s *Shell) Echo(args []string) error {
+	return s.echo(args, "", false)
+}
+
+// echo runs echo with the redirect to target, if any, already taken off args
+func (s *Shell) echo(args []string, target string, appendMode bool) error {
+	newline := true
+	var mimeType string
+	for len(args) > 0 {
+		if args[0] == "-n" {
+			newline = false
+			args = args[1:]
+			continue
+		}
+		if args[0] != "--type" {
+			break
+		}
+		var err error
+		if args, mimeType, err = takeTypeFlag(args); err != nil {
+			return err
+		}
+	}
+
+	text := args
+	if mimeType != "" && target == "" {
+		return fmt.Errorf("--type requires a file to write to")
+	}
+
+	var content []byte
+	if len(text) > 0 || target == "" {
+		content = []byte(strings.Join(text, " "))
+		if newline {
+			content = append(content, '\n')
+		}
+	} else {
+		var err error
+		if content, err = s.readInput(); err != nil {
+			return err
+		}
+	}
+
+	if target == "" {
+		fmt.Print(string(content))
+		return nil
+	}
+	return s.writeContent(target, content, appendMode, mimeType)
+}
+
+// WriteFile writes standard input to a file, replacing or with -a appending
+// to its content (write [-a] [--type <mime>] <file>). Non-interactive input
+// is read until EOF; at an interactive prompt, until a line containing
+// only ".".
+func (s *Shell) WriteFile(args []string) error {
+	args, mimeType, err := takeTypeFlag(args)
+	if err != nil {
+		return err
+	}
+	flags, args, err := splitFlags("write", args, "-a")
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: write [-a] [--type <mime>] <file>")
+	}
+
+	content, err := s.readInput()
+	if err != nil {
+		return err
+	}
+	return s.writeContent(args[0], content, flags["-a"], mimeType)
+}
+
+// takeTypeFlag removes "--type <mime>" from args and returns the MIME type
+// in canonical form, or "" when the flag is absent
+func takeTypeFlag(args []string) ([]string, string, error) {
+	var mimeType string
+	rest := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--type" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, "", fmt.Errorf("--type requires a MIME type")
+		}
+		mediaType, params, err := mime.ParseMediaType(args[i+1])
+		if err != nil || !strings.Contains(mediaType, "/") {
+			return nil, "", fmt.Errorf("invalid MIME type: %s", args[i+1])
+		}
+		mimeType = mime.FormatMediaType(mediaType, params)
+		i++
+	}
+
+	return rest, mimeType, nil
+}
+
+// readInput reads file content from the shell's input
+func (s *Shell) readInput() ([]byte, error) {
+	if !s.state.IsInteractive {
+		content, err := io.ReadAll(s.input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input: %w", err)
+		}
+		return content, nil
+	}
+
+	fmt.Printf("Enter content, ending with a line containing only %q:\n", inputSentinel)
+	var content []byte
+	for {
+		line, err := s.readLine()
+		if err == nil && line == inputSentinel {
+			return content, nil
+		}
+		if line != "" || err == nil {
+			content = append(content, line...)
+			content = append(content, '\n')
+		}
+		if err == io.EOF {
+			return content, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input: %w", err)
+		}
+	}
+}
+
+// writeContent creates or replaces the content of a file on the current
+// branch, or appends to it. A non-empty mimeType replaces the MIME type
+// detected from the file's name.
+func (s *Shell) writeContent(arg string, content []byte, appendMode bool, mimeType string) error {
+	path, err := s.resolveLocalPath(arg)
+	if err != nil {
+		return err
+	}
+
+	return s.withImplicitTransaction(func(tx *database.Transaction, out *txOutput) error {
+		existing, err := s.lookupResource(tx, path)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return err
+		}
+		if existing == nil {
+			metadata := schema.NewResourceMetadata(s.state.User, schema.DefaultFilePermissions)
+			metadata.MimeType = mimeType
+			file, err := s.fm.CreateFileWithMetadata(path, content, metadata, tx)
+			if err != nil {
+				return err
+			}
+			if err := s.recordOperation(tx, schema.OperationKindCreate, []string{file.ID}); err != nil {
+				return err
+			}
+			out.Printf("Wrote %d byte(s) to %s\n", len(content), path)
+			return nil
+		}
+
+		if existing.Type != schema.ResourceTypeFile {
+			return fmt.Errorf("%s is a %s", path, existing.Type)
+		}
+
+		newContent := content
+		if appendMode {
+			options, err := branchOptions(s.state.CurrentBranch, nil)
+			if err != nil {
+				return err
+			}
+			current, err := s.fm.GetFile(path, tx, options)
+			if err != nil {
+				return err
+			}
+			newContent = append(append([]byte(nil), current.Content...), content...)
+		}
+
+		file, err := s.fm.UpdateFileWithType(path, newContent, mimeType, tx)
+		if err != nil {
+			return err
+		}
+		if err := s.recordOperation(tx, schema.OperationKindUpdate, []string{file.ID}); err != nil {
+			return err
+		}
+		out.Printf("Wrote %d byte(s) to %s\n", len(newContent), path)
+		return nil
+	})
+}
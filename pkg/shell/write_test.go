@@ -0,0 +1,83 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteAppend(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo one > /notes")
+	run(t, sh, "echo two >> /notes")
+
+	if got := readFile(t, sh, "/notes"); got != "one\ntwo\n" {
+		t.Errorf("content = %q, want %q", got, "one\ntwo\n")
+	}
+}
+
+// TestWriteStdin writes multi-line standard input to files with write and
+// with echo given no text
+func TestWriteStdin(t *testing.T) {
+	sh := newTestShell(t)
+
+	setInput(sh, "one\ntwo\n\nfour")
+	run(t, sh, "write /tmp/w")
+	if got := readFile(t, sh, "/tmp/w"); got != "one\ntwo\n\nfour" {
+		t.Errorf("write content = %q", got)
+	}
+
+	setInput(sh, "five\nsix\n")
+	run(t, sh, "write -a /tmp/w")
+	if got := readFile(t, sh, "/tmp/w"); got != "one\ntwo\n\nfourfive\nsix\n" {
+		t.Errorf("write -a content = %q", got)
+	}
+
+	setInput(sh, "one\n.\ntwo\n")
+	run(t, sh, "echo > /tmp/e")
+	if got := readFile(t, sh, "/tmp/e"); got != "one\n.\ntwo\n" {
+		t.Errorf("echo content = %q, want the sentinel kept when not interactive", got)
+	}
+
+	sh.state.IsInteractive = true
+	setInput(sh, "one\ntwo\n.\nleft over\n")
+	run(t, sh, "echo > /tmp/e")
+	if got := readFile(t, sh, "/tmp/e"); got != "one\ntwo\n" {
+		t.Errorf("interactive echo content = %q, want %q", got, "one\ntwo\n")
+	}
+}
+
+// TestWriteAppendRetried appends while the first attempts of the implicit
+// transaction fail part way through and are retried
+func TestWriteAppendRetried(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo one > /notes")
+
+	holdReadLock(t, "resources", 25*time.Millisecond)
+	output := run(t, sh, "echo two >> /notes")
+
+	if got := readFile(t, sh, "/notes"); got != "one\ntwo\n" {
+		t.Errorf("content = %q, want %q", got, "one\ntwo\n")
+	}
+	if n := strings.Count(output, "Wrote"); n != 1 {
+		t.Errorf("reported the write %d times:\n%s", n, output)
+	}
+}
+
+// TestWriteLookupError writes over a file whose metadata cannot be read,
+// which must fail rather than create a second current version
+func TestWriteLookupError(t *testing.T) {
+	sh := newTestShell(t)
+	run(t, sh, "echo one > /notes")
+	if _, err := sh.db.ExecuteStatement(`UPDATE resources SET metadata = 'broken' WHERE path = '/notes'`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runErr(sh, "echo two > /notes"); err == nil {
+		t.Fatal("write over unreadable metadata succeeded")
+	}
+	n := countRows(t, sh, `SELECT COUNT(*) FROM resources WHERE path = '/notes' AND valid_to IS NULL`)
+	if n != 1 {
+		t.Errorf("%d current versions of /notes, want 1", n)
+	}
+}